@@ -0,0 +1,277 @@
+package management
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+// harDocument is the top level of an HTTP Archive (HAR 1.2) file.
+type harDocument struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+}
+
+// harTimings attributes the whole duration to waiting; the proxy does not
+// record connect or transfer phases separately. -1 marks a phase as unknown.
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// GetDetailedRequestHAR returns a detailed request record as an HTTP Archive
+// that can be imported into browser devtools or Charles. The first entry is
+// the client request; each upstream attempt follows as its own entry.
+func (h *Handler) GetDetailedRequestHAR(c *gin.Context) {
+	if h == nil || h.cfg == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "handler unavailable"})
+		return
+	}
+	if h.detailedLogger == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "detailed logging not available"})
+		return
+	}
+
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing request ID"})
+		return
+	}
+
+	record, err := h.detailedLogger.ReadRecordByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read record: %v", err)})
+		return
+	}
+	if record == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.har\"", record.ID))
+	c.JSON(http.StatusOK, buildHAR(record, c.Request.Host))
+}
+
+// buildHAR converts a record and its upstream attempts into a HAR document.
+// Records store the client URL as a path, so it is resolved against the
+// recorded Host header, falling back to fallbackHost.
+func buildHAR(record *logging.DetailedRequestRecord, fallbackHost string) harDocument {
+	host := firstHeader(record.RequestHeaders, "Host")
+	if host == "" {
+		host = fallbackHost
+	}
+	client := harEntry{
+		StartedDateTime: harTime(record.Timestamp),
+		Time:            float64(record.TotalDurationMs),
+		Request:         newHARRequest(record.Method, absoluteURL(record.URL, host), record.RequestHeaders, record.RequestBody),
+		Response:        newHARResponse(record.StatusCode, record.ResponseHeaders, record.ResponseBody),
+		Timings:         newHARTimings(record.TotalDurationMs),
+		Comment:         "client request",
+	}
+	if record.IsStreaming && client.Response.Content.MimeType == "" {
+		client.Response.Content.MimeType = "text/event-stream"
+	}
+	if record.Error != "" {
+		client.Comment += ": " + record.Error
+	}
+
+	entries := []harEntry{client}
+	for _, attempt := range record.Attempts {
+		started := attempt.Timestamp
+		if started.IsZero() {
+			started = record.Timestamp
+		}
+		method := attempt.Method
+		if method == "" {
+			method = record.Method
+		}
+		entry := harEntry{
+			StartedDateTime: harTime(started),
+			Time:            float64(attempt.DurationMs),
+			Request:         newHARRequest(method, attempt.UpstreamURL, attempt.RequestHeaders, attempt.RequestBody),
+			Response:        newHARResponse(attempt.StatusCode, attempt.ResponseHeaders, attempt.ResponseBody),
+			Timings:         newHARTimings(attempt.DurationMs),
+			Comment:         fmt.Sprintf("upstream attempt %d", attempt.Index),
+		}
+		if attempt.Auth != "" {
+			entry.Comment += " (" + attempt.Auth + ")"
+		}
+		if attempt.Error != "" {
+			entry.Comment += ": " + attempt.Error
+		}
+		entries = append(entries, entry)
+	}
+
+	return harDocument{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "CLIProxyAPI", Version: buildinfo.Version},
+		Entries: entries,
+	}}
+}
+
+func newHARRequest(method, rawURL string, headers map[string][]string, body string) harRequest {
+	req := harRequest{
+		Method:      method,
+		URL:         rawURL,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []harNameValue{},
+		Headers:     harHeaders(headers),
+		QueryString: []harNameValue{},
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	if parsed, err := url.Parse(rawURL); err == nil {
+		for name, values := range parsed.Query() {
+			for _, value := range values {
+				req.QueryString = append(req.QueryString, harNameValue{Name: name, Value: value})
+			}
+		}
+		sort.SliceStable(req.QueryString, func(i, j int) bool { return req.QueryString[i].Name < req.QueryString[j].Name })
+	}
+	if body != "" {
+		mimeType := firstHeader(headers, "Content-Type")
+		if mimeType == "" {
+			mimeType = "application/json"
+		}
+		req.PostData = &harPostData{MimeType: mimeType, Text: body}
+	}
+	return req
+}
+
+// newHARResponse builds a HAR response; for streams the body is the captured
+// SSE text, which devtools shows as the response content.
+func newHARResponse(status int, headers map[string][]string, body string) harResponse {
+	return harResponse{
+		Status:      status,
+		StatusText:  http.StatusText(status),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []harNameValue{},
+		Headers:     harHeaders(headers),
+		Content: harContent{
+			Size:     len(body),
+			MimeType: firstHeader(headers, "Content-Type"),
+			Text:     body,
+		},
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+}
+
+func newHARTimings(durationMs int64) harTimings {
+	return harTimings{Blocked: -1, DNS: -1, Connect: -1, Send: 0, Wait: float64(durationMs), Receive: 0, SSL: -1}
+}
+
+// harHeaders flattens headers in name order, masking the same headers as the
+// cURL export.
+func harHeaders(headers map[string][]string) []harNameValue {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]harNameValue, 0, len(names))
+	for _, name := range names {
+		for _, value := range headers[name] {
+			if isSensitiveHeader(strings.ToLower(name)) {
+				value = "***"
+			}
+			out = append(out, harNameValue{Name: name, Value: value})
+		}
+	}
+	return out
+}
+
+func firstHeader(headers map[string][]string, name string) string {
+	for key, values := range headers {
+		if strings.EqualFold(key, name) && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+func absoluteURL(rawURL, host string) string {
+	if strings.HasPrefix(rawURL, "http://") || strings.HasPrefix(rawURL, "https://") || host == "" {
+		return rawURL
+	}
+	return "http://" + host + rawURL
+}
+
+func harTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Unix(0, 0)
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
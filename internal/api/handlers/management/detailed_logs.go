@@ -1,6 +1,7 @@
 package management
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	log "github.com/sirupsen/logrus"
 )
 
 // GetDetailedRequestLog returns the current detailed request logging status and stats.
@@ -25,10 +27,15 @@ func (h *Handler) GetDetailedRequestLog(c *gin.Context) {
 	}
 
 	result := gin.H{
-		"detailed-request-log":                enabled,
-		"detailed-request-log-max-size-mb":    maxSizeMB,
-		"detailed-request-log-show-retries":   h.cfg.DetailedRequestLogShowRetries,
-		"detailed-request-log-show-simulated": h.cfg.DetailedRequestLogShowSimulated,
+		"detailed-request-log":                       enabled,
+		"detailed-request-log-max-size-mb":           maxSizeMB,
+		"detailed-request-log-show-retries":          h.cfg.DetailedRequestLogShowRetries,
+		"detailed-request-log-show-simulated":        h.cfg.DetailedRequestLogShowSimulated,
+		"detailed-request-log-max-age-hours":         h.cfg.DetailedRequestLogMaxAgeHours,
+		"detailed-request-log-sample-rate":           h.cfg.EffectiveDetailedRequestLogSampleRate(),
+		"mask-authorization-in-logs":                 h.cfg.EffectiveMaskAuthorizationInLogs(),
+		"detailed-request-log-get-requests":          h.cfg.LogGetRequests,
+		"detailed-request-log-head-options-requests": h.cfg.LogHeadOptionsRequests,
 	}
 
 	// Include stats if logger is available
@@ -39,13 +46,19 @@ func (h *Handler) GetDetailedRequestLog(c *gin.Context) {
 			result["size_mb"] = fmt.Sprintf("%.2f", float64(sizeBytes)/1024/1024)
 			result["record_count"] = recordCount
 		}
+		if streaming, err := h.detailedLogger.GetStreamingStats(); err == nil {
+			result["streaming"] = streaming
+		}
 	}
 
 	c.JSON(http.StatusOK, result)
 }
 
 // PutDetailedRequestLog enables or disables detailed request logging, and/or updates show-retries UI preference.
-// Body may include "value" (bool) for detailed log enabled, "show_retries" (bool) for UI preference; at least one required.
+// Body may include "value" (bool) for detailed log enabled, "show_retries" (bool) for UI preference,
+// "max_age_hours" (int, 0 disables) for age-based retention, "sample_rate" (0.0–1.0) for
+// the fraction of successful requests kept, "log_get_requests" (bool) to also record GET
+// requests; at least one required.
 func (h *Handler) PutDetailedRequestLog(c *gin.Context) {
 	if h == nil || h.cfg == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "handler unavailable"})
@@ -53,16 +66,27 @@ func (h *Handler) PutDetailedRequestLog(c *gin.Context) {
 	}
 
 	var body struct {
-		Value         *bool `json:"value"`
-		ShowRetries   *bool `json:"show_retries"`
-		ShowSimulated *bool `json:"show_simulated"`
+		Value         *bool    `json:"value"`
+		ShowRetries   *bool    `json:"show_retries"`
+		ShowSimulated *bool    `json:"show_simulated"`
+		MaxAgeHours   *int     `json:"max_age_hours"`
+		SampleRate    *float64 `json:"sample_rate"`
+		LogGets       *bool    `json:"log_get_requests"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
 		return
 	}
-	if body.Value == nil && body.ShowRetries == nil && body.ShowSimulated == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body, expected {\"value\": true/false} and/or {\"show_retries\": true/false} and/or {\"show_simulated\": true/false}"})
+	if body.Value == nil && body.ShowRetries == nil && body.ShowSimulated == nil && body.MaxAgeHours == nil && body.SampleRate == nil && body.LogGets == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body, expected {\"value\": true/false} and/or {\"show_retries\": true/false} and/or {\"show_simulated\": true/false} and/or {\"max_age_hours\": n} and/or {\"sample_rate\": 0.0-1.0} and/or {\"log_get_requests\": true/false}"})
+		return
+	}
+	if body.MaxAgeHours != nil && *body.MaxAgeHours < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_age_hours must be >= 0"})
+		return
+	}
+	if body.SampleRate != nil && (*body.SampleRate < 0 || *body.SampleRate > 1) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sample_rate must be between 0 and 1"})
 		return
 	}
 
@@ -78,11 +102,34 @@ func (h *Handler) PutDetailedRequestLog(c *gin.Context) {
 	if body.ShowSimulated != nil {
 		h.cfg.DetailedRequestLogShowSimulated = *body.ShowSimulated
 	}
+	if body.MaxAgeHours != nil {
+		h.cfg.DetailedRequestLogMaxAgeHours = *body.MaxAgeHours
+		if h.detailedLogger != nil {
+			h.detailedLogger.SetMaxAge(time.Duration(*body.MaxAgeHours) * time.Hour)
+		}
+	}
+	if body.SampleRate != nil {
+		rate := *body.SampleRate
+		h.cfg.DetailedRequestLogSampleRate = &rate
+		if h.detailedLogger != nil {
+			h.detailedLogger.SetSampleRate(rate)
+		}
+	}
+	if body.LogGets != nil {
+		h.cfg.LogGetRequests = *body.LogGets
+		if h.detailedLogger != nil {
+			h.detailedLogger.SetLogGetRequests(*body.LogGets)
+		}
+	}
 
 	h.persist(c)
 }
 
 // ListDetailedRequests returns a paginated, filtered list of detailed request records.
+// With ?summary=true each row is reduced to the fields needed by the list table.
+// Cursor paging is preferred: pass the previous response's next_cursor as
+// ?before_id= (optionally with ?before_ts= unix seconds, used if that record has
+// since been removed). ?offset= still works but rescans earlier pages.
 func (h *Handler) ListDetailedRequests(c *gin.Context) {
 	if h == nil || h.cfg == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "handler unavailable"})
@@ -105,16 +152,12 @@ func (h *Handler) ListDetailedRequests(c *gin.Context) {
 		return
 	}
 
-	// Support filtering by api_key_hash (SHA hash) or api_key (masked key)
-	apiKeyFilter := strings.TrimSpace(c.Query("api_key_hash"))
-	if apiKeyFilter == "" {
-		apiKeyFilter = strings.TrimSpace(c.Query("api_key"))
-	}
-	filter := logging.RecordFilter{
-		APIKeyHash:       apiKeyFilter,
-		StatusCode:       strings.TrimSpace(c.Query("status_code")),
-		IncludeSimulated: c.Query("include_simulated") == "true",
+	filter, err := parseDetailedRecordFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
+	filter.Compact = c.Query("summary") == "true"
 
 	// Parse pagination
 	if limitStr := c.Query("limit"); limitStr != "" {
@@ -134,17 +177,14 @@ func (h *Handler) ListDetailedRequests(c *gin.Context) {
 			filter.Offset = n
 		}
 	}
-
-	// Parse time filters
-	if afterStr := c.Query("after"); afterStr != "" {
-		if ts, err := strconv.ParseInt(afterStr, 10, 64); err == nil && ts > 0 {
-			filter.After = time.Unix(ts, 0)
+	filter.BeforeID = strings.TrimSpace(c.Query("before_id"))
+	if tsStr := c.Query("before_ts"); tsStr != "" {
+		if ts, err := strconv.ParseInt(tsStr, 10, 64); err == nil && ts > 0 {
+			filter.BeforeTS = time.Unix(ts, 0)
 		}
 	}
-	if beforeStr := c.Query("before"); beforeStr != "" {
-		if ts, err := strconv.ParseInt(beforeStr, 10, 64); err == nil && ts > 0 {
-			filter.Before = time.Unix(ts, 0)
-		}
+	if filter.HasCursor() {
+		filter.Offset = 0
 	}
 
 	// Parse known_ids for incremental sync
@@ -157,7 +197,7 @@ func (h *Handler) ListDetailedRequests(c *gin.Context) {
 		}
 	}
 
-	results, total, err := h.detailedLogger.ReadRecordSummaries(filter, knownIDs)
+	results, total, skipped, err := h.detailedLogger.ReadRecordSummaries(filter, knownIDs)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read records: %v", err)})
 		return
@@ -173,15 +213,187 @@ func (h *Handler) ListDetailedRequests(c *gin.Context) {
 		}
 	}
 
+	// A full page may have more behind it; the last row is the next cursor.
+	nextCursor := ""
+	if len(results) > 0 && len(results) >= filter.Limit {
+		nextCursor = detailedResultID(results[len(results)-1])
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"records":  results,
-		"total":    total,
-		"offset":   filter.Offset,
-		"limit":    filter.Limit,
-		"api_keys": apiKeys,
+		"records":     results,
+		"total":       total,
+		"offset":      filter.Offset,
+		"limit":       filter.Limit,
+		"next_cursor": nextCursor,
+		"skipped":     skipped,
+		"api_keys":    apiKeys,
 	})
 }
 
+// detailedResultID returns the record ID of a ReadRecordSummaries row.
+func detailedResultID(row any) string {
+	switch r := row.(type) {
+	case logging.DetailedRequestCompact:
+		return r.ID
+	case logging.DetailedRequestSummary:
+		return r.ID
+	case map[string]any:
+		id, _ := r["id"].(string)
+		return id
+	}
+	return ""
+}
+
+// ExportDetailedRequests streams all records matching the list filters as
+// newline-delimited JSON, one full record per line, as a file download.
+func (h *Handler) ExportDetailedRequests(c *gin.Context) {
+	if h == nil || h.cfg == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "handler unavailable"})
+		return
+	}
+	if h.detailedLogger == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "detailed logging not available"})
+		return
+	}
+
+	filter, err := parseDetailedRecordFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := fmt.Sprintf("detailed-requests-%s.ndjson", time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", name))
+	c.Status(http.StatusOK)
+
+	if err := h.detailedLogger.StreamRecords(filter, c.Writer); err != nil {
+		// Headers are already sent; the truncated download is the only signal left.
+		log.Warnf("detailed request export aborted: %v", err)
+	}
+}
+
+// GetDetailedRequestStats returns per-model call volume, error counts and duration
+// percentiles, scoped by the same query filters as the list endpoint.
+func (h *Handler) GetDetailedRequestStats(c *gin.Context) {
+	if h == nil || h.cfg == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "handler unavailable"})
+		return
+	}
+	if h.detailedLogger == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "detailed logging not available"})
+		return
+	}
+
+	filter, err := parseDetailedRecordFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	stats, err := h.detailedLogger.GetDetailedStats(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to compute stats: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// parseDetailedRecordFilter builds a record filter from the shared list/export
+// query parameters: api_key_hash (or api_key), status_code, include_simulated,
+// has_tools, has_error, exclude_canceled, model (prefix, or * pattern),
+// min_duration_ms, url_pattern (regexp), tag, and the after/before unix
+// timestamps. It fails only when url_pattern is not a valid regular expression.
+func parseDetailedRecordFilter(c *gin.Context) (logging.RecordFilter, error) {
+	// Support filtering by api_key_hash (SHA hash) or api_key (masked key)
+	apiKeyFilter := strings.TrimSpace(c.Query("api_key_hash"))
+	if apiKeyFilter == "" {
+		apiKeyFilter = strings.TrimSpace(c.Query("api_key"))
+	}
+	filter := logging.RecordFilter{
+		APIKeyHash:       apiKeyFilter,
+		StatusCode:       strings.TrimSpace(c.Query("status_code")),
+		IncludeSimulated: c.Query("include_simulated") == "true",
+		HasToolCalls:     c.Query("has_tools") == "true",
+		HasError:         c.Query("has_error") == "true",
+		ExcludeCanceled:  c.Query("exclude_canceled") == "true",
+		ModelPrefix:      strings.TrimSpace(c.Query("model")),
+		URLPattern:       strings.TrimSpace(c.Query("url_pattern")),
+		Tag:              strings.TrimSpace(c.Query("tag")),
+		RouteID:          strings.TrimSpace(c.Query("route_id")),
+	}
+	if err := filter.Compile(); err != nil {
+		return filter, err
+	}
+
+	// Parse time filters
+	if afterStr := c.Query("after"); afterStr != "" {
+		if ts, err := strconv.ParseInt(afterStr, 10, 64); err == nil && ts > 0 {
+			filter.After = time.Unix(ts, 0)
+		}
+	}
+	if beforeStr := c.Query("before"); beforeStr != "" {
+		if ts, err := strconv.ParseInt(beforeStr, 10, 64); err == nil && ts > 0 {
+			filter.Before = time.Unix(ts, 0)
+		}
+	}
+	if durationStr := c.Query("min_duration_ms"); durationStr != "" {
+		if ms, err := strconv.ParseInt(durationStr, 10, 64); err == nil && ms > 0 {
+			filter.MinDurationMs = ms
+		}
+	}
+	return filter, nil
+}
+
+// detailedTailKeepAlive is how often TailDetailedRequests sends a comment line
+// so idle connections are not closed by proxies.
+const detailedTailKeepAlive = 15 * time.Second
+
+// TailDetailedRequests streams a compact summary of each detailed record as it
+// is logged, as server-sent "record" events, until the client disconnects.
+func (h *Handler) TailDetailedRequests(c *gin.Context) {
+	if h == nil || h.cfg == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "handler unavailable"})
+		return
+	}
+	if h.detailedLogger == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "detailed logging not available"})
+		return
+	}
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "streaming not supported"})
+		return
+	}
+
+	records := h.detailedLogger.Subscribe()
+	defer h.detailedLogger.Unsubscribe(records)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(detailedTailKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case record, ok := <-records:
+			if !ok {
+				return
+			}
+			c.SSEvent("record", record)
+			flusher.Flush()
+		case <-keepAlive.C:
+			_, _ = c.Writer.WriteString(": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
 // GetDetailedRequest returns a single detailed request record by ID.
 func (h *Handler) GetDetailedRequest(c *gin.Context) {
 	if h == nil || h.cfg == nil {
@@ -209,13 +421,107 @@ func (h *Handler) GetDetailedRequest(c *gin.Context) {
 		return
 	}
 
-	// Generate curl command
-	curlCmd := generateCurlCommand(record)
+	// Generate curl command; reveal_auth=true restores the masked client key from config
+	var replayKey string
+	if c.Query("reveal_auth") == "true" {
+		replayKey = h.replayAPIKey(record)
+	}
+	curlCmd := generateCurlCommand(record, replayKey)
 
-	c.JSON(http.StatusOK, gin.H{
+	result := gin.H{
 		"record": record,
 		"curl":   curlCmd,
-	})
+	}
+	// ?reconstruct=true adds the assistant text assembled from a streamed response.
+	if c.Query("reconstruct") == "true" && record.IsStreaming {
+		result["reconstructed_text"] = record.ReconstructedText()
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetDetailedRequestRaw downloads a record as it is stored, e.g. to attach to
+// a bug report. ?bodies=true returns the bodies companion file instead of the
+// meta file.
+func (h *Handler) GetDetailedRequestRaw(c *gin.Context) {
+	if h == nil || h.cfg == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "handler unavailable"})
+		return
+	}
+	if h.detailedLogger == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "detailed logging not available"})
+		return
+	}
+
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing request ID"})
+		return
+	}
+
+	name, data, err := h.detailedLogger.ReadRawRecord(id, c.Query("bodies") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read record: %v", err)})
+		return
+	}
+	if data == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", name))
+	c.Data(http.StatusOK, "application/json", data)
+}
+
+// UpdateDetailedRequestTags adds and removes tags on a completed record.
+// Body: {"add": ["incident-42"], "remove": ["todo"]}. Responds with the
+// record's resulting tags.
+func (h *Handler) UpdateDetailedRequestTags(c *gin.Context) {
+	if h == nil || h.cfg == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "handler unavailable"})
+		return
+	}
+	if h.detailedLogger == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "detailed logging not available"})
+		return
+	}
+
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing request ID"})
+		return
+	}
+	var body struct {
+		Add    []string `json:"add"`
+		Remove []string `json:"remove"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if len(body.Add) == 0 && len(body.Remove) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "add or remove is required"})
+		return
+	}
+
+	tags, err := h.detailedLogger.UpdateRecordTags(id, body.Add, body.Remove)
+	switch {
+	case errors.Is(err, logging.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, logging.ErrRecordPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, logging.ErrWriteQueueFull):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to update tags: %v", err)})
+		return
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "tags": tags})
 }
 
 // DeleteDetailedRequests removes all detailed request log records.
@@ -237,8 +543,23 @@ func (h *Handler) DeleteDetailedRequests(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "all detailed request records deleted"})
 }
 
+// replayAPIKey returns the configured client API key the record was made with,
+// matched by APIKeyHash, or "" when it is no longer configured.
+func (h *Handler) replayAPIKey(record *logging.DetailedRequestRecord) string {
+	if record == nil || record.APIKeyHash == "" {
+		return ""
+	}
+	for _, key := range h.cfg.APIKeys {
+		if logging.HashAPIKey(key) == record.APIKeyHash {
+			return key
+		}
+	}
+	return ""
+}
+
 // generateCurlCommand builds a curl command string from a request record.
-func generateCurlCommand(record *logging.DetailedRequestRecord) string {
+// When apiKey is set it replaces the (possibly masked) client credential headers.
+func generateCurlCommand(record *logging.DetailedRequestRecord, apiKey string) string {
 	if record == nil {
 		return ""
 	}
@@ -257,6 +578,8 @@ func generateCurlCommand(record *logging.DetailedRequestRecord) string {
 				// Mask sensitive headers
 				if isSensitiveHeader(lowerKey) {
 					value = "***"
+				} else if apiKey != "" && isClientCredentialHeader(lowerKey) {
+					value = withCredential(lowerKey, value, apiKey)
 				}
 				builder.WriteString(fmt.Sprintf(" \\\n  -H '%s: %s'", key, escapeShellSingle(value)))
 			}
@@ -271,7 +594,8 @@ func generateCurlCommand(record *logging.DetailedRequestRecord) string {
 }
 
 // isSensitiveHeader checks if a header name contains sensitive information.
-// Note: Authorization is NOT masked because the user needs it for cURL replay.
+// Note: Authorization is not masked here; it is either stored masked
+// (mask-authorization-in-logs) or restored via replayAPIKey for cURL replay.
 func isSensitiveHeader(name string) bool {
 	sensitiveHeaders := []string{"cookie", "x-management-key"}
 	for _, h := range sensitiveHeaders {
@@ -282,6 +606,26 @@ func isSensitiveHeader(name string) bool {
 	return false
 }
 
+// isClientCredentialHeader reports whether a header carries the client's API key.
+func isClientCredentialHeader(name string) bool {
+	switch name {
+	case "authorization", "x-api-key", "x-goog-api-key":
+		return true
+	}
+	return false
+}
+
+// withCredential replaces the credential in a header value, keeping the
+// Authorization scheme (e.g. "Bearer ").
+func withCredential(name, value, apiKey string) string {
+	if name == "authorization" {
+		if scheme, _, ok := strings.Cut(strings.TrimSpace(value), " "); ok {
+			return scheme + " " + apiKey
+		}
+	}
+	return apiKey
+}
+
 // escapeShellSingle escapes single quotes for use in shell single-quoted strings.
 func escapeShellSingle(s string) string {
 	return strings.ReplaceAll(s, "'", "'\\''")
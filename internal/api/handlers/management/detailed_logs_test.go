@@ -0,0 +1,105 @@
+package management
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+func TestGenerateCurlCommandRestoresClientKey(t *testing.T) {
+	const key = "sk-client-0123456789abcdef"
+	record := &logging.DetailedRequestRecord{
+		Method:     "POST",
+		URL:        "/v1/chat/completions",
+		APIKeyHash: logging.HashAPIKey(key),
+		RequestHeaders: map[string][]string{
+			"Authorization": {"Bearer sk-c...cdef"},
+			"Cookie":        {"session=abc"},
+		},
+	}
+
+	masked := generateCurlCommand(record, "")
+	if strings.Contains(masked, key) {
+		t.Fatalf("curl without replay key leaks the client key:\n%s", masked)
+	}
+	if !strings.Contains(masked, "Authorization: Bearer sk-c...cdef") {
+		t.Fatalf("curl without replay key should keep the stored masked value:\n%s", masked)
+	}
+
+	h := &Handler{cfg: &config.Config{SDKConfig: config.SDKConfig{APIKeys: []string{"other", key}}}}
+	replayKey := h.replayAPIKey(record)
+	if replayKey != key {
+		t.Fatalf("replayAPIKey = %q, want the configured key", replayKey)
+	}
+	restored := generateCurlCommand(record, replayKey)
+	if !strings.Contains(restored, "Authorization: Bearer "+key) {
+		t.Fatalf("curl with replay key should restore Authorization:\n%s", restored)
+	}
+	if !strings.Contains(restored, "Cookie: ***") {
+		t.Fatalf("curl should still mask cookies:\n%s", restored)
+	}
+
+	h.cfg.APIKeys = []string{"other"}
+	if got := h.replayAPIKey(record); got != "" {
+		t.Fatalf("replayAPIKey = %q for a removed key, want empty", got)
+	}
+}
+
+func TestBuildHARIncludesClientRequestAndAttempts(t *testing.T) {
+	started := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	record := &logging.DetailedRequestRecord{
+		ID:              "req-1",
+		Timestamp:       started,
+		Method:          "POST",
+		URL:             "/v1/chat/completions?beta=true",
+		StatusCode:      200,
+		RequestHeaders:  map[string][]string{"Host": {"proxy.local:8317"}, "Cookie": {"session=abc"}},
+		RequestBody:     `{"stream":true}`,
+		ResponseBody:    "data: {\"a\":1}\n\ndata: [DONE]\n\n",
+		IsStreaming:     true,
+		TotalDurationMs: 1500,
+		Attempts: []logging.DetailedAttempt{{
+			Index:       1,
+			UpstreamURL: "https://upstream.example/v1/chat/completions",
+			StatusCode:  502,
+			Error:       "bad gateway",
+			DurationMs:  300,
+		}},
+	}
+
+	doc := buildHAR(record, "fallback:1")
+	if doc.Log.Version != "1.2" || len(doc.Log.Entries) != 2 {
+		t.Fatalf("har log = %+v", doc.Log)
+	}
+	client := doc.Log.Entries[0]
+	if client.Request.URL != "http://proxy.local:8317/v1/chat/completions?beta=true" {
+		t.Fatalf("client url = %q", client.Request.URL)
+	}
+	if len(client.Request.QueryString) != 1 || client.Request.QueryString[0].Value != "true" {
+		t.Fatalf("query string = %+v", client.Request.QueryString)
+	}
+	if client.Request.PostData == nil || client.Request.PostData.Text != record.RequestBody {
+		t.Fatalf("post data = %+v", client.Request.PostData)
+	}
+	if client.Response.Content.Text != record.ResponseBody || client.Response.Content.MimeType != "text/event-stream" {
+		t.Fatalf("response content = %+v", client.Response.Content)
+	}
+	if client.Time != 1500 || client.Timings.Wait != 1500 || client.StartedDateTime != "2026-03-01T12:00:00Z" {
+		t.Fatalf("client timing = %v %+v %s", client.Time, client.Timings, client.StartedDateTime)
+	}
+	for _, header := range client.Request.Headers {
+		if header.Name == "Cookie" && header.Value != "***" {
+			t.Fatalf("cookie header not masked: %q", header.Value)
+		}
+	}
+	attempt := doc.Log.Entries[1]
+	if attempt.Request.Method != "POST" || attempt.Response.Status != 502 || attempt.Time != 300 {
+		t.Fatalf("attempt entry = %+v", attempt)
+	}
+	if !strings.Contains(attempt.Comment, "bad gateway") {
+		t.Fatalf("attempt comment = %q", attempt.Comment)
+	}
+}
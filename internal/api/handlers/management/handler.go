@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	unifiedrouting "github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules/unified-routing"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
@@ -49,6 +50,7 @@ type Handler struct {
 	envSecret           string
 	logDir              string
 	detailedLogger      *logging.DetailedRequestLogger
+	routingState        unifiedrouting.StateManager
 }
 
 // NewHandler creates a new management handler instance.
@@ -122,6 +124,11 @@ func (h *Handler) SetDetailedLogger(logger *logging.DetailedRequestLogger) {
 	h.detailedLogger = logger
 }
 
+// SetRoutingStateManager sets the unified routing state manager used by the health endpoint.
+func (h *Handler) SetRoutingStateManager(sm unifiedrouting.StateManager) {
+	h.routingState = sm
+}
+
 // SetLogDirectory updates the directory where main.log should be looked up.
 func (h *Handler) SetLogDirectory(dir string) {
 	if dir == "" {
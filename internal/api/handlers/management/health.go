@@ -0,0 +1,80 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// logQueueBackedUpRatio is the write-channel fill level at which the detailed
+// logger is reported as backed up.
+const logQueueBackedUpRatio = 0.8
+
+// GetHealth returns a combined liveness/readiness summary of unified routing and
+// detailed logging. It responds 503 when routing is enabled and every route is
+// unhealthy, and 200 otherwise ("degraded" is reported in the body only).
+func (h *Handler) GetHealth(c *gin.Context) {
+	if h == nil || h.cfg == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "handler unavailable"})
+		return
+	}
+
+	status := "ok"
+	result := gin.H{}
+
+	if h.routingState != nil {
+		overview, err := h.routingState.GetOverview(c.Request.Context())
+		if err != nil {
+			result["routing"] = gin.H{"error": err.Error()}
+			status = "degraded"
+		} else {
+			result["routing"] = gin.H{
+				"enabled":          overview.UnifiedRoutingEnabled,
+				"total_routes":     overview.TotalRoutes,
+				"healthy_routes":   overview.HealthyRoutes,
+				"degraded_routes":  overview.DegradedRoutes,
+				"unhealthy_routes": overview.UnhealthyRoutes,
+			}
+			if overview.UnifiedRoutingEnabled && overview.TotalRoutes > 0 {
+				switch {
+				case overview.UnhealthyRoutes == overview.TotalRoutes:
+					status = "unhealthy"
+				case overview.UnhealthyRoutes > 0 || overview.DegradedRoutes > 0:
+					status = "degraded"
+				}
+			}
+		}
+	}
+
+	maxSizeMB := h.cfg.DetailedRequestLogMaxSizeMB
+	if maxSizeMB <= 0 {
+		maxSizeMB = 100
+	}
+	logStatus := gin.H{
+		"enabled":     h.cfg.DetailedRequestLog,
+		"max_size_mb": maxSizeMB,
+	}
+	if h.detailedLogger != nil {
+		if sizeBytes, recordCount, err := h.detailedLogger.GetStats(); err == nil {
+			logStatus["size_bytes"] = sizeBytes
+			logStatus["record_count"] = recordCount
+			logStatus["usage_percent"] = float64(sizeBytes) * 100 / float64(int64(maxSizeMB)*1024*1024)
+		}
+		depth, capacity := h.detailedLogger.QueueDepth()
+		backedUp := capacity > 0 && float64(depth) >= float64(capacity)*logQueueBackedUpRatio
+		logStatus["queue_depth"] = depth
+		logStatus["queue_capacity"] = capacity
+		logStatus["backed_up"] = backedUp
+		if backedUp && status == "ok" {
+			status = "degraded"
+		}
+	}
+	result["detailed_log"] = logStatus
+	result["status"] = status
+
+	code := http.StatusOK
+	if status == "unhealthy" {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, result)
+}
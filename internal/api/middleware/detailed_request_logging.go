@@ -1,12 +1,15 @@
 // Package middleware provides HTTP middleware components for the CLI Proxy API server.
 // This file implements the detailed request logging middleware: it records each request's
 // client request, final response, and the retry part (upstream attempts). The retry part
-// is filled from Gin context (API_REQUEST/API_RESPONSE), which the executor writes when
-// DetailedRequestLog is enabled—no dependency on RequestLog.
+// is read from Gin context (logging.DetailedAttemptsKey) as structured attempts, which the
+// executor writes when DetailedRequestLog is enabled—no dependency on RequestLog.
 package middleware
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"io"
 	"net/http"
 	"strings"
 	"time"
@@ -14,6 +17,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/compat"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 )
 
@@ -27,13 +31,12 @@ func DetailedRequestLoggingMiddleware(logger *logging.DetailedRequestLogger) gin
 			return
 		}
 
-		if c.Request.Method == http.MethodGet {
+		path := c.Request.URL.Path
+		if !shouldLogDetailedMethod(c.Request.Method, path, logger.LogGetRequests(), logger.LogHeadOptionsRequests()) {
 			c.Next()
 			return
 		}
-
-		path := c.Request.URL.Path
-		if !shouldLogDetailedRequest(path) {
+		if !shouldLogDetailedRequest(path, logger.IncludeManagement(), logger.PathFilter()) {
 			c.Next()
 			return
 		}
@@ -42,10 +45,11 @@ func DetailedRequestLoggingMiddleware(logger *logging.DetailedRequestLogger) gin
 
 		// Capture request body (it was already read and restored by RequestLoggingMiddleware)
 		var requestBody []byte
+		var bodyTruncated bool
 		if c.Request.Body != nil {
-			bodyBytes, err := readAndRestoreBody(c)
+			bodyBytes, truncated, err := readAndRestoreBody(c, logger.MaxBodyBytes())
 			if err == nil {
-				requestBody = bodyBytes
+				requestBody, bodyTruncated = bodyBytes, truncated
 			}
 		}
 
@@ -73,6 +77,11 @@ func DetailedRequestLoggingMiddleware(logger *logging.DetailedRequestLogger) gin
 			copy(headerValues, values)
 			requestHeaders[key] = headerValues
 		}
+		if isManagementPath(path) {
+			maskManagementCredentials(requestHeaders)
+		} else if logger.MaskAuthorization() {
+			maskClientCredentials(requestHeaders)
+		}
 
 		// Create a response capture wrapper if not already wrapped
 		detailedCapture := &detailedResponseCapture{
@@ -112,14 +121,11 @@ func DetailedRequestLoggingMiddleware(logger *logging.DetailedRequestLogger) gin
 				record.Model = model
 			}
 			record.RequestBody = string(requestBody)
+			record.BodyTruncated = bodyTruncated
 		}
 
 		record.RequestHeaders = requestHeaders
 
-		// Detect streaming
-		contentType := detailedCapture.Header().Get("Content-Type")
-		record.IsStreaming = strings.Contains(contentType, "text/event-stream")
-
 		// Capture response status code.
 		// detailedCapture.statusCode is only set when WriteHeader() is called on our wrapper.
 		// Fall back to the underlying Gin ResponseWriter's Status() to handle cases where
@@ -133,6 +139,15 @@ func DetailedRequestLoggingMiddleware(logger *logging.DetailedRequestLogger) gin
 		}
 		record.StatusCode = finalStatus
 
+		// Detect streaming. HEAD, OPTIONS, 204 and 304 responses have no body,
+		// whatever Content-Type the handler left set.
+		contentType := detailedCapture.Header().Get("Content-Type")
+		record.IsStreaming = responseHasBody(c.Request.Method, finalStatus) && strings.Contains(contentType, "text/event-stream")
+		if record.IsStreaming {
+			record.StreamedBytes = detailedCapture.totalBytes
+			record.StreamChunks = detailedCapture.chunks
+		}
+
 		responseHeaders := make(map[string][]string)
 		for key, values := range detailedCapture.Header() {
 			headerValues := make([]string, len(values))
@@ -155,6 +170,18 @@ func DetailedRequestLoggingMiddleware(logger *logging.DetailedRequestLogger) gin
 			}
 		}
 
+		if raw, exists := c.Get(logging.RoutingAttemptsKey); exists {
+			if attempts, ok := raw.(logging.RoutingAttempts); ok {
+				record.RoutingAttempts = &attempts
+			}
+		}
+		if raw, exists := c.Get(logging.RoutingTargetKey); exists {
+			if target, ok := raw.(logging.RoutingTarget); ok {
+				record.RouteID, record.RouteName = target.RouteID, target.RouteName
+				record.TargetID, record.CredentialID, record.RouteLayer = target.TargetID, target.CredentialID, target.Layer
+			}
+		}
+
 		// Extract errors
 		apiResponseError, isExist := c.Get("API_RESPONSE_ERROR")
 		if isExist {
@@ -163,24 +190,47 @@ func DetailedRequestLoggingMiddleware(logger *logging.DetailedRequestLogger) gin
 			}
 		}
 
+		// Executors record the class of every upstream error, including ones a
+		// retry recovered from, so it only describes the record when it failed.
+		if raw, exists := c.Get(logging.ErrorClassKey); exists && (record.Error != "" || record.StatusCode >= http.StatusBadRequest) {
+			if class, ok := raw.(string); ok {
+				record.ErrorClass = class
+			}
+		}
+		// A client that disconnects cancels the request context; executors may
+		// also report the cancellation before the handler notices.
+		if errors.Is(c.Request.Context().Err(), context.Canceled) || record.ErrorClass == logging.ErrorClassClientCanceled {
+			record.ClientCanceled = true
+			record.ErrorClass = logging.ErrorClassClientCanceled
+		}
+
+		logger.SanitizeBodies(record, bodyTruncated, detailedCapture.totalBytes > int64(detailedCapture.body.Len()))
+
 		// Calculate duration
 		record.TotalDurationMs = time.Since(startTime).Milliseconds()
 
+		annotateRecord(record, requestBody, detailedCapture.body.Bytes())
+
 		logger.LogRecord(record)
 	}
 }
 
 // detailedResponseCapture wraps gin.ResponseWriter to capture the response body.
+// totalBytes and chunks count everything written, even past the capture cap.
 type detailedResponseCapture struct {
 	gin.ResponseWriter
 	body       *bytes.Buffer
 	statusCode int
+	totalBytes int64
+	chunks     int
 }
 
 const detailedCaptureMaxBytes = 10 * 1024 * 1024 // 10 MB
 
 func (w *detailedResponseCapture) Write(data []byte) (int, error) {
 	n, err := w.ResponseWriter.Write(data)
+	w.totalBytes += int64(n)
+	w.chunks++
 	if w.body.Len() < detailedCaptureMaxBytes {
 		remaining := detailedCaptureMaxBytes - w.body.Len()
 		if len(data) > remaining {
@@ -194,6 +244,8 @@ func (w *detailedResponseCapture) Write(data []byte) (int, error) {
 
 func (w *detailedResponseCapture) WriteString(data string) (int, error) {
 	n, err := w.ResponseWriter.WriteString(data)
+	w.totalBytes += int64(n)
+	w.chunks++
 	if w.body.Len() < detailedCaptureMaxBytes {
 		remaining := detailedCaptureMaxBytes - w.body.Len()
 		if len(data) > remaining {
@@ -210,277 +262,235 @@ func (w *detailedResponseCapture) WriteHeader(statusCode int) {
 	w.ResponseWriter.WriteHeader(statusCode)
 }
 
-// shouldLogDetailedRequest determines whether this request should be captured for detailed logging.
-func shouldLogDetailedRequest(path string) bool {
-	if strings.HasPrefix(path, "/v0/management") || strings.HasPrefix(path, "/management") {
-		return false
-	}
-	if strings.HasPrefix(path, "/api") {
-		return strings.HasPrefix(path, "/api/provider")
+// shouldLogDetailedMethod reports whether requests with this method are captured.
+// GETs are skipped unless logGets is set, and management GETs are always skipped:
+// they are the log viewer's own polling and the long-lived detailed-log tail stream.
+// HEAD and OPTIONS (CORS preflight) requests likewise need logHeadOptions.
+func shouldLogDetailedMethod(method, path string, logGets, logHeadOptions bool) bool {
+	switch method {
+	case http.MethodGet:
+		return logGets && !isManagementPath(path)
+	case http.MethodHead, http.MethodOptions:
+		return logHeadOptions && !isManagementPath(path)
+	default:
+		return true
 	}
-	return true
 }
 
-// readAndRestoreBody reads the request body and restores it for subsequent handlers.
-func readAndRestoreBody(c *gin.Context) ([]byte, error) {
-	if c.Request.Body == nil {
-		return nil, nil
-	}
-	buf := new(bytes.Buffer)
-	if _, err := buf.ReadFrom(c.Request.Body); err != nil {
-		return nil, err
+// responseHasBody reports whether a response to method with this status can
+// carry a body.
+func responseHasBody(method string, status int) bool {
+	if method == http.MethodHead || method == http.MethodOptions {
+		return false
 	}
-	bodyBytes := buf.Bytes()
-	c.Request.Body = nopCloser{bytes.NewReader(bodyBytes)}
-	return bodyBytes, nil
+	return status != http.StatusNoContent && status != http.StatusNotModified
 }
 
-type nopCloser struct {
-	*bytes.Reader
-}
-
-func (nopCloser) Close() error { return nil }
-
-// 详细日志专用 Gin 键，与请求日志（API_REQUEST/API_RESPONSE）完全解耦
-const (
-	detailedLogAPIRequestKey  = "DETAILED_LOG_API_REQUEST"
-	detailedLogAPIResponseKey = "DETAILED_LOG_API_RESPONSE"
-)
-
-// extractAttempts 记录重试部分：从 Gin 读取详细日志专用键（仅当开启详细日志时由 executor 写入）
-// 与请求日志的 API_REQUEST/API_RESPONSE 无关。
-func extractAttempts(c *gin.Context) []logging.DetailedAttempt {
-	apiRequestRaw, hasReq := c.Get(detailedLogAPIRequestKey)
-	apiResponseRaw, hasResp := c.Get(detailedLogAPIResponseKey)
-
-	if !hasReq && !hasResp {
-		return nil
-	}
-
-	var attempts []DetailedAttemptFromContext
-
-	if hasReq {
-		if reqData, ok := apiRequestRaw.([]byte); ok && len(reqData) > 0 {
-			attempts = parseAttemptRequests(string(reqData))
-		}
-	}
-
-	if hasResp {
-		if respData, ok := apiResponseRaw.([]byte); ok && len(respData) > 0 {
-			mergeAttemptResponses(attempts, string(respData))
-		}
-	}
-
-	// Convert to logging.DetailedAttempt
-	result := make([]logging.DetailedAttempt, 0, len(attempts))
-	for _, a := range attempts {
-		result = append(result, logging.DetailedAttempt{
-			Index:           a.Index,
-			UpstreamURL:     a.UpstreamURL,
-			Method:          a.Method,
-			Auth:            a.Auth,
-			RequestHeaders:  a.RequestHeaders,
-			RequestBody:     a.RequestBody,
-			StatusCode:      a.StatusCode,
-			ResponseHeaders: a.ResponseHeaders,
-			ResponseBody:    a.ResponseBody,
-			Error:           a.Error,
-		})
+// shouldLogDetailedRequest determines whether this request should be captured for detailed logging.
+// Management paths are only captured when includeManagement is set; other paths go through the
+// configured include/exclude globs (nil filter: only /api/provider* under /api).
+func shouldLogDetailedRequest(path string, includeManagement bool, filter *logging.DetailedPathFilter) bool {
+	if isManagementPath(path) {
+		return includeManagement
 	}
-
-	return result
+	return filter.Match(path)
 }
 
-// DetailedAttemptFromContext is a temporary struct for parsing attempt data from context.
-type DetailedAttemptFromContext struct {
-	Index           int
-	UpstreamURL     string
-	Method          string
-	Auth            string
-	RequestHeaders  map[string][]string
-	RequestBody     string
-	StatusCode      int
-	ResponseHeaders map[string][]string
-	ResponseBody    string
-	Error           string
+// annotateRecord precomputes the cheap filter attributes of a finished record so
+// queries never have to re-parse stored bodies.
+func annotateRecord(record *logging.DetailedRequestRecord, requestBody, responseBody []byte) {
+	record.HasToolCalls = detectToolCalls(requestBody, responseBody)
+	record.HasError = record.Error != "" || record.StatusCode >= http.StatusBadRequest
+	record.PromptTokens, record.CompletionTokens, record.TotalTokens = extractTokenUsage(responseBody, record.IsStreaming)
 }
 
-// parseAttemptRequests parses the aggregated API_REQUEST data into attempt records.
-func parseAttemptRequests(data string) []DetailedAttemptFromContext {
-	sections := strings.Split(data, "=== API REQUEST ")
-	var attempts []DetailedAttemptFromContext
-
-	for _, section := range sections {
-		section = strings.TrimSpace(section)
-		if section == "" {
-			continue
-		}
-
-		attempt := DetailedAttemptFromContext{}
-
-		// Extract index
-		if idx := strings.Index(section, " ==="); idx > 0 {
-			numStr := section[:idx]
-			var n int
-			if _, err := parseIntSafe(numStr); err == nil {
-				n = parseIntValue(numStr)
+// extractTokenUsage parses token counts from a response body. Non-streaming bodies
+// are read directly; for SSE streams every data event is scanned and the last
+// reported prompt/completion counts win, since providers send usage in the final
+// chunk (or, for Claude, split across message_start and message_delta).
+func extractTokenUsage(body []byte, streaming bool) (prompt, completion, total int) {
+	if len(body) == 0 {
+		return 0, 0, 0
+	}
+	if !streaming {
+		prompt, completion, total = usageFromJSON(gjson.ParseBytes(body))
+	} else {
+		for _, line := range bytes.Split(body, []byte("\n")) {
+			line = bytes.TrimSpace(line)
+			if !bytes.HasPrefix(line, []byte("data:")) {
+				continue
 			}
-			attempt.Index = n
-			section = section[idx+4:]
-		}
-
-		lines := strings.Split(section, "\n")
-		for _, line := range lines {
-			line = strings.TrimSpace(line)
-			if strings.HasPrefix(line, "Upstream URL: ") {
-				attempt.UpstreamURL = strings.TrimPrefix(line, "Upstream URL: ")
-			} else if strings.HasPrefix(line, "HTTP Method: ") {
-				attempt.Method = strings.TrimPrefix(line, "HTTP Method: ")
-			} else if strings.HasPrefix(line, "Auth: ") {
-				attempt.Auth = strings.TrimPrefix(line, "Auth: ")
+			payload := bytes.TrimSpace(line[len("data:"):])
+			if !gjson.ValidBytes(payload) {
+				continue
 			}
-		}
-
-		// Extract headers section (between "Headers:\n" and "\nBody:\n")
-		if headersIdx := strings.Index(section, "Headers:\n"); headersIdx >= 0 {
-			headersEnd := strings.Index(section[headersIdx+9:], "\nBody:\n")
-			if headersEnd >= 0 {
-				attempt.RequestHeaders = parseHeaderBlock(section[headersIdx+9 : headersIdx+9+headersEnd])
-			} else {
-				// No Body:\n; take until end of section
-				attempt.RequestHeaders = parseHeaderBlock(section[headersIdx+9:])
+			p, c, t := usageFromJSON(gjson.ParseBytes(payload))
+			if p > 0 {
+				prompt = p
+			}
+			if c > 0 {
+				completion = c
+			}
+			if t > 0 {
+				total = t
 			}
 		}
-
-		// Extract body section
-		if bodyIdx := strings.Index(section, "Body:\n"); bodyIdx >= 0 {
-			body := section[bodyIdx+6:]
-			// Trim trailing empty lines
-			body = strings.TrimRight(body, "\n ")
-			attempt.RequestBody = body
-		}
-
-		attempts = append(attempts, attempt)
 	}
-
-	return attempts
+	if total == 0 {
+		total = prompt + completion
+	}
+	return prompt, completion, total
 }
 
-// parseHeaderBlock parses a "Headers:\n" block (key: value lines, one per line; <none> means empty).
-func parseHeaderBlock(block string) map[string][]string {
-	block = strings.TrimSpace(block)
-	if block == "" || block == "<none>" {
-		return nil
+// usageFromJSON reads token counts from a single JSON document in any of the
+// supported formats: OpenAI chat (usage.prompt_tokens), OpenAI responses and
+// Claude (usage.input_tokens), and Gemini (usageMetadata.promptTokenCount).
+func usageFromJSON(doc gjson.Result) (prompt, completion, total int) {
+	usage := doc.Get("usage")
+	if !usage.Exists() {
+		usage = doc.Get("response.usage") // OpenAI responses stream: response.completed
 	}
-	out := make(map[string][]string)
-	lines := strings.Split(block, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		idx := strings.Index(line, ": ")
-		if idx <= 0 {
-			continue
-		}
-		key := line[:idx]
-		value := line[idx+2:]
-		out[key] = append(out[key], value)
+	if !usage.Exists() {
+		usage = doc.Get("message.usage") // Claude stream: message_start
 	}
-	if len(out) == 0 {
-		return nil
+	if usage.Exists() {
+		prompt = int(firstInt(usage, "prompt_tokens", "input_tokens"))
+		completion = int(firstInt(usage, "completion_tokens", "output_tokens"))
+		total = int(usage.Get("total_tokens").Int())
+		return prompt, completion, total
+	}
+	if meta := doc.Get("usageMetadata"); meta.Exists() {
+		prompt = int(meta.Get("promptTokenCount").Int())
+		completion = int(meta.Get("candidatesTokenCount").Int())
+		total = int(meta.Get("totalTokenCount").Int())
 	}
-	return out
+	return prompt, completion, total
 }
 
-// mergeAttemptResponses merges response data into existing attempt records.
-func mergeAttemptResponses(attempts []DetailedAttemptFromContext, data string) {
-	sections := strings.Split(data, "=== API RESPONSE ")
-
-	for _, section := range sections {
-		section = strings.TrimSpace(section)
-		if section == "" {
-			continue
+// firstInt returns the first non-zero integer among the given keys of obj.
+func firstInt(obj gjson.Result, keys ...string) int64 {
+	for _, key := range keys {
+		if v := obj.Get(key).Int(); v != 0 {
+			return v
 		}
+	}
+	return 0
+}
 
-		var index int
-		if idx := strings.Index(section, " ==="); idx > 0 {
-			numStr := section[:idx]
-			if _, err := parseIntSafe(numStr); err == nil {
-				index = parseIntValue(numStr)
-			}
-			section = section[idx+4:]
+// detectToolCalls reports whether the request declared tools/functions or the
+// response carried tool calls. JSON responses are inspected per provider format;
+// streaming responses are matched by marker substring since SSE framing is not a
+// single JSON document.
+func detectToolCalls(requestBody, responseBody []byte) bool {
+	for _, path := range []string{"tools", "functions"} {
+		if v := gjson.GetBytes(requestBody, path); v.IsArray() && len(v.Array()) > 0 {
+			return true
 		}
-
-		// Find matching attempt
-		var target *DetailedAttemptFromContext
-		for i := range attempts {
-			if attempts[i].Index == index {
-				target = &attempts[i]
-				break
+	}
+	if len(responseBody) == 0 {
+		return false
+	}
+	if gjson.ValidBytes(responseBody) {
+		for _, path := range []string{
+			"choices.#.message.tool_calls|@flatten",              // OpenAI chat completions
+			`content.#(type=="tool_use")#`,                       // Claude messages
+			"candidates.#.content.parts.#.functionCall|@flatten", // Gemini
+			`output.#(type=="function_call")#`,                   // OpenAI responses
+		} {
+			if v := gjson.GetBytes(responseBody, path); v.IsArray() && len(v.Array()) > 0 {
+				return true
 			}
 		}
-		if target == nil {
-			continue
+		return false
+	}
+	for _, marker := range []string{`"tool_calls":[{`, `"type":"tool_use"`, `"functionCall"`, `"type":"function_call"`} {
+		if bytes.Contains(responseBody, []byte(marker)) {
+			return true
 		}
+	}
+	return false
+}
 
-		lines := strings.Split(section, "\n")
-		for _, line := range lines {
-			line = strings.TrimSpace(line)
-			if strings.HasPrefix(line, "Status: ") {
-				statusStr := strings.TrimPrefix(line, "Status: ")
-				if n := parseIntValue(statusStr); n > 0 {
-					target.StatusCode = n
-				}
-			} else if strings.HasPrefix(line, "Error: ") {
-				target.Error = strings.TrimPrefix(line, "Error: ")
-			}
-		}
+// isManagementPath reports whether the path belongs to the management API.
+func isManagementPath(path string) bool {
+	return strings.HasPrefix(path, "/v0/management") || strings.HasPrefix(path, "/management")
+}
 
-		// Extract response headers (between "Headers:\n" and "\nBody:\n" or "\nError: " or end)
-		if headersIdx := strings.Index(section, "Headers:\n"); headersIdx >= 0 {
-			afterHeaders := section[headersIdx+9:]
-			headersEnd := strings.Index(afterHeaders, "\nBody:\n")
-			if headersEnd < 0 {
-				headersEnd = strings.Index(afterHeaders, "\nError: ")
-			}
-			if headersEnd >= 0 {
-				target.ResponseHeaders = parseHeaderBlock(afterHeaders[:headersEnd])
-			} else {
-				target.ResponseHeaders = parseHeaderBlock(afterHeaders)
+// maskManagementCredentials replaces every header value that may carry the management
+// key with a fixed placeholder. Unlike MaskAPIKey, no prefix or suffix is kept.
+func maskManagementCredentials(headers map[string][]string) {
+	for key, values := range headers {
+		switch strings.ToLower(key) {
+		case "authorization", "x-management-key", "cookie":
+			for i := range values {
+				values[i] = "***"
 			}
 		}
+	}
+}
 
-		// Extract body
-		if bodyIdx := strings.Index(section, "Body:\n"); bodyIdx >= 0 {
-			body := section[bodyIdx+6:]
-			body = strings.TrimRight(body, "\n ")
-			target.ResponseBody = body
+// maskClientCredentials masks the client's API key wherever it may appear in the
+// request headers, keeping the scheme (e.g. "Bearer ") and a short prefix and suffix
+// so records stay distinguishable without storing the token.
+func maskClientCredentials(headers map[string][]string) {
+	for key, values := range headers {
+		for i, value := range values {
+			values[i] = util.MaskSensitiveHeaderValue(key, value)
 		}
 	}
 }
 
-func parseIntSafe(s string) (int, error) {
-	s = strings.TrimSpace(s)
-	n := 0
-	for _, c := range s {
-		if c < '0' || c > '9' {
-			return 0, nil
-		}
-		n = n*10 + int(c-'0')
+// readAndRestoreBody reads up to limit bytes of the request body and restores
+// the body for subsequent handlers. A longer body is reported as truncated; it
+// is not buffered in full, the rest is streamed from the original body instead.
+func readAndRestoreBody(c *gin.Context, limit int64) ([]byte, bool, error) {
+	body := c.Request.Body
+	if body == nil {
+		return nil, false, nil
+	}
+	buf := new(bytes.Buffer)
+	// One byte past the limit tells a body of exactly limit bytes from a longer one.
+	if _, err := buf.ReadFrom(io.LimitReader(body, limit+1)); err != nil {
+		return nil, false, err
+	}
+	bodyBytes := buf.Bytes()
+	if int64(len(bodyBytes)) <= limit {
+		c.Request.Body = nopCloser{bytes.NewReader(bodyBytes)}
+		return bodyBytes, false, nil
 	}
-	return n, nil
+	c.Request.Body = prefixedBody{Reader: io.MultiReader(bytes.NewReader(bodyBytes), body), Closer: body}
+	return bodyBytes[:limit], true, nil
+}
+
+type nopCloser struct {
+	*bytes.Reader
 }
 
-func parseIntValue(s string) int {
-	s = strings.TrimSpace(s)
-	n := 0
-	for _, c := range s {
-		if c < '0' || c > '9' {
-			break
+func (nopCloser) Close() error { return nil }
+
+// prefixedBody replays the bytes already read from a request body before the
+// unread remainder, and closes the original body.
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// extractAttempts 记录重试部分：读取 executor 在开启详细日志时写入的结构化尝试记录
+// （logging.DetailedAttemptsKey），与请求日志的 API_REQUEST/API_RESPONSE 文本无关。
+func extractAttempts(c *gin.Context) []logging.DetailedAttempt {
+	raw, exists := c.Get(logging.DetailedAttemptsKey)
+	if !exists {
+		return nil
+	}
+	attempts, ok := raw.([]*logging.DetailedAttempt)
+	if !ok || len(attempts) == 0 {
+		return nil
+	}
+	result := make([]logging.DetailedAttempt, 0, len(attempts))
+	for _, a := range attempts {
+		if a != nil {
+			result = append(result, *a)
 		}
-		n = n*10 + int(c-'0')
 	}
-	return n
+	return result
 }
-
@@ -0,0 +1,191 @@
+package unifiedrouting
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TargetAlertType identifies the state transition a TargetAlert reports.
+type TargetAlertType string
+
+const (
+	AlertCooldownStarted  TargetAlertType = "cooldown_started"  // a routable target started cooling down
+	AlertTargetRecovered  TargetAlertType = "target_recovered"  // a cooling target is routable again
+	AlertFailureThreshold TargetAlertType = "failure_threshold" // consecutive failures reached AlertFailureThreshold
+)
+
+// DefaultAlertDebounce is used when Settings.AlertDebounceSeconds is not set.
+const DefaultAlertDebounce = 5 * time.Minute
+
+// TargetAlert is sent to notifiers when a target changes state.
+type TargetAlert struct {
+	Type                TargetAlertType `json:"type"`
+	TargetID            string          `json:"target_id"`
+	RouteID             string          `json:"route_id,omitempty"`
+	RouteName           string          `json:"route_name,omitempty"`
+	Status              TargetStatus    `json:"status"`
+	ConsecutiveFailures int             `json:"consecutive_failures"`
+	LastFailureReason   string          `json:"last_failure_reason,omitempty"`
+	Timestamp           time.Time       `json:"timestamp"`
+}
+
+// Notifier delivers target alerts, e.g. to a paging system. Notify is called
+// from a background goroutine and may block.
+type Notifier interface {
+	Notify(ctx context.Context, alert *TargetAlert) error
+}
+
+// alertDispatcher turns target state transitions into TargetAlerts and hands
+// them to the registered notifiers and Settings.AlertWebhookURL. Repeats of the
+// same alert for the same target are suppressed within the debounce window,
+// so a flapping target pages once rather than on every transition.
+type alertDispatcher struct {
+	configSvc ConfigService
+	webhook   *webhookNotifier
+
+	mu        sync.Mutex
+	notifiers []Notifier
+	lastSent  map[string]time.Time
+}
+
+func newAlertDispatcher(configSvc ConfigService) *alertDispatcher {
+	return &alertDispatcher{
+		configSvc: configSvc,
+		webhook:   newWebhookNotifier(nil),
+		lastSent:  make(map[string]time.Time),
+	}
+}
+
+func (d *alertDispatcher) addNotifier(n Notifier) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.notifiers = append(d.notifiers, n)
+}
+
+// observe checks the transition of a target from (before, beforeFailures) to
+// state and dispatches any resulting alerts in the background. It does not
+// block, so it is safe to call with the state manager's lock held.
+func (d *alertDispatcher) observe(before TargetStatus, beforeFailures int, state *TargetState) {
+	if d == nil {
+		return
+	}
+	var types []TargetAlertType
+	switch {
+	case (before == "" || before.IsRoutable()) && state.Status == StatusCooling:
+		types = append(types, AlertCooldownStarted)
+	case (before == StatusCooling || before == StatusChecking) && state.Status.IsRoutable():
+		types = append(types, AlertTargetRecovered)
+	}
+	failuresRose := state.ConsecutiveFailures > beforeFailures
+	if len(types) == 0 && !failuresRose {
+		return
+	}
+
+	base := TargetAlert{
+		TargetID:            state.TargetID,
+		Status:              state.Status,
+		ConsecutiveFailures: state.ConsecutiveFailures,
+		LastFailureReason:   state.LastFailureReason,
+		Timestamp:           time.Now(),
+	}
+	go d.dispatch(base, types, beforeFailures)
+}
+
+func (d *alertDispatcher) dispatch(base TargetAlert, types []TargetAlertType, beforeFailures int) {
+	ctx := context.Background()
+	var settings *Settings
+	if d.configSvc != nil {
+		settings, _ = d.configSvc.GetSettings(ctx)
+	}
+	if settings == nil {
+		settings = &Settings{}
+	}
+	if threshold := settings.AlertFailureThreshold; threshold > 0 && beforeFailures < threshold && base.ConsecutiveFailures >= threshold {
+		types = append(types, AlertFailureThreshold)
+	}
+
+	d.mu.Lock()
+	notifiers := append([]Notifier(nil), d.notifiers...)
+	d.mu.Unlock()
+	if len(types) == 0 || (len(notifiers) == 0 && settings.AlertWebhookURL == "") {
+		return
+	}
+
+	window := DefaultAlertDebounce
+	if settings.AlertDebounceSeconds > 0 {
+		window = time.Duration(settings.AlertDebounceSeconds) * time.Second
+	}
+	if route := d.routeForTarget(ctx, base.TargetID); route != nil {
+		base.RouteID, base.RouteName = route.ID, route.Name
+	}
+
+	for _, alertType := range types {
+		if !d.claim(base.TargetID, alertType, base.Timestamp, window) {
+			log.Debugf("[UnifiedRouting] alert %s for target %s suppressed by debounce", alertType, base.TargetID)
+			continue
+		}
+		alert := base
+		alert.Type = alertType
+		for _, n := range notifiers {
+			if err := n.Notify(ctx, &alert); err != nil {
+				log.Warnf("[UnifiedRouting] alert %s for target %s: notifier failed: %v", alertType, alert.TargetID, err)
+			}
+		}
+		if settings.AlertWebhookURL != "" {
+			body, err := json.Marshal(&alert)
+			if err != nil {
+				log.Warnf("[UnifiedRouting] alert %s: marshal: %v", alertType, err)
+				continue
+			}
+			d.webhook.deliver(settings.AlertWebhookURL, settings.WebhookSecret, body, string(alertType))
+		}
+	}
+}
+
+// claim reports whether an alert of alertType for targetID may be sent at now,
+// and records it as sent if so.
+func (d *alertDispatcher) claim(targetID string, alertType TargetAlertType, now time.Time, window time.Duration) bool {
+	key := targetID + "|" + string(alertType)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if last, ok := d.lastSent[key]; ok && now.Sub(last) < window {
+		return false
+	}
+	d.lastSent[key] = now
+	return true
+}
+
+// routeForTarget returns the route whose pipeline contains targetID, or nil.
+func (d *alertDispatcher) routeForTarget(ctx context.Context, targetID string) *Route {
+	return findRouteForTarget(ctx, d.configSvc, targetID)
+}
+
+// findRouteForTarget returns the route whose pipeline contains targetID, or
+// nil when no route does or configSvc is nil.
+func findRouteForTarget(ctx context.Context, configSvc ConfigService, targetID string) *Route {
+	if configSvc == nil {
+		return nil
+	}
+	routes, err := configSvc.ListRoutes(ctx)
+	if err != nil {
+		return nil
+	}
+	for _, route := range routes {
+		pipeline, err := configSvc.GetPipeline(ctx, route.ID)
+		if err != nil {
+			continue
+		}
+		for _, layer := range pipeline.Layers {
+			for _, t := range layer.Targets {
+				if t.ID == targetID {
+					return route
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package unifiedrouting
+
+import (
+	"context"
+	"sync"
+)
+
+// checkGroup lets only one health check per target run at a time. A caller
+// that arrives while a check of the same target is running waits for it and
+// shares its result instead of sending a second probe.
+type checkGroup struct {
+	mu    sync.Mutex
+	calls map[string]*checkCall // target ID -> running check
+}
+
+type checkCall struct {
+	done    chan struct{}
+	settled bool // a caller has acted on the result; guarded by checkGroup.mu
+	result  *HealthResult
+	err     error
+}
+
+// do runs check for targetID unless one is already running, in which case it
+// waits for that one (or for ctx to be done). shared reports whether the result
+// came from another caller's check.
+//
+// settle, when not nil, acts on the outcome (moving the target out of
+// "checking", rescheduling it, ...). It runs exactly once per check among the
+// callers that pass one: by the caller that ran the check if it passed one,
+// else by the first waiter, since a plain check only records its result.
+func (g *checkGroup) do(ctx context.Context, targetID string, check func() (*HealthResult, error), settle func(*HealthResult, error)) (result *HealthResult, shared bool, err error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*checkCall)
+	}
+	if call, ok := g.calls[targetID]; ok {
+		g.mu.Unlock()
+		select {
+		case <-call.done:
+			result, err = call.result, call.err
+		case <-ctx.Done():
+			result, err = nil, ctx.Err()
+		}
+		if settle != nil && g.claim(call) {
+			settle(result, err)
+		}
+		return result, true, err
+	}
+	call := &checkCall{done: make(chan struct{}), settled: settle != nil}
+	g.calls[targetID] = call
+	g.mu.Unlock()
+
+	func() {
+		defer func() {
+			g.mu.Lock()
+			delete(g.calls, targetID)
+			g.mu.Unlock()
+			close(call.done)
+		}()
+		call.result, call.err = check()
+	}()
+	if settle != nil {
+		settle(call.result, call.err)
+	}
+	return call.result, false, call.err
+}
+
+// claim reports whether the caller is the first to act on call's outcome.
+func (g *checkGroup) claim(call *checkCall) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if call.settled {
+		return false
+	}
+	call.settled = true
+	return true
+}
@@ -0,0 +1,106 @@
+package unifiedrouting
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckGroupSharesConcurrentChecks(t *testing.T) {
+	var group checkGroup
+	var probes atomic.Int32
+	release := make(chan struct{})
+	check := func() (*HealthResult, error) {
+		probes.Add(1)
+		<-release
+		return &HealthResult{TargetID: "t1", Status: "healthy"}, nil
+	}
+
+	type outcome struct {
+		result *HealthResult
+		shared bool
+	}
+	first := make(chan outcome)
+	go func() {
+		result, shared, _ := group.do(context.Background(), "t1", check, nil)
+		first <- outcome{result, shared}
+	}()
+	for probes.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Two waiters that act on the outcome join a plain check; exactly one of
+	// them settles it.
+	var settles atomic.Int32
+	settle := func(*HealthResult, error) { settles.Add(1) }
+	second := make(chan outcome, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			result, shared, _ := group.do(context.Background(), "t1", check, settle)
+			second <- outcome{result, shared}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	// Another target is not held up by t1's check.
+	if _, shared, _ := group.do(context.Background(), "t2", func() (*HealthResult, error) { return &HealthResult{}, nil }, nil); shared {
+		t.Fatalf("check of t2 shared t1's result")
+	}
+
+	close(release)
+	a, b, c := <-first, <-second, <-second
+	if a.shared || !b.shared || !c.shared {
+		t.Fatalf("shared = %v, %v, %v; want false for the running check and true for the waiters", a.shared, b.shared, c.shared)
+	}
+	if a.result != b.result || a.result != c.result {
+		t.Fatalf("waiter got a different result than the running check")
+	}
+	if n := probes.Load(); n != 1 {
+		t.Fatalf("probes = %d, want 1", n)
+	}
+	if n := settles.Load(); n != 1 {
+		t.Fatalf("settles = %d, want 1", n)
+	}
+
+	// Once finished, the next check probes again.
+	if _, shared, _ := group.do(context.Background(), "t1", func() (*HealthResult, error) { return &HealthResult{}, nil }, nil); shared {
+		t.Fatalf("check after completion was shared")
+	}
+}
+
+func TestCheckGroupRunnerSettlesItsOwnCheck(t *testing.T) {
+	var group checkGroup
+	release := make(chan struct{})
+	var settles atomic.Int32
+	settle := func(*HealthResult, error) { settles.Add(1) }
+
+	done := make(chan struct{})
+	go func() {
+		group.do(context.Background(), "t1", func() (*HealthResult, error) {
+			<-release
+			return &HealthResult{}, nil
+		}, settle)
+		close(done)
+	}()
+	for {
+		group.mu.Lock()
+		_, running := group.calls["t1"]
+		group.mu.Unlock()
+		if running {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	waiter := make(chan struct{})
+	go func() {
+		group.do(context.Background(), "t1", nil, settle)
+		close(waiter)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	<-done
+	<-waiter
+	if n := settles.Load(); n != 1 {
+		t.Fatalf("settles = %d, want 1", n)
+	}
+}
@@ -8,11 +8,12 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 )
 
 // ConfigChangeEvent represents a configuration change event.
 type ConfigChangeEvent struct {
-	Type    string // "route_created", "route_updated", "route_deleted", "settings_updated", "pipeline_updated"
+	Type    string // "route_created", "route_updated", "route_archived", "route_restored", "route_purged", "settings_updated", "pipeline_updated"
 	RouteID string
 	Payload any
 }
@@ -30,20 +31,50 @@ type ConfigService interface {
 	GetHealthCheckConfig(ctx context.Context) (*HealthCheckConfig, error)
 	UpdateHealthCheckConfig(ctx context.Context, config *HealthCheckConfig) error
 
+	// Error classification rules
+	GetClassificationRules(ctx context.Context) (*ClassificationRules, error)
+	UpdateClassificationRules(ctx context.Context, rules *ClassificationRules) error
+	// ReloadClassificationRules re-reads the rules from the store and makes
+	// them the ones ClassifyError uses.
+	ReloadClassificationRules(ctx context.Context) (*ClassificationRules, error)
+
 	// Routes
 	ListRoutes(ctx context.Context) ([]*Route, error)
 	GetRoute(ctx context.Context, id string) (*Route, error)
 	CreateRoute(ctx context.Context, route *Route) error
 	UpdateRoute(ctx context.Context, route *Route) error
+	// DeleteRoute archives the route with its pipeline; RestoreRoute brings it
+	// back and PurgeRoute removes an active or archived route permanently.
 	DeleteRoute(ctx context.Context, id string) error
+	ListArchivedRoutes(ctx context.Context) ([]*ArchivedRoute, error)
+	RestoreRoute(ctx context.Context, id string) (*Route, error)
+	PurgeRoute(ctx context.Context, id string) error
+	// CloneRoute copies a route's pipeline under a new name with fresh target IDs.
+	CloneRoute(ctx context.Context, routeID, name string, aliases []string) (*Route, *Pipeline, error)
 
 	// Pipelines
 	GetPipeline(ctx context.Context, routeID string) (*Pipeline, error)
-	UpdatePipeline(ctx context.Context, routeID string, pipeline *Pipeline) error
+	// UpdatePipeline validates, normalizes (target IDs, default weights) and stores
+	// the pipeline, returning the stored result.
+	UpdatePipeline(ctx context.Context, routeID string, pipeline *Pipeline) (*Pipeline, error)
+	// SetTargetsEnabled sets Enabled on every target of the route, or only on
+	// the layer with the given level if level is non-zero, and returns the
+	// stored pipeline with the number of targets that changed.
+	SetTargetsEnabled(ctx context.Context, routeID string, level int, enabled bool) (*Pipeline, int, error)
 
 	// Export/Import
 	Export(ctx context.Context) (*ExportData, error)
-	Import(ctx context.Context, data *ExportData, merge bool) error
+	// ExportRoutes exports only the given routes with their pipelines, plus a
+	// snapshot of the global settings, as a partial export. ExportRoute is the
+	// single-route form.
+	ExportRoutes(ctx context.Context, ids []string) (*ExportData, error)
+	ExportRoute(ctx context.Context, routeID string) (*ExportData, error)
+	// Import applies data and, with RegenerateIDs, returns the old→new ID mapping.
+	Import(ctx context.Context, data *ExportData, opts ImportOptions) (map[string]string, error)
+	// ExportYAML and ImportYAML are the YAML forms of Export and Import.
+	// ImportYAML validates every route first and rejects the whole document on failure.
+	ExportYAML(ctx context.Context) ([]byte, error)
+	ImportYAML(ctx context.Context, data []byte, opts ImportOptions) (map[string]string, error)
 
 	// Validation
 	Validate(ctx context.Context, route *Route, pipeline *Pipeline) []ValidationError
@@ -60,11 +91,14 @@ type DefaultConfigService struct {
 }
 
 // NewConfigService creates a new configuration service.
+// Config changes are also posted to Settings.WebhookURL whenever one is set.
 func NewConfigService(store ConfigStore) *DefaultConfigService {
-	return &DefaultConfigService{
+	s := &DefaultConfigService{
 		store:    store,
 		handlers: make([]ConfigChangeHandler, 0),
 	}
+	s.Subscribe(newWebhookNotifier(store).handle)
+	return s
 }
 
 func (s *DefaultConfigService) GetSettings(ctx context.Context) (*Settings, error) {
@@ -72,6 +106,9 @@ func (s *DefaultConfigService) GetSettings(ctx context.Context) (*Settings, erro
 }
 
 func (s *DefaultConfigService) UpdateSettings(ctx context.Context, settings *Settings) error {
+	if err := validateSettings(settings); err != nil {
+		return err
+	}
 	if err := s.store.SaveSettings(ctx, settings); err != nil {
 		return err
 	}
@@ -84,11 +121,74 @@ func (s *DefaultConfigService) UpdateSettings(ctx context.Context, settings *Set
 	return nil
 }
 
+// validateSettings checks the fields of settings that UpdateSettings persists.
+func validateSettings(settings *Settings) error {
+	if err := validateWebhookURL("webhook_url", settings.WebhookURL); err != nil {
+		return err
+	}
+	if err := validateWebhookURL("alert_webhook_url", settings.AlertWebhookURL); err != nil {
+		return err
+	}
+	if settings.AlertFailureThreshold < 0 {
+		return fmt.Errorf("alert_failure_threshold must be >= 0")
+	}
+	if settings.AlertDebounceSeconds < 0 {
+		return fmt.Errorf("alert_debounce_seconds must be >= 0")
+	}
+	if settings.RequestTimeoutSeconds < 0 {
+		return fmt.Errorf("request_timeout_seconds must be >= 0")
+	}
+	if sc := settings.Scoring; sc != nil {
+		if sc.LatencyWeight < 0 || sc.FailureWeight < 0 || sc.InFlightWeight < 0 || sc.FailureHalfLifeSeconds < 0 {
+			return fmt.Errorf("scoring weights and failure_half_life_seconds must be >= 0")
+		}
+	}
+	if err := config.ValidateProxyURL(settings.UpstreamProxy); err != nil {
+		return fmt.Errorf("upstream_proxy: %w", err)
+	}
+	return nil
+}
+
+// validateHealthCheckConfig checks the fields of config that
+// UpdateHealthCheckConfig persists.
+func validateHealthCheckConfig(config *HealthCheckConfig) error {
+	for _, field := range []struct {
+		name  string
+		value int
+	}{
+		{"check_interval_seconds", config.CheckIntervalSeconds},
+		{"check_timeout_seconds", config.CheckTimeoutSeconds},
+		{"max_consecutive_failures", config.MaxConsecutiveFailures},
+		{"half_open_requests", config.HalfOpenRequests},
+		{"warmup_requests", config.WarmupRequests},
+		{"max_cooldown_seconds", config.MaxCooldownSeconds},
+		{"health_check_jitter_seconds", config.HealthCheckJitterSeconds},
+		{"health_check_max_tokens", config.HealthCheckMaxTokens},
+		{"health_check_concurrency", config.HealthCheckConcurrency},
+		{"health_check_credential_interval_ms", config.HealthCheckCredentialIntervalMs},
+		{"history_size", config.HistorySize},
+		{"max_concurrent_checks", config.MaxConcurrentChecks},
+		{"network_error_cooldown_seconds", config.NetworkErrorCooldownSeconds},
+		{"min_reply_bytes", config.MinReplyBytes},
+	} {
+		if field.value < 0 {
+			return fmt.Errorf("%s must be >= 0", field.name)
+		}
+	}
+	if !config.HealthCheckMode.IsValid() {
+		return fmt.Errorf("invalid health_check_mode: %s", config.HealthCheckMode)
+	}
+	return nil
+}
+
 func (s *DefaultConfigService) GetHealthCheckConfig(ctx context.Context) (*HealthCheckConfig, error) {
 	return s.store.LoadHealthCheckConfig(ctx)
 }
 
 func (s *DefaultConfigService) UpdateHealthCheckConfig(ctx context.Context, config *HealthCheckConfig) error {
+	if err := validateHealthCheckConfig(config); err != nil {
+		return err
+	}
 	if err := s.store.SaveHealthCheckConfig(ctx, config); err != nil {
 		return err
 	}
@@ -101,6 +201,40 @@ func (s *DefaultConfigService) UpdateHealthCheckConfig(ctx context.Context, conf
 	return nil
 }
 
+func (s *DefaultConfigService) GetClassificationRules(ctx context.Context) (*ClassificationRules, error) {
+	return s.store.LoadClassificationRules(ctx)
+}
+
+func (s *DefaultConfigService) UpdateClassificationRules(ctx context.Context, rules *ClassificationRules) error {
+	if err := ValidateClassificationRules(rules); err != nil {
+		return err
+	}
+	if err := s.store.SaveClassificationRules(ctx, rules); err != nil {
+		return err
+	}
+	if err := SetClassificationRules(rules); err != nil {
+		return err
+	}
+
+	s.notify(ConfigChangeEvent{
+		Type:    "classification_rules_updated",
+		Payload: rules,
+	})
+
+	return nil
+}
+
+func (s *DefaultConfigService) ReloadClassificationRules(ctx context.Context) (*ClassificationRules, error) {
+	rules, err := s.store.LoadClassificationRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := SetClassificationRules(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
 func (s *DefaultConfigService) ListRoutes(ctx context.Context) ([]*Route, error) {
 	return s.store.ListRoutes(ctx)
 }
@@ -117,7 +251,7 @@ func (s *DefaultConfigService) CreateRoute(ctx context.Context, route *Route) er
 
 	// Validate route name
 	if route.Name == "" {
-		return fmt.Errorf("route name is required")
+		return ValidationErrors{{Field: "name", Message: "route name is required"}}
 	}
 
 	// Deduplicate and clean aliases (remove empty, remove duplicates with name)
@@ -183,52 +317,186 @@ func (s *DefaultConfigService) UpdateRoute(ctx context.Context, route *Route) er
 }
 
 func (s *DefaultConfigService) DeleteRoute(ctx context.Context, id string) error {
+	if err := s.store.ArchiveRoute(ctx, id); err != nil {
+		return err
+	}
+
+	s.notify(ConfigChangeEvent{
+		Type:    "route_archived",
+		RouteID: id,
+	})
+
+	return nil
+}
+
+func (s *DefaultConfigService) ListArchivedRoutes(ctx context.Context) ([]*ArchivedRoute, error) {
+	return s.store.ListArchivedRoutes(ctx)
+}
+
+func (s *DefaultConfigService) RestoreRoute(ctx context.Context, id string) (*Route, error) {
+	archived, err := s.findArchivedRoute(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Another route may have taken the name or an alias in the meantime.
+	routes, err := s.store.ListRoutes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNameConflicts(&archived.Route, routes); err != nil {
+		return nil, err
+	}
+
+	route, err := s.store.RestoreRoute(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.notify(ConfigChangeEvent{
+		Type:    "route_restored",
+		RouteID: id,
+		Payload: route,
+	})
+
+	return route, nil
+}
+
+func (s *DefaultConfigService) PurgeRoute(ctx context.Context, id string) error {
+	_, activeErr := s.store.GetRoute(ctx, id)
+	_, archivedErr := s.findArchivedRoute(ctx, id)
+	if activeErr != nil && archivedErr != nil {
+		return fmt.Errorf("route not found: %s", id)
+	}
+
 	if err := s.store.DeleteRoute(ctx, id); err != nil {
 		return err
 	}
+	if err := s.store.DeleteArchivedRoute(ctx, id); err != nil {
+		return err
+	}
 
 	s.notify(ConfigChangeEvent{
-		Type:    "route_deleted",
+		Type:    "route_purged",
 		RouteID: id,
 	})
 
 	return nil
 }
 
+func (s *DefaultConfigService) findArchivedRoute(ctx context.Context, id string) (*ArchivedRoute, error) {
+	archived, err := s.store.ListArchivedRoutes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range archived {
+		if a.Route.ID == id {
+			return a, nil
+		}
+	}
+	return nil, fmt.Errorf("archived route not found: %s", id)
+}
+
 func (s *DefaultConfigService) GetPipeline(ctx context.Context, routeID string) (*Pipeline, error) {
 	return s.store.GetPipeline(ctx, routeID)
 }
 
-func (s *DefaultConfigService) UpdatePipeline(ctx context.Context, routeID string, pipeline *Pipeline) error {
+func (s *DefaultConfigService) UpdatePipeline(ctx context.Context, routeID string, pipeline *Pipeline) (*Pipeline, error) {
 	// Validate pipeline
 	if errs := s.validatePipeline(pipeline); len(errs) > 0 {
-		return fmt.Errorf("pipeline validation failed: %s", errs[0].Message)
+		return nil, fmt.Errorf("pipeline validation failed: %w", ValidationErrors(errs))
+	}
+
+	// Canary ramps run from CreatedAt, which clients need not send back.
+	createdAt := make(map[string]*time.Time)
+	if previous, err := s.store.GetPipeline(ctx, routeID); err == nil && previous != nil {
+		for _, layer := range previous.Layers {
+			for _, target := range layer.Targets {
+				if target.CreatedAt != nil {
+					createdAt[target.ID] = target.CreatedAt
+				}
+			}
+		}
 	}
+	now := time.Now()
 
+	applyDefaultModel(pipeline)
 	// Ensure target IDs are set
 	for i := range pipeline.Layers {
 		for j := range pipeline.Layers[i].Targets {
-			if pipeline.Layers[i].Targets[j].ID == "" {
-				pipeline.Layers[i].Targets[j].ID = "target-" + generateShortID()
+			target := &pipeline.Layers[i].Targets[j]
+			if target.ID == "" {
+				target.ID = "target-" + generateShortID()
 			}
 			// Default weight to 1
-			if pipeline.Layers[i].Targets[j].Weight <= 0 {
-				pipeline.Layers[i].Targets[j].Weight = 1
+			if target.Weight <= 0 {
+				target.Weight = 1
+			}
+			if target.CreatedAt == nil {
+				target.CreatedAt = createdAt[target.ID]
+			}
+			if target.CreatedAt == nil && target.IsCanary() {
+				target.CreatedAt = &now
 			}
 		}
 	}
 
 	if err := s.store.SavePipeline(ctx, routeID, pipeline); err != nil {
-		return err
+		return nil, err
+	}
+
+	// Read back the stored pipeline so callers see exactly what was persisted.
+	stored, err := s.store.GetPipeline(ctx, routeID)
+	if err != nil {
+		stored = pipeline
 	}
 
 	s.notify(ConfigChangeEvent{
 		Type:    "pipeline_updated",
 		RouteID: routeID,
-		Payload: pipeline,
+		Payload: stored,
 	})
 
-	return nil
+	return stored, nil
+}
+
+func (s *DefaultConfigService) SetTargetsEnabled(ctx context.Context, routeID string, level int, enabled bool) (*Pipeline, int, error) {
+	if _, err := s.store.GetRoute(ctx, routeID); err != nil {
+		return nil, 0, err
+	}
+	pipeline, err := s.store.GetPipeline(ctx, routeID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	changed, matched := 0, level == 0
+	for i := range pipeline.Layers {
+		layer := &pipeline.Layers[i]
+		if level != 0 && layer.Level != level {
+			continue
+		}
+		matched = true
+		for j := range layer.Targets {
+			if layer.Targets[j].Enabled != enabled {
+				layer.Targets[j].Enabled = enabled
+				changed++
+			}
+		}
+	}
+	if !matched {
+		return nil, 0, fmt.Errorf("route %s has no layer with level %d", routeID, level)
+	}
+	if changed == 0 {
+		return pipeline, 0, nil
+	}
+
+	// The pipeline keeps the version it was read with, so a concurrent edit
+	// makes this fail instead of being overwritten.
+	stored, err := s.UpdatePipeline(ctx, routeID, pipeline)
+	if err != nil {
+		return nil, 0, err
+	}
+	return stored, changed, nil
 }
 
 func (s *DefaultConfigService) Export(ctx context.Context) (*ExportData, error) {
@@ -263,57 +531,254 @@ func (s *DefaultConfigService) Export(ctx context.Context) (*ExportData, error)
 		Version:    "1.0",
 		ExportedAt: time.Now(),
 		Config: ExportedConfig{
-			Settings:    *settings,
+			Settings:    stripSettingsSecrets(settings),
 			HealthCheck: *healthConfig,
 			Routes:      routesWithPipelines,
 		},
 	}, nil
 }
 
-func (s *DefaultConfigService) Import(ctx context.Context, data *ExportData, merge bool) error {
-	if !merge {
-		// Delete all existing routes first
-		routes, _ := s.store.ListRoutes(ctx)
-		for _, route := range routes {
-			_ = s.store.DeleteRoute(ctx, route.ID)
+func (s *DefaultConfigService) CloneRoute(ctx context.Context, routeID, name string, aliases []string) (*Route, *Pipeline, error) {
+	source, err := s.store.GetRoute(ctx, routeID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clone := &Route{
+		Name:        name,
+		Aliases:     aliases,
+		Description: source.Description,
+		Enabled:     source.Enabled,
+	}
+	if err := s.CreateRoute(ctx, clone); err != nil {
+		return nil, nil, err
+	}
+
+	sourcePipeline, err := s.store.GetPipeline(ctx, routeID)
+	if err != nil || len(sourcePipeline.Layers) == 0 {
+		return clone, &Pipeline{RouteID: clone.ID, Layers: []Layer{}}, nil
+	}
+
+	// Copy layers and targets; cleared IDs are regenerated by UpdatePipeline so the
+	// clone starts without any runtime state from the source targets.
+	pipeline := &Pipeline{RouteID: clone.ID, Layers: make([]Layer, len(sourcePipeline.Layers))}
+	for i, layer := range sourcePipeline.Layers {
+		layer.Targets = append([]Target(nil), layer.Targets...)
+		for j := range layer.Targets {
+			layer.Targets[j].ID = ""
 		}
+		pipeline.Layers[i] = layer
 	}
 
-	// Import settings
-	if err := s.store.SaveSettings(ctx, &data.Config.Settings); err != nil {
-		return fmt.Errorf("failed to import settings: %w", err)
+	stored, err := s.UpdatePipeline(ctx, clone.ID, pipeline)
+	if err != nil {
+		_ = s.PurgeRoute(ctx, clone.ID)
+		return nil, nil, err
 	}
+	return clone, stored, nil
+}
+
+func (s *DefaultConfigService) ExportRoute(ctx context.Context, routeID string) (*ExportData, error) {
+	return s.ExportRoutes(ctx, []string{routeID})
+}
 
-	// Import health config
-	if err := s.store.SaveHealthCheckConfig(ctx, &data.Config.HealthCheck); err != nil {
-		return fmt.Errorf("failed to import health config: %w", err)
+func (s *DefaultConfigService) ExportRoutes(ctx context.Context, ids []string) (*ExportData, error) {
+	settings, err := s.store.LoadSettings(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	// Import routes and pipelines
-	for _, rwp := range data.Config.Routes {
-		route := rwp.Route
+	healthConfig, err := s.store.LoadHealthCheckConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	routesWithPipelines := make([]RouteWithPipeline, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		route, err := s.store.GetRoute(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		pipeline, err := s.store.GetPipeline(ctx, route.ID)
+		if err != nil {
+			pipeline = &Pipeline{RouteID: route.ID, Layers: []Layer{}}
+		}
+		routesWithPipelines = append(routesWithPipelines, RouteWithPipeline{
+			Route:    *route,
+			Pipeline: *pipeline,
+		})
+	}
 
-		if merge {
+	return &ExportData{
+		Version:    "1.0",
+		ExportedAt: time.Now(),
+		Partial:    true,
+		Config: ExportedConfig{
+			Settings:    stripSettingsSecrets(settings),
+			HealthCheck: *healthConfig,
+			Routes:      routesWithPipelines,
+		},
+	}, nil
+}
+
+// ImportOptions controls how Import applies an ExportData.
+type ImportOptions struct {
+	// Merge keeps existing routes, replacing those with the same ID; otherwise
+	// all existing routes are removed first. Partial exports always merge.
+	Merge bool
+	// RegenerateIDs gives every imported route and target a fresh ID, so a
+	// config exported from another instance cannot overwrite an unrelated
+	// local route that happens to share an ID.
+	RegenerateIDs bool
+}
+
+func (s *DefaultConfigService) Import(ctx context.Context, data *ExportData, opts ImportOptions) (map[string]string, error) {
+	if data.Partial {
+		opts.Merge = true
+	}
+	routes := make([]RouteWithPipeline, len(data.Config.Routes))
+	for i, rwp := range data.Config.Routes {
+		rwp.Pipeline.Layers = append([]Layer(nil), rwp.Pipeline.Layers...)
+		for j := range rwp.Pipeline.Layers {
+			rwp.Pipeline.Layers[j].Targets = append([]Target(nil), rwp.Pipeline.Layers[j].Targets...)
+		}
+		routes[i] = rwp
+	}
+	var mapping map[string]string
+	if opts.RegenerateIDs {
+		mapping = regenerateImportIDs(routes)
+	}
+
+	// Reject name clashes before changing anything.
+	var existing []*Route
+	if opts.Merge {
+		existing, _ = s.store.ListRoutes(ctx)
+	}
+	for i := range routes {
+		route := &routes[i].Route
+		if err := checkNameConflicts(route, existing); err != nil {
+			return nil, fmt.Errorf("import rejected: route %q: %w", route.Name, err)
+		}
+		existing = replaceRouteByID(existing, route)
+	}
+
+	if !opts.Merge {
+		// Delete all existing routes first
+		current, _ := s.store.ListRoutes(ctx)
+		for _, route := range current {
+			_ = s.store.DeleteRoute(ctx, route.ID)
+		}
+	}
+
+	// A partial export only carries its routes; the settings in it are a
+	// snapshot of the exporting instance, not a replacement.
+	if !data.Partial {
+		if data.Config.Settings != nil {
+			// Exports carry no secrets; keep the ones already configured.
+			settings := *data.Config.Settings
+			if current, err := s.store.LoadSettings(ctx); err == nil {
+				restoreSettingsSecrets(&settings, current, stripSettingsSecrets(current))
+			}
+			if err := s.store.SaveSettings(ctx, &settings); err != nil {
+				return nil, fmt.Errorf("failed to import settings: %w", err)
+			}
+		}
+		if err := s.store.SaveHealthCheckConfig(ctx, &data.Config.HealthCheck); err != nil {
+			return nil, fmt.Errorf("failed to import health config: %w", err)
+		}
+	}
+
+	// Import routes and pipelines
+	for i := range routes {
+		route := &routes[i].Route
+		pipeline := &routes[i].Pipeline
+		// Versions in the file belong to the exporting instance; an import
+		// always overwrites.
+		route.Version, pipeline.Version = 0, 0
+
+		if opts.Merge {
 			// Update if exists, create if not
 			_, err := s.store.GetRoute(ctx, route.ID)
 			if err != nil {
-				_ = s.store.CreateRoute(ctx, &route)
+				_ = s.store.CreateRoute(ctx, route)
 			} else {
-				_ = s.store.UpdateRoute(ctx, &route)
+				_ = s.store.UpdateRoute(ctx, route)
 			}
 		} else {
-			_ = s.store.CreateRoute(ctx, &route)
+			_ = s.store.CreateRoute(ctx, route)
 		}
 
-		_ = s.store.SavePipeline(ctx, route.ID, &rwp.Pipeline)
+		pipeline.RouteID = route.ID
+		applyDefaultModel(pipeline)
+		_ = s.store.SavePipeline(ctx, route.ID, pipeline)
 	}
 
+	imported := *data
+	imported.Config.Routes = routes
 	s.notify(ConfigChangeEvent{
 		Type:    "config_imported",
-		Payload: data,
+		Payload: &imported,
 	})
 
-	return nil
+	return mapping, nil
+}
+
+// applyDefaultModel gives targets without a model the pipeline's DefaultModel.
+func applyDefaultModel(pipeline *Pipeline) {
+	if pipeline.DefaultModel == "" {
+		return
+	}
+	for i := range pipeline.Layers {
+		for j := range pipeline.Layers[i].Targets {
+			if target := &pipeline.Layers[i].Targets[j]; target.Model == "" {
+				target.Model = pipeline.DefaultModel
+			}
+		}
+	}
+}
+
+// regenerateImportIDs assigns fresh route and target IDs in place and returns
+// the old→new mapping. Routes and targets without an ID get one too but are
+// not listed.
+func regenerateImportIDs(routes []RouteWithPipeline) map[string]string {
+	mapping := make(map[string]string)
+	for i := range routes {
+		rwp := &routes[i]
+		newID := "route-" + generateShortID()
+		if rwp.Route.ID != "" {
+			mapping[rwp.Route.ID] = newID
+		}
+		rwp.Route.ID = newID
+		for j := range rwp.Pipeline.Layers {
+			targets := rwp.Pipeline.Layers[j].Targets
+			for k := range targets {
+				newTargetID := "target-" + generateShortID()
+				if targets[k].ID != "" {
+					mapping[targets[k].ID] = newTargetID
+				}
+				targets[k].ID = newTargetID
+			}
+		}
+	}
+	return mapping
+}
+
+// replaceRouteByID returns routes with route added, replacing any entry that
+// has the same ID.
+func replaceRouteByID(routes []*Route, route *Route) []*Route {
+	for i, r := range routes {
+		if r.ID == route.ID {
+			routes[i] = route
+			return routes
+		}
+	}
+	return append(routes, route)
 }
 
 func (s *DefaultConfigService) Validate(ctx context.Context, route *Route, pipeline *Pipeline) []ValidationError {
@@ -363,10 +828,42 @@ func (s *DefaultConfigService) Validate(ctx context.Context, route *Route, pipel
 func (s *DefaultConfigService) validatePipeline(pipeline *Pipeline) []ValidationError {
 	var errors []ValidationError
 
+	if pipeline.RequestTimeoutSeconds < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "request_timeout_seconds",
+			Message: "request_timeout_seconds must be >= 0",
+		})
+	}
+	if pipeline.MaxAttempts < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "max_attempts",
+			Message: "max_attempts must be >= 0",
+		})
+	}
+
 	if len(pipeline.Layers) == 0 {
 		return errors
 	}
 
+	// Parking layers must leave something to route to. A pipeline whose
+	// targets are all disabled is a route taken out of service on purpose.
+	parked, servable := false, false
+	for _, layer := range pipeline.Layers {
+		if layer.Disabled {
+			parked = true
+			continue
+		}
+		for _, target := range layer.Targets {
+			servable = servable || target.Enabled
+		}
+	}
+	if parked && !servable {
+		errors = append(errors, ValidationError{
+			Field:   "layers",
+			Message: "at least one enabled layer must have an enabled target",
+		})
+	}
+
 	seenLevels := make(map[int]bool)
 	for i, layer := range pipeline.Layers {
 		// Check level uniqueness
@@ -378,24 +875,106 @@ func (s *DefaultConfigService) validatePipeline(pipeline *Pipeline) []Validation
 		}
 		seenLevels[layer.Level] = true
 
+		// The same credential and model twice in one layer doubles its share
+		// of traffic and health checks; across layers it is a deliberate fallback.
+		seenTargets := make(map[[2]string]int)
 		for j, target := range layer.Targets {
+			model := target.Model
+			if model == "" {
+				model = pipeline.DefaultModel
+			}
+			if target.CredentialID != "" && model != "" {
+				key := [2]string{target.CredentialID, model}
+				if first, ok := seenTargets[key]; ok {
+					errors = append(errors, ValidationError{
+						Field:   fmt.Sprintf("layers[%d].targets[%d]", i, j),
+						Message: fmt.Sprintf("duplicate of layers[%d].targets[%d]: credential %s with model %s", i, first, target.CredentialID, model),
+					})
+				} else {
+					seenTargets[key] = j
+				}
+			}
 			if target.CredentialID == "" {
 				errors = append(errors, ValidationError{
 					Field:   fmt.Sprintf("layers[%d].targets[%d].credential_id", i, j),
 					Message: "credential_id is required",
 				})
 			}
-			if target.Model == "" {
+			if model == "" {
 				errors = append(errors, ValidationError{
 					Field:   fmt.Sprintf("layers[%d].targets[%d].model", i, j),
-					Message: "model is required",
+					Message: "model is required (set it on the target or as the pipeline's default_model)",
+				})
+			}
+			if target.MaxConcurrent < 0 {
+				errors = append(errors, ValidationError{
+					Field:   fmt.Sprintf("layers[%d].targets[%d].max_concurrent", i, j),
+					Message: "max_concurrent must be >= 0",
+				})
+			}
+			if target.HealthCheck != nil && target.HealthCheck.MaxTokens < 0 {
+				errors = append(errors, ValidationError{
+					Field:   fmt.Sprintf("layers[%d].targets[%d].health_check.max_tokens", i, j),
+					Message: "max_tokens must be >= 0",
+				})
+			}
+			if target.ShadowSampleRate < 0 || target.ShadowSampleRate > 1 {
+				errors = append(errors, ValidationError{
+					Field:   fmt.Sprintf("layers[%d].targets[%d].shadow_sample_rate", i, j),
+					Message: "shadow_sample_rate must be between 0 and 1",
+				})
+			}
+			if err := config.ValidateProxyURL(target.UpstreamProxy); err != nil {
+				errors = append(errors, ValidationError{
+					Field:   fmt.Sprintf("layers[%d].targets[%d].upstream_proxy", i, j),
+					Message: err.Error(),
+				})
+			}
+			for k, transform := range target.RequestTransform {
+				if err := transform.Validate(); err != nil {
+					errors = append(errors, ValidationError{
+						Field:   fmt.Sprintf("layers[%d].targets[%d].request_transform[%d]", i, j, k),
+						Message: err.Error(),
+					})
+				}
+			}
+			for k, transform := range target.ResponseTransform {
+				if err := transform.Validate(); err != nil {
+					errors = append(errors, ValidationError{
+						Field:   fmt.Sprintf("layers[%d].targets[%d].response_transform[%d]", i, j, k),
+						Message: err.Error(),
+					})
+				}
+			}
+			if target.CanaryStartWeight < 0 || target.CanaryTargetWeight < 0 || target.CanaryRampSeconds < 0 {
+				errors = append(errors, ValidationError{
+					Field:   fmt.Sprintf("layers[%d].targets[%d].canary_ramp_seconds", i, j),
+					Message: "canary weights and ramp must be >= 0",
+				})
+			}
+			if (target.CanaryStartWeight > 0 || target.CanaryTargetWeight > 0) && !target.IsCanary() {
+				errors = append(errors, ValidationError{
+					Field:   fmt.Sprintf("layers[%d].targets[%d].canary_ramp_seconds", i, j),
+					Message: "canary_ramp_seconds is required when canary weights are set",
+				})
+			}
+			if target.IsCanary() && layer.Strategy != StrategyWeightedRound {
+				errors = append(errors, ValidationError{
+					Field:   fmt.Sprintf("layers[%d].targets[%d].canary_ramp_seconds", i, j),
+					Message: fmt.Sprintf("canary ramp requires the %s strategy", StrategyWeightedRound),
+				})
+			}
+			if target.HealthCheck != nil && !target.HealthCheck.Mode.IsValid() {
+				errors = append(errors, ValidationError{
+					Field:   fmt.Sprintf("layers[%d].targets[%d].health_check.mode", i, j),
+					Message: fmt.Sprintf("invalid health check mode: %s", target.HealthCheck.Mode),
 				})
 			}
 		}
 
 		// Validate strategy
 		switch layer.Strategy {
-		case StrategyRoundRobin, StrategyWeightedRound, StrategyLeastConn, StrategyRandom, StrategyFirstAvailable, "":
+		case StrategyRoundRobin, StrategyWeightedRound, StrategyLeastConn, StrategyRandom, StrategyFirstAvailable, StrategyLeastLatency, StrategySticky, StrategyBestScore, "":
 			// Valid
 		default:
 			errors = append(errors, ValidationError{
@@ -455,7 +1034,8 @@ func cleanAliases(name string, aliases []string) []string {
 }
 
 // checkNameConflicts checks that the route's name and aliases don't conflict
-// with any other route's name or aliases. Skips routes with the same ID (for updates).
+// with any other route's name or aliases, returning ValidationErrors for field
+// "name" when they do. Skips routes with the same ID (for updates).
 func checkNameConflicts(route *Route, allRoutes []*Route) error {
 	// Collect all names from the new/updated route
 	newNames := make(map[string]string) // lowercase -> original
@@ -470,12 +1050,12 @@ func checkNameConflicts(route *Route, allRoutes []*Route) error {
 		}
 		// Check against existing route's name
 		if original, ok := newNames[strings.ToLower(r.Name)]; ok {
-			return fmt.Errorf("name/alias '%s' conflicts with route '%s'", original, r.Name)
+			return ValidationErrors{{Field: "name", Message: fmt.Sprintf("name/alias '%s' conflicts with route '%s'", original, r.Name)}}
 		}
 		// Check against existing route's aliases
 		for _, a := range r.Aliases {
 			if original, ok := newNames[strings.ToLower(a)]; ok {
-				return fmt.Errorf("name/alias '%s' conflicts with alias '%s' on route '%s'", original, a, r.Name)
+				return ValidationErrors{{Field: "name", Message: fmt.Sprintf("name/alias '%s' conflicts with alias '%s' on route '%s'", original, a, r.Name)}}
 			}
 		}
 	}
@@ -0,0 +1,161 @@
+package unifiedrouting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ExportYAML exports the configuration as YAML. The document uses the same
+// keys as the JSON export (not the kebab-case keys of the on-disk store), so the
+// two forms convert into each other without loss.
+func (s *DefaultConfigService) ExportYAML(ctx context.Context) ([]byte, error) {
+	data, err := s.Export(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return exportDataToYAML(data)
+}
+
+// ImportYAML imports a YAML document produced by ExportYAML (or its JSON
+// equivalent). Every route and pipeline is validated first; if any fails, the
+// whole document is rejected and nothing is changed.
+func (s *DefaultConfigService) ImportYAML(ctx context.Context, raw []byte, opts ImportOptions) (map[string]string, error) {
+	data, err := exportDataFromYAML(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateImport(ctx, data); err != nil {
+		return nil, err
+	}
+	return s.Import(ctx, data, opts)
+}
+
+// validateImport runs Validate over every route and pipeline in data.
+func (s *DefaultConfigService) validateImport(ctx context.Context, data *ExportData) error {
+	var problems []string
+	for i := range data.Config.Routes {
+		rwp := &data.Config.Routes[i]
+		for _, verr := range s.Validate(ctx, &rwp.Route, &rwp.Pipeline) {
+			problems = append(problems, fmt.Sprintf("route %q: %s: %s", rwp.Route.Name, verr.Field, verr.Message))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("import rejected: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func exportDataToYAML(data *ExportData) ([]byte, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	node, err := jsonToYAMLNode(json.NewDecoder(bytes.NewReader(raw)))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func exportDataFromYAML(raw []byte) (*ExportData, error) {
+	var doc any
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("invalid yaml: %w", err)
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("invalid yaml: empty document")
+	}
+	asJSON, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid yaml: %w", err)
+	}
+	var data ExportData
+	if err := json.Unmarshal(asJSON, &data); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return &data, nil
+}
+
+// jsonToYAMLNode converts the next JSON value from dec into a YAML node,
+// keeping object keys in their JSON (struct field) order.
+func jsonToYAMLNode(dec *json.Decoder) (*yaml.Node, error) {
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch v := tok.(type) {
+	case json.Delim:
+		switch v {
+		case '{':
+			node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := jsonToYAMLNode(dec)
+				if err != nil {
+					return nil, err
+				}
+				key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: keyTok.(string)}
+				node.Content = append(node.Content, key, value)
+			}
+			_, err = dec.Token() // closing '}'
+			return node, err
+		case '[':
+			node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			for dec.More() {
+				value, err := jsonToYAMLNode(dec)
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, value)
+			}
+			_, err = dec.Token() // closing ']'
+			return node, err
+		}
+		return nil, fmt.Errorf("unexpected delimiter %q", v)
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}, nil
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(v.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v.String()}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(v)}, nil
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+	return nil, fmt.Errorf("unexpected json token %v", tok)
+}
+
+// readAllLimited reads at most limit bytes from r, failing if there are more.
+func readAllLimited(r io.Reader, limit int64) ([]byte, error) {
+	raw, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(raw)) > limit {
+		return nil, fmt.Errorf("document exceeds %d bytes", limit)
+	}
+	return raw, nil
+}
@@ -0,0 +1,183 @@
+package unifiedrouting
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestConfigYAMLRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	cfgStore, err := NewFileConfigStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileConfigStore: %v", err)
+	}
+	configSvc := NewConfigService(cfgStore)
+	if err := configSvc.UpdateSettings(ctx, &Settings{Enabled: true}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	route := &Route{Name: "claude-main", Aliases: []string{"cm"}, Description: "main: route # with yaml chars", Enabled: true}
+	if err := configSvc.CreateRoute(ctx, route); err != nil {
+		t.Fatalf("CreateRoute: %v", err)
+	}
+	disableStream := true
+	pipeline := &Pipeline{RouteID: route.ID, Layers: []Layer{{
+		Level:    1,
+		Strategy: StrategyWeightedRound,
+		Targets: []Target{
+			{CredentialID: "cred-a", Model: "123", Weight: 3, Enabled: true, MaxConcurrent: 2},
+			{CredentialID: "cred-b", Model: "true", Enabled: false, HealthCheck: &TargetHealthCheck{Prompt: "ping", DisableStream: &disableStream}},
+		},
+	}}}
+	if _, err := configSvc.UpdatePipeline(ctx, route.ID, pipeline); err != nil {
+		t.Fatalf("UpdatePipeline: %v", err)
+	}
+
+	exported, err := configSvc.Export(ctx)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	wantJSON, _ := json.Marshal(exported)
+
+	raw, err := exportDataToYAML(exported)
+	if err != nil {
+		t.Fatalf("exportDataToYAML: %v", err)
+	}
+	if !strings.Contains(string(raw), "credential_id: cred-a") {
+		t.Fatalf("expected JSON field names in YAML, got:\n%s", raw)
+	}
+	parsed, err := exportDataFromYAML(raw)
+	if err != nil {
+		t.Fatalf("exportDataFromYAML: %v", err)
+	}
+	gotJSON, _ := json.Marshal(parsed)
+	if string(gotJSON) != string(wantJSON) {
+		t.Fatalf("YAML round trip changed the config:\n got %s\nwant %s", gotJSON, wantJSON)
+	}
+
+	// Import into an empty store and check the pipeline survived.
+	otherStore, _ := NewFileConfigStore(t.TempDir())
+	other := NewConfigService(otherStore)
+	if _, err := other.ImportYAML(ctx, raw, ImportOptions{}); err != nil {
+		t.Fatalf("ImportYAML: %v", err)
+	}
+	imported, err := other.GetPipeline(ctx, route.ID)
+	if err != nil || len(imported.Layers) != 1 || imported.Layers[0].Targets[0].Model != "123" {
+		t.Fatalf("imported pipeline = %+v, err = %v", imported, err)
+	}
+}
+
+func TestImportYAMLRejectsInvalidRoutes(t *testing.T) {
+	ctx := context.Background()
+	cfgStore, _ := NewFileConfigStore(t.TempDir())
+	configSvc := NewConfigService(cfgStore)
+
+	doc := `
+version: "1.0"
+config:
+  health_check:
+    check_interval_seconds: 30
+  routes:
+    - route: {id: good, name: good-route, enabled: true}
+      pipeline: {route_id: good, layers: []}
+    - route: {id: bad, name: "bad route!", enabled: true}
+      pipeline: {route_id: bad, layers: []}
+`
+	_, err := configSvc.ImportYAML(ctx, []byte(doc), ImportOptions{})
+	if err == nil || !strings.Contains(err.Error(), "bad route!") {
+		t.Fatalf("ImportYAML error = %v, want rejection naming the bad route", err)
+	}
+	if routes, _ := configSvc.ListRoutes(ctx); len(routes) != 0 {
+		t.Fatalf("rejected import stored %d routes", len(routes))
+	}
+}
+
+func TestImportRegeneratesIDsAndRejectsNameClashes(t *testing.T) {
+	ctx := context.Background()
+	cfgStore, _ := NewFileConfigStore(t.TempDir())
+	configSvc := NewConfigService(cfgStore)
+
+	local := &Route{ID: "shared", Name: "local-route", Enabled: true}
+	if err := configSvc.CreateRoute(ctx, local); err != nil {
+		t.Fatalf("CreateRoute: %v", err)
+	}
+
+	data := &ExportData{Config: ExportedConfig{HealthCheck: DefaultHealthCheckConfig(), Routes: []RouteWithPipeline{{
+		Route:    Route{ID: "shared", Name: "remote-route", Aliases: []string{"remote"}, Enabled: true},
+		Pipeline: Pipeline{RouteID: "shared", Layers: []Layer{{Level: 1, Targets: []Target{{ID: "t1", CredentialID: "cred", Model: "m", Enabled: true}}}}},
+	}}}}
+	mapping, err := configSvc.Import(ctx, data, ImportOptions{Merge: true, RegenerateIDs: true})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	newRouteID, newTargetID := mapping["shared"], mapping["t1"]
+	if newRouteID == "" || newRouteID == "shared" || newTargetID == "" || newTargetID == "t1" {
+		t.Fatalf("id mapping = %v, want fresh IDs for the route and target", mapping)
+	}
+	if route, err := configSvc.GetRoute(ctx, "shared"); err != nil || route.Name != "local-route" {
+		t.Fatalf("local route was overwritten: %+v, %v", route, err)
+	}
+	imported, err := configSvc.GetPipeline(ctx, newRouteID)
+	if err != nil || imported.RouteID != newRouteID || imported.Layers[0].Targets[0].ID != newTargetID {
+		t.Fatalf("imported pipeline = %+v, %v", imported, err)
+	}
+	if data.Config.Routes[0].Route.ID != "shared" {
+		t.Fatalf("Import modified the caller's data")
+	}
+
+	// Importing the same document again would duplicate its name and alias.
+	if _, err := configSvc.Import(ctx, data, ImportOptions{Merge: true, RegenerateIDs: true}); err == nil || !strings.Contains(err.Error(), "conflicts") {
+		t.Fatalf("second import error = %v, want a name conflict", err)
+	}
+	if routes, _ := configSvc.ListRoutes(ctx); len(routes) != 2 {
+		t.Fatalf("routes after rejected import = %d, want 2", len(routes))
+	}
+}
+
+func TestPartialExportImportsWithoutReplacing(t *testing.T) {
+	ctx := context.Background()
+	srcStore, _ := NewFileConfigStore(t.TempDir())
+	src := NewConfigService(srcStore)
+	if err := src.UpdateSettings(ctx, &Settings{Enabled: true, WebhookURL: "https://hooks.example.com", WebhookSecret: "s3cret"}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	var ids []string
+	for _, name := range []string{"shared", "private"} {
+		route := &Route{Name: name, Enabled: true}
+		if err := src.CreateRoute(ctx, route); err != nil {
+			t.Fatalf("CreateRoute: %v", err)
+		}
+		ids = append(ids, route.ID)
+	}
+
+	data, err := src.ExportRoutes(ctx, ids[:1])
+	if err != nil {
+		t.Fatalf("ExportRoutes: %v", err)
+	}
+	if !data.Partial || len(data.Config.Routes) != 1 || data.Config.Routes[0].Route.Name != "shared" {
+		t.Fatalf("export = %+v, want a partial export of the shared route only", data)
+	}
+	if data.Config.Settings == nil || !data.Config.Settings.Enabled || data.Config.Settings.WebhookSecret != "" {
+		t.Fatalf("settings snapshot = %+v, want it present with the secret removed", data.Config.Settings)
+	}
+	if _, err := src.ExportRoutes(ctx, []string{"missing"}); err == nil {
+		t.Fatalf("ExportRoutes accepted an unknown route")
+	}
+
+	dstStore, _ := NewFileConfigStore(t.TempDir())
+	dst := NewConfigService(dstStore)
+	local := &Route{Name: "local", Enabled: true}
+	if err := dst.CreateRoute(ctx, local); err != nil {
+		t.Fatalf("CreateRoute: %v", err)
+	}
+	if _, err := dst.Import(ctx, data, ImportOptions{}); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if routes, _ := dst.ListRoutes(ctx); len(routes) != 2 {
+		t.Fatalf("routes after partial import = %d, want the local route kept plus the shared one", len(routes))
+	}
+	if settings, _ := dst.GetSettings(ctx); settings.Enabled {
+		t.Fatalf("partial import applied the exporter's settings")
+	}
+}
@@ -0,0 +1,75 @@
+package unifiedrouting
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// credentialTestReplyMax caps the upstream reply returned by TestCredential.
+const credentialTestReplyMax = 4096
+
+// CredentialTestResult is the outcome of a one-off credential test.
+type CredentialTestResult struct {
+	CredentialID string `json:"credential_id"`
+	Model        string `json:"model"`
+	Status       string `json:"status"` // "healthy" or "unhealthy"
+	LatencyMs    int64  `json:"latency_ms,omitempty"`
+	Message      string `json:"message,omitempty"`
+	ErrorClass   string `json:"error_class,omitempty"`
+	// Error is the upstream error as returned by the provider.
+	Error string `json:"error,omitempty"`
+	// Reply is the start of the upstream reply; its first chunk when streaming.
+	Reply string `json:"reply,omitempty"`
+}
+
+// TestCredential runs a completion probe, as a health check of a target with
+// this credential and model would, but leaves target state, history and
+// events untouched and does not wait for or take the credential's probe
+// spacing slot. An empty prompt uses the configured probe prompt.
+func (h *DefaultHealthChecker) TestCredential(ctx context.Context, credentialID, model, prompt string) *CredentialTestResult {
+	target := &Target{
+		CredentialID: credentialID,
+		Model:        model,
+		HealthCheck:  &TargetHealthCheck{Prompt: prompt, Mode: HealthCheckModeCompletion},
+	}
+	result, reply, err := h.runProbe(ctx, target, false)
+	test := &CredentialTestResult{
+		CredentialID: credentialID,
+		Model:        model,
+		Status:       result.Status,
+		LatencyMs:    result.LatencyMs,
+		Message:      result.Message,
+		ErrorClass:   result.ErrorClass,
+	}
+	if err != nil {
+		test.Error = err.Error()
+	}
+	if len(reply) > credentialTestReplyMax {
+		reply = reply[:credentialTestReplyMax]
+	}
+	test.Reply = string(reply)
+	return test
+}
+
+// TestCredential checks a credential against a model before it is added to a
+// pipeline. Body: {"credential_id", "model", "prompt"?}.
+func (h *Handlers) TestCredential(c *gin.Context) {
+	var req struct {
+		CredentialID string `json:"credential_id"`
+		Model        string `json:"model"`
+		Prompt       string `json:"prompt"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.CredentialID, req.Model = strings.TrimSpace(req.CredentialID), strings.TrimSpace(req.Model)
+	if req.CredentialID == "" || req.Model == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "credential_id and model are required"})
+		return
+	}
+	c.JSON(http.StatusOK, h.healthChecker.TestCredential(c.Request.Context(), req.CredentialID, req.Model, req.Prompt))
+}
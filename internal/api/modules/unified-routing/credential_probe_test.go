@@ -0,0 +1,96 @@
+package unifiedrouting
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// replyProbeExecutor answers probes for model "ok" with a one-chunk stream and
+// fails the rest with probeStatusExecutor's statuses.
+type replyProbeExecutor struct {
+	probeStatusExecutor
+}
+
+func (e *replyProbeExecutor) ExecuteStream(ctx context.Context, auth *coreauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	if req.Model != "ok" {
+		return e.probeStatusExecutor.ExecuteStream(ctx, auth, req, opts)
+	}
+	chunks := make(chan cliproxyexecutor.StreamChunk, 1)
+	chunks <- cliproxyexecutor.StreamChunk{Payload: []byte(`data: {"choices":[{"delta":{"content":"pong"}}]}`)}
+	close(chunks)
+	return &cliproxyexecutor.StreamResult{Chunks: chunks}, nil
+}
+
+func TestTestCredentialLeavesStateAlone(t *testing.T) {
+	ctx := context.Background()
+	engine, configSvc, stateMgr := newFailoverTestEngine(t)
+	engine.authManager.RegisterExecutor(&replyProbeExecutor{probeStatusExecutor{status: map[string]int{"revoked": http.StatusUnauthorized}}})
+	checker := NewHealthChecker(configSvc, stateMgr, engine.metrics, engine.authManager, nil)
+
+	tests := []struct {
+		credential, model string
+		wantStatus        string
+		wantReply         bool
+		wantError         bool
+	}{
+		{credential: "cred", model: "ok", wantStatus: "healthy", wantReply: true},
+		{credential: "cred", model: "revoked", wantStatus: "unhealthy", wantError: true},
+		{credential: "missing", model: "ok", wantStatus: "unhealthy"},
+	}
+	for _, tt := range tests {
+		got := checker.TestCredential(ctx, tt.credential, tt.model, "ping")
+		if got.Status != tt.wantStatus || (got.Reply != "") != tt.wantReply || (got.Error != "") != tt.wantError {
+			t.Fatalf("%s/%s: got %+v", tt.credential, tt.model, got)
+		}
+	}
+
+	if history, _ := checker.GetHistory(ctx, HealthHistoryFilter{}); len(history) != 0 {
+		t.Fatalf("credential tests recorded in history: %+v", history)
+	}
+	if states, _ := stateMgr.ListTargetStates(ctx); len(states) != 0 {
+		t.Fatalf("credential tests created target states: %+v", states)
+	}
+}
+
+// slowProbeExecutor delays every probe before answering like probeStatusExecutor.
+type slowProbeExecutor struct {
+	probeStatusExecutor
+	delay time.Duration
+}
+
+func (e *slowProbeExecutor) ExecuteStream(ctx context.Context, auth *coreauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	time.Sleep(e.delay)
+	return e.probeStatusExecutor.ExecuteStream(ctx, auth, req, opts)
+}
+
+func TestTestCredentialReportsFailureLatencyAndSkipsSpacing(t *testing.T) {
+	ctx := context.Background()
+	engine, configSvc, stateMgr := newFailoverTestEngine(t)
+	engine.authManager.RegisterExecutor(&slowProbeExecutor{probeStatusExecutor{status: map[string]int{"revoked": http.StatusUnauthorized}}, 20 * time.Millisecond})
+	checker := NewHealthChecker(configSvc, stateMgr, engine.metrics, engine.authManager, nil)
+
+	cfg, _ := configSvc.GetHealthCheckConfig(ctx)
+	cfg.HealthCheckCredentialIntervalMs = 60000
+	if err := configSvc.UpdateHealthCheckConfig(ctx, cfg); err != nil {
+		t.Fatalf("update health check config: %v", err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		got := checker.TestCredential(ctx, "cred", "revoked", "ping")
+		if got.Status != "unhealthy" || got.LatencyMs < 20 {
+			t.Fatalf("failed test: got %+v, want unhealthy with latency", got)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("credential tests waited for probe spacing: %v", elapsed)
+	}
+	if slot := checker.probeSpacing.next["cred"]; !slot.IsZero() {
+		t.Fatalf("credential test reserved a probe slot until %v", slot)
+	}
+}
@@ -4,12 +4,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"math"
 	"math/rand"
+	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	log "github.com/sirupsen/logrus"
@@ -63,11 +69,23 @@ type DefaultRoutingEngine struct {
 	routeActivity *RouteActivityTracker
 	healthChecker HealthChecker
 	hookExecutor  *HookExecutor
+	shadowLogger  *logging.DetailedRequestLogger
+
+	shadowsInFlight atomic.Int32 // mirrored requests running; see maxInFlightShadows
 
 	mu            sync.RWMutex
 	routeIndex    map[string]*Route    // name -> route
 	pipelineIndex map[string]*Pipeline // routeID -> pipeline
 	rrCounters    map[string]*atomic.Uint64
+	stickyTargets map[string]*stickyEntry   // "routeID:level:clientKey" -> target
+	weighted      map[string]*weightedState // "routeID:level" -> smooth weighted round-robin state
+}
+
+// weightedState is the smooth weighted round-robin state of one layer.
+type weightedState struct {
+	current map[string]int // target ID -> current weight
+	step    uint64         // round-robin counter value of the last pick
+	last    string         // target ID picked at step
 }
 
 // NewRoutingEngine creates a new routing engine.
@@ -92,6 +110,7 @@ func NewRoutingEngine(
 		routeIndex:    make(map[string]*Route),
 		pipelineIndex: make(map[string]*Pipeline),
 		rrCounters:    make(map[string]*atomic.Uint64),
+		stickyTargets: make(map[string]*stickyEntry),
 	}
 
 	// Subscribe to config changes
@@ -220,18 +239,37 @@ func (e *DefaultRoutingEngine) Reload(ctx context.Context) error {
 		if err != nil {
 			pipeline = &Pipeline{RouteID: route.ID, Layers: []Layer{}}
 		}
-		newPipelineIndex[route.ID] = pipeline
+		newPipelineIndex[route.ID] = withoutDisabledLayers(pipeline)
 	}
 
 	e.mu.Lock()
 	e.routeIndex = newRouteIndex
 	e.pipelineIndex = newPipelineIndex
+	// Targets and weights may have changed; start every layer's rotation afresh.
+	e.weighted = nil
 	e.mu.Unlock()
 
 	log.Debugf("unified routing engine reloaded: %d routes", len(routes))
 	return nil
 }
 
+// withoutDisabledLayers returns pipeline without its disabled layers, so every
+// routing decision sees only the layers in service.
+func withoutDisabledLayers(pipeline *Pipeline) *Pipeline {
+	layers := make([]Layer, 0, len(pipeline.Layers))
+	for _, layer := range pipeline.Layers {
+		if !layer.Disabled {
+			layers = append(layers, layer)
+		}
+	}
+	if len(layers) == len(pipeline.Layers) {
+		return pipeline
+	}
+	serving := *pipeline
+	serving.Layers = layers
+	return &serving
+}
+
 // SelectTarget selects the next target from a layer based on the strategy.
 // AdvanceRoundRobin increments the round-robin counter for a layer.
 // Call once per new request before entering the retry loop;
@@ -253,11 +291,14 @@ func (e *DefaultRoutingEngine) AdvanceRoundRobin(routeID string, level int) {
 func (e *DefaultRoutingEngine) SelectTarget(ctx context.Context, routeID string, layer *Layer) (*Target, error) {
 	availableTargets := make([]Target, 0)
 	for _, target := range layer.Targets {
-		if !target.Enabled {
+		if !target.Serves() {
 			continue
 		}
 		state, _ := e.stateMgr.GetTargetState(ctx, target.ID)
-		if state != nil && state.Status != StatusHealthy {
+		if state != nil && !state.Status.IsRoutable() {
+			continue
+		}
+		if e.atCapacity(ctx, &target, state) {
 			continue
 		}
 		availableTargets = append(availableTargets, target)
@@ -272,13 +313,19 @@ func (e *DefaultRoutingEngine) SelectTarget(ctx context.Context, routeID string,
 	case StrategyRoundRobin, "":
 		selected = e.selectRoundRobin(routeID, layer.Level, availableTargets)
 	case StrategyWeightedRound:
-		selected = e.selectWeightedRoundRobin(routeID, layer.Level, availableTargets)
+		selected = e.selectWeightedRoundRobin(ctx, routeID, layer.Level, availableTargets)
 	case StrategyRandom:
 		selected = e.selectRandom(availableTargets)
 	case StrategyFirstAvailable:
 		selected = &availableTargets[0]
 	case StrategyLeastConn:
 		selected = e.selectLeastConnections(ctx, availableTargets)
+	case StrategyLeastLatency:
+		selected = e.selectLeastLatency(ctx, availableTargets)
+	case StrategyBestScore:
+		selected = e.selectBestScore(ctx, availableTargets)
+	case StrategySticky:
+		selected = e.selectSticky(ctx, routeID, layer, availableTargets)
 	default:
 		selected = e.selectRoundRobin(routeID, layer.Level, availableTargets)
 	}
@@ -304,47 +351,96 @@ func (e *DefaultRoutingEngine) selectRoundRobin(routeID string, level int, targe
 	return &targets[int(val-1)%len(targets)]
 }
 
-func (e *DefaultRoutingEngine) selectWeightedRoundRobin(routeID string, level int, targets []Target) *Target {
-	// Calculate total weight
+// selectWeightedRoundRobin picks a target by smooth weighted round-robin, as
+// in nginx: every available target's weight is added to its current weight, the
+// highest current weight wins and the total is subtracted from it. A 3:1 split
+// thus interleaves as A A B A instead of A A A B. Targets that are not available
+// keep their current weight and the rest share the picks in proportion to their
+// weights. The layer's state steps once per round-robin counter value, so
+// repeated selection within one request returns the same target while it stays
+// available.
+func (e *DefaultRoutingEngine) selectWeightedRoundRobin(ctx context.Context, routeID string, level int, targets []Target) *Target {
+	now := time.Now()
+	weights := make([]int, len(targets))
 	totalWeight := 0
-	for _, t := range targets {
-		weight := t.Weight
-		if weight <= 0 {
-			weight = 1
-		}
-		totalWeight += weight
+	for i := range targets {
+		weights[i] = e.effectiveWeight(ctx, &targets[i], now)
+		totalWeight += weights[i]
 	}
 
-	key := fmt.Sprintf("%s:%d:weighted", routeID, level)
+	key := fmt.Sprintf("%s:%d", routeID, level)
 
 	e.mu.Lock()
+	defer e.mu.Unlock()
 	counter, ok := e.rrCounters[key]
 	if !ok {
 		counter = &atomic.Uint64{}
 		e.rrCounters[key] = counter
 	}
-	e.mu.Unlock()
+	step := counter.Load()
 
-	val := counter.Load()
-	if val == 0 {
-		val = 1
+	if e.weighted == nil {
+		e.weighted = make(map[string]*weightedState)
+	}
+	state, ok := e.weighted[key]
+	if !ok {
+		state = &weightedState{current: make(map[string]int)}
+		e.weighted[key] = state
+	}
+	if state.step == step && state.last != "" {
+		for i := range targets {
+			if targets[i].ID == state.last {
+				return &targets[i]
+			}
+		}
 	}
-	idx := int(val-1) % totalWeight
 
-	// Find the target
-	cumulative := 0
+	best := 0
 	for i := range targets {
-		weight := targets[i].Weight
-		if weight <= 0 {
-			weight = 1
-		}
-		cumulative += weight
-		if idx < cumulative {
-			return &targets[i]
+		state.current[targets[i].ID] += weights[i]
+		if state.current[targets[i].ID] > state.current[targets[best].ID] {
+			best = i
 		}
 	}
+	state.current[targets[best].ID] -= totalWeight
+	state.step = step
+	state.last = targets[best].ID
+	return &targets[best]
+}
+
+// effectiveWeight returns t's weight for weighted round-robin at now. A canary
+// target ramps linearly from CanaryStartWeight to CanaryTargetWeight (or Weight)
+// over CanaryRampSeconds from its CreatedAt, restarting after a run of
+// consecutive failures (TargetState.CanaryRampFrom).
+func (e *DefaultRoutingEngine) effectiveWeight(ctx context.Context, t *Target, now time.Time) int {
+	weight := t.Weight
+	if weight <= 0 {
+		weight = 1
+	}
+	if !t.IsCanary() || t.CreatedAt == nil {
+		return weight
+	}
+	if t.CanaryTargetWeight > 0 {
+		weight = t.CanaryTargetWeight
+	}
 
-	return &targets[0]
+	start := *t.CreatedAt
+	if state, _ := e.stateMgr.GetTargetState(ctx, t.ID); state != nil && state.CanaryRampFrom != nil && state.CanaryRampFrom.After(start) {
+		start = *state.CanaryRampFrom
+	}
+	ramp := time.Duration(t.CanaryRampSeconds) * time.Second
+	elapsed := now.Sub(start)
+	if elapsed >= ramp {
+		return weight
+	}
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	ramped := t.CanaryStartWeight + int(int64(weight-t.CanaryStartWeight)*int64(elapsed)/int64(ramp))
+	if ramped < 1 {
+		ramped = 1
+	}
+	return ramped
 }
 
 func (e *DefaultRoutingEngine) selectRandom(targets []Target) *Target {
@@ -375,6 +471,148 @@ func (e *DefaultRoutingEngine) selectLeastConnections(ctx context.Context, targe
 	return selected
 }
 
+// latencyDecayHalfLife is how quickly a target's recorded latency stops counting
+// against it: the average is halved for every half-life since its last success,
+// so a target that was slow an hour ago gets sampled again.
+const latencyDecayHalfLife = 15 * time.Minute
+
+// effectiveLatencyMs returns the target's average latency decayed by the time
+// since its last success. Targets without latency history count as 0 so they
+// are tried first.
+func effectiveLatencyMs(state *TargetState, now time.Time) float64 {
+	if state == nil || state.AvgLatencyMs <= 0 || state.LastSuccessAt == nil {
+		return 0
+	}
+	age := now.Sub(*state.LastSuccessAt)
+	if age <= 0 {
+		return state.AvgLatencyMs
+	}
+	return state.AvgLatencyMs * math.Pow(0.5, float64(age)/float64(latencyDecayHalfLife))
+}
+
+func (e *DefaultRoutingEngine) selectLeastLatency(ctx context.Context, targets []Target) *Target {
+	now := time.Now()
+	minLatency := -1.0
+	var selected *Target
+
+	for i := range targets {
+		state, _ := e.stateMgr.GetTargetState(ctx, targets[i].ID)
+		latency := effectiveLatencyMs(state, now)
+		if minLatency < 0 || latency < minLatency {
+			minLatency = latency
+			selected = &targets[i]
+		}
+	}
+
+	if selected == nil {
+		return &targets[0]
+	}
+	return selected
+}
+
+// stickyMaxEntries bounds the sticky mapping table so abandoned client keys
+// cannot grow it without limit. When it is full the least recently used tenth
+// is evicted, leaving active clients on their targets.
+const stickyMaxEntries = 10000
+
+// stickyEntry is the target a client is pinned to on a sticky layer.
+type stickyEntry struct {
+	targetID string
+	lastUsed atomic.Int64 // unix nanoseconds of the last request routed by this entry
+}
+
+// stickyClientKey returns the key that identifies the client for sticky routing:
+// the layer's StickyKeyHeader value when present, else the masked API key.
+// It returns "" when neither is available.
+func stickyClientKey(ctx context.Context, layer *Layer) string {
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	if ginCtx == nil {
+		return ""
+	}
+	if layer.StickyKeyHeader != "" && ginCtx.Request != nil {
+		if v := strings.TrimSpace(ginCtx.GetHeader(layer.StickyKeyHeader)); v != "" {
+			return "h:" + v
+		}
+	}
+	if apiKey := ginCtx.GetString("apiKey"); apiKey != "" {
+		return "k:" + logging.MaskAPIKey(apiKey)
+	}
+	return ""
+}
+
+// selectSticky keeps a client on the target it last used in this layer. When that
+// target is no longer among the healthy targets, the client key is hashed onto the
+// healthy set so the same client lands on the same replacement. Requests without a
+// client key fall back to round-robin.
+func (e *DefaultRoutingEngine) selectSticky(ctx context.Context, routeID string, layer *Layer, targets []Target) *Target {
+	clientKey := stickyClientKey(ctx, layer)
+	if clientKey == "" {
+		return e.selectRoundRobin(routeID, layer.Level, targets)
+	}
+	key := fmt.Sprintf("%s:%d:%s", routeID, layer.Level, clientKey)
+
+	e.mu.RLock()
+	remembered := e.stickyTargets[key]
+	e.mu.RUnlock()
+	if remembered != nil {
+		for i := range targets {
+			if targets[i].ID == remembered.targetID {
+				remembered.lastUsed.Store(time.Now().UnixNano())
+				return &targets[i]
+			}
+		}
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(clientKey))
+	selected := &targets[int(h.Sum32()%uint32(len(targets)))]
+	e.setStickyTarget(key, selected.ID)
+	return selected
+}
+
+// rememberStickyTarget records that targetID served the client on a sticky layer,
+// so failover onto another target carries over to the client's next request.
+func (e *DefaultRoutingEngine) rememberStickyTarget(ctx context.Context, routeID string, layer *Layer, targetID string) {
+	if layer.Strategy != StrategySticky {
+		return
+	}
+	clientKey := stickyClientKey(ctx, layer)
+	if clientKey == "" {
+		return
+	}
+	e.setStickyTarget(fmt.Sprintf("%s:%d:%s", routeID, layer.Level, clientKey), targetID)
+}
+
+func (e *DefaultRoutingEngine) setStickyTarget(key, targetID string) {
+	entry := &stickyEntry{targetID: targetID}
+	entry.lastUsed.Store(time.Now().UnixNano())
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stickyTargets == nil {
+		e.stickyTargets = make(map[string]*stickyEntry)
+	}
+	if _, ok := e.stickyTargets[key]; !ok && len(e.stickyTargets) >= stickyMaxEntries {
+		e.evictStickyTargets(stickyMaxEntries / 10)
+	}
+	e.stickyTargets[key] = entry
+}
+
+// evictStickyTargets removes the n least recently used sticky entries. The
+// caller holds e.mu.
+func (e *DefaultRoutingEngine) evictStickyTargets(n int) {
+	keys := make([]string, 0, len(e.stickyTargets))
+	for key := range e.stickyTargets {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return e.stickyTargets[keys[i]].lastUsed.Load() < e.stickyTargets[keys[j]].lastUsed.Load()
+	})
+	for _, key := range keys[:min(n, len(keys))] {
+		delete(e.stickyTargets, key)
+	}
+}
+
 // failoverFirstChunkTimeout is the maximum time to wait for the first stream chunk
 // during failover. If the target doesn't return any data within this period,
 // it is considered unresponsive and the next target is tried.
@@ -387,29 +625,126 @@ const failoverNonStreamTimeout = 45 * time.Second
 
 // filterAvailableTargets returns enabled, healthy targets from a layer.
 func (e *DefaultRoutingEngine) filterAvailableTargets(ctx context.Context, layer *Layer) []Target {
+	available, _ := e.filterTargetsWithCapacity(ctx, layer)
+	return available
+}
+
+// filterTargetsWithCapacity returns the enabled, healthy targets of a layer that
+// have a free concurrency slot, and how many healthy targets were skipped for
+// being at their MaxConcurrent limit.
+func (e *DefaultRoutingEngine) filterTargetsWithCapacity(ctx context.Context, layer *Layer) ([]Target, int) {
 	available := make([]Target, 0, len(layer.Targets))
+	var warming []Target
+	saturated := 0
 	for _, target := range layer.Targets {
-		if !target.Enabled {
+		if !target.Serves() {
 			continue
 		}
 		state, _ := e.stateMgr.GetTargetState(ctx, target.ID)
-		if state != nil && state.Status != StatusHealthy {
+		if state != nil && !state.Status.IsRoutable() &&
+			(state.Status == StatusMisconfigured || state.Status == StatusDraining || !cooldownBypassed(ctx)) {
+			continue
+		}
+		if e.atCapacity(ctx, &target, state) {
+			saturated++
+			continue
+		}
+		if state != nil && state.WarmingUp {
+			warming = append(warming, target)
 			continue
 		}
 		available = append(available, target)
 	}
-	return available
+	// Targets still warming up serve only when nothing else in the layer can.
+	if len(available) == 0 {
+		available = warming
+	}
+	return available, saturated
+}
+
+// atCapacity reports whether the target already serves as many requests as slotLimit allows.
+func (e *DefaultRoutingEngine) atCapacity(ctx context.Context, target *Target, state *TargetState) bool {
+	limit := e.slotLimit(ctx, target, state)
+	return limit > 0 && state != nil && state.InFlight >= int64(limit)
+}
+
+// slotLimit returns the in-flight cap for a target (0 = unlimited): its MaxConcurrent,
+// lowered while half-open to the number of probe requests still needed.
+func (e *DefaultRoutingEngine) slotLimit(ctx context.Context, target *Target, state *TargetState) int {
+	limit := target.MaxConcurrent
+	if state == nil || state.Status != StatusHalfOpen {
+		return limit
+	}
+	remaining := 1
+	if e.configSvc != nil {
+		if cfg, _ := e.configSvc.GetHealthCheckConfig(ctx); cfg != nil && cfg.HalfOpenRequests-state.HalfOpenSuccesses > 1 {
+			remaining = cfg.HalfOpenRequests - state.HalfOpenSuccesses
+		}
+	}
+	if limit <= 0 || remaining < limit {
+		limit = remaining
+	}
+	return limit
+}
+
+// concurrencyWaitTimeout is how long a request waits for a slot when every healthy
+// target in a layer is at its concurrency limit, before falling to the next layer.
+const concurrencyWaitTimeout = 2 * time.Second
+
+// concurrencyPollInterval is how often a waiting request rechecks for a free slot.
+const concurrencyPollInterval = 50 * time.Millisecond
+
+// waitForAvailableTargets returns the layer's available targets. If none are
+// available only because healthy targets are at capacity, it waits up to
+// concurrencyWaitTimeout for a slot to free up.
+func (e *DefaultRoutingEngine) waitForAvailableTargets(ctx context.Context, layer *Layer) []Target {
+	available, saturated := e.filterTargetsWithCapacity(ctx, layer)
+	if len(available) > 0 || saturated == 0 {
+		return available
+	}
+
+	deadline := time.NewTimer(concurrencyWaitTimeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(concurrencyPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-deadline.C:
+			return nil
+		case <-ticker.C:
+			if available, _ = e.filterTargetsWithCapacity(ctx, layer); len(available) > 0 {
+				return available
+			}
+		}
+	}
+}
+
+// acquireSlot reserves an in-flight slot on target and returns a release func
+// that is safe to call more than once. ok is false when the target is at capacity.
+func (e *DefaultRoutingEngine) acquireSlot(ctx context.Context, target *Target) (release func(), ok bool) {
+	state, _ := e.stateMgr.GetTargetState(ctx, target.ID)
+	if !e.stateMgr.TryAcquire(ctx, target.ID, e.slotLimit(ctx, target, state)) {
+		return nil, false
+	}
+	var once sync.Once
+	targetID := target.ID
+	return func() {
+		once.Do(func() { e.stateMgr.Release(context.Background(), targetID) })
+	}, true
 }
 
 // selectStartIndex determines the starting index in the available targets
 // slice based on the layer's load-balancing strategy. This is called once
 // per layer; the failover loop then iterates sequentially from this position.
-func (e *DefaultRoutingEngine) selectStartIndex(routeID string, level int, strategy LoadStrategy, ctx context.Context, targets []Target) int {
+func (e *DefaultRoutingEngine) selectStartIndex(routeID string, layer *Layer, ctx context.Context, targets []Target) int {
 	if len(targets) == 0 {
 		return 0
 	}
+	level := layer.Level
 
-	switch strategy {
+	switch layer.Strategy {
 	case StrategyRoundRobin, "":
 		key := fmt.Sprintf("%s:%d", routeID, level)
 		e.mu.Lock()
@@ -426,7 +761,7 @@ func (e *DefaultRoutingEngine) selectStartIndex(routeID string, level int, strat
 		return int(val-1) % len(targets)
 
 	case StrategyWeightedRound:
-		selected := e.selectWeightedRoundRobin(routeID, level, targets)
+		selected := e.selectWeightedRoundRobin(ctx, routeID, level, targets)
 		for i := range targets {
 			if targets[i].ID == selected.ID {
 				return i
@@ -449,6 +784,33 @@ func (e *DefaultRoutingEngine) selectStartIndex(routeID string, level int, strat
 		}
 		return 0
 
+	case StrategyLeastLatency:
+		selected := e.selectLeastLatency(ctx, targets)
+		for i := range targets {
+			if targets[i].ID == selected.ID {
+				return i
+			}
+		}
+		return 0
+
+	case StrategyBestScore:
+		selected := e.selectBestScore(ctx, targets)
+		for i := range targets {
+			if targets[i].ID == selected.ID {
+				return i
+			}
+		}
+		return 0
+
+	case StrategySticky:
+		selected := e.selectSticky(ctx, routeID, layer, targets)
+		for i := range targets {
+			if targets[i].ID == selected.ID {
+				return i
+			}
+		}
+		return 0
+
 	default:
 		key := fmt.Sprintf("%s:%d", routeID, level)
 		e.mu.Lock()
@@ -475,6 +837,10 @@ func (e *DefaultRoutingEngine) ExecuteWithFailover(
 	if decision == nil || decision.Pipeline == nil {
 		return fmt.Errorf("invalid routing decision")
 	}
+	ctx, decision, err := e.applyHeaderRouting(ctx, decision)
+	if err != nil {
+		return err
+	}
 
 	e.routeActivity.Mark(decision.RouteID)
 	if e.healthChecker != nil {
@@ -484,18 +850,42 @@ func (e *DefaultRoutingEngine) ExecuteWithFailover(
 	traceBuilder := NewTraceBuilder(decision.RouteID, decision.RouteName, decision.InputModel)
 	startTime := time.Now()
 
+	timeout := e.requestTimeout(ctx, decision.Pipeline)
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = startTime.Add(timeout)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	attempts, maxAttempts := 0, decision.Pipeline.MaxAttempts
+	var lastErr error
+	defer func() { noteRoutingAttempts(ctx, attempts, maxAttempts) }()
+	noteRoutingTarget(ctx, decision, 0, nil)
+
 	// Try each layer in order
 	for layerIdx, layer := range decision.Pipeline.Layers {
+		if pastDeadline(deadline) {
+			return e.routeTimedOut(decision, traceBuilder, startTime, timeout)
+		}
 		e.AdvanceRoundRobin(decision.RouteID, layer.Level)
 
-		availableTargets := e.filterAvailableTargets(ctx, &layer)
-		idx := e.selectStartIndex(decision.RouteID, layer.Level, layer.Strategy, ctx, availableTargets)
+		availableTargets := e.waitForAvailableTargets(ctx, &layer)
+		idx := e.selectStartIndex(decision.RouteID, &layer, ctx, availableTargets)
 
 		for len(availableTargets) > 0 {
+			if pastDeadline(deadline) {
+				return e.routeTimedOut(decision, traceBuilder, startTime, timeout)
+			}
+			if maxAttempts > 0 && attempts >= maxAttempts {
+				return e.attemptsExhausted(decision, traceBuilder, startTime, attempts, maxAttempts, lastErr)
+			}
 			if idx >= len(availableTargets) {
 				idx = 0
 			}
 			target := availableTargets[idx]
+			e.stateMgr.MarkUsed(ctx, target.ID)
 
 			auth, authErr := e.findAuth(target.CredentialID)
 			if authErr != nil {
@@ -507,14 +897,25 @@ func (e *DefaultRoutingEngine) ExecuteWithFailover(
 				continue
 			}
 
+			releaseSlot, acquired := e.acquireSlot(ctx, &target)
+			if !acquired {
+				// Filled up since the layer was filtered; try the next target.
+				availableTargets = append(availableTargets[:idx], availableTargets[idx+1:]...)
+				continue
+			}
+
+			attempts++
+			noteRoutingTarget(ctx, decision, layer.Level, &target)
 			attemptStart := time.Now()
-			execCtx, execCancel := context.WithTimeout(ctx, failoverNonStreamTimeout)
+			execCtx, execCancel := context.WithTimeout(withTargetUpstream(ctx, e.configSvc, &target), failoverNonStreamTimeout)
 			err := executeFunc(execCtx, auth, target.Model)
 			execCancel()
+			releaseSlot()
 			attemptLatency := time.Since(attemptStart).Milliseconds()
 
 			if err == nil {
-				e.stateMgr.RecordSuccess(ctx, target.ID, time.Since(attemptStart))
+				e.recordTargetSuccess(ctx, target.ID, time.Since(attemptStart))
+				e.rememberStickyTarget(ctx, decision.RouteID, &layer, target.ID)
 				traceBuilder.AddAttempt(layer.Level, target.ID, target.CredentialID, target.Model).
 					Success(attemptLatency)
 
@@ -529,7 +930,14 @@ func (e *DefaultRoutingEngine) ExecuteWithFailover(
 				return nil
 			}
 
-			errClass := ClassifyError(err)
+			if pastDeadline(deadline) {
+				// The route ran out of time; the target is not to blame.
+				traceBuilder.AddAttempt(layer.Level, target.ID, target.CredentialID, target.Model).
+					Failed(err.Error(), attemptLatency)
+				return e.routeTimedOut(decision, traceBuilder, startTime, timeout)
+			}
+
+			errClass, providerCooldown := classifyProviderError(auth.Provider, err)
 			statusCode := extractStatusCode(err)
 
 			if errClass == ErrorClassNonRetryable {
@@ -556,20 +964,23 @@ func (e *DefaultRoutingEngine) ExecuteWithFailover(
 				return err
 			}
 
-			e.stateMgr.RecordFailure(ctx, target.ID, err.Error())
+			lastErr = err
+			e.recordAttemptFailure(ctx, target.ID, err.Error(), errClass, err, providerCooldown)
 			traceBuilder.AddAttempt(layer.Level, target.ID, target.CredentialID, target.Model).
 				Failed(err.Error(), attemptLatency)
-			e.stateMgr.StartCooldownTimed(ctx, target.ID)
-			e.healthChecker.ScheduleTargetCheck(target.ID)
-			e.metrics.RecordEvent(&RoutingEvent{
-				Type:     EventCooldownStarted,
-				RouteID:  decision.RouteID,
-				TargetID: target.ID,
-				Details: map[string]any{
-					"reason":      err.Error(),
-					"error_class": errClass.String(),
-				},
-			})
+			if !e.markMisconfigured(ctx, decision, &target, err) {
+				e.stateMgr.StartCooldownTimed(ctx, target.ID)
+				e.healthChecker.ScheduleTargetCheck(target.ID)
+				e.metrics.RecordEvent(&RoutingEvent{
+					Type:     EventCooldownStarted,
+					RouteID:  decision.RouteID,
+					TargetID: target.ID,
+					Details: map[string]any{
+						"reason":      err.Error(),
+						"error_class": errClass.String(),
+					},
+				})
+			}
 
 			e.fireHook(HookAttemptEvent{
 				RouteID: decision.RouteID, RouteName: decision.RouteName,
@@ -615,6 +1026,10 @@ func (e *DefaultRoutingEngine) ExecuteStreamWithFailover(
 	if decision == nil || decision.Pipeline == nil {
 		return nil, fmt.Errorf("invalid routing decision")
 	}
+	ctx, decision, err := e.applyHeaderRouting(ctx, decision)
+	if err != nil {
+		return nil, err
+	}
 
 	e.routeActivity.Mark(decision.RouteID)
 	if e.healthChecker != nil {
@@ -624,18 +1039,41 @@ func (e *DefaultRoutingEngine) ExecuteStreamWithFailover(
 	traceBuilder := NewTraceBuilder(decision.RouteID, decision.RouteName, decision.InputModel)
 	startTime := time.Now()
 
+	// The route timeout bounds failover only; a stream that has started is
+	// not cut off, so the deadline is enforced on the wait for the first chunk.
+	timeout := e.requestTimeout(ctx, decision.Pipeline)
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = startTime.Add(timeout)
+	}
+
+	attempts, maxAttempts := 0, decision.Pipeline.MaxAttempts
+	var lastErr error
+	defer func() { noteRoutingAttempts(ctx, attempts, maxAttempts) }()
+	noteRoutingTarget(ctx, decision, 0, nil)
+
 	// Try each layer in order
 	for layerIdx, layer := range decision.Pipeline.Layers {
+		if pastDeadline(deadline) {
+			return nil, e.routeTimedOut(decision, traceBuilder, startTime, timeout)
+		}
 		e.AdvanceRoundRobin(decision.RouteID, layer.Level)
 
-		availableTargets := e.filterAvailableTargets(ctx, &layer)
-		idx := e.selectStartIndex(decision.RouteID, layer.Level, layer.Strategy, ctx, availableTargets)
+		availableTargets := e.waitForAvailableTargets(ctx, &layer)
+		idx := e.selectStartIndex(decision.RouteID, &layer, ctx, availableTargets)
 
 		for len(availableTargets) > 0 {
+			if pastDeadline(deadline) {
+				return nil, e.routeTimedOut(decision, traceBuilder, startTime, timeout)
+			}
+			if maxAttempts > 0 && attempts >= maxAttempts {
+				return nil, e.attemptsExhausted(decision, traceBuilder, startTime, attempts, maxAttempts, lastErr)
+			}
 			if idx >= len(availableTargets) {
 				idx = 0
 			}
 			target := availableTargets[idx]
+			e.stateMgr.MarkUsed(ctx, target.ID)
 
 			auth, authErr := e.findAuth(target.CredentialID)
 			if authErr != nil {
@@ -647,6 +1085,15 @@ func (e *DefaultRoutingEngine) ExecuteStreamWithFailover(
 				continue
 			}
 
+			releaseSlot, acquired := e.acquireSlot(ctx, &target)
+			if !acquired {
+				// Filled up since the layer was filtered; try the next target.
+				availableTargets = append(availableTargets[:idx], availableTargets[idx+1:]...)
+				continue
+			}
+
+			attempts++
+			noteRoutingTarget(ctx, decision, layer.Level, &target)
 			attemptStart := time.Now()
 
 			type streamConnResult struct {
@@ -654,12 +1101,13 @@ func (e *DefaultRoutingEngine) ExecuteStreamWithFailover(
 				err    error
 			}
 			connCh := make(chan streamConnResult, 1)
+			streamCtx := withTargetUpstream(ctx, e.configSvc, &target)
 			go func() {
-				c, e := executeFunc(ctx, auth, target.Model)
+				c, e := executeFunc(streamCtx, auth, target.Model)
 				connCh <- streamConnResult{c, e}
 			}()
 
-			firstChunkTimer := time.NewTimer(failoverFirstChunkTimeout)
+			firstChunkTimer := time.NewTimer(firstChunkWait(deadline))
 
 			var chunks <-chan cliproxyexecutor.StreamChunk
 			var connTimedOut bool
@@ -667,7 +1115,8 @@ func (e *DefaultRoutingEngine) ExecuteStreamWithFailover(
 			case res := <-connCh:
 				if res.err != nil {
 					firstChunkTimer.Stop()
-					errClass := ClassifyError(res.err)
+					releaseSlot()
+					errClass, providerCooldown := classifyProviderError(auth.Provider, res.err)
 
 					if errClass == ErrorClassNonRetryable {
 						connLatency := time.Since(attemptStart).Milliseconds()
@@ -688,21 +1137,24 @@ func (e *DefaultRoutingEngine) ExecuteStreamWithFailover(
 					}
 
 					connLatency := time.Since(attemptStart).Milliseconds()
-					e.stateMgr.RecordFailure(ctx, target.ID, res.err.Error())
+					lastErr = res.err
+					e.recordAttemptFailure(ctx, target.ID, res.err.Error(), errClass, res.err, providerCooldown)
 					traceBuilder.AddAttempt(layer.Level, target.ID, target.CredentialID, target.Model).
 						Failed(res.err.Error(), connLatency)
-					e.stateMgr.StartCooldownTimed(ctx, target.ID)
-					e.healthChecker.ScheduleTargetCheck(target.ID)
-					e.metrics.RecordEvent(&RoutingEvent{
-						Type:     EventCooldownStarted,
-						RouteID:  decision.RouteID,
-						TargetID: target.ID,
-						Details: map[string]any{
-							"reason":      res.err.Error(),
-							"error_class": errClass.String(),
-							"latency_ms":  time.Since(attemptStart).Milliseconds(),
-						},
-					})
+					if !e.markMisconfigured(ctx, decision, &target, res.err) {
+						e.stateMgr.StartCooldownTimed(ctx, target.ID)
+						e.healthChecker.ScheduleTargetCheck(target.ID)
+						e.metrics.RecordEvent(&RoutingEvent{
+							Type:     EventCooldownStarted,
+							RouteID:  decision.RouteID,
+							TargetID: target.ID,
+							Details: map[string]any{
+								"reason":      res.err.Error(),
+								"error_class": errClass.String(),
+								"latency_ms":  time.Since(attemptStart).Milliseconds(),
+							},
+						})
+					}
 					availableTargets = append(availableTargets[:idx], availableTargets[idx+1:]...)
 					continue
 				}
@@ -711,10 +1163,25 @@ func (e *DefaultRoutingEngine) ExecuteStreamWithFailover(
 				connTimedOut = true
 			}
 
+			if connTimedOut && pastDeadline(deadline) {
+				traceBuilder.AddAttempt(layer.Level, target.ID, target.CredentialID, target.Model).
+					Failed("route request timeout", time.Since(attemptStart).Milliseconds())
+				go func() {
+					defer releaseSlot()
+					res := <-connCh
+					if res.chunks != nil {
+						for range res.chunks {
+						}
+					}
+				}()
+				return nil, e.routeTimedOut(decision, traceBuilder, startTime, timeout)
+			}
+
 			if connTimedOut {
 				attemptLatency := time.Since(attemptStart).Milliseconds()
 				errMsg := fmt.Sprintf("connection timeout (%s)", failoverFirstChunkTimeout)
-				e.stateMgr.RecordFailure(ctx, target.ID, errMsg)
+				lastErr = errors.New(errMsg)
+				e.stateMgr.RecordFailure(ctx, target.ID, errMsg, 0)
 				traceBuilder.AddAttempt(layer.Level, target.ID, target.CredentialID, target.Model).
 					Failed(errMsg, attemptLatency)
 				e.stateMgr.StartCooldownTimed(ctx, target.ID)
@@ -729,6 +1196,7 @@ func (e *DefaultRoutingEngine) ExecuteStreamWithFailover(
 					},
 				})
 				go func() {
+					defer releaseSlot()
 					res := <-connCh
 					if res.chunks != nil {
 						for range res.chunks {
@@ -747,8 +1215,19 @@ func (e *DefaultRoutingEngine) ExecuteStreamWithFailover(
 				firstChunkTimer.Stop()
 			case <-firstChunkTimer.C:
 				attemptLatency := time.Since(attemptStart).Milliseconds()
+				if pastDeadline(deadline) {
+					traceBuilder.AddAttempt(layer.Level, target.ID, target.CredentialID, target.Model).
+						Failed("route request timeout", attemptLatency)
+					go func() {
+						defer releaseSlot()
+						for range chunks {
+						}
+					}()
+					return nil, e.routeTimedOut(decision, traceBuilder, startTime, timeout)
+				}
 				errMsg := fmt.Sprintf("first chunk timeout (%s)", failoverFirstChunkTimeout)
-				e.stateMgr.RecordFailure(ctx, target.ID, errMsg)
+				lastErr = errors.New(errMsg)
+				e.stateMgr.RecordFailure(ctx, target.ID, errMsg, 0)
 				traceBuilder.AddAttempt(layer.Level, target.ID, target.CredentialID, target.Model).
 					Failed(errMsg, attemptLatency)
 				e.stateMgr.StartCooldownTimed(ctx, target.ID)
@@ -763,6 +1242,7 @@ func (e *DefaultRoutingEngine) ExecuteStreamWithFailover(
 					},
 				})
 				go func() {
+					defer releaseSlot()
 					for range chunks {
 					}
 				}()
@@ -771,8 +1251,10 @@ func (e *DefaultRoutingEngine) ExecuteStreamWithFailover(
 			}
 
 			if !ok {
+				releaseSlot()
 				attemptLatency := time.Since(attemptStart).Milliseconds()
-				e.stateMgr.RecordFailure(ctx, target.ID, "stream closed without data")
+				lastErr = errors.New("stream closed without data")
+				e.stateMgr.RecordFailure(ctx, target.ID, "stream closed without data", 0)
 				traceBuilder.AddAttempt(layer.Level, target.ID, target.CredentialID, target.Model).
 					Failed("stream closed without data", attemptLatency)
 				e.stateMgr.StartCooldownTimed(ctx, target.ID)
@@ -791,10 +1273,11 @@ func (e *DefaultRoutingEngine) ExecuteStreamWithFailover(
 			}
 
 			if firstChunk.Err != nil {
-				chunkErrClass := ClassifyError(firstChunk.Err)
+				chunkErrClass, providerCooldown := classifyProviderError(auth.Provider, firstChunk.Err)
 				errMsg := firstChunk.Err.Error()
 
 				go func() {
+					defer releaseSlot()
 					for range chunks {
 					}
 				}()
@@ -818,25 +1301,30 @@ func (e *DefaultRoutingEngine) ExecuteStreamWithFailover(
 					return nil, firstChunk.Err
 				}
 
-				e.stateMgr.RecordFailure(ctx, target.ID, errMsg)
+				lastErr = firstChunk.Err
+				e.recordAttemptFailure(ctx, target.ID, errMsg, chunkErrClass, firstChunk.Err, providerCooldown)
 				traceBuilder.AddAttempt(layer.Level, target.ID, target.CredentialID, target.Model).
 					Failed(errMsg, attemptLatency)
-				e.stateMgr.StartCooldownTimed(ctx, target.ID)
-				e.healthChecker.ScheduleTargetCheck(target.ID)
-				e.metrics.RecordEvent(&RoutingEvent{
-					Type:     EventCooldownStarted,
-					RouteID:  decision.RouteID,
-					TargetID: target.ID,
-					Details: map[string]any{
-						"reason":      errMsg,
-						"error_class": chunkErrClass.String(),
-						"latency_ms":  attemptLatency,
-					},
-				})
+				if !e.markMisconfigured(ctx, decision, &target, firstChunk.Err) {
+					e.stateMgr.StartCooldownTimed(ctx, target.ID)
+					e.healthChecker.ScheduleTargetCheck(target.ID)
+					e.metrics.RecordEvent(&RoutingEvent{
+						Type:     EventCooldownStarted,
+						RouteID:  decision.RouteID,
+						TargetID: target.ID,
+						Details: map[string]any{
+							"reason":      errMsg,
+							"error_class": chunkErrClass.String(),
+							"latency_ms":  attemptLatency,
+						},
+					})
+				}
 				availableTargets = append(availableTargets[:idx], availableTargets[idx+1:]...)
 				continue
 			}
 
+			e.rememberStickyTarget(ctx, decision.RouteID, &layer, target.ID)
+
 			outputChan := make(chan cliproxyexecutor.StreamChunk, 100)
 			outputChan <- firstChunk
 
@@ -845,6 +1333,7 @@ func (e *DefaultRoutingEngine) ExecuteStreamWithFailover(
 
 			go func() {
 				defer close(outputChan)
+				defer releaseSlot()
 
 				var streamErr error
 				for chunk := range chunks {
@@ -858,7 +1347,7 @@ func (e *DefaultRoutingEngine) ExecuteStreamWithFailover(
 				if streamErr != nil {
 					log.Warnf("[UnifiedRouting] Stream error after successful start: %v", streamErr)
 				}
-				e.stateMgr.RecordSuccess(ctx, capturedTarget.ID, time.Since(capturedAttemptStart))
+				e.recordTargetSuccess(ctx, capturedTarget.ID, time.Since(capturedAttemptStart))
 				traceBuilder.AddAttempt(layer.Level, capturedTarget.ID, capturedTarget.CredentialID, capturedTarget.Model).
 					Success(attemptLatency)
 
@@ -889,6 +1378,89 @@ func (e *DefaultRoutingEngine) ExecuteStreamWithFailover(
 	return nil, &AllTargetsExhaustedError{RouteID: decision.RouteID}
 }
 
+// requestTimeout returns the time budget for one request on pipeline: its own
+// RequestTimeoutSeconds, else the global setting. 0 means unbounded.
+func (e *DefaultRoutingEngine) requestTimeout(ctx context.Context, pipeline *Pipeline) time.Duration {
+	if pipeline.RequestTimeoutSeconds > 0 {
+		return time.Duration(pipeline.RequestTimeoutSeconds) * time.Second
+	}
+	if e.configSvc == nil {
+		return 0
+	}
+	settings, err := e.configSvc.GetSettings(ctx)
+	if err != nil || settings == nil || settings.RequestTimeoutSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(settings.RequestTimeoutSeconds) * time.Second
+}
+
+// pastDeadline reports whether a route deadline is set and has passed.
+func pastDeadline(deadline time.Time) bool {
+	return !deadline.IsZero() && !time.Now().Before(deadline)
+}
+
+// firstChunkWait is how long a stream attempt may wait for its first chunk:
+// failoverFirstChunkTimeout, shortened to what is left of the route deadline.
+func firstChunkWait(deadline time.Time) time.Duration {
+	if deadline.IsZero() {
+		return failoverFirstChunkTimeout
+	}
+	return min(failoverFirstChunkTimeout, max(time.Until(deadline), 0))
+}
+
+// routeTimedOut records the trace of a request that ran out of route time.
+func (e *DefaultRoutingEngine) routeTimedOut(decision *RoutingDecision, traceBuilder *TraceBuilder, startTime time.Time, timeout time.Duration) error {
+	trace := traceBuilder.Build(time.Since(startTime).Milliseconds())
+	e.metrics.RecordRequest(trace)
+	log.Debugf("[UnifiedRouting] Route %s exceeded its request timeout (%s)", decision.RouteName, timeout)
+	return &RouteTimeoutError{RouteID: decision.RouteID, Timeout: timeout}
+}
+
+// attemptsExhausted records the trace of a request that used up its pipeline's
+// MaxAttempts and returns the last upstream error.
+func (e *DefaultRoutingEngine) attemptsExhausted(decision *RoutingDecision, traceBuilder *TraceBuilder, startTime time.Time, attempts, maxAttempts int, lastErr error) error {
+	trace := traceBuilder.Build(time.Since(startTime).Milliseconds())
+	e.metrics.RecordRequest(trace)
+	log.Debugf("[UnifiedRouting] Route %s stopped after %d/%d attempts: %v", decision.RouteName, attempts, maxAttempts, lastErr)
+	if lastErr == nil {
+		return &AllTargetsExhaustedError{RouteID: decision.RouteID}
+	}
+	return lastErr
+}
+
+// noteRoutingAttempts stores the attempt count on the request's Gin context so
+// the detailed request log can show it.
+func noteRoutingAttempts(ctx context.Context, attempts, maxAttempts int) {
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Set(logging.RoutingAttemptsKey, logging.RoutingAttempts{Used: attempts, Max: maxAttempts})
+	}
+}
+
+// noteRoutingTarget stores the matched route and the target about to be tried
+// on the request's Gin context, so the detailed request log shows which target
+// served the request. target is nil before any target is tried.
+func noteRoutingTarget(ctx context.Context, decision *RoutingDecision, level int, target *Target) {
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return
+	}
+	note := logging.RoutingTarget{RouteID: decision.RouteID, RouteName: decision.RouteName}
+	if target != nil {
+		note.TargetID, note.CredentialID, note.Layer = target.ID, target.CredentialID, level
+	}
+	ginCtx.Set(logging.RoutingTargetKey, note)
+}
+
+// recordTargetSuccess marks a target healthy after it served a real request.
+// RecordSuccess clears any cooldown, so a cooling target picked as a last resort
+// recovers immediately; its pending recheck timer is no longer needed.
+func (e *DefaultRoutingEngine) recordTargetSuccess(ctx context.Context, targetID string, latency time.Duration) {
+	e.stateMgr.RecordSuccess(ctx, targetID, latency)
+	if e.healthChecker != nil {
+		e.healthChecker.CancelTargetCheck(targetID)
+	}
+}
+
 func (e *DefaultRoutingEngine) findAuth(credentialID string) (*coreauth.Auth, error) {
 	if e.authManager == nil {
 		return nil, errors.New("auth manager not initialized")
@@ -953,6 +1525,22 @@ func (e *AllTargetsExhaustedError) Error() string {
 	return fmt.Sprintf("all targets exhausted for route: %s", e.RouteID)
 }
 
+// RouteTimeoutError is returned when a request exceeds its route's time budget
+// before any target succeeded.
+type RouteTimeoutError struct {
+	RouteID string
+	Timeout time.Duration
+}
+
+func (e *RouteTimeoutError) Error() string {
+	return fmt.Sprintf("request timeout: route %s did not complete within %s", e.RouteID, e.Timeout)
+}
+
+// StatusCode reports 504 so handlers answer with Gateway Timeout.
+func (e *RouteTimeoutError) StatusCode() int {
+	return http.StatusGatewayTimeout
+}
+
 // fireHook evaluates and runs hooks if a HookExecutor is attached.
 func (e *DefaultRoutingEngine) fireHook(evt HookAttemptEvent) {
 	if e.hookExecutor != nil {
@@ -975,3 +1563,70 @@ func extractStatusCode(err error) int {
 	}
 	return 0
 }
+
+// markMisconfigured takes a target out of rotation when err says its model
+// does not exist upstream. Such a target would fail every health check and
+// request, so it is not cooled down and retried but left for an operator to
+// fix and reset. It reports whether the target was marked.
+func (e *DefaultRoutingEngine) markMisconfigured(ctx context.Context, decision *RoutingDecision, target *Target, err error) bool {
+	if !isModelNotFoundError(err) {
+		return false
+	}
+	e.stateMgr.MarkMisconfigured(ctx, target.ID, err.Error())
+	e.metrics.RecordEvent(&RoutingEvent{
+		Type:     EventTargetMisconfigured,
+		RouteID:  decision.RouteID,
+		TargetID: target.ID,
+		Details: map[string]any{
+			"model":  target.Model,
+			"reason": err.Error(),
+		},
+	})
+	log.Warnf("[UnifiedRouting] Target %s marked misconfigured: model %q not found upstream", target.ID, target.Model)
+	return true
+}
+
+// recordAttemptFailure records a failed attempt with its cooldown hint.
+func (e *DefaultRoutingEngine) recordAttemptFailure(ctx context.Context, targetID, reason string, class ErrorClass, err error, providerCooldown time.Duration) {
+	cooldown, source := e.failureCooldown(ctx, class, err, providerCooldown)
+	e.stateMgr.RecordFailureCooldown(ctx, targetID, reason, cooldown, source)
+}
+
+// failureCooldown returns the cooldown hint for a failed attempt and where it
+// came from: the upstream Retry-After when present, otherwise the
+// provider-specific cooldown, or for an unreachable upstream the configured
+// network error cooldown.
+func (e *DefaultRoutingEngine) failureCooldown(ctx context.Context, class ErrorClass, err error, providerCooldown time.Duration) (time.Duration, string) {
+	if retryAfter := extractRetryAfter(err); retryAfter > 0 {
+		return retryAfter, "upstream Retry-After"
+	}
+	if providerCooldown > 0 || class != ErrorClassNetwork {
+		return providerCooldown, "provider cooldown"
+	}
+	if cfg, _ := e.configSvc.GetHealthCheckConfig(ctx); cfg != nil && cfg.NetworkErrorCooldownSeconds > 0 {
+		return time.Duration(cfg.NetworkErrorCooldownSeconds) * time.Second, "network error cooldown"
+	}
+	return 0, ""
+}
+
+// extractRetryAfter returns the upstream Retry-After hint carried by an error,
+// or 0. Executors report the Retry-After header separately from the quota
+// delay the auth manager acts on, so honoring it here stays routing-only.
+func extractRetryAfter(err error) time.Duration {
+	if err == nil {
+		return 0
+	}
+	var upstream interface{ UpstreamRetryAfter() *time.Duration }
+	if errors.As(err, &upstream) {
+		if retryAfter := upstream.UpstreamRetryAfter(); retryAfter != nil {
+			return *retryAfter
+		}
+	}
+	var provider interface{ RetryAfter() *time.Duration }
+	if errors.As(err, &provider) {
+		if retryAfter := provider.RetryAfter(); retryAfter != nil {
+			return *retryAfter
+		}
+	}
+	return 0
+}
@@ -3,7 +3,12 @@ package unifiedrouting
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net"
+	"regexp"
 	"strings"
+	"sync/atomic"
+	"syscall"
 
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
@@ -25,6 +30,14 @@ const (
 	// putting any target into cooldown.
 	// Examples: 400 (invalid request body), 413 (payload too large), 422 (unprocessable).
 	ErrorClassNonRetryable
+
+	// ErrorClassNetwork is a retryable error where the upstream could not be
+	// reached at all (connection refused, DNS failure, dial timeout), so the
+	// target is likely down. It is retried like ErrorClassRetryable, but the
+	// target cools down for HealthCheckConfig.NetworkErrorCooldownSeconds.
+	// Callers that only care about retryability should test for
+	// ErrorClassNonRetryable.
+	ErrorClassNetwork
 )
 
 // String returns a human-readable label for the error class.
@@ -34,6 +47,8 @@ func (c ErrorClass) String() string {
 		return "retryable"
 	case ErrorClassNonRetryable:
 		return "non_retryable"
+	case ErrorClassNetwork:
+		return "network"
 	default:
 		return "unknown"
 	}
@@ -46,7 +61,8 @@ func (c ErrorClass) String() string {
 //  1. Context cancellation — always non-retryable (client gave up).
 //  2. auth.Error with explicit Retryable flag — trust the provider.
 //  3. HTTP status code from StatusError or auth.Error.
-//  4. Error message heuristics for overload / capacity keywords.
+//  4. Unreachable upstream — network class; error message heuristics for
+//     overload / capacity keywords.
 //  5. Default: treat as retryable (conservative; prefer retry over silent failure).
 func ClassifyError(err error) ErrorClass {
 	if err == nil {
@@ -78,53 +94,121 @@ func ClassifyError(err error) ErrorClass {
 	return classifyByMessage(err)
 }
 
-// classifyHTTPStatus maps an HTTP status code to an ErrorClass.
-// When the status code alone is ambiguous (e.g. 400), the error message
-// is inspected for overload/capacity keywords that indicate a node issue.
-func classifyHTTPStatus(code int, err error) ErrorClass {
-	switch {
-	// ---- Request-level problems (non-retryable) ----
-	case code == 400:
-		// 400 is usually a bad request, but some providers return 400 for
-		// overload / capacity issues. Check the message.
-		if isOverloadMessage(err.Error()) {
-			return ErrorClassRetryable
-		}
-		// Some providers return 400 for token/auth problems that a different
-		// credential would fix. Retry on the next target.
-		if isTokenError(err.Error()) {
-			return ErrorClassRetryable
-		}
-		return ErrorClassNonRetryable
+// compiledClassificationRules is the lookup form of ClassificationRules.
+type compiledClassificationRules struct {
+	status           map[int]ErrorClass
+	overloadKeywords []string // lower-cased
+	overloadPatterns []*regexp.Regexp
+}
 
-	case code == 413: // Payload too large
-		return ErrorClassNonRetryable
+var activeClassificationRules atomic.Pointer[compiledClassificationRules]
 
-	case code == 422: // Unprocessable entity
-		return ErrorClassNonRetryable
+func init() {
+	defaults := DefaultClassificationRules()
+	_ = SetClassificationRules(&defaults)
+}
 
-	// ---- Node-level problems (retryable) ----
-	case code == 401: // Unauthorized — different credential may work
-		return ErrorClassRetryable
+// SetClassificationRules validates rules and makes them the ones ClassifyError uses.
+func SetClassificationRules(rules *ClassificationRules) error {
+	if err := ValidateClassificationRules(rules); err != nil {
+		return err
+	}
+	compiled := &compiledClassificationRules{status: make(map[int]ErrorClass, len(rules.StatusRules))}
+	for code, class := range rules.StatusRules {
+		compiled.status[code], _ = parseErrorClass(class)
+	}
+	keywords := rules.OverloadKeywords
+	if len(keywords) == 0 {
+		keywords = DefaultClassificationRules().OverloadKeywords
+	}
+	if rules.ExtraOverloadMatch == OverloadMatchRegex {
+		for _, pattern := range rules.ExtraOverloadKeywords {
+			compiled.overloadPatterns = append(compiled.overloadPatterns, regexp.MustCompile("(?i)"+pattern))
+		}
+	} else {
+		keywords = append(keywords[:len(keywords):len(keywords)], rules.ExtraOverloadKeywords...)
+	}
+	for _, kw := range keywords {
+		compiled.overloadKeywords = append(compiled.overloadKeywords, strings.ToLower(strings.TrimSpace(kw)))
+	}
+	activeClassificationRules.Store(compiled)
+	return nil
+}
 
-	case code == 402: // Payment required — different account may work
-		return ErrorClassRetryable
+// ValidateClassificationRules checks status codes, class names and keywords.
+func ValidateClassificationRules(rules *ClassificationRules) error {
+	if rules == nil {
+		return fmt.Errorf("classification rules are required")
+	}
+	for code, class := range rules.StatusRules {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("status_rules: invalid HTTP status %d", code)
+		}
+		if _, ok := parseErrorClass(class); !ok {
+			return fmt.Errorf("status_rules[%d]: class must be %q or %q, got %q", code, ErrorClassRetryable, ErrorClassNonRetryable, class)
+		}
+	}
+	for i, kw := range rules.OverloadKeywords {
+		if strings.TrimSpace(kw) == "" {
+			return fmt.Errorf("overload_keywords[%d]: keyword must not be empty", i)
+		}
+	}
+	switch rules.ExtraOverloadMatch {
+	case "", OverloadMatchSubstring, OverloadMatchRegex:
+	default:
+		return fmt.Errorf("extra_overload_match must be %q or %q, got %q", OverloadMatchSubstring, OverloadMatchRegex, rules.ExtraOverloadMatch)
+	}
+	for i, kw := range rules.ExtraOverloadKeywords {
+		if strings.TrimSpace(kw) == "" {
+			return fmt.Errorf("extra_overload_keywords[%d]: keyword must not be empty", i)
+		}
+		if rules.ExtraOverloadMatch == OverloadMatchRegex {
+			if _, err := regexp.Compile("(?i)" + kw); err != nil {
+				return fmt.Errorf("extra_overload_keywords[%d]: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
 
-	case code == 403: // Forbidden — different credential/account may work
-		return ErrorClassRetryable
+func parseErrorClass(s string) (ErrorClass, bool) {
+	switch s {
+	case ErrorClassRetryable.String():
+		return ErrorClassRetryable, true
+	case ErrorClassNonRetryable.String():
+		return ErrorClassNonRetryable, true
+	}
+	return ErrorClassRetryable, false
+}
 
-	case code == 404: // Not found — different node may host the model
-		return ErrorClassRetryable
+// classifyHTTPStatus maps an HTTP status code to an ErrorClass using the
+// active ClassificationRules. A 400 is ambiguous: its message is inspected for
+// overload/capacity or token keywords that indicate a node issue.
+func classifyHTTPStatus(code int, err error) ErrorClass {
+	if code == 0 { // No HTTP status — fall through to message check
+		return classifyByMessage(err)
+	}
 
-	case code == 429: // Rate limited — different node may have quota
-		return ErrorClassRetryable
+	if class, ok := activeClassificationRules.Load().status[code]; ok {
+		if class == ErrorClassNonRetryable && code == 400 {
+			// 400 is usually a bad request, but some providers return 400 for
+			// overload / capacity issues. Check the message.
+			if isOverloadMessage(err.Error()) {
+				return ErrorClassRetryable
+			}
+			// Some providers return 400 for token/auth problems that a different
+			// credential would fix. Retry on the next target.
+			if isTokenError(err.Error()) {
+				return ErrorClassRetryable
+			}
+		}
+		return class
+	}
 
+	switch {
 	case code >= 500: // Server errors — node specific
 		return ErrorClassRetryable
 
-	case code == 0: // No HTTP status — fall through to message check
-		return classifyByMessage(err)
-
 	default:
 		// Other 4xx we haven't explicitly handled — conservatively treat as
 		// non-retryable because they typically indicate client errors.
@@ -135,21 +219,52 @@ func classifyHTTPStatus(code int, err error) ErrorClass {
 	}
 }
 
+// unreachableKeywords mark an upstream that could not be connected to.
+var unreachableKeywords = []string{
+	"connection refused",
+	"no such host",
+	"dial tcp",
+	"no route to host",
+	"network is unreachable",
+}
+
+// isUnreachableError reports whether err means no connection to the upstream
+// could be made, as opposed to one that failed part way.
+func isUnreachableError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, kw := range unreachableKeywords {
+		if strings.Contains(msg, kw) {
+			return true
+		}
+	}
+	return false
+}
+
 // classifyByMessage inspects the error string for patterns that hint at
 // the error class. This is the fallback when no structured status is available.
 func classifyByMessage(err error) ErrorClass {
+	// Upstream unreachable → likely down, cooled down separately.
+	if isUnreachableError(err) {
+		return ErrorClassNetwork
+	}
+
 	msg := strings.ToLower(err.Error())
 
-	// Network / connectivity errors → node-specific.
+	// Connections that failed part way → node-specific, often one-off.
 	networkKeywords := []string{
-		"connection refused",
 		"connection reset",
-		"no such host",
 		"i/o timeout",
 		"tls handshake",
 		"eof",
 		"broken pipe",
-		"dial tcp",
 	}
 	for _, kw := range networkKeywords {
 		if strings.Contains(msg, kw) {
@@ -166,6 +281,33 @@ func classifyByMessage(err error) ErrorClass {
 	return ErrorClassRetryable
 }
 
+// modelNotFoundKeywords appear in 404 bodies that reject the requested model
+// name itself, as opposed to a missing path or a node that is still loading.
+var modelNotFoundKeywords = []string{
+	"model_not_found",
+	"model not found",
+	"unknown model",
+	"unsupported model",
+	"model is not supported",
+	"model does not exist",
+	"is not found for api version",
+}
+
+// isModelNotFoundError reports whether err is a 404 whose message says the
+// requested model does not exist. Generic or empty 404s are not matched.
+func isModelNotFoundError(err error) bool {
+	if err == nil || extractStatusCode(err) != 404 {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, kw := range modelNotFoundKeywords {
+		if strings.Contains(msg, kw) {
+			return true
+		}
+	}
+	return false
+}
+
 // isTokenError returns true if the 400 error message indicates an
 // authentication / token problem that a different credential could fix.
 func isTokenError(msg string) bool {
@@ -196,22 +338,18 @@ func isTokenError(msg string) bool {
 	return false
 }
 
-// isOverloadMessage returns true if the message suggests the failure
-// is due to temporary overload or capacity, not a malformed request.
+// isOverloadMessage returns true if the message contains one of the active
+// overload keywords or matches one of the extra patterns, i.e. the failure is
+// due to temporary overload or capacity, not a malformed request.
 func isOverloadMessage(msg string) bool {
+	rules := activeClassificationRules.Load()
+	for _, re := range rules.overloadPatterns {
+		if re.MatchString(msg) {
+			return true
+		}
+	}
 	msg = strings.ToLower(msg)
-	overloadKeywords := []string{
-		"overloaded",
-		"capacity",
-		"too many requests",
-		"rate limit",
-		"resource exhausted",
-		"server is busy",
-		"temporarily unavailable",
-		"service unavailable",
-		"quota",
-	}
-	for _, kw := range overloadKeywords {
+	for _, kw := range rules.overloadKeywords {
 		if strings.Contains(msg, kw) {
 			return true
 		}
@@ -0,0 +1,235 @@
+package unifiedrouting
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func httpErr(status int, msg string) error {
+	return &coreauth.Error{HTTPStatus: status, Message: msg}
+}
+
+func restoreDefaultClassificationRules(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		defaults := DefaultClassificationRules()
+		if err := SetClassificationRules(&defaults); err != nil {
+			t.Fatalf("restore default rules: %v", err)
+		}
+	})
+}
+
+func TestClassifyErrorDefaultRules(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{"bad request", httpErr(400, "invalid request body"), ErrorClassNonRetryable},
+		{"overloaded 400", httpErr(400, "model is overloaded"), ErrorClassRetryable},
+		{"token 400", httpErr(400, "invalid api key"), ErrorClassRetryable},
+		{"payload too large", httpErr(413, "too large"), ErrorClassNonRetryable},
+		{"unauthorized", httpErr(401, "unauthorized"), ErrorClassRetryable},
+		{"rate limited", httpErr(429, "slow down"), ErrorClassRetryable},
+		{"unlisted 4xx", httpErr(409, "conflict"), ErrorClassNonRetryable},
+		{"server error", httpErr(503, "unavailable"), ErrorClassRetryable},
+	}
+	for _, tc := range cases {
+		if got := ClassifyError(tc.err); got != tc.want {
+			t.Fatalf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestClassifyNetworkErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{"refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, ErrorClassNetwork},
+		{"dns", &net.DNSError{Err: "no such host", Name: "api.example.invalid"}, ErrorClassNetwork},
+		{"refused message", errors.New(`Post "https://api.example.com": dial tcp 10.0.0.1:443: connect: connection refused`), ErrorClassNetwork},
+		{"dial timeout", httpErr(0, "dial tcp 10.0.0.1:443: i/o timeout"), ErrorClassNetwork},
+		{"read timeout", errors.New("read tcp 10.0.0.2:51234->10.0.0.1:443: i/o timeout"), ErrorClassRetryable},
+		{"reset", errors.New("connection reset by peer"), ErrorClassRetryable},
+		{"status wins", httpErr(502, "connection refused"), ErrorClassRetryable},
+	}
+	for _, tc := range cases {
+		if got := ClassifyError(tc.err); got != tc.want {
+			t.Fatalf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestClassifyErrorCustomRules(t *testing.T) {
+	restoreDefaultClassificationRules(t)
+
+	rules := DefaultClassificationRules()
+	rules.StatusRules[529] = "non_retryable"
+	rules.StatusRules[409] = "retryable"
+	rules.OverloadKeywords = append(rules.OverloadKeywords, "try again later")
+	if err := SetClassificationRules(&rules); err != nil {
+		t.Fatalf("SetClassificationRules: %v", err)
+	}
+
+	if got := ClassifyError(httpErr(529, "overloaded")); got != ErrorClassNonRetryable {
+		t.Fatalf("529: got %s, want non_retryable", got)
+	}
+	if got := ClassifyError(httpErr(409, "conflict")); got != ErrorClassRetryable {
+		t.Fatalf("409: got %s, want retryable", got)
+	}
+	if got := ClassifyError(httpErr(400, "Please try again later")); got != ErrorClassRetryable {
+		t.Fatalf("400 with custom keyword: got %s, want retryable", got)
+	}
+}
+
+func TestExtraOverloadKeywordsExtendDefaults(t *testing.T) {
+	restoreDefaultClassificationRules(t)
+
+	cases := []struct {
+		name  string
+		rules ClassificationRules
+		msg   string
+		want  ErrorClass
+	}{
+		{"builtin kept", ClassificationRules{ExtraOverloadKeywords: []string{"系统繁忙"}}, "model is overloaded", ErrorClassRetryable},
+		{"substring", ClassificationRules{ExtraOverloadKeywords: []string{"系统繁忙"}}, "错误: 系统繁忙,请稍后重试", ErrorClassRetryable},
+		{"substring ignores case", ClassificationRules{ExtraOverloadKeywords: []string{"Try Again Later"}}, "please TRY AGAIN LATER", ErrorClassRetryable},
+		{"substring is literal", ClassificationRules{ExtraOverloadKeywords: []string{"busy.*now"}}, "busy right now", ErrorClassNonRetryable},
+		{"regex", ClassificationRules{ExtraOverloadKeywords: []string{`busy.*now`}, ExtraOverloadMatch: OverloadMatchRegex}, "Busy right NOW", ErrorClassRetryable},
+		{"regex miss", ClassificationRules{ExtraOverloadKeywords: []string{`^busy$`}, ExtraOverloadMatch: OverloadMatchRegex}, "not busy", ErrorClassNonRetryable},
+		{"listed keywords replace builtins", ClassificationRules{OverloadKeywords: []string{"saturated"}}, "model is overloaded", ErrorClassNonRetryable},
+	}
+	for _, tc := range cases {
+		tc.rules.StatusRules = map[int]string{400: "non_retryable"}
+		if err := SetClassificationRules(&tc.rules); err != nil {
+			t.Fatalf("%s: SetClassificationRules: %v", tc.name, err)
+		}
+		if got := ClassifyError(httpErr(400, tc.msg)); got != tc.want {
+			t.Fatalf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestValidateClassificationRules(t *testing.T) {
+	cases := []ClassificationRules{
+		{StatusRules: map[int]string{42: "retryable"}},
+		{StatusRules: map[int]string{500: "maybe"}},
+		{OverloadKeywords: []string{"busy", "  "}},
+		{ExtraOverloadKeywords: []string{""}},
+		{ExtraOverloadKeywords: []string{"busy("}, ExtraOverloadMatch: OverloadMatchRegex},
+		{ExtraOverloadKeywords: []string{"busy"}, ExtraOverloadMatch: "glob"},
+	}
+	for i, rules := range cases {
+		if err := ValidateClassificationRules(&rules); err == nil {
+			t.Fatalf("case %d: expected validation error", i)
+		}
+	}
+	defaults := DefaultClassificationRules()
+	if err := ValidateClassificationRules(&defaults); err != nil {
+		t.Fatalf("defaults rejected: %v", err)
+	}
+}
+
+func TestReloadClassificationRulesFromStore(t *testing.T) {
+	restoreDefaultClassificationRules(t)
+	ctx := context.Background()
+
+	store, err := NewFileConfigStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileConfigStore: %v", err)
+	}
+	svc := NewConfigService(store)
+
+	rules, err := svc.ReloadClassificationRules(ctx)
+	if err != nil {
+		t.Fatalf("reload defaults: %v", err)
+	}
+	if rules.StatusRules[400] != "non_retryable" {
+		t.Fatalf("missing file should load defaults, got %v", rules.StatusRules)
+	}
+
+	custom := ClassificationRules{StatusRules: map[int]string{400: "retryable"}}
+	if err := store.SaveClassificationRules(ctx, &custom); err != nil {
+		t.Fatalf("SaveClassificationRules: %v", err)
+	}
+	if got := ClassifyError(httpErr(400, "invalid request body")); got != ErrorClassNonRetryable {
+		t.Fatalf("rules applied before reload: got %s", got)
+	}
+	if _, err := svc.ReloadClassificationRules(ctx); err != nil {
+		t.Fatalf("reload custom: %v", err)
+	}
+	if got := ClassifyError(httpErr(400, "invalid request body")); got != ErrorClassRetryable {
+		t.Fatalf("400 after reload: got %s, want retryable", got)
+	}
+}
+
+func TestPartialClassificationRulesFileKeepsDefaultSections(t *testing.T) {
+	restoreDefaultClassificationRules(t)
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	store, err := NewFileConfigStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileConfigStore: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "classification-rules.yaml"), []byte("overload-keywords:\n  - \"系统繁忙\"\n"), 0644); err != nil {
+		t.Fatalf("write rules file: %v", err)
+	}
+	rules, err := NewConfigService(store).ReloadClassificationRules(ctx)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if rules.StatusRules[401] != "retryable" || len(rules.StatusRules) != len(DefaultClassificationRules().StatusRules) {
+		t.Fatalf("status rules = %v, want the defaults", rules.StatusRules)
+	}
+	if got := ClassifyError(httpErr(401, "invalid api key")); got != ErrorClassRetryable {
+		t.Fatalf("401 after partial reload: got %s, want retryable", got)
+	}
+	if got := ClassifyError(httpErr(400, "系统繁忙")); got != ErrorClassRetryable {
+		t.Fatalf("file keyword not applied: got %s", got)
+	}
+}
+
+func TestClassifyErrorForProvider(t *testing.T) {
+	restoreDefaultClassificationRules(t)
+	rules := DefaultClassificationRules()
+	rules.StatusRules[529] = "non_retryable"
+	if err := SetClassificationRules(&rules); err != nil {
+		t.Fatalf("SetClassificationRules: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		provider string
+		err      error
+		want     ErrorClass
+		cooldown time.Duration
+	}{
+		{"anthropic overloaded", "claude", httpErr(529, "overloaded_error"), ErrorClassRetryable, 0},
+		{"unknown provider 529", "iflow", httpErr(529, "overloaded"), ErrorClassNonRetryable, 0},
+		{"gemini quota", "gemini-cli", httpErr(429, `{"status":"RESOURCE_EXHAUSTED"}`), ErrorClassRetryable, geminiQuotaCooldown},
+		{"gemini rate limit", "gemini", httpErr(429, "slow down"), ErrorClassRetryable, 0},
+		{"openai content policy", "codex", httpErr(400, "content_policy_violation: server overloaded"), ErrorClassNonRetryable, 0},
+		{"openai overloaded 400", "codex", httpErr(400, "server overloaded"), ErrorClassRetryable, 0},
+		{"cancelled", "claude", context.Canceled, ErrorClassNonRetryable, 0},
+	}
+	for _, tc := range cases {
+		class, cooldown := classifyProviderError(tc.provider, tc.err)
+		if class != tc.want || cooldown != tc.cooldown {
+			t.Fatalf("%s: got (%s, %v), want (%s, %v)", tc.name, class, cooldown, tc.want, tc.cooldown)
+		}
+		if got := ClassifyErrorForProvider(tc.provider, tc.err); got != tc.want {
+			t.Fatalf("%s: ClassifyErrorForProvider = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
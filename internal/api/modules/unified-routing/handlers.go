@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -65,8 +66,7 @@ func (h *Handlers) GetSettings(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	log.Infof("[UnifiedRouting] GetSettings success: %+v", settings)
-	c.JSON(http.StatusOK, settings)
+	c.JSON(http.StatusOK, maskSettingsSecrets(settings))
 }
 
 // PutSettings updates the unified routing settings.
@@ -76,13 +76,21 @@ func (h *Handlers) PutSettings(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// Secrets come back masked from GetSettings; keep the stored ones.
+	if current, err := h.configSvc.GetSettings(c.Request.Context()); err == nil {
+		restoreSettingsSecrets(&settings, current, maskSettingsSecrets(current))
+	}
+	if err := validateSettings(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err := h.configSvc.UpdateSettings(c.Request.Context(), &settings); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, settings)
+	c.JSON(http.StatusOK, maskSettingsSecrets(&settings))
 }
 
 // GetHealthCheckConfig returns the health check configuration.
@@ -97,11 +105,15 @@ func (h *Handlers) GetHealthCheckConfig(c *gin.Context) {
 
 // PutHealthCheckConfig updates the health check configuration.
 func (h *Handlers) PutHealthCheckConfig(c *gin.Context) {
-	var config HealthCheckConfig
+	config := DefaultHealthCheckConfig()
 	if err := c.ShouldBindJSON(&config); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := validateHealthCheckConfig(&config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err := h.configSvc.UpdateHealthCheckConfig(c.Request.Context(), &config); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -111,6 +123,48 @@ func (h *Handlers) PutHealthCheckConfig(c *gin.Context) {
 	c.JSON(http.StatusOK, config)
 }
 
+// GetClassificationRules returns the error classification rules.
+func (h *Handlers) GetClassificationRules(c *gin.Context) {
+	rules, err := h.configSvc.GetClassificationRules(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// PutClassificationRules saves the error classification rules and applies them.
+func (h *Handlers) PutClassificationRules(c *gin.Context) {
+	var rules ClassificationRules
+	if err := c.ShouldBindJSON(&rules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rules.fillDefaultSections()
+	if err := ValidateClassificationRules(&rules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.configSvc.UpdateClassificationRules(c.Request.Context(), &rules); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// ReloadClassificationRules re-reads the classification rules file, so edits
+// made on disk take effect without a restart.
+func (h *Handlers) ReloadClassificationRules(c *gin.Context) {
+	rules, err := h.configSvc.ReloadClassificationRules(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
 // ================== Config: Routes ==================
 
 // ListRoutes returns all routes.
@@ -128,8 +182,9 @@ func (h *Handlers) ListRoutes(c *gin.Context) {
 	type RouteResponse struct {
 		*Route
 		PipelineSummary struct {
-			TotalLayers  int `json:"total_layers"`
-			TotalTargets int `json:"total_targets"`
+			TotalLayers    int `json:"total_layers"`
+			TotalTargets   int `json:"total_targets"`
+			DisabledLayers int `json:"disabled_layers,omitempty"`
 		} `json:"pipeline_summary"`
 	}
 
@@ -142,6 +197,9 @@ func (h *Handlers) ListRoutes(c *gin.Context) {
 			rr.PipelineSummary.TotalLayers = len(pipeline.Layers)
 			for _, layer := range pipeline.Layers {
 				rr.PipelineSummary.TotalTargets += len(layer.Targets)
+				if layer.Disabled {
+					rr.PipelineSummary.DisabledLayers++
+				}
 			}
 		}
 
@@ -202,19 +260,19 @@ func (h *Handlers) CreateRoute(c *gin.Context) {
 	}
 
 	if errs := h.configSvc.Validate(c.Request.Context(), route, pipelineToValidate); len(errs) > 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"errors": errs})
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": errs})
 		return
 	}
 
 	// Create route
 	if err := h.configSvc.CreateRoute(c.Request.Context(), route); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		writeUpdateError(c, err, http.StatusBadRequest)
 		return
 	}
 
 	// Save pipeline if provided
 	if len(req.Pipeline.Layers) > 0 {
-		if err := h.configSvc.UpdatePipeline(c.Request.Context(), route.ID, &req.Pipeline); err != nil {
+		if _, err := h.configSvc.UpdatePipeline(c.Request.Context(), route.ID, &req.Pipeline); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -236,6 +294,7 @@ func (h *Handlers) UpdateRoute(c *gin.Context) {
 		Aliases     []string `json:"aliases"`
 		Description string   `json:"description"`
 		Enabled     bool     `json:"enabled"`
+		Version     int64    `json:"version"`
 		Pipeline    Pipeline `json:"pipeline"`
 	}
 
@@ -244,28 +303,66 @@ func (h *Handlers) UpdateRoute(c *gin.Context) {
 		return
 	}
 
+	// Catch invalid input and a stale pipeline before the route is written, so
+	// neither leaves the route half-updated.
+	var pipelineToValidate *Pipeline
+	if len(req.Pipeline.Layers) > 0 {
+		pipelineToValidate = &req.Pipeline
+	}
+	if errs := h.configSvc.Validate(c.Request.Context(), &Route{Name: req.Name, Aliases: req.Aliases}, pipelineToValidate); len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": errs})
+		return
+	}
+	if len(req.Pipeline.Layers) > 0 && req.Pipeline.Version != 0 {
+		if current, err := h.configSvc.GetPipeline(c.Request.Context(), routeID); err == nil && current.Version != req.Pipeline.Version {
+			writeUpdateError(c, &VersionConflictError{Kind: "pipeline", ID: routeID, Current: current.Version}, http.StatusBadRequest)
+			return
+		}
+	}
+
 	route := &Route{
 		ID:          routeID,
 		Name:        req.Name,
 		Aliases:     req.Aliases,
 		Description: req.Description,
 		Enabled:     req.Enabled,
+		Version:     req.Version,
 	}
 
 	if err := h.configSvc.UpdateRoute(c.Request.Context(), route); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		writeUpdateError(c, err, http.StatusBadRequest)
 		return
 	}
 
 	// Update pipeline if provided
 	if len(req.Pipeline.Layers) > 0 {
-		if err := h.configSvc.UpdatePipeline(c.Request.Context(), routeID, &req.Pipeline); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if _, err := h.configSvc.UpdatePipeline(c.Request.Context(), routeID, &req.Pipeline); err != nil {
+			writeUpdateError(c, err, http.StatusInternalServerError)
 			return
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "route updated successfully"})
+	c.JSON(http.StatusOK, gin.H{
+		"message": "route updated successfully",
+		"version": route.Version,
+	})
+}
+
+// writeUpdateError reports a *VersionConflictError as 409 Conflict together
+// with the current version, ValidationErrors as 422 with the offending fields,
+// and any other error with status.
+func writeUpdateError(c *gin.Context, err error, status int) {
+	var conflict *VersionConflictError
+	if errors.As(err, &conflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "current_version": conflict.Current})
+		return
+	}
+	var invalid ValidationErrors
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": invalid})
+		return
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
 }
 
 // PatchRoute partially updates a route.
@@ -285,7 +382,8 @@ func (h *Handlers) PatchRoute(c *gin.Context) {
 	}
 
 	// Apply patch
-	if name, ok := patch["name"].(string); ok {
+	name, renamed := patch["name"].(string)
+	if renamed {
 		existing.Name = name
 	}
 	if desc, ok := patch["description"].(string); ok {
@@ -294,13 +392,25 @@ func (h *Handlers) PatchRoute(c *gin.Context) {
 	if enabled, ok := patch["enabled"].(bool); ok {
 		existing.Enabled = enabled
 	}
+	if version, ok := patch["version"].(float64); ok {
+		existing.Version = int64(version)
+	}
+	if renamed {
+		if errs := h.configSvc.Validate(c.Request.Context(), existing, nil); len(errs) > 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": errs})
+			return
+		}
+	}
 
 	if err := h.configSvc.UpdateRoute(c.Request.Context(), existing); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		writeUpdateError(c, err, http.StatusBadRequest)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "route updated successfully"})
+	c.JSON(http.StatusOK, gin.H{
+		"message": "route updated successfully",
+		"version": existing.Version,
+	})
 }
 
 // DeleteRoute deletes a route.
@@ -312,7 +422,46 @@ func (h *Handlers) DeleteRoute(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "route deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "route archived successfully"})
+}
+
+// ListArchivedRoutes returns deleted routes that can still be restored.
+func (h *Handlers) ListArchivedRoutes(c *gin.Context) {
+	archived, err := h.configSvc.ListArchivedRoutes(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"routes": archived,
+		"total":  len(archived),
+	})
+}
+
+// RestoreRoute moves an archived route and its pipeline back into service.
+func (h *Handlers) RestoreRoute(c *gin.Context) {
+	routeID := c.Param("route_id")
+
+	route, err := h.configSvc.RestoreRoute(c.Request.Context(), routeID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, route)
+}
+
+// PurgeRoute permanently deletes an active or archived route.
+func (h *Handlers) PurgeRoute(c *gin.Context) {
+	routeID := c.Param("route_id")
+
+	if err := h.configSvc.PurgeRoute(c.Request.Context(), routeID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "route purged"})
 }
 
 // ================== Config: Pipeline ==================
@@ -340,18 +489,75 @@ func (h *Handlers) UpdatePipeline(c *gin.Context) {
 		return
 	}
 
-	if err := h.configSvc.UpdatePipeline(c.Request.Context(), routeID, &pipeline); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	stored, err := h.configSvc.UpdatePipeline(c.Request.Context(), routeID, &pipeline)
+	if err != nil {
+		writeUpdateError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "pipeline updated successfully",
+		"pipeline": stored,
+	})
+}
+
+// EnableRouteTargets enables all targets of a route, or with ?layer=<level>
+// only those of one layer.
+func (h *Handlers) EnableRouteTargets(c *gin.Context) {
+	h.setRouteTargetsEnabled(c, true)
+}
+
+// DisableRouteTargets disables all targets of a route, or with ?layer=<level>
+// only those of one layer, e.g. during provider maintenance.
+func (h *Handlers) DisableRouteTargets(c *gin.Context) {
+	h.setRouteTargetsEnabled(c, false)
+}
+
+func (h *Handlers) setRouteTargetsEnabled(c *gin.Context, enabled bool) {
+	routeID := c.Param("route_id")
+
+	level := 0
+	if raw := c.Query("layer"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "layer must be a positive layer level"})
+			return
+		}
+		level = parsed
+	}
+
+	if _, err := h.configSvc.GetRoute(c.Request.Context(), routeID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "pipeline updated successfully"})
+	pipeline, changed, err := h.configSvc.SetTargetsEnabled(c.Request.Context(), routeID, level, enabled)
+	if err != nil {
+		writeUpdateError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"changed":  changed,
+		"pipeline": pipeline,
+	})
 }
 
 // ================== Config: Export/Import ==================
 
-// ExportConfig exports the configuration.
+// ExportConfig exports the configuration, or with one or more ?route=<id>
+// parameters a partial export of just those routes.
 func (h *Handlers) ExportConfig(c *gin.Context) {
+	if ids := c.QueryArray("route"); len(ids) > 0 {
+		data, err := h.configSvc.ExportRoutes(c.Request.Context(), ids)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, data)
+		return
+	}
+
 	data, err := h.configSvc.Export(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -361,9 +567,56 @@ func (h *Handlers) ExportConfig(c *gin.Context) {
 	c.JSON(http.StatusOK, data)
 }
 
+// CloneRoute creates a new route from an existing route's pipeline.
+// Body: {"name": "new-model", "aliases": [...]}; target IDs are regenerated.
+func (h *Handlers) CloneRoute(c *gin.Context) {
+	routeID := c.Param("route_id")
+
+	var req struct {
+		Name    string   `json:"name" binding:"required"`
+		Aliases []string `json:"aliases"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.configSvc.GetRoute(c.Request.Context(), routeID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	route, pipeline, err := h.configSvc.CloneRoute(c.Request.Context(), routeID, req.Name, req.Aliases)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":       route.ID,
+		"name":     route.Name,
+		"pipeline": pipeline,
+		"message":  "route cloned successfully",
+	})
+}
+
+// ExportRoute exports a single route with its pipeline and the health check config.
+// The result can be imported with merge=true without touching other routes or settings.
+func (h *Handlers) ExportRoute(c *gin.Context) {
+	routeID := c.Param("route_id")
+
+	data, err := h.configSvc.ExportRoute(c.Request.Context(), routeID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, data)
+}
+
 // ImportConfig imports the configuration.
 func (h *Handlers) ImportConfig(c *gin.Context) {
-	merge := c.DefaultQuery("merge", "false") == "true"
+	opts := importOptionsFromQuery(c)
 
 	var data ExportData
 	if err := c.ShouldBindJSON(&data); err != nil {
@@ -371,12 +624,63 @@ func (h *Handlers) ImportConfig(c *gin.Context) {
 		return
 	}
 
-	if err := h.configSvc.Import(c.Request.Context(), &data, merge); err != nil {
+	mapping, err := h.configSvc.Import(c.Request.Context(), &data, opts)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "configuration imported successfully"})
+	c.JSON(http.StatusOK, importResponse(mapping))
+}
+
+// importOptionsFromQuery reads the merge and regenerate_ids query flags.
+func importOptionsFromQuery(c *gin.Context) ImportOptions {
+	return ImportOptions{
+		Merge:         c.DefaultQuery("merge", "false") == "true",
+		RegenerateIDs: c.DefaultQuery("regenerate_ids", "false") == "true",
+	}
+}
+
+func importResponse(mapping map[string]string) gin.H {
+	resp := gin.H{"message": "configuration imported successfully"}
+	if len(mapping) > 0 {
+		resp["id_mapping"] = mapping
+	}
+	return resp
+}
+
+// maxConfigYAMLBytes bounds the size of an uploaded YAML configuration.
+const maxConfigYAMLBytes = 10 << 20
+
+// ExportConfigYAML exports the configuration as YAML.
+func (h *Handlers) ExportConfigYAML(c *gin.Context) {
+	data, err := h.configSvc.ExportYAML(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/yaml; charset=utf-8", data)
+}
+
+// ImportConfigYAML imports a YAML configuration from the request body.
+// The whole document is rejected if any route or pipeline fails validation.
+func (h *Handlers) ImportConfigYAML(c *gin.Context) {
+	opts := importOptionsFromQuery(c)
+
+	raw, err := readAllLimited(c.Request.Body, maxConfigYAMLBytes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	mapping, err := h.configSvc.ImportYAML(c.Request.Context(), raw, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, importResponse(mapping))
 }
 
 // ValidateConfig validates a configuration.
@@ -428,6 +732,20 @@ func (h *Handlers) GetRouteStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, state)
 }
 
+// ListTargetStates returns the runtime state of every known target.
+func (h *Handlers) ListTargetStates(c *gin.Context) {
+	states, err := h.stateMgr.ListTargetStates(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":   len(states),
+		"targets": states,
+	})
+}
+
 // GetTargetStatus returns the status of a target.
 func (h *Handlers) GetTargetStatus(c *gin.Context) {
 	targetID := c.Param("target_id")
@@ -472,6 +790,113 @@ func (h *Handlers) ForceCooldown(c *gin.Context) {
 	})
 }
 
+// RecoverTarget brings a target back into rotation immediately, without
+// waiting for a health check, e.g. after its credential has been fixed.
+func (h *Handlers) RecoverTarget(c *gin.Context) {
+	targetID := c.Param("target_id")
+	ctx := c.Request.Context()
+
+	routes, err := h.configSvc.ListRoutes(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if findTarget(ctx, h.configSvc, routes, targetID) == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": (&TargetNotFoundError{TargetID: targetID}).Error()})
+		return
+	}
+
+	previous := ""
+	if state, err := h.stateMgr.GetTargetState(ctx, targetID); err == nil && state != nil {
+		previous = string(state.Status)
+	}
+
+	if h.healthChecker != nil {
+		h.healthChecker.CancelTargetCheck(targetID)
+	}
+	if err := h.stateMgr.ForceHealthy(ctx, targetID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.metrics.RecordEvent(&RoutingEvent{
+		Type:     EventTargetRecovered,
+		TargetID: targetID,
+		Details: map[string]any{
+			"reason":          "manual",
+			"previous_status": previous,
+		},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "target recovered",
+		"target_id":  targetID,
+		"new_status": "healthy",
+	})
+}
+
+// defaultDrainTimeout bounds how long DrainTarget waits when the request
+// sets no timeout_seconds; maxDrainTimeout caps the timeout a request can set.
+const (
+	defaultDrainTimeout = time.Minute
+	maxDrainTimeout     = 30 * time.Minute
+)
+
+// parseDrainTimeout reads DrainTarget's timeout_seconds parameter. Negative or
+// non-numeric values are rejected; values above maxDrainTimeout are capped.
+func parseDrainTimeout(raw string) (time.Duration, error) {
+	if raw == "" {
+		return defaultDrainTimeout, nil
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds < 0 {
+		return 0, errors.New("timeout_seconds must be a non-negative integer")
+	}
+	if seconds > int(maxDrainTimeout/time.Second) {
+		return maxDrainTimeout, nil
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// DrainTarget stops routing new requests to a target and waits for its
+// in-flight requests to finish (or ?timeout_seconds=, default 60, at most
+// 1800), e.g. before removing a credential during rotation.
+func (h *Handlers) DrainTarget(c *gin.Context) {
+	targetID := c.Param("target_id")
+	ctx := c.Request.Context()
+
+	timeout, err := parseDrainTimeout(c.Query("timeout_seconds"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.healthChecker != nil {
+		h.healthChecker.CancelTargetCheck(targetID)
+	}
+	drained, removed, err := h.stateMgr.DrainTarget(ctx, targetID, timeout)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.metrics.RecordEvent(&RoutingEvent{
+		Type:     EventTargetDrained,
+		TargetID: targetID,
+		Details: map[string]any{
+			"drained": drained,
+			"removed": removed,
+		},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "target drained",
+		"target_id": targetID,
+		"drained":   drained,
+		"removed":   removed,
+	})
+}
+
 // ================== Health ==================
 
 // streamHealthCheckDeadline is the overall deadline for streaming health checks.
@@ -555,13 +980,7 @@ func (h *Handlers) collectTargets(ctx context.Context, routeID string) ([]Target
 		if e != nil {
 			continue
 		}
-		for _, layer := range pipeline.Layers {
-			for _, target := range layer.Targets {
-				if target.Enabled {
-					targets = append(targets, target)
-				}
-			}
-		}
+		targets = append(targets, enabledTargets(pipeline)...)
 	}
 	return targets, nil
 }
@@ -596,6 +1015,10 @@ func (h *Handlers) triggerHealthCheckStream(c *gin.Context, targets []Target) {
 	// Launch concurrent health checks
 	for _, target := range targets {
 		go func(t Target) {
+			if isCredentialDisabled(h.authManager, t.CredentialID) {
+				resultCh <- credentialDisabledResult(&t)
+				return
+			}
 			result, err := h.healthChecker.CheckTarget(streamCtx, t.ID)
 			if err != nil {
 				resultCh <- &HealthResult{
@@ -1078,6 +1501,7 @@ func (h *Handlers) SimulateRoute(c *gin.Context) {
 	attemptIdx := 0
 
 	// Follow the exact same logic as ExecuteWithFailover
+	pipeline = withoutDisabledLayers(pipeline)
 	for layerIdx, layer := range pipeline.Layers {
 		h.engine.AdvanceRoundRobin(routeID, layer.Level)
 
@@ -0,0 +1,78 @@
+package unifiedrouting
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteTargetHeader names the target (by target ID) a request should be pinned
+// to. It is honoured only when Settings.AllowHeaderRouting is on.
+const RouteTargetHeader = "X-Route-Target"
+
+type bypassCooldownKey struct{}
+
+// withCooldownBypass marks ctx so target selection ignores cooldown state.
+func withCooldownBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCooldownKey{}, true)
+}
+
+func cooldownBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassCooldownKey{}).(bool)
+	return bypass
+}
+
+// applyHeaderRouting pins decision to the target named by RouteTargetHeader.
+// It returns decision unchanged when the header is absent or header routing is
+// disabled, and an InvalidRouteTargetError when the header names no serving
+// target of the route.
+func (e *DefaultRoutingEngine) applyHeaderRouting(ctx context.Context, decision *RoutingDecision) (context.Context, *RoutingDecision, error) {
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil || ginCtx.Request == nil {
+		return ctx, decision, nil
+	}
+	targetID := strings.TrimSpace(ginCtx.GetHeader(RouteTargetHeader))
+	if targetID == "" || e.configSvc == nil {
+		return ctx, decision, nil
+	}
+	settings, err := e.configSvc.GetSettings(ctx)
+	if err != nil || settings == nil || !settings.AllowHeaderRouting {
+		return ctx, decision, nil
+	}
+
+	for _, layer := range decision.Pipeline.Layers {
+		for _, target := range layer.Targets {
+			if target.ID != targetID || !target.Serves() {
+				continue
+			}
+			pinned := *decision
+			pipeline := *decision.Pipeline
+			pipeline.Layers = []Layer{{Level: layer.Level, Strategy: StrategyFirstAvailable, Targets: []Target{target}}}
+			pinned.Pipeline = &pipeline
+			if settings.HeaderRoutingBypassCooldown {
+				ctx = withCooldownBypass(ctx)
+			}
+			return ctx, &pinned, nil
+		}
+	}
+	return ctx, decision, &InvalidRouteTargetError{RouteID: decision.RouteID, TargetID: targetID}
+}
+
+// InvalidRouteTargetError is returned when RouteTargetHeader names a target
+// that is not an enabled, serving target of the resolved route.
+type InvalidRouteTargetError struct {
+	RouteID  string
+	TargetID string
+}
+
+func (e *InvalidRouteTargetError) Error() string {
+	return fmt.Sprintf("%s %q is not an enabled target of route %s", RouteTargetHeader, e.TargetID, e.RouteID)
+}
+
+// StatusCode reports 400 so handlers answer with Bad Request.
+func (e *InvalidRouteTargetError) StatusCode() int {
+	return http.StatusBadRequest
+}
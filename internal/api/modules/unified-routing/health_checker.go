@@ -1,7 +1,11 @@
 package unifiedrouting
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -9,6 +13,7 @@ import (
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
 )
 
 // HealthChecker performs health checks on routing targets.
@@ -24,13 +29,22 @@ type HealthChecker interface {
 	// Called after StartCooldownTimed to set up the per-target timer.
 	// Safe to call multiple times; replaces any existing scheduled check.
 	ScheduleTargetCheck(targetID string)
+	// CancelTargetCheck stops any scheduled check for a target, e.g. after it
+	// recovered by serving a real request successfully.
+	CancelTargetCheck(targetID string)
 
 	// Configuration
 	GetSettings(ctx context.Context) (*HealthCheckConfig, error)
 	UpdateSettings(ctx context.Context, settings *HealthCheckConfig) error
 
+	// TestCredential probes a credential with a model once, without a target:
+	// nothing is recorded in target state or history.
+	TestCredential(ctx context.Context, credentialID, model, prompt string) *CredentialTestResult
+
 	// History
 	GetHistory(ctx context.Context, filter HealthHistoryFilter) ([]*HealthResult, error)
+	// LatencyHistograms returns the probe latency histogram of each checked target.
+	LatencyHistograms() map[string]LatencyHistogram
 
 	// Background task control
 	Start(ctx context.Context) error
@@ -48,6 +62,14 @@ type DefaultHealthChecker struct {
 	mu         sync.RWMutex
 	history    []*HealthResult
 	maxHistory int
+	latencies  map[string]*LatencyHistogram // target ID -> probe latency
+
+	// probeSpacing spaces out probes that share a credential.
+	probeSpacing credentialSpacer
+	// checks keeps concurrent checks of one target from probing it twice.
+	checks checkGroup
+	// probes bounds the probes running at once across all checks.
+	probes probeLimiter
 
 	// Per-target scheduled health check timers.
 	// Each target in timed cooling gets its own timer that fires at CooldownEndsAt.
@@ -55,6 +77,10 @@ type DefaultHealthChecker struct {
 	scheduledTimers map[string]*time.Timer
 
 	running bool
+	// lifetime is the parent of every background check's context; Stop
+	// cancels it so in-flight probes do not hold up shutdown.
+	lifetime context.Context
+	cancel   context.CancelFunc
 }
 
 // NewHealthChecker creates a new health checker.
@@ -68,16 +94,53 @@ func NewHealthChecker(
 	if routeActivity == nil {
 		routeActivity = NewRouteActivityTracker()
 	}
-	return &DefaultHealthChecker{
+	checker := &DefaultHealthChecker{
 		configSvc:       configSvc,
 		stateMgr:        stateMgr,
 		metrics:         metrics,
 		authManager:     authManager,
 		routeActivity:   routeActivity,
-		history:         make([]*HealthResult, 0, 1000),
-		maxHistory:      1000,
+		maxHistory:      DefaultHealthHistorySize,
+		latencies:       make(map[string]*LatencyHistogram),
 		scheduledTimers: make(map[string]*time.Timer),
 	}
+	checker.lifetime, checker.cancel = context.WithCancel(context.Background())
+	checker.probes.setLimit(DefaultMaxConcurrentHealthChecks)
+	if configSvc != nil {
+		if cfg, _ := configSvc.GetHealthCheckConfig(context.Background()); cfg != nil {
+			checker.setHistorySize(cfg.HistorySize)
+			checker.probes.setLimit(cfg.MaxConcurrentChecks)
+		}
+		// Handlers run concurrently, so read the saved config rather than the
+		// event payload, which may be older than a later update's.
+		configSvc.Subscribe(func(event ConfigChangeEvent) {
+			if event.Type != "health_config_updated" {
+				return
+			}
+			if cfg, _ := configSvc.GetHealthCheckConfig(context.Background()); cfg != nil {
+				checker.setHistorySize(cfg.HistorySize)
+				checker.probes.setLimit(cfg.MaxConcurrentChecks)
+			}
+		})
+	}
+	return checker
+}
+
+// setHistorySize resizes the result history to size (0 for the default, at
+// most MaxHealthHistorySize), dropping the oldest results if it shrinks.
+func (h *DefaultHealthChecker) setHistorySize(size int) {
+	if size <= 0 {
+		size = DefaultHealthHistorySize
+	}
+	if size > MaxHealthHistorySize {
+		size = MaxHealthHistorySize
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.maxHistory = size
+	if len(h.history) > size {
+		h.history = append([]*HealthResult(nil), h.history[len(h.history)-size:]...)
+	}
 }
 
 func (h *DefaultHealthChecker) CheckAll(ctx context.Context) ([]*HealthResult, error) {
@@ -86,16 +149,18 @@ func (h *DefaultHealthChecker) CheckAll(ctx context.Context) ([]*HealthResult, e
 		return nil, err
 	}
 
-	var results []*HealthResult
+	// Collect every route's targets first so one worker pool bounds the
+	// whole run instead of one pool per route.
+	var targets []Target
 	for _, route := range routes {
-		routeResults, err := h.CheckRoute(ctx, route.ID)
+		pipeline, err := h.configSvc.GetPipeline(ctx, route.ID)
 		if err != nil {
 			continue
 		}
-		results = append(results, routeResults...)
+		targets = append(targets, enabledTargets(pipeline)...)
 	}
 
-	return results, nil
+	return h.checkTargets(ctx, targets), nil
 }
 
 func (h *DefaultHealthChecker) CheckRoute(ctx context.Context, routeID string) ([]*HealthResult, error) {
@@ -104,75 +169,121 @@ func (h *DefaultHealthChecker) CheckRoute(ctx context.Context, routeID string) (
 		return nil, err
 	}
 
-	var results []*HealthResult
+	return h.checkTargets(ctx, enabledTargets(pipeline)), nil
+}
+
+// enabledTargets returns the enabled targets of the pipeline's enabled layers,
+// in layer order.
+func enabledTargets(pipeline *Pipeline) []Target {
+	var targets []Target
 	for _, layer := range pipeline.Layers {
+		if layer.Disabled {
+			continue
+		}
 		for _, target := range layer.Targets {
-			if !target.Enabled {
-				continue
+			if target.Enabled {
+				targets = append(targets, target)
 			}
+		}
+	}
+	return targets
+}
+
+// checkTargets runs CheckTarget for each target on a bounded worker pool and
+// returns the results in target order. Targets sharing a credential are still
+// probed no closer together than HealthCheckCredentialIntervalMs.
+func (h *DefaultHealthChecker) checkTargets(ctx context.Context, targets []Target) []*HealthResult {
+	results := make([]*HealthResult, len(targets))
+	sem := make(chan struct{}, h.checkConcurrency(ctx))
+	var wg sync.WaitGroup
+	for i := range targets {
+		target := &targets[i]
+		// A credential switched off at the auth layer would fail every probe;
+		// report it once instead of churning unhealthy results and cooldowns.
+		if isCredentialDisabled(h.authManager, target.CredentialID) {
+			results[i] = credentialDisabledResult(target)
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, target *Target) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
 			result, err := h.CheckTarget(ctx, target.ID)
 			if err != nil {
-				results = append(results, &HealthResult{
+				result = &HealthResult{
 					TargetID:     target.ID,
 					CredentialID: target.CredentialID,
 					Model:        target.Model,
 					Status:       "unhealthy",
 					Message:      err.Error(),
 					CheckedAt:    time.Now(),
-				})
-				continue
+				}
 			}
-			results = append(results, result)
-		}
+			results[i] = result
+		}(i, target)
 	}
+	wg.Wait()
+	return results
+}
 
-	return results, nil
+// checkConcurrency returns the configured number of parallel target checks.
+func (h *DefaultHealthChecker) checkConcurrency(ctx context.Context) int {
+	if cfg, _ := h.configSvc.GetHealthCheckConfig(ctx); cfg != nil && cfg.HealthCheckConcurrency > 0 {
+		return cfg.HealthCheckConcurrency
+	}
+	return DefaultHealthCheckConcurrency
 }
 
 func (h *DefaultHealthChecker) CheckTarget(ctx context.Context, targetID string) (*HealthResult, error) {
+	return h.checkTarget(ctx, targetID, nil)
+}
+
+// checkTarget checks targetID and records the result, unless a check of the
+// target is already running; then it returns that check's result. settle, if
+// not nil, moves the target on from "checking" and is run once per check even
+// when the check was started by a plain CheckTarget (see checkGroup.do).
+func (h *DefaultHealthChecker) checkTarget(ctx context.Context, targetID string, settle func(*HealthResult, error)) (*HealthResult, error) {
+	result, _, err := h.checks.do(ctx, targetID, func() (*HealthResult, error) {
+		return h.runCheck(ctx, targetID)
+	}, settle)
+	return result, err
+}
+
+// runCheck probes targetID and records the result, state change and event.
+func (h *DefaultHealthChecker) runCheck(ctx context.Context, targetID string) (*HealthResult, error) {
 	// Find the target configuration
 	routes, err := h.configSvc.ListRoutes(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	var target *Target
-	for _, route := range routes {
-		pipeline, err := h.configSvc.GetPipeline(ctx, route.ID)
-		if err != nil {
-			continue
-		}
-		for _, layer := range pipeline.Layers {
-			for i := range layer.Targets {
-				if layer.Targets[i].ID == targetID {
-					target = &layer.Targets[i]
-					break
-				}
-			}
-			if target != nil {
-				break
-			}
-		}
-		if target != nil {
-			break
-		}
-	}
-
+	target := findTarget(ctx, h.configSvc, routes, targetID)
 	if target == nil {
 		return nil, &TargetNotFoundError{TargetID: targetID}
 	}
 
 	// Perform health check
-	result := h.performHealthCheck(ctx, target)
+	result, err := h.probe(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		// The caller gave up or the checker stopped mid-probe; the
+		// failure is not the target's.
+		return nil, err
+	}
 
 	// Record result
 	h.recordResult(result)
 
 	// Update state based on result
 	if result.Status == "healthy" {
-		h.stateMgr.RecordSuccess(ctx, targetID, time.Duration(result.LatencyMs)*time.Millisecond)
+		h.stateMgr.RecordProbeSuccess(ctx, targetID, time.Duration(result.LatencyMs)*time.Millisecond)
 	} else {
-		h.stateMgr.RecordFailure(ctx, targetID, result.Message)
+		h.stateMgr.RecordFailure(ctx, targetID, result.Message, 0)
 	}
 
 	// Record event
@@ -194,7 +305,66 @@ func (h *DefaultHealthChecker) CheckTarget(ctx context.Context, targetID string)
 	return result, nil
 }
 
+// findTarget returns the configured target with targetID from any of routes, or nil.
+func findTarget(ctx context.Context, configSvc ConfigService, routes []*Route, targetID string) *Target {
+	for _, route := range routes {
+		pipeline, err := configSvc.GetPipeline(ctx, route.ID)
+		if err != nil {
+			continue
+		}
+		for _, layer := range pipeline.Layers {
+			for i := range layer.Targets {
+				if layer.Targets[i].ID == targetID {
+					return &layer.Targets[i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isCredentialDisabled reports whether the credential exists and is disabled in the auth manager.
+func isCredentialDisabled(authManager *coreauth.Manager, credentialID string) bool {
+	if authManager == nil {
+		return false
+	}
+	auth, ok := authManager.GetByID(credentialID)
+	return ok && auth != nil && auth.Disabled
+}
+
+// credentialDisabledResult builds the health result reported for a target whose
+// credential is disabled; no probe is sent for it.
+func credentialDisabledResult(target *Target) *HealthResult {
+	return &HealthResult{
+		TargetID:     target.ID,
+		CredentialID: target.CredentialID,
+		Model:        target.Model,
+		Status:       HealthStatusCredentialDisabled,
+		Message:      "credential disabled",
+		CheckedAt:    time.Now(),
+	}
+}
+
+// probe runs performHealthCheck, or returns ctx's error if it ends before the
+// probe could run.
+func (h *DefaultHealthChecker) probe(ctx context.Context, target *Target) (*HealthResult, error) {
+	result := h.performHealthCheck(ctx, target)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 func (h *DefaultHealthChecker) performHealthCheck(ctx context.Context, target *Target) *HealthResult {
+	result, _, _ := h.runProbe(ctx, target, true)
+	return result
+}
+
+// runProbe probes target and returns the result together with the upstream
+// reply (its first chunk when streaming) and the probe's error, if any. When
+// spaced is false the probe skips the credential's probe spacing, for one-off
+// tests that should not delay its health checks.
+func (h *DefaultHealthChecker) runProbe(ctx context.Context, target *Target, spaced bool) (*HealthResult, []byte, error) {
 	result := &HealthResult{
 		TargetID:     target.ID,
 		CredentialID: target.CredentialID,
@@ -205,7 +375,7 @@ func (h *DefaultHealthChecker) performHealthCheck(ctx context.Context, target *T
 	if h.authManager == nil {
 		result.Status = "unhealthy"
 		result.Message = "auth manager unavailable"
-		return result
+		return result, nil, nil
 	}
 
 	// Find the auth entry for this credential
@@ -221,68 +391,223 @@ func (h *DefaultHealthChecker) performHealthCheck(ctx context.Context, target *T
 	if targetAuth == nil {
 		result.Status = "unhealthy"
 		result.Message = "credential not found"
-		return result
+		return result, nil, nil
 	}
 
 	if targetAuth.Disabled {
 		result.Status = "unhealthy"
 		result.Message = "credential disabled"
-		return result
+		return result, nil, nil
 	}
 
-	req, opts, err := healthcheck.BuildProbeRequest(targetAuth, target.Model)
-	if err != nil {
-		result.Status = "unhealthy"
-		result.Message = "failed to build request"
-		return result
-	}
-
-	// Get health check config for timeout
+	// Get health check config for timeout and probe settings
 	healthConfig, _ := h.configSvc.GetHealthCheckConfig(ctx)
 	if healthConfig == nil {
 		cfg := DefaultHealthCheckConfig()
 		healthConfig = &cfg
 	}
 
-	checkCtx, cancel := context.WithTimeout(usage.WithSkipUsage(ctx), time.Duration(healthConfig.CheckTimeoutSeconds)*time.Second)
+	// Wait for this credential's probe slot before the timeout starts, so
+	// queueing behind other targets on the same account is not a failure.
+	if spaced {
+		interval := time.Duration(healthConfig.HealthCheckCredentialIntervalMs) * time.Millisecond
+		if err := h.probeSpacing.wait(ctx, target.CredentialID, interval); err != nil {
+			result.Status = "unhealthy"
+			result.Message = fmt.Sprintf("waiting for credential probe slot: %v", err)
+			return result, nil, err
+		}
+	}
+	// Take a global probe slot only once this credential's turn has come, so
+	// targets queued on one credential do not hold slots others could use.
+	release, err := h.probes.acquire(ctx)
+	if err != nil {
+		result.Status = "unhealthy"
+		result.Message = fmt.Sprintf("waiting for probe slot: %v", err)
+		return result, nil, err
+	}
+	defer release()
+
+	checkCtx, cancel := context.WithTimeout(withTargetUpstream(usage.WithSkipUsage(ctx), h.configSvc, target), time.Duration(healthConfig.CheckTimeoutSeconds)*time.Second)
 	defer cancel()
 
+	mode := probeMode(healthConfig, target)
 	startTime := time.Now()
+	var reply []byte
+	var errProbe error
+	switch mode {
+	case HealthCheckModeModels:
+		errProbe = healthcheck.ProbeModels(checkCtx, targetAuth)
+	case HealthCheckModeTCP:
+		errProbe = healthcheck.ProbeTCP(checkCtx, targetAuth)
+	default:
+		reply, errProbe = h.probeCompletion(checkCtx, cancel, targetAuth, target, probeOptions(healthConfig, target))
+	}
+	result.LatencyMs = time.Since(startTime).Milliseconds()
+
+	if errProbe != nil {
+		class, _ := classifyProviderError(targetAuth.Provider, errProbe)
+		if checkCtx.Err() != nil {
+			// A probe cut short says nothing about the target's requests.
+			class = ErrorClassRetryable
+		}
+		if checkCtx.Err() == context.DeadlineExceeded {
+			errProbe = errHealthCheckTimeout
+		}
+		result.Status = "unhealthy"
+		result.ErrorClass = class.String()
+		result.Message = fmt.Sprintf("%s probe: %v", mode, errProbe)
+		return result, reply, errProbe
+	}
+	if mode == HealthCheckModeCompletion {
+		if errReply := checkProbeReply(healthConfig, reply); errReply != nil {
+			result.Status = "unhealthy"
+			result.ErrorClass = ErrorClassRetryable.String()
+			result.Message = fmt.Sprintf("%s probe: %v", mode, errReply)
+			return result, reply, errReply
+		}
+	}
+	result.Status = "healthy"
+	result.Message = fmt.Sprintf("%s probe ok", mode)
+	return result, reply, nil
+}
+
+var errHealthCheckTimeout = errors.New("health check timeout")
 
-	stream, err := h.authManager.ExecuteStreamWithAuth(checkCtx, targetAuth, req, opts)
+// checkProbeReply applies the configured success criteria to a completion
+// probe's reply, naming the criterion that failed in the error.
+func checkProbeReply(cfg *HealthCheckConfig, reply []byte) error {
+	if cfg.MinReplyBytes > 0 && len(reply) < cfg.MinReplyBytes {
+		return fmt.Errorf("reply failed min_reply_bytes: got %d bytes, want at least %d", len(reply), cfg.MinReplyBytes)
+	}
+	if !cfg.RequireValidReply {
+		return nil
+	}
+	docs, err := replyDocuments(reply)
 	if err != nil {
-		result.Status = "unhealthy"
-		result.Message = err.Error()
-		return result
+		return fmt.Errorf("reply failed require_valid_reply: %v", err)
+	}
+	for _, doc := range docs {
+		if errField := gjson.GetBytes(doc, "error"); errField.Exists() {
+			return fmt.Errorf("reply failed require_valid_reply: upstream error %s", truncateReply(errField.Raw))
+		}
+	}
+	return nil
+}
+
+// replyDocuments splits a probe reply into its JSON documents: the reply
+// itself when it is plain JSON, otherwise the data of each SSE event.
+func replyDocuments(reply []byte) ([][]byte, error) {
+	trimmed := bytes.TrimSpace(reply)
+	if len(trimmed) == 0 {
+		return nil, errors.New("empty reply")
+	}
+	if trimmed[0] == '{' || trimmed[0] == '[' {
+		if !gjson.ValidBytes(trimmed) {
+			return nil, errors.New("reply is not valid JSON")
+		}
+		return [][]byte{trimmed}, nil
+	}
+	var docs [][]byte
+	for _, line := range bytes.Split(trimmed, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		switch {
+		case len(line) == 0, line[0] == ':':
+		case bytes.HasPrefix(line, []byte("data:")):
+			data := bytes.TrimSpace(line[len("data:"):])
+			if string(data) == "[DONE]" {
+				continue
+			}
+			if !gjson.ValidBytes(data) {
+				return nil, errors.New("SSE data is not valid JSON")
+			}
+			docs = append(docs, data)
+		case bytes.HasPrefix(line, []byte("event:")), bytes.HasPrefix(line, []byte("id:")), bytes.HasPrefix(line, []byte("retry:")):
+		default:
+			return nil, fmt.Errorf("unexpected SSE line %s", truncateReply(string(line)))
+		}
+	}
+	if len(docs) == 0 {
+		return nil, errors.New("no SSE data in reply")
+	}
+	return docs, nil
+}
+
+// truncateReply shortens reply excerpts quoted in health check messages.
+func truncateReply(s string) string {
+	const max = 200
+	if len(s) > max {
+		return s[:max] + "..."
+	}
+	return s
+}
+
+// probeCompletion sends a minimal completion request and waits for the reply
+// (or, when streaming, just its first chunk), which it returns.
+func (h *DefaultHealthChecker) probeCompletion(ctx context.Context, cancel context.CancelFunc, auth *coreauth.Auth, target *Target, probe healthcheck.ProbeOptions) ([]byte, error) {
+	req, opts, err := healthcheck.BuildProbeRequestWithOptions(auth, target.Model, probe)
+	if err != nil {
+		return nil, errors.New("failed to build request")
+	}
+
+	if probe.NoStream {
+		resp, err := h.authManager.ExecuteWithAuth(ctx, auth, req, opts)
+		return resp.Payload, err
+	}
+
+	stream, err := h.authManager.ExecuteStreamWithAuth(ctx, auth, req, opts)
+	if err != nil {
+		return nil, err
 	}
 
 	// Wait for first chunk
 	select {
 	case chunk, ok := <-stream:
-		if ok {
-			if chunk.Err != nil {
-				result.Status = "unhealthy"
-				result.Message = chunk.Err.Error()
-			} else {
-				result.Status = "healthy"
-				result.LatencyMs = time.Since(startTime).Milliseconds()
-			}
-			// Drain remaining chunks
-			cancel()
-			go func() {
-				for range stream {
-				}
-			}()
-		} else {
-			result.Status = "unhealthy"
-			result.Message = "stream closed without data"
+		if !ok {
+			return nil, errors.New("stream closed without data")
 		}
-	case <-checkCtx.Done():
-		result.Status = "unhealthy"
-		result.Message = "health check timeout"
+		// Drain remaining chunks
+		cancel()
+		go func() {
+			for range stream {
+			}
+		}()
+		return chunk.Payload, chunk.Err
+	case <-ctx.Done():
+		return nil, errHealthCheckTimeout
 	}
+}
 
-	return result
+// probeMode returns the target's health check mode, falling back to the global one.
+func probeMode(cfg *HealthCheckConfig, target *Target) HealthCheckMode {
+	mode := cfg.HealthCheckMode
+	if target.HealthCheck != nil && target.HealthCheck.Mode != "" {
+		mode = target.HealthCheck.Mode
+	}
+	if mode == "" || !mode.IsValid() {
+		return HealthCheckModeCompletion
+	}
+	return mode
+}
+
+// probeOptions merges the global probe settings with the target's overrides.
+func probeOptions(cfg *HealthCheckConfig, target *Target) healthcheck.ProbeOptions {
+	probe := healthcheck.ProbeOptions{
+		Prompt:    cfg.HealthCheckPrompt,
+		MaxTokens: cfg.HealthCheckMaxTokens,
+		NoStream:  cfg.HealthCheckDisableStream,
+	}
+	if override := target.HealthCheck; override != nil {
+		if override.Prompt != "" {
+			probe.Prompt = override.Prompt
+		}
+		if override.MaxTokens > 0 {
+			probe.MaxTokens = override.MaxTokens
+		}
+		if override.DisableStream != nil {
+			probe.NoStream = *override.DisableStream
+		}
+	}
+	return probe
 }
 
 func (h *DefaultHealthChecker) recordResult(result *HealthResult) {
@@ -291,9 +616,28 @@ func (h *DefaultHealthChecker) recordResult(result *HealthResult) {
 
 	// Ring buffer behavior
 	if len(h.history) >= h.maxHistory {
-		h.history = h.history[1:]
+		h.history = h.history[len(h.history)-h.maxHistory+1:]
 	}
 	h.history = append(h.history, result)
+
+	if result.LatencyMs > 0 {
+		hist := h.latencies[result.TargetID]
+		if hist == nil {
+			hist = newLatencyHistogram()
+			h.latencies[result.TargetID] = hist
+		}
+		hist.Observe(time.Duration(result.LatencyMs) * time.Millisecond)
+	}
+}
+
+func (h *DefaultHealthChecker) LatencyHistograms() map[string]LatencyHistogram {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := make(map[string]LatencyHistogram, len(h.latencies))
+	for targetID, hist := range h.latencies {
+		out[targetID] = hist.clone()
+	}
+	return out
 }
 
 func (h *DefaultHealthChecker) GetSettings(ctx context.Context) (*HealthCheckConfig, error) {
@@ -340,6 +684,9 @@ func (h *DefaultHealthChecker) Start(ctx context.Context) error {
 		return nil
 	}
 	h.running = true
+	if h.lifetime.Err() != nil {
+		h.lifetime, h.cancel = context.WithCancel(context.Background())
+	}
 	h.mu.Unlock()
 
 	// Schedule checks for any targets already in timed cooling (e.g. after restart).
@@ -347,6 +694,7 @@ func (h *DefaultHealthChecker) Start(ctx context.Context) error {
 	return nil
 }
 
+// Stop cancels scheduled checks and in-flight background probes.
 func (h *DefaultHealthChecker) Stop(ctx context.Context) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -356,6 +704,7 @@ func (h *DefaultHealthChecker) Stop(ctx context.Context) error {
 	}
 
 	h.running = false
+	h.cancel()
 
 	// Cancel all per-target timers.
 	h.timerMu.Lock()
@@ -369,7 +718,9 @@ func (h *DefaultHealthChecker) Stop(ctx context.Context) error {
 }
 
 // scheduleExistingCoolingTargets scans all target states on startup and schedules
-// timers for any targets already in timed cooling.
+// timers for any targets already in timed cooling. ScheduleTargetCheck applies the
+// configured jitter, so targets whose cooldown expired during downtime are not all
+// checked the moment the service restarts.
 func (h *DefaultHealthChecker) scheduleExistingCoolingTargets() {
 	ctx := context.Background()
 	states, err := h.stateMgr.ListTargetStates(ctx)
@@ -383,7 +734,8 @@ func (h *DefaultHealthChecker) scheduleExistingCoolingTargets() {
 	}
 }
 
-// ScheduleTargetCheck schedules a health check for the given target at its CooldownEndsAt time.
+// ScheduleTargetCheck schedules a health check for the given target at its CooldownEndsAt time,
+// shifted by up to HealthCheckJitterSeconds either way.
 // It reads the target's current state to determine when to fire.
 // Safe to call multiple times — replaces any existing scheduled check for this target.
 func (h *DefaultHealthChecker) ScheduleTargetCheck(targetID string) {
@@ -396,6 +748,11 @@ func (h *DefaultHealthChecker) ScheduleTargetCheck(targetID string) {
 	}
 
 	delay := time.Until(*state.CooldownEndsAt)
+	if h.configSvc != nil {
+		if cfg, _ := h.configSvc.GetHealthCheckConfig(ctx); cfg != nil {
+			delay = jitterDelay(delay, time.Duration(cfg.HealthCheckJitterSeconds)*time.Second)
+		}
+	}
 	if delay < 0 {
 		delay = 0 // already expired, check immediately
 	}
@@ -413,6 +770,34 @@ func (h *DefaultHealthChecker) ScheduleTargetCheck(targetID string) {
 	})
 }
 
+// CancelTargetCheck stops and removes the scheduled check for the given target, if any.
+func (h *DefaultHealthChecker) CancelTargetCheck(targetID string) {
+	h.timerMu.Lock()
+	defer h.timerMu.Unlock()
+
+	if t, ok := h.scheduledTimers[targetID]; ok {
+		t.Stop()
+		delete(h.scheduledTimers, targetID)
+	}
+}
+
+// jitterDelay shifts delay by a random amount in [-jitter, +jitter]. The
+// result may be negative; callers clamp it.
+func jitterDelay(delay, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return delay
+	}
+	return delay + time.Duration(rand.Int63n(int64(2*jitter)+1)) - jitter
+}
+
+// backgroundContext returns the context for checks that outlive the request
+// or timer that started them; it is cancelled by Stop.
+func (h *DefaultHealthChecker) backgroundContext() context.Context {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.lifetime
+}
+
 // onTargetCheckDue is the callback when a per-target timer fires.
 // It runs the health check and either recovers the target, reschedules, or moves to untimed.
 func (h *DefaultHealthChecker) onTargetCheckDue(targetID string) {
@@ -429,7 +814,7 @@ func (h *DefaultHealthChecker) onTargetCheckDue(targetID string) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx := h.backgroundContext()
 
 	// Verify target is still in timed cooling.
 	state, _ := h.stateMgr.GetTargetState(ctx, targetID)
@@ -440,12 +825,24 @@ func (h *DefaultHealthChecker) onTargetCheckDue(targetID string) {
 	// Transition to "checking" so the frontend shows "检查中" instead of "冷却中".
 	h.stateMgr.StartChecking(ctx, targetID)
 
-	// Run health check.
-	result, err := h.CheckTarget(ctx, targetID)
+	// Run health check; if another check is already probing the target, its
+	// result is shared and settled only once.
+	_, _ = h.checkTarget(ctx, targetID, func(result *HealthResult, err error) {
+		h.settleScheduledCheck(ctx, targetID, result, err)
+	})
+}
+
+// settleScheduledCheck acts on the outcome of a timer-driven check: it either
+// recovers the target, reschedules it, or moves it to untimed cooling.
+func (h *DefaultHealthChecker) settleScheduledCheck(ctx context.Context, targetID string, result *HealthResult, err error) {
+	if ctx.Err() != nil {
+		h.abandonCheck(targetID)
+		return
+	}
 	if err != nil {
 		log.Debugf("scheduled health check failed for target %s: %v", targetID, err)
-		// Reschedule with the same interval so we retry later.
-		interval := h.getCheckInterval(ctx)
+		// Reschedule with the backed-off interval so we retry later.
+		interval := h.stateMgr.CooldownInterval(ctx, targetID)
 		h.stateMgr.SetCooldownNextCheckIn(ctx, targetID, interval)
 		h.ScheduleTargetCheck(targetID)
 		return
@@ -454,14 +851,19 @@ func (h *DefaultHealthChecker) onTargetCheckDue(targetID string) {
 	if result.Status == "healthy" {
 		h.stateMgr.EndCooldown(ctx, targetID)
 		log.Infof("target %s recovered after scheduled health check", targetID)
+		h.startWarmup(ctx, targetID)
+		return
+	}
+
+	if h.markMisconfigured(ctx, result) {
 		return
 	}
 
 	// Still unhealthy — decide timed vs untimed by route activity.
 	routeID := h.getRouteIDForTarget(ctx, targetID)
 	if h.routeActivity.IsProcessing(routeID) {
-		// Route active → schedule next check after another interval.
-		interval := h.getCheckInterval(ctx)
+		// Route active → schedule next check after a longer interval.
+		interval := h.stateMgr.CooldownInterval(ctx, targetID)
 		h.stateMgr.SetCooldownNextCheckIn(ctx, targetID, interval)
 		h.ScheduleTargetCheck(targetID) // reschedule
 	} else {
@@ -470,12 +872,33 @@ func (h *DefaultHealthChecker) onTargetCheckDue(targetID string) {
 	}
 }
 
-// getCheckInterval returns the configured health check interval.
-func (h *DefaultHealthChecker) getCheckInterval(ctx context.Context) time.Duration {
-	if cfg, _ := h.configSvc.GetHealthCheckConfig(ctx); cfg != nil && cfg.CheckIntervalSeconds > 0 {
-		return time.Duration(cfg.CheckIntervalSeconds) * time.Second
+// abandonCheck puts a target whose check was cut short by Stop back into
+// untimed cooling: the probe result says nothing about the target, and the
+// next request through its route checks it again.
+func (h *DefaultHealthChecker) abandonCheck(targetID string) {
+	h.stateMgr.StartCooldownUntimed(context.Background(), targetID)
+}
+
+// markMisconfigured takes the target of a failed check out of rotation when
+// the probe's error is non-retryable, e.g. a 400 for a malformed model name:
+// rechecking would fail the same way forever, so the target waits for an
+// operator to fix and reset it. It reports whether the target was marked.
+func (h *DefaultHealthChecker) markMisconfigured(ctx context.Context, result *HealthResult) bool {
+	if result.ErrorClass != ErrorClassNonRetryable.String() {
+		return false
 	}
-	return 30 * time.Second
+	h.stateMgr.MarkMisconfigured(ctx, result.TargetID, result.Message)
+	log.Warnf("[UnifiedRouting] Target %s marked misconfigured after health check: %s", result.TargetID, result.Message)
+	h.metrics.RecordEvent(&RoutingEvent{
+		Type:     EventTargetMisconfigured,
+		RouteID:  h.getRouteIDForTarget(ctx, result.TargetID),
+		TargetID: result.TargetID,
+		Details: map[string]any{
+			"model":  result.Model,
+			"reason": result.Message,
+		},
+	})
+	return true
 }
 
 // getRouteIDForTarget returns the route ID that contains the given target, or "" if not found.
@@ -507,9 +930,9 @@ func (h *DefaultHealthChecker) getRouteIDForTarget(ctx context.Context, targetID
 // Called when a request arrives so these targets get a chance to recover.
 // Runs async; does not block the request.
 func (h *DefaultHealthChecker) TriggerCheckUntimedCoolingTargets(ctx context.Context, routeID string) {
-	// Use background context since this runs asynchronously and must not be
-	// cancelled when the originating HTTP request finishes.
-	bgCtx := context.Background()
+	// This runs asynchronously and must not be cancelled when the originating
+	// HTTP request finishes, only when the checker stops.
+	bgCtx := h.backgroundContext()
 
 	pipeline, err := h.configSvc.GetPipeline(bgCtx, routeID)
 	if err != nil {
@@ -518,8 +941,11 @@ func (h *DefaultHealthChecker) TriggerCheckUntimedCoolingTargets(ctx context.Con
 	now := time.Now()
 	var checkTargetIDs []string
 	for _, layer := range pipeline.Layers {
+		if layer.Disabled {
+			continue
+		}
 		for _, target := range layer.Targets {
-			if !target.Enabled {
+			if !target.Enabled || isCredentialDisabled(h.authManager, target.CredentialID) {
 				continue
 			}
 			state, _ := h.stateMgr.GetTargetState(bgCtx, target.ID)
@@ -540,23 +966,37 @@ func (h *DefaultHealthChecker) TriggerCheckUntimedCoolingTargets(ctx context.Con
 				defer wg.Done()
 				// Transition to "checking" so the frontend shows "检查中".
 				h.stateMgr.StartChecking(bgCtx, tid)
-				result, err := h.CheckTarget(bgCtx, tid)
-				if err != nil {
-					return
-				}
-				if result.Status == "healthy" {
-					h.stateMgr.EndCooldown(bgCtx, tid)
-					log.Infof("target %s recovered after on-request health check", tid)
-				} else {
-					h.stateMgr.StartCooldownTimed(bgCtx, tid)
-					h.ScheduleTargetCheck(tid)
-				}
+				_, _ = h.checkTarget(bgCtx, tid, func(result *HealthResult, err error) {
+					h.settleOnRequestCheck(bgCtx, tid, result, err)
+				})
 			}(targetID)
 		}
 		wg.Wait()
 	}()
 }
 
+// settleOnRequestCheck acts on the outcome of a check triggered by a request
+// to the target's route: it recovers the target or restarts its timed cooldown.
+func (h *DefaultHealthChecker) settleOnRequestCheck(ctx context.Context, targetID string, result *HealthResult, err error) {
+	if ctx.Err() != nil {
+		h.abandonCheck(targetID)
+		return
+	}
+	if err != nil {
+		// Leave it cooling untimed so the next request checks it again.
+		h.stateMgr.StartCooldownUntimed(ctx, targetID)
+		return
+	}
+	if result.Status == "healthy" {
+		h.stateMgr.EndCooldown(ctx, targetID)
+		log.Infof("target %s recovered after on-request health check", targetID)
+		h.startWarmup(ctx, targetID)
+	} else if !h.markMisconfigured(ctx, result) {
+		h.stateMgr.StartCooldownTimed(ctx, targetID)
+		h.ScheduleTargetCheck(targetID)
+	}
+}
+
 // TargetNotFoundError is returned when a target is not found.
 type TargetNotFoundError struct {
 	TargetID string
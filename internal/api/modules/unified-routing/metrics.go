@@ -28,6 +28,24 @@ type MetricsCollector interface {
 
 	// Real-time subscriptions
 	Subscribe(ctx context.Context) (<-chan MetricUpdate, error)
+
+	// AttemptCounts returns cumulative attempt totals per route, target and
+	// outcome since process start. Simulated requests are not counted.
+	AttemptCounts() []AttemptCount
+}
+
+// AttemptCount is the number of attempts a target made on a route with one outcome.
+type AttemptCount struct {
+	RouteID   string
+	RouteName string
+	TargetID  string
+	Status    AttemptStatus
+	Count     int64
+}
+
+type attemptCountKey struct {
+	routeID, routeName, targetID string
+	status                       AttemptStatus
 }
 
 // MetricUpdate represents a real-time metric update.
@@ -42,13 +60,17 @@ type DefaultMetricsCollector struct {
 	store       MetricsStore
 	mu          sync.RWMutex
 	subscribers []chan MetricUpdate
+
+	countsMu      sync.Mutex
+	attemptCounts map[attemptCountKey]int64
 }
 
 // NewMetricsCollector creates a new metrics collector.
 func NewMetricsCollector(store MetricsStore) *DefaultMetricsCollector {
 	return &DefaultMetricsCollector{
-		store:       store,
-		subscribers: make([]chan MetricUpdate, 0),
+		store:         store,
+		subscribers:   make([]chan MetricUpdate, 0),
+		attemptCounts: make(map[attemptCountKey]int64),
 	}
 }
 
@@ -60,6 +82,10 @@ func (c *DefaultMetricsCollector) RecordRequest(trace *RequestTrace) {
 		trace.Timestamp = time.Now()
 	}
 
+	if !trace.IsSimulated {
+		c.countAttempts(trace)
+	}
+
 	ctx := context.Background()
 	_ = c.store.RecordTrace(ctx, trace)
 
@@ -71,6 +97,31 @@ func (c *DefaultMetricsCollector) RecordRequest(trace *RequestTrace) {
 	})
 }
 
+func (c *DefaultMetricsCollector) countAttempts(trace *RequestTrace) {
+	c.countsMu.Lock()
+	defer c.countsMu.Unlock()
+	for _, attempt := range trace.Attempts {
+		key := attemptCountKey{routeID: trace.RouteID, routeName: trace.RouteName, targetID: attempt.TargetID, status: attempt.Status}
+		c.attemptCounts[key]++
+	}
+}
+
+func (c *DefaultMetricsCollector) AttemptCounts() []AttemptCount {
+	c.countsMu.Lock()
+	defer c.countsMu.Unlock()
+	counts := make([]AttemptCount, 0, len(c.attemptCounts))
+	for key, n := range c.attemptCounts {
+		counts = append(counts, AttemptCount{
+			RouteID:   key.routeID,
+			RouteName: key.routeName,
+			TargetID:  key.targetID,
+			Status:    key.status,
+			Count:     n,
+		})
+	}
+	return counts
+}
+
 func (c *DefaultMetricsCollector) RecordEvent(event *RoutingEvent) {
 	if event.ID == "" {
 		event.ID = "evt-" + uuid.New().String()[:8]
@@ -72,6 +72,14 @@ func WithDataDir(dir string) Option {
 	}
 }
 
+// WithStateStore sets the store for target runtime state. By default a
+// FileStateStore under the logs directory is used, so cooldowns survive restarts.
+func WithStateStore(store StateStore) Option {
+	return func(m *Module) {
+		m.stateStore = store
+	}
+}
+
 // WithSkipAutoRoutes skips automatic route registration in Register().
 // Use this when you want to register routes manually via RegisterRoutes().
 func WithSkipAutoRoutes() Option {
@@ -93,6 +101,9 @@ func (m *Module) SetDetailedLogger(logger *logging.DetailedRequestLogger) {
 	if m.handlers != nil {
 		m.handlers.detailedLogger = logger
 	}
+	if re, ok := m.engine.(*DefaultRoutingEngine); ok {
+		re.SetShadowLogger(logger)
+	}
 }
 
 // Name returns the module identifier.
@@ -162,14 +173,16 @@ func (m *Module) init(ctx modules.Context) error {
 		baseLogsDir := logging.ResolveLogDirectory(ctx.Config)
 		logsDir := filepath.Join(baseLogsDir, "unified-routing")
 
-		stateDir := filepath.Join(logsDir, "state")
-		stateStore, err := NewFileStateStore(stateDir)
-		if err != nil {
-			initErr = err
-			return
+		if m.stateStore == nil {
+			stateDir := filepath.Join(logsDir, "state")
+			stateStore, err := NewFileStateStore(stateDir)
+			if err != nil {
+				initErr = err
+				return
+			}
+			m.stateStore = stateStore
+			log.Infof("[UnifiedRouting] State directory: %s", stateDir)
 		}
-		m.stateStore = stateStore
-		log.Infof("[UnifiedRouting] State directory: %s", stateDir)
 		metricsStore, err := NewFileMetricsStore(logsDir, 100) // 100MB max for traces
 		if err != nil {
 			initErr = err
@@ -180,11 +193,17 @@ func (m *Module) init(ctx modules.Context) error {
 
 		// Initialize services
 		m.configSvc = NewConfigService(m.configStore)
+		if _, err := m.configSvc.ReloadClassificationRules(context.Background()); err != nil {
+			log.Warnf("[UnifiedRouting] Invalid classification rules, using defaults: %v", err)
+		}
 		m.stateMgr = NewStateManager(m.stateStore, m.configSvc)
 		m.metrics = NewMetricsCollector(m.metricsStore)
 		m.routeActivity = NewRouteActivityTracker()
 		m.healthChecker = NewHealthChecker(m.configSvc, m.stateMgr, m.metrics, m.authManager, m.routeActivity)
 		m.engine = NewRoutingEngine(m.configSvc, m.stateMgr, m.metrics, m.authManager, m.routeActivity, m.healthChecker)
+		if re, ok := m.engine.(*DefaultRoutingEngine); ok {
+			re.SetShadowLogger(m.detailedLogger)
+		}
 
 		// Initialize hook executor
 		hookScriptsDir := filepath.Join(dataDir, "hook-scripts")
@@ -249,6 +268,11 @@ func (m *Module) doRegisterRoutes(engine *gin.Engine, auth gin.HandlerFunc) {
 	ur.GET("/config/health-check", m.handlers.GetHealthCheckConfig)
 	ur.PUT("/config/health-check", m.handlers.PutHealthCheckConfig)
 
+	// Config: Error classification rules
+	ur.GET("/config/classification-rules", m.handlers.GetClassificationRules)
+	ur.PUT("/config/classification-rules", m.handlers.PutClassificationRules)
+	ur.POST("/config/classification-rules/reload", m.handlers.ReloadClassificationRules)
+
 	// Config: Routes
 	ur.GET("/config/routes", m.handlers.ListRoutes)
 	ur.POST("/config/routes", m.handlers.CreateRoute)
@@ -256,22 +280,34 @@ func (m *Module) doRegisterRoutes(engine *gin.Engine, auth gin.HandlerFunc) {
 	ur.PUT("/config/routes/:route_id", m.handlers.UpdateRoute)
 	ur.PATCH("/config/routes/:route_id", m.handlers.PatchRoute)
 	ur.DELETE("/config/routes/:route_id", m.handlers.DeleteRoute)
+	ur.POST("/config/routes/:route_id/clone", m.handlers.CloneRoute)
+	ur.GET("/config/archived-routes", m.handlers.ListArchivedRoutes)
+	ur.POST("/config/archived-routes/:route_id/restore", m.handlers.RestoreRoute)
+	ur.DELETE("/config/archived-routes/:route_id", m.handlers.PurgeRoute)
 
 	// Config: Pipeline
 	ur.GET("/config/routes/:route_id/pipeline", m.handlers.GetPipeline)
 	ur.PUT("/config/routes/:route_id/pipeline", m.handlers.UpdatePipeline)
+	ur.POST("/config/routes/:route_id/targets/enable", m.handlers.EnableRouteTargets)
+	ur.POST("/config/routes/:route_id/targets/disable", m.handlers.DisableRouteTargets)
+	ur.GET("/config/routes/:route_id/export", m.handlers.ExportRoute)
 
 	// Config: Export/Import
 	ur.GET("/config/export", m.handlers.ExportConfig)
 	ur.POST("/config/import", m.handlers.ImportConfig)
+	ur.GET("/config.yaml", m.handlers.ExportConfigYAML)
+	ur.POST("/config.yaml", m.handlers.ImportConfigYAML)
 	ur.POST("/config/validate", m.handlers.ValidateConfig)
 
 	// State
 	ur.GET("/state/overview", m.handlers.GetOverview)
 	ur.GET("/state/routes/:route_id", m.handlers.GetRouteStatus)
+	ur.GET("/state/targets", m.handlers.ListTargetStates)
 	ur.GET("/state/targets/:target_id", m.handlers.GetTargetStatus)
 	ur.POST("/state/targets/:target_id/reset", m.handlers.ResetTarget)
 	ur.POST("/state/targets/:target_id/force-cooldown", m.handlers.ForceCooldown)
+	ur.POST("/state/targets/:target_id/recover", m.handlers.RecoverTarget)
+	ur.POST("/state/targets/:target_id/drain", m.handlers.DrainTarget)
 
 	// Health
 	ur.POST("/health/check", m.handlers.TriggerHealthCheck)
@@ -290,11 +326,19 @@ func (m *Module) doRegisterRoutes(engine *gin.Engine, auth gin.HandlerFunc) {
 	ur.GET("/metrics/events", m.handlers.GetEvents)
 	ur.GET("/metrics/traces", m.handlers.GetTraces)
 	ur.GET("/metrics/traces/:trace_id", m.handlers.GetTrace)
+	ur.GET("/metrics/prometheus", m.handlers.GetPrometheusMetrics)
+
+	// Prometheus scrape endpoint at the conventional path, behind the same auth.
+	engine.GET("/metrics", auth, m.handlers.GetPrometheusMetrics)
+
+	// Public status for uptime monitors; off unless enabled in settings.
+	engine.GET("/status", m.handlers.GetPublicStatus)
 
 	// Credentials
 	ur.GET("/credentials", m.handlers.ListCredentials)
 	ur.GET("/credentials/:credential_id", m.handlers.GetCredential)
 	ur.PATCH("/credentials/:credential_id/status", m.handlers.PatchCredentialStatus)
+	ur.POST("/test-credential", m.handlers.TestCredential)
 
 	// Hooks
 	ur.GET("/hooks", m.handlers.ListHooks)
@@ -0,0 +1,45 @@
+package unifiedrouting
+
+import (
+	"context"
+	"sync"
+)
+
+// probeLimiter bounds the live health probes running at once across every
+// caller: scheduled checks, CheckAll and CheckRoute runs, on-request checks and
+// warmup. Probes beyond the limit wait for a slot. When the limit changes,
+// probes already running finish against the old limit.
+type probeLimiter struct {
+	mu    sync.Mutex
+	slots chan struct{}
+}
+
+// setLimit sets the number of concurrent probes; n <= 0 selects
+// DefaultMaxConcurrentHealthChecks.
+func (l *probeLimiter) setLimit(n int) {
+	if n <= 0 {
+		n = DefaultMaxConcurrentHealthChecks
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.slots == nil || cap(l.slots) != n {
+		l.slots = make(chan struct{}, n)
+	}
+}
+
+// acquire waits for a probe slot or for ctx to be done. The returned function
+// frees the slot.
+func (l *probeLimiter) acquire(ctx context.Context) (func(), error) {
+	l.mu.Lock()
+	if l.slots == nil {
+		l.slots = make(chan struct{}, DefaultMaxConcurrentHealthChecks)
+	}
+	slots := l.slots
+	l.mu.Unlock()
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
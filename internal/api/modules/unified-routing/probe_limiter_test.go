@@ -0,0 +1,85 @@
+package unifiedrouting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestProbeLimiterQueuesBeyondLimit(t *testing.T) {
+	var limiter probeLimiter
+	limiter.setLimit(2)
+
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		release, err := limiter.acquire(context.Background())
+		if err != nil {
+			t.Fatalf("acquire %d: %v", i, err)
+		}
+		releases = append(releases, release)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.acquire(ctx); err == nil {
+		t.Fatalf("third probe got a slot with the limit at 2")
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		release, err := limiter.acquire(context.Background())
+		if err == nil {
+			release()
+		}
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatalf("queued probe ran before a slot was freed")
+	case <-time.After(20 * time.Millisecond):
+	}
+	releases[0]()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatalf("queued probe did not run after a slot was freed")
+	}
+	releases[1]()
+}
+
+func TestSpacedProbesDoNotHoldGlobalSlots(t *testing.T) {
+	ctx := context.Background()
+	engine, configSvc, stateMgr := newFailoverTestEngine(t)
+	engine.authManager.RegisterExecutor(&probeStatusExecutor{})
+	if _, err := engine.authManager.Register(ctx, &coreauth.Auth{ID: "other", Provider: "openai"}); err != nil {
+		t.Fatalf("register auth: %v", err)
+	}
+	cfg, _ := configSvc.GetHealthCheckConfig(ctx)
+	cfg.HealthCheckCredentialIntervalMs = 2000
+	if err := configSvc.UpdateHealthCheckConfig(ctx, cfg); err != nil {
+		t.Fatalf("update health check config: %v", err)
+	}
+	checker := NewHealthChecker(configSvc, stateMgr, engine.metrics, engine.authManager, nil)
+	checker.probes.setLimit(1)
+
+	// The first probe on "cred" reserves its next slot two seconds out; the
+	// second waits for it.
+	if _, err := checker.probe(ctx, &Target{ID: "a", CredentialID: "cred", Model: "m"}); err != nil {
+		t.Fatalf("first probe: %v", err)
+	}
+	go checker.probe(ctx, &Target{ID: "b", CredentialID: "cred", Model: "m"})
+	time.Sleep(20 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		checker.probe(ctx, &Target{ID: "c", CredentialID: "other", Model: "m"})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("probe on another credential waited behind a spaced probe")
+	}
+}
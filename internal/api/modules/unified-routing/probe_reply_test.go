@@ -0,0 +1,75 @@
+package unifiedrouting
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestCheckProbeReply(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       HealthCheckConfig
+		reply     string
+		criterion string
+	}{
+		{name: "no criteria", reply: `data: {"error":{"message":"quota"}}`},
+		{name: "valid sse", cfg: HealthCheckConfig{RequireValidReply: true}, reply: "event: message\ndata: {\"choices\":[]}\n\n"},
+		{name: "valid json", cfg: HealthCheckConfig{RequireValidReply: true}, reply: `{"choices":[]}`},
+		{name: "sse error field", cfg: HealthCheckConfig{RequireValidReply: true}, reply: `data: {"error":{"message":"quota"}}`, criterion: "require_valid_reply"},
+		{name: "json error field", cfg: HealthCheckConfig{RequireValidReply: true}, reply: `{"error":"bad key"}`, criterion: "require_valid_reply"},
+		{name: "bad sse data", cfg: HealthCheckConfig{RequireValidReply: true}, reply: `data: {"choices":`, criterion: "require_valid_reply"},
+		{name: "not sse", cfg: HealthCheckConfig{RequireValidReply: true}, reply: `<html>oops</html>`, criterion: "require_valid_reply"},
+		{name: "empty", cfg: HealthCheckConfig{RequireValidReply: true}, criterion: "require_valid_reply"},
+		{name: "long enough", cfg: HealthCheckConfig{MinReplyBytes: 4}, reply: "pong"},
+		{name: "too short", cfg: HealthCheckConfig{MinReplyBytes: 5}, reply: "pong", criterion: "min_reply_bytes"},
+	}
+	for _, tt := range tests {
+		err := checkProbeReply(&tt.cfg, []byte(tt.reply))
+		if tt.criterion == "" {
+			if err != nil {
+				t.Fatalf("%s: unexpected error %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.criterion) {
+			t.Fatalf("%s: got %v, want failure of %s", tt.name, err, tt.criterion)
+		}
+	}
+}
+
+// errorBodyProbeExecutor streams a provider error inside a successful reply.
+type errorBodyProbeExecutor struct {
+	probeStatusExecutor
+}
+
+func (e *errorBodyProbeExecutor) ExecuteStream(ctx context.Context, auth *coreauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	chunks := make(chan cliproxyexecutor.StreamChunk, 1)
+	chunks <- cliproxyexecutor.StreamChunk{Payload: []byte(`data: {"error":{"message":"quota exceeded"}}`)}
+	close(chunks)
+	return &cliproxyexecutor.StreamResult{Chunks: chunks}, nil
+}
+
+func TestProbeReplyCriteriaFailErrorBodies(t *testing.T) {
+	ctx := context.Background()
+	engine, configSvc, stateMgr := newFailoverTestEngine(t)
+	engine.authManager.RegisterExecutor(&errorBodyProbeExecutor{})
+	checker := NewHealthChecker(configSvc, stateMgr, engine.metrics, engine.authManager, nil)
+
+	if got := checker.TestCredential(ctx, "cred", "m", "ping"); got.Status != "healthy" {
+		t.Fatalf("without criteria: got %+v, want healthy", got)
+	}
+
+	cfg, _ := configSvc.GetHealthCheckConfig(ctx)
+	cfg.RequireValidReply = true
+	if err := configSvc.UpdateHealthCheckConfig(ctx, cfg); err != nil {
+		t.Fatalf("update health check config: %v", err)
+	}
+	got := checker.TestCredential(ctx, "cred", "m", "ping")
+	if got.Status != "unhealthy" || !strings.Contains(got.Message, "require_valid_reply") {
+		t.Fatalf("with require_valid_reply: got %+v", got)
+	}
+}
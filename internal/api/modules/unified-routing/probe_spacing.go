@@ -0,0 +1,48 @@
+package unifiedrouting
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// credentialSpacer hands out probe start times per credential, at least the
+// configured interval apart. Slots are reserved up front, so concurrent
+// callers queue in arrival order rather than all waking at once.
+type credentialSpacer struct {
+	mu   sync.Mutex
+	next map[string]time.Time // credential ID -> earliest start of the next probe
+}
+
+// wait blocks until the caller may probe credentialID, or until ctx is done.
+// A non-positive interval returns immediately.
+func (s *credentialSpacer) wait(ctx context.Context, credentialID string, interval time.Duration) error {
+	if interval <= 0 || credentialID == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	if s.next == nil {
+		s.next = make(map[string]time.Time)
+	}
+	now := time.Now()
+	slot := s.next[credentialID]
+	if slot.Before(now) {
+		slot = now
+	}
+	s.next[credentialID] = slot.Add(interval)
+	s.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
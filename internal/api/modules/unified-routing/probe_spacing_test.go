@@ -0,0 +1,51 @@
+package unifiedrouting
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCredentialSpacerSpacesConcurrentProbes(t *testing.T) {
+	var spacer credentialSpacer
+	const interval = 40 * time.Millisecond
+	start := time.Now()
+
+	var mu sync.Mutex
+	var starts []time.Duration
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := spacer.wait(context.Background(), "shared", interval); err != nil {
+				t.Errorf("wait: %v", err)
+			}
+			mu.Lock()
+			starts = append(starts, time.Since(start))
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	for i := 1; i < len(starts); i++ {
+		if gap := starts[i] - starts[i-1]; gap < interval-5*time.Millisecond {
+			t.Fatalf("probes %d and %d started %v apart, want at least %v", i-1, i, gap, interval)
+		}
+	}
+
+	// Other credentials are not held up.
+	before := time.Now()
+	if err := spacer.wait(context.Background(), "other", interval); err != nil || time.Since(before) > 10*time.Millisecond {
+		t.Fatalf("unrelated credential waited %v (err %v)", time.Since(before), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := spacer.wait(ctx, "shared", interval); err == nil {
+		t.Fatalf("wait on a busy credential with a cancelled context should fail")
+	}
+}
@@ -0,0 +1,208 @@
+package unifiedrouting
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the health-check latency
+// histogram buckets.
+var latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// LatencyHistogram is a cumulative latency histogram over latencyBuckets.
+type LatencyHistogram struct {
+	Counts []uint64 // per bucket, non-cumulative; len(latencyBuckets)+1 with +Inf last
+	Sum    float64  // seconds
+	Count  uint64
+}
+
+func newLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{Counts: make([]uint64, len(latencyBuckets)+1)}
+}
+
+// Observe records one latency sample.
+func (h *LatencyHistogram) Observe(d time.Duration) {
+	seconds := d.Seconds()
+	i := sort.SearchFloat64s(latencyBuckets, seconds)
+	h.Counts[i]++
+	h.Sum += seconds
+	h.Count++
+}
+
+func (h *LatencyHistogram) clone() LatencyHistogram {
+	return LatencyHistogram{Counts: append([]uint64(nil), h.Counts...), Sum: h.Sum, Count: h.Count}
+}
+
+// promTargetStatuses are the values of the status label on the target status gauge.
+var promTargetStatuses = []TargetStatus{StatusHealthy, StatusHalfOpen, StatusCooling, StatusChecking, StatusMisconfigured, StatusDraining}
+
+// promTarget is a configured target with the route it belongs to.
+type promTarget struct {
+	routeID, routeName string
+	target             Target
+}
+
+// GetPrometheusMetrics serves routing and target metrics in the Prometheus
+// text exposition format.
+func (h *Handlers) GetPrometheusMetrics(c *gin.Context) {
+	body, err := h.renderPrometheus(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", body)
+}
+
+func (h *Handlers) renderPrometheus(ctx context.Context) ([]byte, error) {
+	targets, err := h.configuredTargets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	states, err := h.stateMgr.ListTargetStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stateByID := make(map[string]*TargetState, len(states))
+	for _, state := range states {
+		stateByID[state.TargetID] = state
+	}
+	routeOf := make(map[string]promTarget, len(targets))
+	for _, t := range targets {
+		routeOf[t.target.ID] = t
+	}
+
+	var buf bytes.Buffer
+
+	counts := h.metrics.AttemptCounts()
+	sort.Slice(counts, func(i, j int) bool {
+		a, b := counts[i], counts[j]
+		if a.RouteName != b.RouteName {
+			return a.RouteName < b.RouteName
+		}
+		if a.TargetID != b.TargetID {
+			return a.TargetID < b.TargetID
+		}
+		return a.Status < b.Status
+	})
+	writePromHeader(&buf, "unified_routing_target_attempts_total", "counter", "Upstream attempts per route target, by outcome.")
+	for _, n := range counts {
+		writePromSample(&buf, "unified_routing_target_attempts_total",
+			promLabels("route", n.RouteName, "route_id", n.RouteID, "target", n.TargetID, "status", string(n.Status)), float64(n.Count))
+	}
+
+	writePromHeader(&buf, "unified_routing_target_status", "gauge", "Current target status; 1 for the active status label.")
+	for _, t := range targets {
+		status := StatusHealthy
+		if state := stateByID[t.target.ID]; state != nil {
+			status = state.Status
+		}
+		for _, s := range promTargetStatuses {
+			value := 0.0
+			if s == status {
+				value = 1
+			}
+			writePromSample(&buf, "unified_routing_target_status", targetLabels(t, "status", string(s)), value)
+		}
+	}
+
+	writePromHeader(&buf, "unified_routing_target_in_flight", "gauge", "Requests currently being served by the target.")
+	for _, t := range targets {
+		var inFlight int64
+		if state := stateByID[t.target.ID]; state != nil {
+			inFlight = state.InFlight
+		}
+		writePromSample(&buf, "unified_routing_target_in_flight", targetLabels(t), float64(inFlight))
+	}
+
+	if h.healthChecker != nil {
+		histograms := h.healthChecker.LatencyHistograms()
+		ids := make([]string, 0, len(histograms))
+		for id := range histograms {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		name := "unified_routing_health_check_latency_seconds"
+		writePromHeader(&buf, name, "histogram", "Latency of target health-check probes.")
+		for _, id := range ids {
+			hist := histograms[id]
+			t, ok := routeOf[id]
+			if !ok {
+				t = promTarget{target: Target{ID: id}}
+			}
+			var cumulative uint64
+			for i, bound := range latencyBuckets {
+				cumulative += hist.Counts[i]
+				writePromSample(&buf, name+"_bucket", targetLabels(t, "le", strconv.FormatFloat(bound, 'g', -1, 64)), float64(cumulative))
+			}
+			writePromSample(&buf, name+"_bucket", targetLabels(t, "le", "+Inf"), float64(hist.Count))
+			writePromSample(&buf, name+"_sum", targetLabels(t), hist.Sum)
+			writePromSample(&buf, name+"_count", targetLabels(t), float64(hist.Count))
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// configuredTargets lists every target of every route, ordered by route name
+// and pipeline position.
+func (h *Handlers) configuredTargets(ctx context.Context) ([]promTarget, error) {
+	routes, err := h.configSvc.ListRoutes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Name < routes[j].Name })
+
+	var targets []promTarget
+	for _, route := range routes {
+		pipeline, err := h.configSvc.GetPipeline(ctx, route.ID)
+		if err != nil || pipeline == nil {
+			continue
+		}
+		for _, layer := range pipeline.Layers {
+			for _, target := range layer.Targets {
+				targets = append(targets, promTarget{routeID: route.ID, routeName: route.Name, target: target})
+			}
+		}
+	}
+	return targets, nil
+}
+
+func targetLabels(t promTarget, extra ...string) string {
+	pairs := []string{"route", t.routeName, "route_id", t.routeID, "target", t.target.ID, "credential", t.target.CredentialID}
+	return promLabels(append(pairs, extra...)...)
+}
+
+// promLabels formats alternating name/value pairs as a Prometheus label set.
+func promLabels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(promLabelEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writePromHeader(buf *bytes.Buffer, name, kind, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writePromSample(buf *bytes.Buffer, name, labels string, value float64) {
+	fmt.Fprintf(buf, "%s%s %s\n", name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+}
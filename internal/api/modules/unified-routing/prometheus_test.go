@@ -0,0 +1,78 @@
+package unifiedrouting
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderPrometheus(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfgStore, err := NewFileConfigStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileConfigStore: %v", err)
+	}
+	configSvc := NewConfigService(cfgStore)
+	metricsStore, err := NewFileMetricsStore(dir, 0)
+	if err != nil {
+		t.Fatalf("NewFileMetricsStore: %v", err)
+	}
+	metrics := NewMetricsCollector(metricsStore)
+	stateMgr := NewStateManager(NewMemoryStateStore(), configSvc)
+	checker := NewHealthChecker(configSvc, stateMgr, metrics, nil, nil)
+	h := &Handlers{configSvc: configSvc, stateMgr: stateMgr, metrics: metrics, healthChecker: checker}
+
+	route := &Route{Name: "chat", Enabled: true}
+	if err := configSvc.CreateRoute(ctx, route); err != nil {
+		t.Fatalf("CreateRoute: %v", err)
+	}
+	pipeline := &Pipeline{RouteID: route.ID, Layers: []Layer{{Level: 1, Strategy: StrategyRoundRobin, Targets: []Target{
+		{ID: "t1", CredentialID: "cred-1", Model: "m", Enabled: true},
+		{ID: "t2", CredentialID: "cred-2", Model: "m", Enabled: true},
+	}}}}
+	if _, err := configSvc.UpdatePipeline(ctx, route.ID, pipeline); err != nil {
+		t.Fatalf("UpdatePipeline: %v", err)
+	}
+
+	metrics.RecordRequest(&RequestTrace{RouteID: route.ID, RouteName: "chat", Attempts: []AttemptTrace{
+		{TargetID: "t1", Status: AttemptStatusFailed},
+		{TargetID: "t2", Status: AttemptStatusSuccess},
+	}})
+	metrics.RecordRequest(&RequestTrace{RouteID: route.ID, RouteName: "chat", IsSimulated: true, Attempts: []AttemptTrace{
+		{TargetID: "t2", Status: AttemptStatusSuccess},
+	}})
+	stateMgr.StartCooldownTimed(ctx, "t1")
+	checker.recordResult(&HealthResult{TargetID: "t2", LatencyMs: 300, CheckedAt: time.Now()})
+
+	out, err := h.renderPrometheus(ctx)
+	if err != nil {
+		t.Fatalf("renderPrometheus: %v", err)
+	}
+	labels := func(target, credential string) string {
+		return `route="chat",route_id="` + route.ID + `",target="` + target + `",credential="` + credential + `"`
+	}
+	for _, want := range []string{
+		`unified_routing_target_attempts_total{route="chat",route_id="` + route.ID + `",target="t1",status="failed"} 1`,
+		`unified_routing_target_attempts_total{route="chat",route_id="` + route.ID + `",target="t2",status="success"} 1`,
+		`unified_routing_target_status{` + labels("t1", "cred-1") + `,status="cooling"} 1`,
+		`unified_routing_target_status{` + labels("t1", "cred-1") + `,status="healthy"} 0`,
+		`unified_routing_target_status{` + labels("t2", "cred-2") + `,status="healthy"} 1`,
+		`unified_routing_target_in_flight{` + labels("t2", "cred-2") + `} 0`,
+		`unified_routing_health_check_latency_seconds_bucket{` + labels("t2", "cred-2") + `,le="0.25"} 0`,
+		`unified_routing_health_check_latency_seconds_bucket{` + labels("t2", "cred-2") + `,le="0.5"} 1`,
+		`unified_routing_health_check_latency_seconds_count{` + labels("t2", "cred-2") + `} 1`,
+		"# TYPE unified_routing_health_check_latency_seconds histogram",
+	} {
+		if !strings.Contains(string(out), want+"\n") {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestPromLabelsEscapesValues(t *testing.T) {
+	if got, want := promLabels("route", `a"b\c`+"\n"), `{route="a\"b\\c\n"}`; got != want {
+		t.Fatalf("promLabels = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,100 @@
+package unifiedrouting
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// geminiQuotaCooldown is the cooldown applied when Gemini reports an exhausted
+// quota without a Retry-After hint; quotas rarely recover within the usual
+// backoff, so a short cooldown would just bounce off the same limit.
+const geminiQuotaCooldown = 10 * time.Minute
+
+// providerOverride inspects an upstream failure from one provider family. When
+// matched is false the generic classification applies.
+type providerOverride func(status int, msg string) (class ErrorClass, cooldown time.Duration, matched bool)
+
+// providerOverrides maps a provider family to its classification override.
+var providerOverrides = map[string]providerOverride{
+	"anthropic": classifyAnthropicError,
+	"gemini":    classifyGeminiError,
+	"openai":    classifyOpenAIError,
+}
+
+// providerFamily maps an auth provider identifier to the upstream API family
+// whose error conventions it follows, or "" when unknown.
+func providerFamily(provider string) string {
+	switch strings.ToLower(provider) {
+	case "claude", "anthropic":
+		return "anthropic"
+	case "gemini", "gemini-cli", "vertex", "aistudio", "antigravity":
+		return "gemini"
+	case "codex", "openai":
+		return "openai"
+	default:
+		return ""
+	}
+}
+
+// ClassifyErrorForProvider is ClassifyError with provider-specific overrides
+// applied first. Unknown providers get the generic classification.
+func ClassifyErrorForProvider(provider string, err error) ErrorClass {
+	class, _ := classifyProviderError(provider, err)
+	return class
+}
+
+// classifyProviderError classifies err for provider and returns the cooldown
+// the provider's error calls for, or 0 to use the target's normal backoff.
+func classifyProviderError(provider string, err error) (ErrorClass, time.Duration) {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return ClassifyError(err), 0
+	}
+	if override, ok := providerOverrides[providerFamily(provider)]; ok {
+		if class, cooldown, matched := override(extractStatusCode(err), strings.ToLower(err.Error())); matched {
+			return class, cooldown
+		}
+	}
+	return ClassifyError(err), 0
+}
+
+// classifyAnthropicError keeps Anthropic's 529 "overloaded" retryable no matter
+// how the status rules treat 529.
+func classifyAnthropicError(status int, msg string) (ErrorClass, time.Duration, bool) {
+	if status == 529 || strings.Contains(msg, "overloaded_error") {
+		return ErrorClassRetryable, 0, true
+	}
+	return ErrorClassRetryable, 0, false
+}
+
+// classifyGeminiError treats RESOURCE_EXHAUSTED as a quota problem: retryable
+// on another target, but with a long cooldown for this one.
+func classifyGeminiError(status int, msg string) (ErrorClass, time.Duration, bool) {
+	if status == 429 && strings.Contains(msg, "resource_exhausted") {
+		return ErrorClassRetryable, geminiQuotaCooldown, true
+	}
+	return ErrorClassRetryable, 0, false
+}
+
+// openAIContentPolicyKeywords mark a 400 rejected by OpenAI's content filter.
+var openAIContentPolicyKeywords = []string{
+	"content_policy_violation",
+	"content_filter",
+	"content management policy",
+	"flagged by our safety system",
+}
+
+// classifyOpenAIError makes content-policy rejections non-retryable: every
+// OpenAI target applies the same filter, so failing over only burns attempts.
+func classifyOpenAIError(status int, msg string) (ErrorClass, time.Duration, bool) {
+	if status != 400 {
+		return ErrorClassRetryable, 0, false
+	}
+	for _, kw := range openAIContentPolicyKeywords {
+		if strings.Contains(msg, kw) {
+			return ErrorClassNonRetryable, 0, true
+		}
+	}
+	return ErrorClassRetryable, 0, false
+}
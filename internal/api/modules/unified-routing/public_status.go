@@ -0,0 +1,70 @@
+package unifiedrouting
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PublicStatus is the body of GET /status. It holds route counts only, never
+// route, target or credential details.
+type PublicStatus struct {
+	Status          string `json:"status"` // "healthy", "degraded" or "unhealthy"
+	HealthyRoutes   int    `json:"healthy_routes"`
+	DegradedRoutes  int    `json:"degraded_routes"`
+	UnhealthyRoutes int    `json:"unhealthy_routes"`
+}
+
+// publicStatus summarizes overview for uptime monitors and returns the HTTP
+// status to serve it with: 200 when every route is healthy, 503 when every
+// route is unhealthy, and 207 otherwise.
+func publicStatus(overview *StateOverview) (int, PublicStatus) {
+	status := PublicStatus{
+		Status:          "healthy",
+		HealthyRoutes:   overview.HealthyRoutes,
+		DegradedRoutes:  overview.DegradedRoutes,
+		UnhealthyRoutes: overview.UnhealthyRoutes,
+	}
+	switch {
+	case status.UnhealthyRoutes > 0 && status.HealthyRoutes+status.DegradedRoutes == 0:
+		status.Status = "unhealthy"
+		return http.StatusServiceUnavailable, status
+	case status.DegradedRoutes > 0 || status.UnhealthyRoutes > 0:
+		status.Status = "degraded"
+		return http.StatusMultiStatus, status
+	default:
+		return http.StatusOK, status
+	}
+}
+
+// GetPublicStatus serves GET /status for uptime monitors when
+// Settings.PublicStatus is on; otherwise the path does not exist. It needs no
+// management key, only Settings.PublicStatusKey (as ?key= or a Bearer token)
+// if one is set.
+func (h *Handlers) GetPublicStatus(c *gin.Context) {
+	settings, err := h.configSvc.GetSettings(c.Request.Context())
+	if err != nil || !settings.PublicStatus {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if settings.PublicStatusKey != "" {
+		key := c.Query("key")
+		if key == "" {
+			key = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(key), []byte(settings.PublicStatusKey)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid status key"})
+			return
+		}
+	}
+
+	overview, err := h.stateMgr.GetOverview(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "status unavailable"})
+		return
+	}
+	code, status := publicStatus(overview)
+	c.JSON(code, status)
+}
@@ -0,0 +1,85 @@
+package unifiedrouting
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPublicStatusMapsWorstRouteState(t *testing.T) {
+	tests := []struct {
+		name                        string
+		healthy, degraded, unhealth int
+		wantCode                    int
+		wantStatus                  string
+	}{
+		{name: "no routes", wantCode: http.StatusOK, wantStatus: "healthy"},
+		{name: "all healthy", healthy: 2, wantCode: http.StatusOK, wantStatus: "healthy"},
+		{name: "one degraded", healthy: 1, degraded: 1, wantCode: http.StatusMultiStatus, wantStatus: "degraded"},
+		{name: "some unhealthy", healthy: 1, unhealth: 1, wantCode: http.StatusMultiStatus, wantStatus: "degraded"},
+		{name: "all unhealthy", unhealth: 2, wantCode: http.StatusServiceUnavailable, wantStatus: "unhealthy"},
+	}
+	for _, tt := range tests {
+		code, status := publicStatus(&StateOverview{HealthyRoutes: tt.healthy, DegradedRoutes: tt.degraded, UnhealthyRoutes: tt.unhealth})
+		if code != tt.wantCode || status.Status != tt.wantStatus {
+			t.Fatalf("%s: got %d %q, want %d %q", tt.name, code, status.Status, tt.wantCode, tt.wantStatus)
+		}
+	}
+}
+
+func TestGetPublicStatus(t *testing.T) {
+	ctx := context.Background()
+	engine, configSvc, stateMgr := newFailoverTestEngine(t)
+	h := &Handlers{configSvc: configSvc, stateMgr: stateMgr, metrics: engine.metrics, healthChecker: engine.healthChecker}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/status", h.GetPublicStatus)
+	get := func(target, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("/status", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("disabled status = %d, want 404", rec.Code)
+	}
+
+	route := &Route{Name: "monitored", Enabled: true}
+	if err := configSvc.CreateRoute(ctx, route); err != nil {
+		t.Fatalf("CreateRoute: %v", err)
+	}
+	if _, err := configSvc.UpdatePipeline(ctx, route.ID, &Pipeline{Layers: []Layer{
+		{Level: 1, Targets: []Target{{ID: "secret-target", CredentialID: "cred", Model: "m", Enabled: true}}},
+	}}); err != nil {
+		t.Fatalf("UpdatePipeline: %v", err)
+	}
+	if err := configSvc.UpdateSettings(ctx, &Settings{PublicStatus: true, PublicStatusKey: "k3y"}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+
+	if rec := get("/status", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status without key = %d, want 401", rec.Code)
+	}
+	rec := get("/status?key=k3y", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"healthy"`) {
+		t.Fatalf("status = %d %s, want 200 healthy", rec.Code, rec.Body.String())
+	}
+
+	stateMgr.StartCooldownTimed(ctx, "secret-target")
+	rec = get("/status", "Bearer k3y")
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"unhealthy_routes":1`) {
+		t.Fatalf("status = %d %s, want 503 with one unhealthy route", rec.Code, rec.Body.String())
+	}
+	if body := rec.Body.String(); strings.Contains(body, "secret-target") || strings.Contains(body, "cred") {
+		t.Fatalf("status leaks target details: %s", body)
+	}
+}
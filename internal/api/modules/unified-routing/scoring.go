@@ -0,0 +1,67 @@
+package unifiedrouting
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// scoringConfig returns the configured scoring weights, or the defaults when
+// none are set or configSvc is nil.
+func scoringConfig(ctx context.Context, configSvc ConfigService) *ScoringConfig {
+	if configSvc != nil {
+		if settings, _ := configSvc.GetSettings(ctx); settings != nil && settings.Scoring != nil {
+			return settings.Scoring
+		}
+	}
+	return DefaultScoringConfig()
+}
+
+// targetScore ranks a target for the best-score strategy; lower is better.
+// It adds the decayed average latency, the recent error rate (fading with the
+// time since the last failure) and the current in-flight requests, each
+// scaled by its weight in cfg. A target without history scores 0, so new
+// targets are sampled first as with least-latency.
+func targetScore(state *TargetState, cfg *ScoringConfig, now time.Time) float64 {
+	if state == nil {
+		return 0
+	}
+	score := cfg.LatencyWeight * effectiveLatencyMs(state, now)
+
+	if state.TotalRequests > 0 && state.LastFailureAt != nil {
+		errorRate := float64(state.TotalRequests-state.SuccessfulRequests) / float64(state.TotalRequests)
+		penalty := cfg.FailureWeight * errorRate
+		if cfg.FailureHalfLifeSeconds > 0 {
+			if age := now.Sub(*state.LastFailureAt); age > 0 {
+				penalty *= math.Pow(0.5, age.Seconds()/float64(cfg.FailureHalfLifeSeconds))
+			}
+		}
+		score += penalty
+	}
+
+	return score + cfg.InFlightWeight*float64(state.InFlight)
+}
+
+// selectBestScore picks the target with the lowest score. Scores are
+// recomputed here rather than read from TargetState.Score so that decay and
+// in-flight requests are current.
+func (e *DefaultRoutingEngine) selectBestScore(ctx context.Context, targets []Target) *Target {
+	cfg := scoringConfig(ctx, e.configSvc)
+	now := time.Now()
+	best := -1.0
+	var selected *Target
+
+	for i := range targets {
+		state, _ := e.stateMgr.GetTargetState(ctx, targets[i].ID)
+		score := targetScore(state, cfg, now)
+		if best < 0 || score < best {
+			best = score
+			selected = &targets[i]
+		}
+	}
+
+	if selected == nil {
+		return &targets[0]
+	}
+	return selected
+}
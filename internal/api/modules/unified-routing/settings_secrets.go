@@ -0,0 +1,82 @@
+package unifiedrouting
+
+import (
+	"net/url"
+	"strings"
+)
+
+// maskedSecret stands in for a secret in settings returned by the API. A PUT
+// that sends it back keeps the stored value.
+const maskedSecret = "********"
+
+// maskSettingsSecrets returns a copy of settings with every secret replaced by
+// maskedSecret: the webhook secret, the public status key and the upstream
+// proxy's credentials.
+func maskSettingsSecrets(settings *Settings) *Settings {
+	if settings == nil {
+		return nil
+	}
+	masked := *settings
+	if masked.WebhookSecret != "" {
+		masked.WebhookSecret = maskedSecret
+	}
+	if masked.PublicStatusKey != "" {
+		masked.PublicStatusKey = maskedSecret
+	}
+	masked.UpstreamProxy = rewriteProxyUserinfo(masked.UpstreamProxy, func(user *url.Userinfo) string {
+		if _, ok := user.Password(); ok {
+			return url.User(user.Username()).String() + ":" + maskedSecret + "@"
+		}
+		// ss:// URLs carry the method and password in the username.
+		return maskedSecret + "@"
+	})
+	return &masked
+}
+
+// stripSettingsSecrets returns a copy of settings without any secrets, for
+// exports and webhook payloads that leave the instance.
+func stripSettingsSecrets(settings *Settings) *Settings {
+	if settings == nil {
+		return nil
+	}
+	stripped := *settings
+	stripped.WebhookSecret = ""
+	stripped.PublicStatusKey = ""
+	stripped.UpstreamProxy = rewriteProxyUserinfo(stripped.UpstreamProxy, func(*url.Userinfo) string { return "" })
+	return &stripped
+}
+
+// restoreSettingsSecrets puts current's secrets back into settings wherever
+// settings still holds the redacted form produced from current, so saving
+// masked or stripped settings does not overwrite the stored secrets.
+func restoreSettingsSecrets(settings, current, redacted *Settings) {
+	if settings == nil || current == nil || redacted == nil {
+		return
+	}
+	if current.WebhookSecret != "" && settings.WebhookSecret == redacted.WebhookSecret {
+		settings.WebhookSecret = current.WebhookSecret
+	}
+	if current.PublicStatusKey != "" && settings.PublicStatusKey == redacted.PublicStatusKey {
+		settings.PublicStatusKey = current.PublicStatusKey
+	}
+	if current.UpstreamProxy != redacted.UpstreamProxy && settings.UpstreamProxy == redacted.UpstreamProxy {
+		settings.UpstreamProxy = current.UpstreamProxy
+	}
+}
+
+// rewriteProxyUserinfo replaces the userinfo of proxyURL with the literal
+// (unescaped, "@"-terminated) string rewrite returns; URLs without credentials,
+// or that do not parse, are returned unchanged.
+func rewriteProxyUserinfo(proxyURL string, rewrite func(*url.Userinfo) string) string {
+	if proxyURL == "" {
+		return proxyURL
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.User == nil || u.Scheme == "" {
+		return proxyURL
+	}
+	userinfo := rewrite(u.User)
+	u.User = nil
+	rest := strings.TrimPrefix(u.String(), u.Scheme+"://")
+	return u.Scheme + "://" + userinfo + rest
+}
@@ -0,0 +1,116 @@
+package unifiedrouting
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+// shadowRequestTimeout bounds each mirrored request; shadows outlive the client
+// request, so they cannot use its context.
+const shadowRequestTimeout = 5 * time.Minute
+
+// maxInFlightShadows bounds the mirrored requests running at once across all
+// routes; further mirrors are dropped until some finish.
+const maxInFlightShadows = 64
+
+// ShadowExecuteFunc sends a mirrored copy of the client request to auth using
+// model and returns the upstream response body.
+type ShadowExecuteFunc func(ctx context.Context, auth *coreauth.Auth, model string) ([]byte, error)
+
+// SetShadowLogger sets the detailed logger that receives shadow records.
+func (e *DefaultRoutingEngine) SetShadowLogger(logger *logging.DetailedRequestLogger) {
+	e.mu.Lock()
+	e.shadowLogger = logger
+	e.mu.Unlock()
+}
+
+// MirrorToShadows asynchronously sends the request to every sampled shadow
+// target of the decision's pipeline and logs each result as a shadow record.
+// It returns immediately; mirrors beyond maxInFlightShadows are dropped. Shadow results never touch target state, metrics or
+// the client response.
+func (e *DefaultRoutingEngine) MirrorToShadows(ctx context.Context, decision *RoutingDecision, requestBody []byte, fn ShadowExecuteFunc) {
+	if decision == nil || decision.Pipeline == nil || fn == nil {
+		return
+	}
+
+	var primaryID, url, method string
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		primaryID = logging.GetGinRequestID(ginCtx)
+		if ginCtx.Request != nil {
+			url = ginCtx.Request.URL.String()
+			method = ginCtx.Request.Method
+		}
+	}
+
+	for _, layer := range decision.Pipeline.Layers {
+		for _, target := range layer.Targets {
+			if !target.Enabled || !target.Shadow {
+				continue
+			}
+			if rand.Float64() >= target.ShadowSampleRate {
+				continue
+			}
+			auth, err := e.findAuth(target.CredentialID)
+			if err != nil {
+				log.Debugf("[UnifiedRouting] shadow target %s skipped: %v", target.ID, err)
+				continue
+			}
+			if e.shadowsInFlight.Add(1) > maxInFlightShadows {
+				e.shadowsInFlight.Add(-1)
+				log.Debugf("[UnifiedRouting] shadow target %s skipped: %d mirrors already in flight", target.ID, maxInFlightShadows)
+				continue
+			}
+			go func(target Target) {
+				defer e.shadowsInFlight.Add(-1)
+				e.runShadow(target, auth, primaryID, url, method, requestBody, fn)
+			}(target)
+		}
+	}
+}
+
+func (e *DefaultRoutingEngine) runShadow(target Target, auth *coreauth.Auth, primaryID, url, method string, requestBody []byte, fn ShadowExecuteFunc) {
+	ctx, cancel := context.WithTimeout(withTargetUpstream(context.Background(), e.configSvc, &target), shadowRequestTimeout)
+	defer cancel()
+
+	start := time.Now()
+	body, err := fn(ctx, auth, target.Model)
+	elapsed := time.Since(start)
+
+	e.mu.RLock()
+	logger := e.shadowLogger
+	e.mu.RUnlock()
+	if logger == nil || !logger.IsEnabled() {
+		return
+	}
+
+	record := &logging.DetailedRequestRecord{
+		ID:              fmt.Sprintf("%s-shadow-%s", primaryID, target.ID),
+		Timestamp:       start,
+		URL:             url,
+		Method:          method,
+		StatusCode:      200,
+		Model:           target.Model,
+		RequestBody:     string(requestBody),
+		ResponseBody:    string(body),
+		TotalDurationMs: elapsed.Milliseconds(),
+		IsShadow:        true,
+		ShadowOf:        primaryID,
+	}
+	if err != nil {
+		record.StatusCode = extractStatusCode(err)
+		if record.StatusCode == 0 {
+			record.StatusCode = 502
+		}
+		record.Error = err.Error()
+	}
+	// Shadow bodies go through the same redaction and size cap as the primary request.
+	logger.SanitizeBodies(record, false, false)
+	logger.LogRecord(record)
+}
@@ -2,8 +2,12 @@ package unifiedrouting
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	log "github.com/sirupsen/logrus"
 )
 
 // StateManager manages runtime state for unified routing.
@@ -16,16 +20,26 @@ type StateManager interface {
 
 	// State changes (called by engine and health checker)
 	RecordSuccess(ctx context.Context, targetID string, latency time.Duration)
-	RecordFailure(ctx context.Context, targetID string, reason string)
-	StartCooldownTimed(ctx context.Context, targetID string)   // next check in CheckIntervalSeconds
+	RecordProbeSuccess(ctx context.Context, targetID string, latency time.Duration) // health check success; does not close a half-open circuit
+	RecordFailure(ctx context.Context, targetID string, reason string, retryAfter time.Duration) // retryAfter > 0 sets the next cooldown
+	RecordFailureCooldown(ctx context.Context, targetID string, reason string, cooldown time.Duration, source string) // like RecordFailure; source names where cooldown came from
+	MarkUsed(ctx context.Context, targetID string) // target selected to serve a request
+	TryAcquire(ctx context.Context, targetID string, limit int) bool // reserve an in-flight slot; limit <= 0 is unlimited
+	Release(ctx context.Context, targetID string)                    // free a slot reserved by TryAcquire
+	StartCooldownTimed(ctx context.Context, targetID string)   // next check after CooldownInterval; keeps a timed cooldown already running
 	StartCooldownUntimed(ctx context.Context, targetID string)
 	StartChecking(ctx context.Context, targetID string)        // health check in progress
 	EndCooldown(ctx context.Context, targetID string)
+	SetWarmingUp(ctx context.Context, targetID string, warming bool) // recovered target being primed; deprioritized
 	SetCooldownNextCheckIn(ctx context.Context, targetID string, d time.Duration) // when cooling or checking
+	CooldownInterval(ctx context.Context, targetID string) time.Duration            // backoff before the target's next check
 
 	// Manual operations
 	ResetTarget(ctx context.Context, targetID string) error
 	ForceCooldown(ctx context.Context, targetID string) error
+	ForceHealthy(ctx context.Context, targetID string) error // end cooldown now, skipping half-open
+	MarkMisconfigured(ctx context.Context, targetID string, reason string) // cleared only by ResetTarget
+	DrainTarget(ctx context.Context, targetID string, timeout time.Duration) (drained, removed bool, err error) // stop new traffic, wait for in-flight requests
 
 	// Initialize/cleanup
 	InitializeTarget(ctx context.Context, targetID string) error
@@ -36,17 +50,53 @@ type StateManager interface {
 type DefaultStateManager struct {
 	store     StateStore
 	configSvc ConfigService
+	alerts    *alertDispatcher
+	scoring   atomic.Pointer[ScoringConfig] // cached Settings.Scoring, refreshed on settings changes
 	mu        sync.RWMutex
 	stopChan  chan struct{}
+
+	// In-flight counts change on every request and mean nothing after a
+	// restart, so they are kept here rather than in the state store and
+	// merged into the states GetTargetState and ListTargetStates return.
+	inFlightMu sync.Mutex
+	inFlight   map[string]int64
 }
 
 // NewStateManager creates a new state manager.
 func NewStateManager(store StateStore, configSvc ConfigService) *DefaultStateManager {
-	return &DefaultStateManager{
+	if _, ephemeral := store.(*MemoryStateStore); ephemeral {
+		log.Warn("[UnifiedRouting] target state is kept in memory only: cooldowns are lost on restart and failing targets are routed to again immediately; use FileStateStore to keep them")
+	}
+	m := &DefaultStateManager{
 		store:     store,
 		configSvc: configSvc,
+		alerts:    newAlertDispatcher(configSvc),
 		stopChan:  make(chan struct{}),
+		inFlight:  make(map[string]int64),
 	}
+	m.refreshScoringConfig()
+	if configSvc != nil {
+		configSvc.Subscribe(func(event ConfigChangeEvent) {
+			if event.Type == "settings_updated" || event.Type == "config_imported" {
+				m.refreshScoringConfig()
+			}
+		})
+	}
+	return m
+}
+
+// refreshScoringConfig reloads the scoring weights used when recording
+// results, so RecordSuccess and RecordFailure do not read the settings store
+// on every request.
+func (m *DefaultStateManager) refreshScoringConfig() {
+	m.scoring.Store(scoringConfig(context.Background(), m.configSvc))
+}
+
+// AddNotifier registers n to receive TargetAlerts when targets enter cooldown,
+// recover, or cross Settings.AlertFailureThreshold. Settings.AlertWebhookURL
+// is notified independently of registered notifiers.
+func (m *DefaultStateManager) AddNotifier(n Notifier) {
+	m.alerts.addNotifier(n)
 }
 
 func (m *DefaultStateManager) GetOverview(ctx context.Context) (*StateOverview, error) {
@@ -107,10 +157,21 @@ func (m *DefaultStateManager) GetRouteState(ctx context.Context, routeID string)
 	}
 
 	healthyTargets := 0
+	routableTargets := 0
 	totalTargets := 0
 	activeLayerFound := false
 
 	for _, layer := range pipeline.Layers {
+		// A disabled layer is parked on purpose: list it, but leave it out of
+		// the active layer and the route status.
+		if layer.Disabled {
+			routeState.LayerStates = append(routeState.LayerStates, LayerState{
+				Level:        layer.Level,
+				Status:       "disabled",
+				TargetStates: []*TargetState{},
+			})
+			continue
+		}
 		layerState := LayerState{
 			Level:        layer.Level,
 			Status:       "standby",
@@ -119,7 +180,6 @@ func (m *DefaultStateManager) GetRouteState(ctx context.Context, routeID string)
 
 		healthyInLayer := 0
 		for _, target := range layer.Targets {
-			totalTargets++
 			state, _ := m.store.GetTargetState(ctx, target.ID)
 			if state == nil {
 				state = &TargetState{
@@ -128,8 +188,21 @@ func (m *DefaultStateManager) GetRouteState(ctx context.Context, routeID string)
 				}
 			}
 
+			// Monitor-only targets are shown but never take traffic, so they
+			// do not count towards the layer or route status.
+			if target.MonitorOnly {
+				view := *state
+				view.MonitorOnly = true
+				layerState.TargetStates = append(layerState.TargetStates, &view)
+				continue
+			}
+
+			totalTargets++
 			if state.Status == StatusHealthy {
 				healthyTargets++
+			}
+			if state.Status.IsRoutable() {
+				routableTargets++
 				healthyInLayer++
 			}
 
@@ -151,7 +224,7 @@ func (m *DefaultStateManager) GetRouteState(ctx context.Context, routeID string)
 	// Determine overall route status
 	if healthyTargets == totalTargets {
 		routeState.Status = "healthy"
-	} else if healthyTargets == 0 {
+	} else if routableTargets == 0 {
 		routeState.Status = "unhealthy"
 	} else {
 		routeState.Status = "degraded"
@@ -166,14 +239,61 @@ func (m *DefaultStateManager) GetTargetState(ctx context.Context, targetID strin
 		return nil, err
 	}
 
-	return state, nil
+	return m.withInFlight(state), nil
 }
 
 func (m *DefaultStateManager) ListTargetStates(ctx context.Context) ([]*TargetState, error) {
-	return m.store.ListTargetStates(ctx)
+	states, err := m.store.ListTargetStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[string]bool, len(states))
+	for i, state := range states {
+		states[i] = m.withInFlight(state)
+		listed[state.TargetID] = true
+	}
+	// A target serving its first requests has no stored state yet.
+	m.inFlightMu.Lock()
+	var unlisted []string
+	for targetID := range m.inFlight {
+		if !listed[targetID] {
+			unlisted = append(unlisted, targetID)
+		}
+	}
+	m.inFlightMu.Unlock()
+	for _, targetID := range unlisted {
+		if state, _ := m.store.GetTargetState(ctx, targetID); state != nil {
+			states = append(states, m.withInFlight(state))
+		}
+	}
+	return states, nil
+}
+
+// withInFlight returns a copy of state carrying the target's in-flight count.
+func (m *DefaultStateManager) withInFlight(state *TargetState) *TargetState {
+	if state == nil {
+		return nil
+	}
+	withCount := *state
+	withCount.InFlight = m.inFlightCount(state.TargetID)
+	return &withCount
+}
+
+func (m *DefaultStateManager) inFlightCount(targetID string) int64 {
+	m.inFlightMu.Lock()
+	defer m.inFlightMu.Unlock()
+	return m.inFlight[targetID]
 }
 
 func (m *DefaultStateManager) RecordSuccess(ctx context.Context, targetID string, latency time.Duration) {
+	m.recordSuccess(ctx, targetID, latency, false)
+}
+
+func (m *DefaultStateManager) RecordProbeSuccess(ctx context.Context, targetID string, latency time.Duration) {
+	m.recordSuccess(ctx, targetID, latency, true)
+}
+
+func (m *DefaultStateManager) recordSuccess(ctx context.Context, targetID string, latency time.Duration, probe bool) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -181,18 +301,145 @@ func (m *DefaultStateManager) RecordSuccess(ctx context.Context, targetID string
 	if state == nil {
 		state = &TargetState{TargetID: targetID}
 	}
+	before, beforeFailures := state.Status, state.ConsecutiveFailures
 
 	now := time.Now()
-	state.Status = StatusHealthy
+	m.advanceRecovery(ctx, state, probe)
 	state.ConsecutiveFailures = 0
 	state.LastSuccessAt = &now
 	state.CooldownEndsAt = nil
+	state.RetryAfterUntil = nil
 	state.PushResult(true)
+	if latency > 0 {
+		state.PushLatency(latency)
+		ms := float64(latency) / float64(time.Millisecond)
+		if state.AvgLatencyMs == 0 {
+			state.AvgLatencyMs = ms
+		} else {
+			state.AvgLatencyMs = latencyEWMAAlpha*ms + (1-latencyEWMAAlpha)*state.AvgLatencyMs
+		}
+	}
+	state.Score = targetScore(state, m.scoring.Load(), now)
 
+	m.alerts.observe(before, beforeFailures, state)
 	_ = m.store.SetTargetState(ctx, state)
 }
 
-func (m *DefaultStateManager) RecordFailure(ctx context.Context, targetID string, reason string) {
+// latencyEWMAAlpha is the weight of the newest sample in TargetState.AvgLatencyMs.
+const latencyEWMAAlpha = 0.3
+
+// maxCooldownShift caps the cooldown backoff at 2^maxCooldownShift check intervals
+// (before MaxCooldownSeconds is applied).
+const maxCooldownShift = 10
+
+// advanceRecovery moves a target one step towards healthy after a success.
+// A cooling or checking target enters half-open (when enabled); a half-open
+// target becomes healthy once HalfOpenRequests successes of real requests have
+// been recorded; probe successes do not count towards them.
+// Caller must hold m.mu.
+func (m *DefaultStateManager) advanceRecovery(ctx context.Context, state *TargetState, probe bool) {
+	switch state.Status {
+	case StatusMisconfigured, StatusDraining:
+		return
+	case StatusCooling, StatusChecking:
+		if m.halfOpenRequests(ctx) > 0 {
+			state.Status = StatusHalfOpen
+			state.HalfOpenSuccesses = 0
+			return
+		}
+	case StatusHalfOpen:
+		if probe {
+			return
+		}
+		state.HalfOpenSuccesses++
+		if state.HalfOpenSuccesses < m.halfOpenRequests(ctx) {
+			return
+		}
+	}
+	state.Status = StatusHealthy
+	state.HalfOpenSuccesses = 0
+	state.CooldownCount = 0
+}
+
+// halfOpenRequests returns the configured half-open request cap (0 = disabled).
+func (m *DefaultStateManager) halfOpenRequests(ctx context.Context) int {
+	if m.configSvc == nil {
+		return 0
+	}
+	if cfg, _ := m.configSvc.GetHealthCheckConfig(ctx); cfg != nil && cfg.HalfOpenRequests > 0 {
+		return cfg.HalfOpenRequests
+	}
+	return 0
+}
+
+// cooldownInterval returns CheckIntervalSeconds doubled for every cooldown the
+// target has gone through since it was last healthy, capped at MaxCooldownSeconds.
+func (m *DefaultStateManager) cooldownInterval(ctx context.Context, state *TargetState) time.Duration {
+	base := 30 * time.Second
+	var max time.Duration
+	if m.configSvc != nil {
+		if cfg, _ := m.configSvc.GetHealthCheckConfig(ctx); cfg != nil {
+			if cfg.CheckIntervalSeconds > 0 {
+				base = time.Duration(cfg.CheckIntervalSeconds) * time.Second
+			}
+			if cfg.MaxCooldownSeconds > 0 {
+				max = time.Duration(cfg.MaxCooldownSeconds) * time.Second
+			}
+		}
+	}
+	shift := state.CooldownCount
+	if shift > maxCooldownShift {
+		shift = maxCooldownShift
+	}
+	if shift < 0 {
+		shift = 0
+	}
+	interval := base << shift
+	if max > 0 && interval > max {
+		interval = max
+	}
+	if interval < base {
+		interval = base
+	}
+	return interval
+}
+
+// canaryRampResetFailures is how many consecutive failures restart a canary
+// target's weight ramp, so a transient error does not throttle it again.
+const canaryRampResetFailures = 3
+
+// maxRetryAfterCooldown caps how long an upstream Retry-After can keep a target cooling.
+const maxRetryAfterCooldown = time.Hour
+
+// nextCooldownCheck returns when the next cooldown should end: at the upstream
+// Retry-After time if one is pending, otherwise after the backoff interval.
+// It consumes the pending Retry-After. Caller must hold m.mu.
+func (m *DefaultStateManager) nextCooldownCheck(ctx context.Context, state *TargetState, now time.Time) time.Time {
+	retryAt := state.RetryAfterUntil
+	state.RetryAfterUntil = nil
+	if retryAt != nil && retryAt.After(now) {
+		return *retryAt
+	}
+	return now.Add(m.cooldownInterval(ctx, state))
+}
+
+// CooldownInterval returns how long the target should wait before its next check.
+func (m *DefaultStateManager) CooldownInterval(ctx context.Context, targetID string) time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	state, _ := m.store.GetTargetState(ctx, targetID)
+	if state == nil {
+		state = &TargetState{TargetID: targetID}
+	}
+	return m.cooldownInterval(ctx, state)
+}
+
+func (m *DefaultStateManager) RecordFailure(ctx context.Context, targetID string, reason string, retryAfter time.Duration) {
+	m.RecordFailureCooldown(ctx, targetID, reason, retryAfter, "upstream Retry-After")
+}
+
+func (m *DefaultStateManager) RecordFailureCooldown(ctx context.Context, targetID string, reason string, cooldown time.Duration, source string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -200,16 +447,79 @@ func (m *DefaultStateManager) RecordFailure(ctx context.Context, targetID string
 	if state == nil {
 		state = &TargetState{TargetID: targetID}
 	}
+	before, beforeFailures := state.Status, state.ConsecutiveFailures
 
 	now := time.Now()
 	state.ConsecutiveFailures++
 	state.LastFailureAt = &now
+	if state.ConsecutiveFailures >= canaryRampResetFailures {
+		state.CanaryRampFrom = &now
+	}
 	state.LastFailureReason = reason
+	state.RetryAfterUntil = nil
+	if cooldown > 0 {
+		if cooldown > maxRetryAfterCooldown {
+			cooldown = maxRetryAfterCooldown
+		}
+		retryAt := now.Add(cooldown)
+		state.RetryAfterUntil = &retryAt
+		state.LastFailureReason = fmt.Sprintf("%s (%s: %s)", reason, source, cooldown)
+	}
 	state.PushResult(false)
+	if state.Status == StatusHalfOpen {
+		// A failed probe reopens the circuit with a longer cooldown.
+		nextCheck := m.nextCooldownCheck(ctx, state, now)
+		state.CooldownCount++
+		state.Status = StatusCooling
+		state.HalfOpenSuccesses = 0
+		state.CooldownEndsAt = &nextCheck
+	}
+	state.Score = targetScore(state, m.scoring.Load(), now)
+
+	m.alerts.observe(before, beforeFailures, state)
+	_ = m.store.SetTargetState(ctx, state)
+}
+
+func (m *DefaultStateManager) MarkUsed(ctx context.Context, targetID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, _ := m.store.GetTargetState(ctx, targetID)
+	if state == nil {
+		state = &TargetState{TargetID: targetID, Status: StatusHealthy}
+	}
+
+	now := time.Now()
+	state.LastUsedAt = &now
 
 	_ = m.store.SetTargetState(ctx, state)
 }
 
+// TryAcquire increments the target's in-flight count unless it has already
+// reached limit. It reports whether the slot was reserved.
+func (m *DefaultStateManager) TryAcquire(ctx context.Context, targetID string, limit int) bool {
+	m.inFlightMu.Lock()
+	defer m.inFlightMu.Unlock()
+
+	if limit > 0 && m.inFlight[targetID] >= int64(limit) {
+		return false
+	}
+	m.inFlight[targetID]++
+	return true
+}
+
+func (m *DefaultStateManager) Release(ctx context.Context, targetID string) {
+	m.inFlightMu.Lock()
+	defer m.inFlightMu.Unlock()
+
+	switch n := m.inFlight[targetID]; {
+	case n > 1:
+		m.inFlight[targetID] = n - 1
+	case n == 1:
+		delete(m.inFlight, targetID)
+	}
+}
+
 func (m *DefaultStateManager) StartCooldownTimed(ctx context.Context, targetID string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -218,15 +528,32 @@ func (m *DefaultStateManager) StartCooldownTimed(ctx context.Context, targetID s
 	if state == nil {
 		state = &TargetState{TargetID: targetID}
 	}
-
-	interval := 30 * time.Second
-	if cfg, _ := m.configSvc.GetHealthCheckConfig(ctx); cfg != nil && cfg.CheckIntervalSeconds > 0 {
-		interval = time.Duration(cfg.CheckIntervalSeconds) * time.Second
+	if state.Status == StatusDraining {
+		return
+	}
+	now := time.Now()
+	if state.Status == StatusCooling && state.CooldownEndsAt != nil && state.CooldownEndsAt.After(now) {
+		// Already cooling, e.g. a half-open failure RecordFailureCooldown just
+		// reopened: counting it again would double the backoff, so only a
+		// later Retry-After extends the running cooldown.
+		if retryAt := state.RetryAfterUntil; retryAt != nil {
+			state.RetryAfterUntil = nil
+			if retryAt.After(*state.CooldownEndsAt) {
+				state.CooldownEndsAt = retryAt
+			}
+			_ = m.store.SetTargetState(ctx, state)
+		}
+		return
 	}
-	nextCheck := time.Now().Add(interval)
+	before, beforeFailures := state.Status, state.ConsecutiveFailures
+
+	nextCheck := m.nextCooldownCheck(ctx, state, now)
+	state.CooldownCount++
 	state.Status = StatusCooling
+	state.HalfOpenSuccesses = 0
 	state.CooldownEndsAt = &nextCheck
 
+	m.alerts.observe(before, beforeFailures, state)
 	_ = m.store.SetTargetState(ctx, state)
 }
 
@@ -238,10 +565,15 @@ func (m *DefaultStateManager) StartCooldownUntimed(ctx context.Context, targetID
 	if state == nil {
 		state = &TargetState{TargetID: targetID}
 	}
+	if state.Status == StatusDraining {
+		return
+	}
+	before, beforeFailures := state.Status, state.ConsecutiveFailures
 
 	state.Status = StatusCooling
 	state.CooldownEndsAt = nil
 
+	m.alerts.observe(before, beforeFailures, state)
 	_ = m.store.SetTargetState(ctx, state)
 }
 
@@ -253,6 +585,9 @@ func (m *DefaultStateManager) StartChecking(ctx context.Context, targetID string
 	if state == nil {
 		state = &TargetState{TargetID: targetID}
 	}
+	if state.Status == StatusDraining {
+		return
+	}
 
 	state.Status = StatusChecking
 	state.CooldownEndsAt = nil
@@ -269,6 +604,7 @@ func (m *DefaultStateManager) SetCooldownNextCheckIn(ctx context.Context, target
 		return
 	}
 	next := time.Now().Add(d)
+	state.CooldownCount++
 	state.Status = StatusCooling
 	state.CooldownEndsAt = &next
 	_ = m.store.SetTargetState(ctx, state)
@@ -282,10 +618,28 @@ func (m *DefaultStateManager) EndCooldown(ctx context.Context, targetID string)
 	if state == nil {
 		return
 	}
+	before, beforeFailures := state.Status, state.ConsecutiveFailures
 
-	state.Status = StatusHealthy
+	// A health check that already recorded a success has moved the target to
+	// half-open (or healthy); leave that in place.
+	if state.Status == StatusCooling || state.Status == StatusChecking {
+		m.advanceRecovery(ctx, state, true)
+	}
 	state.CooldownEndsAt = nil
 
+	m.alerts.observe(before, beforeFailures, state)
+	_ = m.store.SetTargetState(ctx, state)
+}
+
+func (m *DefaultStateManager) SetWarmingUp(ctx context.Context, targetID string, warming bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, _ := m.store.GetTargetState(ctx, targetID)
+	if state == nil || state.WarmingUp == warming {
+		return
+	}
+	state.WarmingUp = warming
 	_ = m.store.SetTargetState(ctx, state)
 }
 
@@ -308,6 +662,50 @@ func (m *DefaultStateManager) ForceCooldown(ctx context.Context, targetID string
 	return nil
 }
 
+// ForceHealthy ends any cooldown and marks the target fully healthy with no
+// failures on record, skipping the half-open phase. Unlike ResetTarget it keeps
+// the target's success and failure history.
+func (m *DefaultStateManager) ForceHealthy(ctx context.Context, targetID string) error {
+	m.EndCooldown(ctx, targetID)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.store.GetTargetState(ctx, targetID)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		state = &TargetState{TargetID: targetID}
+	}
+	state.Status = StatusHealthy
+	state.ConsecutiveFailures = 0
+	state.HalfOpenSuccesses = 0
+	state.CooldownCount = 0
+	state.CooldownEndsAt = nil
+	state.RetryAfterUntil = nil
+	state.WarmingUp = false
+
+	return m.store.SetTargetState(ctx, state)
+}
+
+func (m *DefaultStateManager) MarkMisconfigured(ctx context.Context, targetID string, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, _ := m.store.GetTargetState(ctx, targetID)
+	if state == nil {
+		state = &TargetState{TargetID: targetID}
+	}
+
+	state.Status = StatusMisconfigured
+	state.LastFailureReason = reason
+	state.HalfOpenSuccesses = 0
+	state.CooldownEndsAt = nil
+
+	_ = m.store.SetTargetState(ctx, state)
+}
+
 func (m *DefaultStateManager) InitializeTarget(ctx context.Context, targetID string) error {
 	state := &TargetState{
 		TargetID: targetID,
@@ -320,6 +718,67 @@ func (m *DefaultStateManager) RemoveTarget(ctx context.Context, targetID string)
 	return m.store.DeleteTargetState(ctx, targetID)
 }
 
+// drainPollInterval is how often DrainTarget checks the in-flight count.
+const drainPollInterval = 50 * time.Millisecond
+
+// DrainTarget takes the target out of rotation and waits until its in-flight
+// requests have finished, timeout elapses or ctx is done. A target that is no
+// longer in any pipeline then has its state removed; one that is still
+// configured stays draining, and so gets no new traffic, until it is removed
+// from its pipeline or reset. drained reports whether the in-flight count
+// reached zero, removed whether the state was deleted.
+func (m *DefaultStateManager) DrainTarget(ctx context.Context, targetID string, timeout time.Duration) (drained, removed bool, err error) {
+	m.mu.Lock()
+	state, _ := m.store.GetTargetState(ctx, targetID)
+	if state == nil {
+		state = &TargetState{TargetID: targetID}
+	}
+	draining := *state
+	draining.Status = StatusDraining
+	draining.CooldownEndsAt = nil
+	draining.HalfOpenSuccesses = 0
+	draining.WarmingUp = false
+	err = m.store.SetTargetState(ctx, &draining)
+	m.mu.Unlock()
+	if err != nil {
+		return false, false, err
+	}
+
+	if drained, err = m.waitIdle(ctx, targetID, timeout); err != nil {
+		return false, false, err
+	}
+
+	if findRouteForTarget(ctx, m.configSvc, targetID) != nil {
+		return drained, false, nil
+	}
+	if err := m.store.DeleteTargetState(ctx, targetID); err != nil {
+		return drained, false, err
+	}
+	return drained, true, nil
+}
+
+// waitIdle polls until the target has no requests in flight or timeout
+// elapses, and reports which happened first.
+func (m *DefaultStateManager) waitIdle(ctx context.Context, targetID string, timeout time.Duration) (bool, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if m.inFlightCount(targetID) <= 0 {
+			return true, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return false, nil
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+}
+
 // Stop stops the state manager background tasks.
 func (m *DefaultStateManager) Stop() {
 	close(m.stopChan)
@@ -331,14 +790,18 @@ func (m *DefaultStateManager) IsTargetAvailable(ctx context.Context, targetID st
 	if err != nil {
 		return true // Default to available if error
 	}
-	return state.Status == StatusHealthy
+	return state.Status.IsRoutable()
 }
 
-// GetAvailableTargetsInLayer returns available targets in a layer.
+// GetAvailableTargetsInLayer returns available targets in a layer; a disabled
+// layer has none.
 func (m *DefaultStateManager) GetAvailableTargetsInLayer(ctx context.Context, layer *Layer) []Target {
+	if layer.Disabled {
+		return nil
+	}
 	available := make([]Target, 0, len(layer.Targets))
 	for _, target := range layer.Targets {
-		if !target.Enabled {
+		if !target.Serves() {
 			continue
 		}
 		if m.IsTargetAvailable(ctx, target.ID) {
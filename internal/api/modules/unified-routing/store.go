@@ -16,6 +16,19 @@ import (
 
 // ================== Store Interfaces ==================
 
+// VersionConflictError is returned when a route or pipeline update carries a
+// version that no longer matches the stored one, i.e. someone else saved in
+// between. Current is the stored version the client should reload.
+type VersionConflictError struct {
+	Kind    string // "route" or "pipeline"
+	ID      string
+	Current int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s %s was modified concurrently (current version %d); reload and retry", e.Kind, e.ID, e.Current)
+}
+
 // ConfigStore defines the interface for configuration persistence.
 type ConfigStore interface {
 	// Settings
@@ -26,6 +39,10 @@ type ConfigStore interface {
 	LoadHealthCheckConfig(ctx context.Context) (*HealthCheckConfig, error)
 	SaveHealthCheckConfig(ctx context.Context, config *HealthCheckConfig) error
 
+	// Error classification rules
+	LoadClassificationRules(ctx context.Context) (*ClassificationRules, error)
+	SaveClassificationRules(ctx context.Context, rules *ClassificationRules) error
+
 	// Routes
 	ListRoutes(ctx context.Context) ([]*Route, error)
 	GetRoute(ctx context.Context, id string) (*Route, error)
@@ -36,9 +53,17 @@ type ConfigStore interface {
 	// Pipelines
 	GetPipeline(ctx context.Context, routeID string) (*Pipeline, error)
 	SavePipeline(ctx context.Context, routeID string, pipeline *Pipeline) error
+
+	// Archive: archived routes keep their pipeline but are not listed with the
+	// active routes, so they take no part in routing or health checks.
+	ArchiveRoute(ctx context.Context, id string) error
+	ListArchivedRoutes(ctx context.Context) ([]*ArchivedRoute, error)
+	RestoreRoute(ctx context.Context, id string) (*Route, error)
+	DeleteArchivedRoute(ctx context.Context, id string) error
 }
 
-// StateStore defines the interface for runtime state storage (in-memory).
+// StateStore defines the interface for runtime state storage. FileStateStore
+// keeps state across restarts; MemoryStateStore loses it, including cooldowns.
 type StateStore interface {
 	GetTargetState(ctx context.Context, targetID string) (*TargetState, error)
 	SetTargetState(ctx context.Context, state *TargetState) error
@@ -76,6 +101,7 @@ func NewFileConfigStore(baseDir string) (*FileConfigStore, error) {
 		baseDir,
 		filepath.Join(baseDir, "routes"),
 		filepath.Join(baseDir, "pipelines"),
+		filepath.Join(baseDir, "archive"),
 	}
 	for _, dir := range dirs {
 		if err := os.MkdirAll(dir, 0755); err != nil {
@@ -94,6 +120,10 @@ func (s *FileConfigStore) healthConfigPath() string {
 	return filepath.Join(s.baseDir, "health-config.yaml")
 }
 
+func (s *FileConfigStore) classificationRulesPath() string {
+	return filepath.Join(s.baseDir, "classification-rules.yaml")
+}
+
 func (s *FileConfigStore) routePath(id string) string {
 	return filepath.Join(s.baseDir, "routes", id+".yaml")
 }
@@ -102,6 +132,10 @@ func (s *FileConfigStore) pipelinePath(routeID string) string {
 	return filepath.Join(s.baseDir, "pipelines", routeID+".yaml")
 }
 
+func (s *FileConfigStore) archivePath(id string) string {
+	return filepath.Join(s.baseDir, "archive", id+".yaml")
+}
+
 func (s *FileConfigStore) LoadSettings(ctx context.Context) (*Settings, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -145,7 +179,8 @@ func (s *FileConfigStore) LoadHealthCheckConfig(ctx context.Context) (*HealthChe
 		return nil, err
 	}
 
-	var config HealthCheckConfig
+	// Fields missing from a file written by an older version keep their defaults.
+	config := DefaultHealthCheckConfig()
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, err
 	}
@@ -163,6 +198,38 @@ func (s *FileConfigStore) SaveHealthCheckConfig(ctx context.Context, config *Hea
 	return os.WriteFile(s.healthConfigPath(), data, 0644)
 }
 
+func (s *FileConfigStore) LoadClassificationRules(ctx context.Context) (*ClassificationRules, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := os.ReadFile(s.classificationRulesPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			rules := DefaultClassificationRules()
+			return &rules, nil
+		}
+		return nil, err
+	}
+
+	var rules ClassificationRules
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	rules.fillDefaultSections()
+	return &rules, nil
+}
+
+func (s *FileConfigStore) SaveClassificationRules(ctx context.Context, rules *ClassificationRules) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := yaml.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.classificationRulesPath(), data, 0644)
+}
+
 func (s *FileConfigStore) ListRoutes(ctx context.Context) ([]*Route, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -227,6 +294,7 @@ func (s *FileConfigStore) CreateRoute(ctx context.Context, route *Route) error {
 
 	route.CreatedAt = time.Now()
 	route.UpdatedAt = route.CreatedAt
+	route.Version = 1
 
 	data, err := yaml.Marshal(route)
 	if err != nil {
@@ -239,12 +307,23 @@ func (s *FileConfigStore) UpdateRoute(ctx context.Context, route *Route) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Check if route exists
-	if _, err := os.Stat(s.routePath(route.ID)); os.IsNotExist(err) {
-		return fmt.Errorf("route not found: %s", route.ID)
+	existing, err := os.ReadFile(s.routePath(route.ID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("route not found: %s", route.ID)
+		}
+		return err
+	}
+	var stored Route
+	if err := yaml.Unmarshal(existing, &stored); err != nil {
+		return err
+	}
+	if route.Version != 0 && route.Version != stored.Version {
+		return &VersionConflictError{Kind: "route", ID: route.ID, Current: stored.Version}
 	}
 
 	route.UpdatedAt = time.Now()
+	route.Version = stored.Version + 1
 
 	data, err := yaml.Marshal(route)
 	if err != nil {
@@ -294,7 +373,20 @@ func (s *FileConfigStore) SavePipeline(ctx context.Context, routeID string, pipe
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	var stored Pipeline
+	if existing, err := os.ReadFile(s.pipelinePath(routeID)); err == nil {
+		if err := yaml.Unmarshal(existing, &stored); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if pipeline.Version != 0 && pipeline.Version != stored.Version {
+		return &VersionConflictError{Kind: "pipeline", ID: routeID, Current: stored.Version}
+	}
+
 	pipeline.RouteID = routeID
+	pipeline.Version = stored.Version + 1
 	data, err := yaml.Marshal(pipeline)
 	if err != nil {
 		return err
@@ -302,6 +394,129 @@ func (s *FileConfigStore) SavePipeline(ctx context.Context, routeID string, pipe
 	return os.WriteFile(s.pipelinePath(routeID), data, 0644)
 }
 
+func (s *FileConfigStore) ArchiveRoute(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	routeData, err := os.ReadFile(s.routePath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("route not found: %s", id)
+		}
+		return err
+	}
+	archived := ArchivedRoute{ArchivedAt: time.Now(), Pipeline: Pipeline{Layers: []Layer{}}}
+	if err := yaml.Unmarshal(routeData, &archived.Route); err != nil {
+		return err
+	}
+	if pipelineData, err := os.ReadFile(s.pipelinePath(id)); err == nil {
+		if err := yaml.Unmarshal(pipelineData, &archived.Pipeline); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	archived.Pipeline.RouteID = id
+
+	data, err := yaml.Marshal(&archived)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.archivePath(id), data, 0644); err != nil {
+		return err
+	}
+	if err := os.Remove(s.routePath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(s.pipelinePath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileConfigStore) ListArchivedRoutes(ctx context.Context) ([]*ArchivedRoute, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	archiveDir := filepath.Join(s.baseDir, "archive")
+	entries, err := os.ReadDir(archiveDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*ArchivedRoute{}, nil
+		}
+		return nil, err
+	}
+
+	archived := make([]*ArchivedRoute, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".yaml" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(archiveDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var a ArchivedRoute
+		if err := yaml.Unmarshal(data, &a); err != nil {
+			continue
+		}
+		a.Pipeline.RouteID = a.Route.ID
+		archived = append(archived, &a)
+	}
+	sort.Slice(archived, func(i, j int) bool { return archived[i].ArchivedAt.After(archived[j].ArchivedAt) })
+	return archived, nil
+}
+
+func (s *FileConfigStore) RestoreRoute(ctx context.Context, id string) (*Route, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.archivePath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("archived route not found: %s", id)
+		}
+		return nil, err
+	}
+	if _, err := os.Stat(s.routePath(id)); err == nil {
+		return nil, fmt.Errorf("route already exists: %s", id)
+	}
+	var archived ArchivedRoute
+	if err := yaml.Unmarshal(data, &archived); err != nil {
+		return nil, err
+	}
+
+	archived.Route.UpdatedAt = time.Now()
+	routeData, err := yaml.Marshal(&archived.Route)
+	if err != nil {
+		return nil, err
+	}
+	pipelineData, err := yaml.Marshal(&archived.Pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(s.pipelinePath(id), pipelineData, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(s.routePath(id), routeData, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(s.archivePath(id)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return &archived.Route, nil
+}
+
+func (s *FileConfigStore) DeleteArchivedRoute(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.archivePath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 // ================== In-Memory State Store ==================
 
 // MemoryStateStore implements StateStore using in-memory storage.
@@ -364,6 +579,7 @@ func (s *MemoryStateStore) DeleteTargetState(ctx context.Context, targetID strin
 // FileStateStore implements StateStore with JSON file persistence.
 // Each target's state is stored as {targetID}.json in the state directory.
 // An in-memory cache is used for fast reads; writes go to both cache and disk.
+// Files are replaced atomically, so a crash mid-write leaves the previous state.
 type FileStateStore struct {
 	mu       sync.RWMutex
 	states   map[string]*TargetState
@@ -401,7 +617,7 @@ func (s *FileStateStore) loadAll() error {
 	}
 	for _, entry := range entries {
 		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
-			continue
+			continue // also skips .json.tmp files left by an interrupted persist
 		}
 		data, err := os.ReadFile(filepath.Join(s.stateDir, entry.Name()))
 		if err != nil {
@@ -411,24 +627,43 @@ func (s *FileStateStore) loadAll() error {
 		if err := json.Unmarshal(data, &state); err != nil || state.TargetID == "" {
 			continue
 		}
-		// Reset transient runtime fields on load — cooldowns are invalid after restart.
-		if state.Status == StatusCooling || state.Status == StatusChecking {
-			state.Status = StatusHealthy
-			state.CooldownEndsAt = nil
+		// Cooldowns are kept so the health checker reschedules them on start
+		// instead of routing to targets that were failing. A health check or
+		// half-open trial that was cut off by the restart becomes a cooldown
+		// that is already due, so the target is checked again first.
+		if state.Status == StatusChecking || state.Status == StatusHalfOpen {
+			now := time.Now()
+			state.Status = StatusCooling
+			state.CooldownEndsAt = &now
 		}
+		// The warmup that would clear this died with the process; the target
+		// passed its recovery check, so it serves normally again.
+		state.WarmingUp = false
 		state.ActiveConnections = 0
+		state.InFlight = 0
 		state.RecalcStats()
 		s.states[state.TargetID] = &state
 	}
 	return nil
 }
 
-func (s *FileStateStore) persist(state *TargetState) {
+// persist writes state to a temp file and renames it over the target's file.
+func (s *FileStateStore) persist(state *TargetState) error {
 	data, err := json.MarshalIndent(state, "", "    ")
 	if err != nil {
-		return
+		return fmt.Errorf("failed to marshal target state: %w", err)
 	}
-	_ = os.WriteFile(s.stateFilePath(state.TargetID), data, 0644)
+	path := s.stateFilePath(state.TargetID)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to write target state: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to write target state: %w", err)
+	}
+	return nil
 }
 
 func (s *FileStateStore) GetTargetState(ctx context.Context, targetID string) (*TargetState, error) {
@@ -450,8 +685,7 @@ func (s *FileStateStore) SetTargetState(ctx context.Context, state *TargetState)
 	defer s.mu.Unlock()
 
 	s.states[state.TargetID] = state
-	s.persist(state)
-	return nil
+	return s.persist(state)
 }
 
 func (s *FileStateStore) ListTargetStates(ctx context.Context) ([]*TargetState, error) {
@@ -0,0 +1,147 @@
+package unifiedrouting
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFileStateStoreKeepsCooldownsAcrossRestart(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewFileStateStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStateStore: %v", err)
+	}
+
+	endsAt := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+	states := []*TargetState{
+		{TargetID: "cooling", Status: StatusCooling, CooldownEndsAt: &endsAt, InFlight: 3},
+		{TargetID: "checking", Status: StatusChecking},
+		{TargetID: "route/healthy", Status: StatusHealthy},
+		{TargetID: "warming", Status: StatusHealthy, WarmingUp: true},
+	}
+	for _, state := range states {
+		if err := store.SetTargetState(ctx, state); err != nil {
+			t.Fatalf("SetTargetState(%s): %v", state.TargetID, err)
+		}
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(leftovers) != 0 {
+		t.Fatalf("temp files left behind: %v", leftovers)
+	}
+
+	restarted, err := NewFileStateStore(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	cooling, _ := restarted.GetTargetState(ctx, "cooling")
+	if cooling.Status != StatusCooling || cooling.CooldownEndsAt == nil || !cooling.CooldownEndsAt.Equal(endsAt) {
+		t.Fatalf("cooling target after restart = %+v", cooling)
+	}
+	if cooling.InFlight != 0 {
+		t.Fatalf("in-flight count survived restart: %d", cooling.InFlight)
+	}
+	checking, _ := restarted.GetTargetState(ctx, "checking")
+	if checking.Status != StatusCooling || checking.CooldownEndsAt == nil || checking.CooldownEndsAt.After(time.Now()) {
+		t.Fatalf("interrupted check should become a due cooldown, got %+v", checking)
+	}
+	if healthy, _ := restarted.GetTargetState(ctx, "route/healthy"); healthy.Status != StatusHealthy {
+		t.Fatalf("healthy target after restart = %+v", healthy)
+	}
+	if warming, _ := restarted.GetTargetState(ctx, "warming"); warming.Status != StatusHealthy || warming.WarmingUp {
+		t.Fatalf("interrupted warmup should end on restart, got %+v", warming)
+	}
+}
+
+func TestFileConfigStoreDefaultsMissingHealthCheckFields(t *testing.T) {
+	dir := t.TempDir()
+	old := "check-interval-seconds: 45\ncheck-timeout-seconds: 10\nmax-consecutive-failures: 3\n"
+	if err := os.WriteFile(filepath.Join(dir, "health-config.yaml"), []byte(old), 0o644); err != nil {
+		t.Fatalf("write health config: %v", err)
+	}
+	store, err := NewFileConfigStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileConfigStore: %v", err)
+	}
+	cfg, err := store.LoadHealthCheckConfig(context.Background())
+	if err != nil {
+		t.Fatalf("LoadHealthCheckConfig: %v", err)
+	}
+	want := DefaultHealthCheckConfig()
+	want.CheckIntervalSeconds = 45
+	if *cfg != want {
+		t.Fatalf("loaded config = %+v, want %+v", *cfg, want)
+	}
+
+	// An explicit 0 still survives a save and reload.
+	cfg.NetworkErrorCooldownSeconds = 0
+	if err := store.SaveHealthCheckConfig(context.Background(), cfg); err != nil {
+		t.Fatalf("SaveHealthCheckConfig: %v", err)
+	}
+	if reloaded, _ := store.LoadHealthCheckConfig(context.Background()); reloaded.NetworkErrorCooldownSeconds != 0 {
+		t.Fatalf("network_error_cooldown_seconds = %d after reload, want 0", reloaded.NetworkErrorCooldownSeconds)
+	}
+}
+
+func TestPutHealthCheckConfigRejectsInvalidValues(t *testing.T) {
+	_, configSvc, _ := newFailoverTestEngine(t)
+	h := &Handlers{configSvc: configSvc}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/health-config", h.PutHealthCheckConfig)
+
+	for _, body := range []string{`{"max_cooldown_seconds":-1}`, `{"health_check_mode":"ping"}`} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/health-config", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("PUT %s = %d, want 400", body, rec.Code)
+		}
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/health-config", strings.NewReader(`{"check_interval_seconds":20}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT valid config = %d, want 200", rec.Code)
+	}
+	if cfg, _ := configSvc.GetHealthCheckConfig(context.Background()); cfg.CheckIntervalSeconds != 20 || cfg.MaxCooldownSeconds != 600 {
+		t.Fatalf("saved config = %+v, want omitted fields defaulted", cfg)
+	}
+}
+
+func TestInFlightCountsStayOutOfTheStateStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewFileStateStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStateStore: %v", err)
+	}
+	stateMgr := NewStateManager(store, nil)
+
+	if !stateMgr.TryAcquire(ctx, "t1", 1) {
+		t.Fatalf("first slot rejected")
+	}
+	if stateMgr.TryAcquire(ctx, "t1", 1) {
+		t.Fatalf("limit of one slot not enforced")
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 0 {
+		t.Fatalf("acquiring a slot wrote state files: %v", files)
+	}
+	if state, _ := stateMgr.GetTargetState(ctx, "t1"); state.InFlight != 1 {
+		t.Fatalf("in_flight = %d, want 1", state.InFlight)
+	}
+	if states, _ := stateMgr.ListTargetStates(ctx); len(states) != 1 || states[0].InFlight != 1 {
+		t.Fatalf("listed states = %+v, want t1 with one request in flight", states)
+	}
+
+	stateMgr.Release(ctx, "t1")
+	stateMgr.Release(ctx, "t1") // an extra release must not go negative
+	if state, _ := stateMgr.GetTargetState(ctx, "t1"); state.InFlight != 0 {
+		t.Fatalf("in_flight after release = %d, want 0", state.InFlight)
+	}
+}
@@ -3,7 +3,11 @@
 package unifiedrouting
 
 import (
+	"sort"
+	"strings"
 	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 )
 
 // ================== Configuration Types ==================
@@ -12,6 +16,68 @@ import (
 type Settings struct {
 	Enabled            bool `json:"enabled" yaml:"enabled"`
 	HideOriginalModels bool `json:"hide_original_models" yaml:"hide-original-models"`
+	// WebhookURL receives every config change event as a JSON POST; empty disables it.
+	WebhookURL string `json:"webhook_url,omitempty" yaml:"webhook-url,omitempty"`
+	// WebhookSecret, when set, signs each webhook body with HMAC-SHA256.
+	WebhookSecret string `json:"webhook_secret,omitempty" yaml:"webhook-secret,omitempty"`
+	// RequestTimeoutSeconds is the default per-request time budget for routes
+	// that set none; 0 means unbounded.
+	RequestTimeoutSeconds int `json:"request_timeout_seconds,omitempty" yaml:"request-timeout-seconds,omitempty"`
+	// AllowHeaderRouting lets clients pin a request to one target of the
+	// resolved route with the X-Route-Target header.
+	AllowHeaderRouting bool `json:"allow_header_routing,omitempty" yaml:"allow-header-routing,omitempty"`
+	// HeaderRoutingBypassCooldown sends pinned requests to the target even
+	// while it is cooling down; otherwise a cooling target fails the request.
+	HeaderRoutingBypassCooldown bool `json:"header_routing_bypass_cooldown,omitempty" yaml:"header-routing-bypass-cooldown,omitempty"`
+	// UpstreamProxy is the default outbound proxy (socks5://, ss:// or
+	// http(s)://) for targets that set none; empty uses the credential's proxy.
+	UpstreamProxy string `json:"upstream_proxy,omitempty" yaml:"upstream-proxy,omitempty"`
+	// AlertWebhookURL receives a TargetAlert as a JSON POST when a target enters
+	// cooldown, recovers, or reaches AlertFailureThreshold consecutive failures.
+	// Bodies are signed with WebhookSecret. Empty disables it.
+	AlertWebhookURL string `json:"alert_webhook_url,omitempty" yaml:"alert-webhook-url,omitempty"`
+	// AlertFailureThreshold raises an alert when a target's consecutive failures
+	// reach this count; 0 disables the threshold alert.
+	AlertFailureThreshold int `json:"alert_failure_threshold,omitempty" yaml:"alert-failure-threshold,omitempty"`
+	// AlertDebounceSeconds suppresses repeats of the same alert for the same
+	// target within this window; 0 uses DefaultAlertDebounce.
+	AlertDebounceSeconds int `json:"alert_debounce_seconds,omitempty" yaml:"alert-debounce-seconds,omitempty"`
+	// Scoring tunes TargetState.Score for best-score layers; nil uses
+	// DefaultScoringConfig.
+	Scoring *ScoringConfig `json:"scoring,omitempty" yaml:"scoring,omitempty"`
+	// PublicStatus serves GET /status, a summary of route health for uptime
+	// monitors that needs no management key. PublicStatusKey, when set, must be
+	// passed to it as ?key= or a Bearer token.
+	PublicStatus    bool   `json:"public_status,omitempty" yaml:"public-status,omitempty"`
+	PublicStatusKey string `json:"public_status_key,omitempty" yaml:"public-status-key,omitempty"`
+}
+
+// ScoringConfig weighs the terms of TargetState.Score. Every term is in
+// milliseconds of latency, so the weights say how much latency a failure or a
+// queued request is worth. A zero weight drops its term.
+type ScoringConfig struct {
+	// LatencyWeight multiplies the decayed average latency, as used by
+	// least-latency.
+	LatencyWeight float64 `json:"latency_weight" yaml:"latency-weight"`
+	// FailureWeight is the penalty for a 100% error rate over RecentResults.
+	FailureWeight float64 `json:"failure_weight" yaml:"failure-weight"`
+	// FailureHalfLifeSeconds halves the failure penalty for every period
+	// elapsed since the last failure; 0 keeps it until successes dilute it.
+	FailureHalfLifeSeconds int `json:"failure_half_life_seconds" yaml:"failure-half-life-seconds"`
+	// InFlightWeight is the penalty per request the target is serving, as
+	// used by least-connections. It is applied when a target is selected.
+	InFlightWeight float64 `json:"in_flight_weight" yaml:"in-flight-weight"`
+}
+
+// DefaultScoringConfig returns the scoring weights used when Settings.Scoring
+// is unset.
+func DefaultScoringConfig() *ScoringConfig {
+	return &ScoringConfig{
+		LatencyWeight:          1,
+		FailureWeight:          10000,
+		FailureHalfLifeSeconds: 300,
+		InFlightWeight:         250,
+	}
 }
 
 // HealthCheckConfig holds the health check configuration.
@@ -20,14 +86,163 @@ type HealthCheckConfig struct {
 	CheckIntervalSeconds   int `json:"check_interval_seconds" yaml:"check-interval-seconds"`
 	CheckTimeoutSeconds    int `json:"check_timeout_seconds" yaml:"check-timeout-seconds"`
 	MaxConsecutiveFailures int `json:"max_consecutive_failures" yaml:"max-consecutive-failures"`
+	// HalfOpenRequests is how many real requests must succeed on a recovered target
+	// before it is fully healthy again. 0 disables the half-open phase.
+	HalfOpenRequests int `json:"half_open_requests" yaml:"half-open-requests"`
+	// WarmupRequests is how many probe requests the health checker sends to a
+	// target right after it leaves cooldown, to prime connections. The target is
+	// routable meanwhile but only used when nothing else in its layer is. 0 disables.
+	WarmupRequests int `json:"warmup_requests,omitempty" yaml:"warmup-requests,omitempty"`
+	// MaxCooldownSeconds caps the backoff between checks of a repeatedly failing
+	// target. 0 leaves only the built-in doubling cap.
+	MaxCooldownSeconds int `json:"max_cooldown_seconds" yaml:"max-cooldown-seconds"`
+	// HealthCheckJitterSeconds randomizes each scheduled check by up to this
+	// many seconds either way, so targets that cooled down together are not
+	// probed in one burst. 0 disables.
+	HealthCheckJitterSeconds int `json:"health_check_jitter_seconds,omitempty" yaml:"health-check-jitter-seconds,omitempty"`
+	// HealthCheckPrompt and HealthCheckMaxTokens customise the probe request;
+	// empty and 0 keep the defaults ("hi", no output token cap).
+	HealthCheckPrompt    string `json:"health_check_prompt,omitempty" yaml:"health-check-prompt,omitempty"`
+	HealthCheckMaxTokens int    `json:"health_check_max_tokens,omitempty" yaml:"health-check-max-tokens,omitempty"`
+	// HealthCheckDisableStream sends the probe as a non-streaming request, for
+	// providers that do not stream a short reply reliably.
+	HealthCheckDisableStream bool `json:"health_check_disable_stream,omitempty" yaml:"health-check-disable-stream,omitempty"`
+	// HealthCheckMode selects how targets are probed; empty means completion.
+	HealthCheckMode HealthCheckMode `json:"health_check_mode,omitempty" yaml:"health-check-mode,omitempty"`
+	// HealthCheckConcurrency bounds how many targets CheckRoute and CheckAll
+	// probe at once; 0 uses the default.
+	HealthCheckConcurrency int `json:"health_check_concurrency,omitempty" yaml:"health-check-concurrency,omitempty"`
+	// HealthCheckCredentialIntervalMs is the minimum time between the starts of
+	// two probes that use the same credential, so checking many targets on one
+	// account does not trip its rate limit. 0 disables the spacing.
+	HealthCheckCredentialIntervalMs int `json:"health_check_credential_interval_ms,omitempty" yaml:"health-check-credential-interval-ms,omitempty"`
+	// HistorySize is how many health check results are kept in memory for
+	// the history endpoint; 0 uses the default and larger values are capped
+	// at MaxHealthHistorySize.
+	HistorySize int `json:"history_size,omitempty" yaml:"history-size,omitempty"`
+	// MaxConcurrentChecks bounds the live probes running at once across all
+	// health checks, scheduled or manual; further probes wait their turn.
+	// 0 uses DefaultMaxConcurrentHealthChecks.
+	MaxConcurrentChecks int `json:"max_concurrent_checks,omitempty" yaml:"max-concurrent-checks,omitempty"`
+	// NetworkErrorCooldownSeconds is how long a target cools down after a
+	// request could not reach it at all (ErrorClassNetwork), instead of the
+	// backoff used for errors returned by the upstream. 0 uses that backoff.
+	NetworkErrorCooldownSeconds int `json:"network_error_cooldown_seconds,omitempty" yaml:"network-error-cooldown-seconds"`
+	// RequireValidReply fails a completion probe whose reply (the first chunk
+	// when streaming) is not JSON or SSE data carrying JSON, or that has a
+	// top-level "error" field, since some providers report errors with a 200.
+	RequireValidReply bool `json:"require_valid_reply,omitempty" yaml:"require-valid-reply,omitempty"`
+	// MinReplyBytes fails a completion probe whose reply is shorter than this
+	// many bytes. 0 disables the check.
+	MinReplyBytes int `json:"min_reply_bytes,omitempty" yaml:"min-reply-bytes,omitempty"`
+}
+
+// DefaultHealthCheckConcurrency is used when HealthCheckConcurrency is not set.
+const DefaultHealthCheckConcurrency = 4
+
+// DefaultMaxConcurrentHealthChecks is used when MaxConcurrentChecks is not set.
+const DefaultMaxConcurrentHealthChecks = 8
+
+const (
+	// DefaultHealthHistorySize is used when HistorySize is not set.
+	DefaultHealthHistorySize = 1000
+	// MaxHealthHistorySize bounds HistorySize; a result takes a few hundred
+	// bytes, so the cap keeps the history within tens of megabytes.
+	MaxHealthHistorySize = 100000
+)
+
+// HealthCheckMode selects how a health check probes a target.
+type HealthCheckMode string
+
+const (
+	HealthCheckModeCompletion HealthCheckMode = "completion" // send a minimal real request
+	HealthCheckModeModels     HealthCheckMode = "models"     // GET the provider's models list; API-key credentials only
+	HealthCheckModeTCP        HealthCheckMode = "tcp"        // dial the upstream and complete TLS; liveness only
+)
+
+// IsValid reports whether m is a known mode or empty (the default).
+func (m HealthCheckMode) IsValid() bool {
+	switch m {
+	case "", HealthCheckModeCompletion, HealthCheckModeModels, HealthCheckModeTCP:
+		return true
+	}
+	return false
 }
 
 // DefaultHealthCheckConfig returns the default health check configuration.
 func DefaultHealthCheckConfig() HealthCheckConfig {
 	return HealthCheckConfig{
-		CheckIntervalSeconds:   30,
-		CheckTimeoutSeconds:    10,
-		MaxConsecutiveFailures: 3,
+		CheckIntervalSeconds:        30,
+		CheckTimeoutSeconds:         10,
+		MaxConsecutiveFailures:      3,
+		HalfOpenRequests:            3,
+		MaxCooldownSeconds:          600,
+		HealthCheckConcurrency:      DefaultHealthCheckConcurrency,
+		NetworkErrorCooldownSeconds: 60,
+	}
+}
+
+// ClassificationRules tunes how ClassifyError treats upstream failures.
+type ClassificationRules struct {
+	// StatusRules maps an HTTP status code to "retryable" or "non_retryable".
+	// Unlisted 5xx codes are retryable and unlisted 4xx codes non-retryable.
+	StatusRules map[int]string `json:"status_rules" yaml:"status-rules"`
+	// OverloadKeywords mark a failure as a temporary capacity problem, making
+	// it retryable even when its status is 400. Matched case-insensitively.
+	// Empty, the built-in list is used.
+	OverloadKeywords []string `json:"overload_keywords" yaml:"overload-keywords"`
+	// ExtraOverloadKeywords are consulted in addition to OverloadKeywords, for
+	// localized or provider-specific messages such as "系统繁忙", without
+	// having to restate the built-in list.
+	ExtraOverloadKeywords []string `json:"extra_overload_keywords,omitempty" yaml:"extra-overload-keywords,omitempty"`
+	// ExtraOverloadMatch selects how ExtraOverloadKeywords are matched:
+	// "substring" (the default) or "regex". Both ignore case.
+	ExtraOverloadMatch string `json:"extra_overload_match,omitempty" yaml:"extra-overload-match,omitempty"`
+}
+
+// ExtraOverloadMatch values.
+const (
+	OverloadMatchSubstring = "substring"
+	OverloadMatchRegex     = "regex"
+)
+
+// DefaultClassificationRules returns the built-in classification rules.
+func DefaultClassificationRules() ClassificationRules {
+	return ClassificationRules{
+		StatusRules: map[int]string{
+			400: ErrorClassNonRetryable.String(),
+			401: ErrorClassRetryable.String(),
+			402: ErrorClassRetryable.String(),
+			403: ErrorClassRetryable.String(),
+			404: ErrorClassRetryable.String(),
+			413: ErrorClassNonRetryable.String(),
+			422: ErrorClassNonRetryable.String(),
+			429: ErrorClassRetryable.String(),
+		},
+		OverloadKeywords: []string{
+			"overloaded",
+			"capacity",
+			"too many requests",
+			"rate limit",
+			"resource exhausted",
+			"server is busy",
+			"temporarily unavailable",
+			"service unavailable",
+			"quota",
+		},
+	}
+}
+
+// fillDefaultSections sets the sections rules leaves out to their built-in
+// values, so a rules file or request that sets only some sections keeps the
+// defaults for the rest. A section given explicitly, even empty, is kept.
+func (r *ClassificationRules) fillDefaultSections() {
+	defaults := DefaultClassificationRules()
+	if r.StatusRules == nil {
+		r.StatusRules = defaults.StatusRules
+	}
+	if r.OverloadKeywords == nil {
+		r.OverloadKeywords = defaults.OverloadKeywords
 	}
 }
 
@@ -40,6 +255,10 @@ type Route struct {
 	Enabled     bool      `json:"enabled" yaml:"enabled"`
 	CreatedAt   time.Time `json:"created_at" yaml:"-"`
 	UpdatedAt   time.Time `json:"updated_at" yaml:"-"`
+	// Version is bumped by every store write. An update that carries a
+	// non-zero Version is rejected with a *VersionConflictError unless it
+	// matches the stored one; zero skips the check.
+	Version int64 `json:"version" yaml:"version,omitempty"`
 }
 
 // AllNames returns the route name followed by all aliases.
@@ -54,6 +273,18 @@ func (r *Route) AllNames() []string {
 type Pipeline struct {
 	RouteID string  `json:"route_id" yaml:"-"`
 	Layers  []Layer `json:"layers" yaml:"layers"`
+	// RequestTimeoutSeconds bounds the time a request may spend across all
+	// layers and targets; 0 falls back to Settings.RequestTimeoutSeconds.
+	RequestTimeoutSeconds int `json:"request_timeout_seconds,omitempty" yaml:"request-timeout-seconds,omitempty"`
+	// MaxAttempts caps the targets tried per request across all layers; 0 means
+	// every available target may be tried.
+	MaxAttempts int `json:"max_attempts,omitempty" yaml:"max-attempts,omitempty"`
+	// DefaultModel is given to targets saved without a model of their own.
+	// It is filled in when the pipeline is saved, so changing it later does
+	// not affect targets that already have a model.
+	DefaultModel string `json:"default_model,omitempty" yaml:"default-model,omitempty"`
+	// Version works like Route.Version, independently of it.
+	Version int64 `json:"version" yaml:"version,omitempty"`
 }
 
 // Layer represents a layer in the pipeline (value object).
@@ -61,15 +292,75 @@ type Layer struct {
 	Level    int          `json:"level" yaml:"level"`
 	Strategy LoadStrategy `json:"strategy" yaml:"strategy"`
 	Targets  []Target     `json:"targets" yaml:"targets"`
+	// StickyKeyHeader names a request header (e.g. X-Session-Id) whose value keys sticky
+	// routing; when empty or absent from the request, the client's masked API key is used.
+	StickyKeyHeader string `json:"sticky_key_header,omitempty" yaml:"sticky-key-header,omitempty"`
+	// Disabled parks the layer: it keeps its config but is skipped by routing
+	// and health checks as if it were not in the pipeline.
+	Disabled bool `json:"disabled,omitempty" yaml:"disabled,omitempty"`
 }
 
 // Target represents a target in a layer (value object).
 type Target struct {
 	ID           string `json:"id" yaml:"id"`
 	CredentialID string `json:"credential_id" yaml:"credential-id"`
+	// Model is sent to the credential's provider as-is. It is never resolved
+	// through routes again, so a model named like a route (even its own)
+	// cannot form an alias loop.
 	Model        string `json:"model" yaml:"model"`
 	Weight       int    `json:"weight,omitempty" yaml:"weight,omitempty"`
 	Enabled      bool   `json:"enabled" yaml:"enabled"`
+	// MaxConcurrent caps in-flight requests on this target; 0 means unlimited.
+	MaxConcurrent int `json:"max_concurrent,omitempty" yaml:"max-concurrent,omitempty"`
+	// HealthCheck overrides the global probe settings for this target.
+	HealthCheck *TargetHealthCheck `json:"health_check,omitempty" yaml:"health-check,omitempty"`
+	// Shadow marks a target that never serves clients; it receives an async
+	// copy of requests routed through its layer so responses can be compared.
+	Shadow bool `json:"shadow,omitempty" yaml:"shadow,omitempty"`
+	// ShadowSampleRate is the fraction (0-1] of requests mirrored to a shadow
+	// target; 0 mirrors none, so a shadow target needs an explicit rate.
+	ShadowSampleRate float64 `json:"shadow_sample_rate,omitempty" yaml:"shadow-sample-rate,omitempty"`
+	// UpstreamProxy routes this target's upstream calls, including health
+	// checks, through the given proxy instead of the credential's.
+	UpstreamProxy string `json:"upstream_proxy,omitempty" yaml:"upstream-proxy,omitempty"`
+	// CanaryStartWeight, CanaryTargetWeight and CanaryRampSeconds ramp the
+	// target's weight linearly from the start weight to the target weight
+	// (Weight when 0) over the ramp window, counted from CreatedAt. Three
+	// consecutive failures restart the ramp; isolated failures do not. Only
+	// weighted-round-robin layers use them.
+	CanaryStartWeight  int `json:"canary_start_weight,omitempty" yaml:"canary-start-weight,omitempty"`
+	CanaryTargetWeight int `json:"canary_target_weight,omitempty" yaml:"canary-target-weight,omitempty"`
+	CanaryRampSeconds  int `json:"canary_ramp_seconds,omitempty" yaml:"canary-ramp-seconds,omitempty"`
+	// CreatedAt is stamped when a canary target is first saved.
+	CreatedAt *time.Time `json:"created_at,omitempty" yaml:"created-at,omitempty"`
+	// MonitorOnly keeps an enabled target out of client routing while it is
+	// still health-checked, e.g. while evaluating a new credential.
+	MonitorOnly bool `json:"monitor_only,omitempty" yaml:"monitor-only,omitempty"`
+	// RequestTransform and ResponseTransform are sjson set/delete edits made
+	// to the JSON bodies of this target's upstream calls, after the request is
+	// translated to the provider's format and before the response is
+	// translated back.
+	RequestTransform  []util.BodyTransform `json:"request_transform,omitempty" yaml:"request-transform,omitempty"`
+	ResponseTransform []util.BodyTransform `json:"response_transform,omitempty" yaml:"response-transform,omitempty"`
+}
+
+// IsCanary reports whether the target ramps its weight over time.
+func (t *Target) IsCanary() bool {
+	return t.CanaryRampSeconds > 0
+}
+
+// Serves reports whether the target takes part in normal client routing.
+func (t *Target) Serves() bool {
+	return t.Enabled && !t.Shadow && !t.MonitorOnly
+}
+
+// TargetHealthCheck holds per-target overrides of the HealthCheckConfig probe
+// settings. Unset fields fall back to the global configuration.
+type TargetHealthCheck struct {
+	Prompt        string          `json:"prompt,omitempty" yaml:"prompt,omitempty"`
+	MaxTokens     int             `json:"max_tokens,omitempty" yaml:"max-tokens,omitempty"`
+	DisableStream *bool           `json:"disable_stream,omitempty" yaml:"disable-stream,omitempty"`
+	Mode          HealthCheckMode `json:"mode,omitempty" yaml:"mode,omitempty"`
 }
 
 // LoadStrategy defines the load balancing strategy.
@@ -81,6 +372,9 @@ const (
 	StrategyLeastConn      LoadStrategy = "least-connections"
 	StrategyRandom         LoadStrategy = "random"
 	StrategyFirstAvailable LoadStrategy = "first-available"
+	StrategyLeastLatency   LoadStrategy = "least-latency"
+	StrategySticky         LoadStrategy = "sticky"
+	StrategyBestScore      LoadStrategy = "best-score"
 )
 
 // ================== Runtime State Types ==================
@@ -90,18 +384,35 @@ const (
 // CooldownEndsAt == nil means untimed cooling (no periodic check until traffic triggers one).
 const RecentResultsMax = 20
 
+// RecentLatenciesMax bounds the latency window the per-target percentiles are computed over.
+const RecentLatenciesMax = 100
+
 type TargetState struct {
 	TargetID            string       `json:"target_id"`
 	Status              TargetStatus `json:"status"`
 	ConsecutiveFailures int          `json:"consecutive_failures"`
 	CooldownEndsAt      *time.Time   `json:"cooldown_ends_at,omitempty"` // next health check due (timed); nil = untimed
 	LastSuccessAt       *time.Time   `json:"last_success_at,omitempty"`
+	LastUsedAt          *time.Time   `json:"last_used_at,omitempty"` // last time the target was selected to serve a request
 	LastFailureAt       *time.Time   `json:"last_failure_at,omitempty"`
 	LastFailureReason   string       `json:"last_failure_reason,omitempty"`
 	ActiveConnections   int64        `json:"active_connections"`
 	RecentResults       []bool       `json:"recent_results"`
 	TotalRequests       int64        `json:"total_requests"`
 	SuccessfulRequests  int64        `json:"successful_requests"`
+	AvgLatencyMs        float64      `json:"avg_latency_ms,omitempty"` // moving average over successful requests
+	LatencyP50Ms        int64        `json:"latency_p50_ms,omitempty"` // percentiles over RecentLatenciesMs
+	LatencyP95Ms        int64        `json:"latency_p95_ms,omitempty"`
+	LatencyP99Ms        int64        `json:"latency_p99_ms,omitempty"`
+	RecentLatenciesMs   []int64      `json:"-" yaml:"-"`               // latest successful latencies, at most RecentLatenciesMax; only the percentiles are exposed
+	InFlight            int64        `json:"in_flight"`                // requests currently being served; kept in memory only
+	HalfOpenSuccesses   int          `json:"half_open_successes,omitempty"` // successful requests while half-open
+	CooldownCount       int          `json:"cooldown_count,omitempty"`      // cooldowns since last healthy; lengthens the next one
+	RetryAfterUntil     *time.Time   `json:"retry_after_until,omitempty"`   // upstream Retry-After; sets the next cooldown's end
+	CanaryRampFrom      *time.Time   `json:"canary_ramp_from,omitempty"`    // last failure of a canaryRampResetFailures streak; a canary's ramp restarts here
+	WarmingUp           bool         `json:"warming_up,omitempty"`          // recovered and still receiving warmup probes; deprioritized
+	MonitorOnly         bool         `json:"monitor_only,omitempty"`        // from Target.MonitorOnly; set in route state views only
+	Score               float64      `json:"score"`                         // best-score ranking as of the last result; lower is better
 }
 
 // RecalcStats recomputes TotalRequests and SuccessfulRequests from RecentResults.
@@ -125,18 +436,56 @@ func (s *TargetState) PushResult(success bool) {
 	s.RecalcStats()
 }
 
+// PushLatency appends a successful request's latency to the rolling window,
+// trimming to RecentLatenciesMax, and recomputes the latency percentiles.
+func (s *TargetState) PushLatency(latency time.Duration) {
+	s.RecentLatenciesMs = append(s.RecentLatenciesMs, latency.Milliseconds())
+	if len(s.RecentLatenciesMs) > RecentLatenciesMax {
+		s.RecentLatenciesMs = s.RecentLatenciesMs[len(s.RecentLatenciesMs)-RecentLatenciesMax:]
+	}
+	sorted := append([]int64(nil), s.RecentLatenciesMs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	s.LatencyP50Ms = latencyPercentile(sorted, 50)
+	s.LatencyP95Ms = latencyPercentile(sorted, 95)
+	s.LatencyP99Ms = latencyPercentile(sorted, 99)
+}
+
+// latencyPercentile returns the nearest-rank p-th percentile of sorted.
+func latencyPercentile(sorted []int64, p int) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
 // TargetStatus defines the status of a target.
-// Simplified to only two states:
 // - healthy: target is available (default state)
 // - cooling: target is in cooldown after failure
+// - checking: a health check for a cooling target is in progress
+// - half_open: target passed its health check and is serving a limited number
+//   of real requests before it is considered healthy again
+// - misconfigured: upstream reported the target's model does not exist; health
+//   checks never recover it, only a manual reset does
 type TargetStatus string
 
 const (
-	StatusHealthy  TargetStatus = "healthy"
-	StatusCooling  TargetStatus = "cooling"
-	StatusChecking TargetStatus = "checking"
+	StatusHealthy       TargetStatus = "healthy"
+	StatusCooling       TargetStatus = "cooling"
+	StatusChecking      TargetStatus = "checking"
+	StatusHalfOpen      TargetStatus = "half_open"
+	StatusMisconfigured TargetStatus = "misconfigured"
+	StatusDraining      TargetStatus = "draining" // finishing in-flight requests before removal; takes no new ones
 )
 
+// IsRoutable reports whether requests may be sent to a target in this status.
+func (s TargetStatus) IsRoutable() bool {
+	return s == StatusHealthy || s == StatusHalfOpen
+}
+
 // RouteState represents the runtime state of a route.
 type RouteState struct {
 	RouteID      string        `json:"route_id"`
@@ -149,7 +498,7 @@ type RouteState struct {
 // LayerState represents the runtime state of a layer.
 type LayerState struct {
 	Level        int            `json:"level"`
-	Status       string         `json:"status"` // "active", "standby", "exhausted"
+	Status       string         `json:"status"` // "active", "standby", "exhausted", "disabled"
 	TargetStates []*TargetState `json:"targets"`
 }
 
@@ -230,6 +579,9 @@ const (
 	EventCooldownStarted  RoutingEventType = "cooldown_started"
 	EventCooldownEnded    RoutingEventType = "cooldown_ended"
 	EventNonRetryableError RoutingEventType = "non_retryable_error"
+	EventTargetMisconfigured RoutingEventType = "target_misconfigured"
+	EventTargetWarmedUp RoutingEventType = "target_warmed_up"
+	EventTargetDrained RoutingEventType = "target_drained"
 )
 
 // ================== Statistics Types ==================
@@ -302,12 +654,18 @@ type HealthResult struct {
 	TargetID     string    `json:"target_id"`
 	CredentialID string    `json:"credential_id"`
 	Model        string    `json:"model"`
-	Status       string    `json:"status"` // "healthy", "unhealthy"
+	Status       string    `json:"status"` // "healthy", "unhealthy", "credential_disabled"
 	LatencyMs    int64     `json:"latency_ms,omitempty"`
 	Message      string    `json:"message,omitempty"`
+	// ErrorClass is the ErrorClass.String() of a failed probe's error.
+	ErrorClass   string    `json:"error_class,omitempty"`
 	CheckedAt    time.Time `json:"checked_at"`
 }
 
+// HealthStatusCredentialDisabled marks a target that was not probed because its
+// credential is disabled at the auth layer.
+const HealthStatusCredentialDisabled = "credential_disabled"
+
 // ================== Filter Types ==================
 
 // StatsFilter defines the filter for statistics queries.
@@ -348,16 +706,28 @@ type HealthHistoryFilter struct {
 type ExportData struct {
 	Version    string            `json:"version"`
 	ExportedAt time.Time         `json:"exported_at"`
+	// Partial marks an export of selected routes. Importing it only adds or
+	// updates those routes, even without merge, and leaves global settings alone.
+	Partial    bool              `json:"partial,omitempty"`
 	Config     ExportedConfig    `json:"config"`
 }
 
 // ExportedConfig represents the exported configuration.
 type ExportedConfig struct {
-	Settings    Settings          `json:"settings"`
+	// Settings is a snapshot for reference in partial exports; importing a
+	// partial export does not apply it.
+	Settings    *Settings         `json:"settings,omitempty"`
 	HealthCheck HealthCheckConfig `json:"health_check"`
 	Routes      []RouteWithPipeline `json:"routes"`
 }
 
+// ArchivedRoute is a deleted route kept with its pipeline so it can be restored.
+type ArchivedRoute struct {
+	Route      Route     `json:"route" yaml:"route"`
+	Pipeline   Pipeline  `json:"pipeline" yaml:"pipeline"`
+	ArchivedAt time.Time `json:"archived_at" yaml:"archived-at"`
+}
+
 // RouteWithPipeline combines route and its pipeline for export.
 type RouteWithPipeline struct {
 	Route    Route    `json:"route"`
@@ -371,3 +741,15 @@ type ValidationError struct {
 	Field   string `json:"field"`
 	Message string `json:"message"`
 }
+
+// ValidationErrors is the error returned when a config write is rejected by
+// validation, so callers can report each offending field.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, v := range e {
+		messages[i] = v.Message
+	}
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,37 @@
+package unifiedrouting
+
+import (
+	"context"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// upstreamProxyFor returns the outbound proxy for target: its own
+// UpstreamProxy, else the Settings default, else "" (use the credential's).
+func upstreamProxyFor(ctx context.Context, configSvc ConfigService, target *Target) string {
+	if target != nil {
+		if proxyURL := strings.TrimSpace(target.UpstreamProxy); proxyURL != "" {
+			return proxyURL
+		}
+	}
+	if configSvc == nil {
+		return ""
+	}
+	settings, err := configSvc.GetSettings(ctx)
+	if err != nil || settings == nil {
+		return ""
+	}
+	return strings.TrimSpace(settings.UpstreamProxy)
+}
+
+// withTargetUpstream returns ctx carrying target's upstream proxy, so executors
+// and probes dial through it instead of the credential's proxy, and its body
+// transforms, so they also apply to probes and shadow copies.
+func withTargetUpstream(ctx context.Context, configSvc ConfigService, target *Target) context.Context {
+	ctx = util.WithUpstreamProxy(ctx, upstreamProxyFor(ctx, configSvc, target))
+	if target == nil {
+		return ctx
+	}
+	return util.WithBodyTransforms(ctx, util.BodyTransforms{Request: target.RequestTransform, Response: target.ResponseTransform})
+}
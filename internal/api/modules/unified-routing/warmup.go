@@ -0,0 +1,56 @@
+package unifiedrouting
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// startWarmup primes a target that just left cooldown with
+// HealthCheckConfig.WarmupRequests probe requests, so the first real request
+// after a long idle period does not pay for cold connections. The target stays
+// routable but deprioritized until the probes finish. It returns immediately.
+func (h *DefaultHealthChecker) startWarmup(ctx context.Context, targetID string) {
+	cfg, _ := h.configSvc.GetHealthCheckConfig(ctx)
+	if cfg == nil || cfg.WarmupRequests <= 0 {
+		return
+	}
+	routes, err := h.configSvc.ListRoutes(ctx)
+	if err != nil {
+		return
+	}
+	target := findTarget(ctx, h.configSvc, routes, targetID)
+	if target == nil {
+		return
+	}
+	h.stateMgr.SetWarmingUp(ctx, targetID, true)
+	go h.runWarmup(target, cfg.WarmupRequests)
+}
+
+// runWarmup sends up to n probes to target one after another and records an
+// EventTargetWarmedUp event. Probe results do not change the target's state;
+// warmup stops early if the target fails again in the meantime.
+func (h *DefaultHealthChecker) runWarmup(target *Target, n int) {
+	ctx := h.backgroundContext()
+	sent, succeeded := 0, 0
+	for ; sent < n && ctx.Err() == nil; sent++ {
+		state, _ := h.stateMgr.GetTargetState(ctx, target.ID)
+		if state == nil || !state.Status.IsRoutable() {
+			break
+		}
+		if result, err := h.probe(ctx, target); err == nil && result.Status == "healthy" {
+			succeeded++
+		}
+	}
+	h.stateMgr.SetWarmingUp(ctx, target.ID, false)
+
+	log.Debugf("target %s warmed up: %d/%d probes succeeded", target.ID, succeeded, sent)
+	h.metrics.RecordEvent(&RoutingEvent{
+		Type:     EventTargetWarmedUp,
+		TargetID: target.ID,
+		Details: map[string]any{
+			"requests":  sent,
+			"succeeded": succeeded,
+		},
+	})
+}
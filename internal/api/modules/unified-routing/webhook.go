@@ -0,0 +1,139 @@
+package unifiedrouting
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// WebhookSignatureHeader carries the hex HMAC-SHA256 of the request body, keyed
+// by Settings.WebhookSecret, as "sha256=<hex>".
+const WebhookSignatureHeader = "X-Unified-Routing-Signature"
+
+const (
+	webhookMaxAttempts    = 4
+	webhookRequestTimeout = 10 * time.Second
+)
+
+// webhookRetryBackoff is the delay before the first retry; it doubles per attempt.
+var webhookRetryBackoff = time.Second
+
+// webhookEvent is the JSON body posted for each ConfigChangeEvent.
+type webhookEvent struct {
+	Type      string    `json:"type"`
+	RouteID   string    `json:"route_id,omitempty"`
+	Payload   any       `json:"payload,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// webhookNotifier posts config change events to Settings.WebhookURL. Settings
+// are read per event, so changing or clearing the URL needs no restart.
+type webhookNotifier struct {
+	store  ConfigStore
+	client *http.Client
+}
+
+func newWebhookNotifier(store ConfigStore) *webhookNotifier {
+	return &webhookNotifier{
+		store:  store,
+		client: &http.Client{Timeout: webhookRequestTimeout},
+	}
+}
+
+// handle is the ConfigChangeHandler registered by NewConfigService.
+func (w *webhookNotifier) handle(event ConfigChangeEvent) {
+	settings, err := w.store.LoadSettings(context.Background())
+	if err != nil || settings == nil || settings.WebhookURL == "" {
+		return
+	}
+
+	payload := event.Payload
+	if s, ok := payload.(*Settings); ok && s != nil {
+		// Never send the signing secret, status key or proxy credentials to
+		// the receiver.
+		payload = stripSettingsSecrets(s)
+	}
+	body, err := json.Marshal(webhookEvent{
+		Type:      event.Type,
+		RouteID:   event.RouteID,
+		Payload:   payload,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		log.Warnf("[UnifiedRouting] webhook: marshal %s event: %v", event.Type, err)
+		return
+	}
+
+	w.deliver(settings.WebhookURL, settings.WebhookSecret, body, event.Type)
+}
+
+// deliver posts body to target, retrying with exponential backoff up to
+// webhookMaxAttempts times. kind names the event in log messages.
+func (w *webhookNotifier) deliver(target, secret string, body []byte, kind string) {
+	backoff := webhookRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := w.post(target, secret, body)
+		if err == nil {
+			return
+		}
+		if attempt == webhookMaxAttempts {
+			log.Warnf("[UnifiedRouting] webhook: giving up on %s event after %d attempts: %v", kind, attempt, err)
+			return
+		}
+		log.Debugf("[UnifiedRouting] webhook: %s event attempt %d failed, retrying in %s: %v", kind, attempt, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (w *webhookNotifier) post(target, secret string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+signWebhookBody(secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// signWebhookBody returns the hex HMAC-SHA256 of body keyed by secret.
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validateWebhookURL accepts an empty URL (webhook disabled) or an absolute
+// http(s) URL. field names the setting in the error.
+func validateWebhookURL(field, raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid %s: must be an absolute http or https URL", field)
+	}
+	return nil
+}
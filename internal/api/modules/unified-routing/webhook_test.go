@@ -0,0 +1,130 @@
+package unifiedrouting
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookPostsSignedConfigEvents(t *testing.T) {
+	webhookRetryBackoff = time.Millisecond
+	defer func() { webhookRetryBackoff = time.Second }()
+
+	var attempts atomic.Int32
+	received := make(chan webhookEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(WebhookSignatureHeader) != "sha256="+signWebhookBody("s3cret", body) {
+			t.Errorf("bad signature %q", r.Header.Get(WebhookSignatureHeader))
+		}
+		// Fail the first attempt to exercise the retry.
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		var event webhookEvent
+		_ = json.Unmarshal(body, &event)
+		received <- event
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	cfgStore, err := NewFileConfigStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileConfigStore: %v", err)
+	}
+	if err := cfgStore.SaveSettings(ctx, &Settings{Enabled: true, WebhookURL: server.URL, WebhookSecret: "s3cret"}); err != nil {
+		t.Fatalf("SaveSettings: %v", err)
+	}
+	configSvc := NewConfigService(cfgStore)
+
+	route := &Route{Name: "hooked", Enabled: true}
+	if err := configSvc.CreateRoute(ctx, route); err != nil {
+		t.Fatalf("CreateRoute: %v", err)
+	}
+
+	select {
+	case event := <-received:
+		if event.Type != "route_created" || event.RouteID != route.ID {
+			t.Fatalf("webhook event = %+v, want route_created for %s", event, route.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("webhook was not delivered")
+	}
+	if got := attempts.Load(); got != 2 {
+		t.Fatalf("webhook attempts = %d, want 2", got)
+	}
+
+	if err := configSvc.UpdateSettings(ctx, &Settings{WebhookURL: "ftp://example.com"}); err == nil {
+		t.Fatalf("UpdateSettings accepted a non-http webhook URL")
+	}
+}
+
+type chanNotifier chan *TargetAlert
+
+func (c chanNotifier) Notify(_ context.Context, alert *TargetAlert) error {
+	c <- alert
+	return nil
+}
+
+func TestTargetAlertsAreDebounced(t *testing.T) {
+	ctx := context.Background()
+	cfgStore, err := NewFileConfigStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileConfigStore: %v", err)
+	}
+	if err := cfgStore.SaveSettings(ctx, &Settings{Enabled: true, AlertFailureThreshold: 2}); err != nil {
+		t.Fatalf("SaveSettings: %v", err)
+	}
+	configSvc := NewConfigService(cfgStore)
+	route := &Route{Name: "paged", Enabled: true}
+	if err := configSvc.CreateRoute(ctx, route); err != nil {
+		t.Fatalf("CreateRoute: %v", err)
+	}
+	pipeline := &Pipeline{RouteID: route.ID, Layers: []Layer{{Level: 1, Targets: []Target{{ID: "t1", CredentialID: "cred", Model: "m", Enabled: true}}}}}
+	if _, err := configSvc.UpdatePipeline(ctx, route.ID, pipeline); err != nil {
+		t.Fatalf("UpdatePipeline: %v", err)
+	}
+
+	stateMgr := NewStateManager(NewMemoryStateStore(), configSvc)
+	alerts := make(chanNotifier, 8)
+	stateMgr.AddNotifier(alerts)
+	expect := func(want TargetAlertType) *TargetAlert {
+		t.Helper()
+		select {
+		case alert := <-alerts:
+			if alert.Type != want {
+				t.Fatalf("alert = %s, want %s", alert.Type, want)
+			}
+			return alert
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %s alert", want)
+		}
+		return nil
+	}
+
+	stateMgr.RecordFailure(ctx, "t1", "HTTP 503", 0)
+	stateMgr.StartCooldownTimed(ctx, "t1")
+	alert := expect(AlertCooldownStarted)
+	if alert.RouteID != route.ID || alert.ConsecutiveFailures != 1 || alert.LastFailureReason != "HTTP 503" {
+		t.Fatalf("alert = %+v, want route, failure count and reason filled in", alert)
+	}
+	stateMgr.EndCooldown(ctx, "t1")
+	expect(AlertTargetRecovered)
+
+	// Flapping straight back into cooldown is debounced; reaching the failure
+	// threshold is a different alert and still goes out.
+	stateMgr.RecordFailure(ctx, "t1", "HTTP 503", 0)
+	expect(AlertFailureThreshold)
+	stateMgr.StartCooldownTimed(ctx, "t1")
+	select {
+	case alert := <-alerts:
+		t.Fatalf("unexpected alert %+v within the debounce window", alert)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -246,7 +246,30 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		if maxSizeMB <= 0 {
 			maxSizeMB = 20
 		}
-		detailedLogger = logging.NewDetailedRequestLogger(cfg.DetailedRequestLog, detailedLogsDir, maxSizeMB)
+		var detailedStore logging.RecordStore
+		if strings.EqualFold(strings.TrimSpace(cfg.DetailedRequestLogStore), "sqlite") {
+			sqliteStore, errStore := logging.NewSQLiteRecordStore(filepath.Join(detailedLogsDir, "detailed-requests.db"))
+			if errStore != nil {
+				log.Warnf("detailed request log: sqlite store unavailable, using file store: %v", errStore)
+			} else {
+				detailedStore = sqliteStore
+			}
+		}
+		detailedLogger = logging.NewDetailedRequestLogger(cfg.DetailedRequestLog, detailedLogsDir, maxSizeMB, cfg.DetailedRequestLogCompressAfterFiles, detailedStore)
+		detailedLogger.SetIncludeManagement(cfg.DetailedRequestLogIncludeManagement)
+		detailedLogger.SetMaskAuthorization(cfg.EffectiveMaskAuthorizationInLogs())
+		detailedLogger.SetLogGetRequests(cfg.LogGetRequests)
+		detailedLogger.SetLogHeadOptionsRequests(cfg.LogHeadOptionsRequests)
+		detailedLogger.SetPathFilters(cfg.DetailedLogIncludePaths, cfg.DetailedLogExcludePaths)
+		detailedLogger.SetMaxAge(time.Duration(cfg.DetailedRequestLogMaxAgeHours) * time.Hour)
+		detailedLogger.SetCleanupInterval(time.Duration(cfg.DetailedRequestLogCleanupIntervalSeconds) * time.Second)
+		detailedLogger.SetWriteBatchSize(cfg.DetailedRequestLogWriteBatchSize)
+		if err := detailedLogger.SetFilenameTemplate(cfg.DetailedRequestLogFilenameTemplate); err != nil {
+			log.WithError(err).Warn("ignoring detailed request log filename template")
+		}
+		detailedLogger.SetRedactPaths(cfg.DetailedRequestLogRedactPaths)
+		detailedLogger.SetSampleRate(cfg.EffectiveDetailedRequestLogSampleRate())
+		detailedLogger.SetMaxBodyBytes(int64(cfg.DetailedRequestLogMaxBodyKB) * 1024)
 		engine.Use(middleware.DetailedRequestLoggingMiddleware(detailedLogger))
 	}
 
@@ -322,6 +345,9 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	if err := modules.RegisterModule(ctx, s.unifiedRoutingModule); err != nil {
 		log.Errorf("Failed to register Unified Routing module: %v", err)
 	}
+	if stateMgr := s.unifiedRoutingModule.GetStateManager(); stateMgr != nil {
+		s.mgmt.SetRoutingStateManager(stateMgr)
+	}
 
 	// Apply additional router configurators from options
 	if optionState.routerConfigurator != nil {
@@ -584,11 +610,19 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.PUT("/request-log", s.mgmt.PutRequestLog)
 		mgmt.PATCH("/request-log", s.mgmt.PutRequestLog)
 
+		mgmt.GET("/health", s.mgmt.GetHealth)
+
 		mgmt.GET("/detailed-request-log", s.mgmt.GetDetailedRequestLog)
 		mgmt.PUT("/detailed-request-log", s.mgmt.PutDetailedRequestLog)
 		mgmt.PATCH("/detailed-request-log", s.mgmt.PutDetailedRequestLog)
 		mgmt.GET("/detailed-requests", s.mgmt.ListDetailedRequests)
+		mgmt.GET("/detailed-requests/export", s.mgmt.ExportDetailedRequests)
+		mgmt.GET("/detailed-requests/stats", s.mgmt.GetDetailedRequestStats)
+		mgmt.GET("/detailed-requests/tail", s.mgmt.TailDetailedRequests)
 		mgmt.GET("/detailed-requests/:id", s.mgmt.GetDetailedRequest)
+		mgmt.GET("/detailed-requests/:id/har", s.mgmt.GetDetailedRequestHAR)
+		mgmt.GET("/detailed-requests/:id/raw", s.mgmt.GetDetailedRequestRaw)
+		mgmt.POST("/detailed-requests/:id/tags", s.mgmt.UpdateDetailedRequestTags)
 		mgmt.DELETE("/detailed-requests", s.mgmt.DeleteDetailedRequests)
 		mgmt.GET("/ws-auth", s.mgmt.GetWebsocketAuth)
 		mgmt.PUT("/ws-auth", s.mgmt.PutWebsocketAuth)
@@ -1357,6 +1391,7 @@ func (s *Server) executeWithUnifiedRoutingFailoverFormat(c *gin.Context, engine
 		c.Writer.Header().Set("Content-Type", "application/json")
 		c.Writer.WriteHeader(http.StatusOK)
 		_, _ = c.Writer.Write(responsePayload)
+		routingEngine.MirrorToShadows(ctx, decision, rawBody, s.unifiedRoutingShadowFunc(rawBody, false, sourceFormat))
 		return
 	}
 
@@ -1398,6 +1433,8 @@ func (s *Server) executeWithUnifiedRoutingFailoverFormat(c *gin.Context, engine
 		return
 	}
 
+	routingEngine.MirrorToShadows(ctx, decision, rawBody, s.unifiedRoutingShadowFunc(rawBody, true, sourceFormat))
+
 	c.Writer.Header().Set("Content-Type", "text/event-stream")
 	c.Writer.Header().Set("Cache-Control", "no-cache")
 	c.Writer.Header().Set("Connection", "keep-alive")
@@ -1440,6 +1477,48 @@ func (s *Server) executeWithUnifiedRoutingFailoverFormat(c *gin.Context, engine
 	}
 }
 
+// unifiedRoutingShadowFunc builds the executor used to mirror a request to
+// shadow targets. Streamed responses are collected into a single body.
+func (s *Server) unifiedRoutingShadowFunc(rawBody []byte, stream bool, sourceFormat sdktranslator.Format) unifiedrouting.ShadowExecuteFunc {
+	return func(ctx context.Context, targetAuth *auth.Auth, targetModel string) ([]byte, error) {
+		newBody, err := sjson.SetBytes(rawBody, "model", targetModel)
+		if err != nil {
+			newBody = rawBody
+		}
+
+		req := cliproxyexecutor.Request{
+			Model:   targetModel,
+			Payload: newBody,
+		}
+		opts := cliproxyexecutor.Options{
+			Stream:          stream,
+			OriginalRequest: rawBody,
+			SourceFormat:    sourceFormat,
+		}
+
+		if !stream {
+			resp, err := s.handlers.AuthManager.ExecuteWithAuth(ctx, targetAuth, req, opts)
+			if err != nil {
+				return nil, err
+			}
+			return resp.Payload, nil
+		}
+
+		chunks, err := s.handlers.AuthManager.ExecuteStreamWithAuth(ctx, targetAuth, req, opts)
+		if err != nil {
+			return nil, err
+		}
+		var body bytes.Buffer
+		for chunk := range chunks {
+			if chunk.Err != nil {
+				return body.Bytes(), chunk.Err
+			}
+			body.Write(chunk.Payload)
+		}
+		return body.Bytes(), nil
+	}
+}
+
 // executeWithUnifiedRoutingSimple executes a request with simple single-target routing (OpenAI format).
 func (s *Server) executeWithUnifiedRoutingSimple(c *gin.Context, engine unifiedrouting.RoutingEngine, modelName string, rawBody []byte, stream bool) {
 	s.executeWithUnifiedRoutingSimpleFormat(c, engine, modelName, rawBody, stream, sdktranslator.FormatOpenAI)
@@ -1689,6 +1768,47 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		if oldCfg == nil || prevMaxSize != cfg.DetailedRequestLogMaxSizeMB {
 			s.detailedLogger.SetMaxSizeMB(cfg.DetailedRequestLogMaxSizeMB)
 		}
+		if oldCfg == nil || oldCfg.EffectiveDetailedRequestLogSampleRate() != cfg.EffectiveDetailedRequestLogSampleRate() {
+			s.detailedLogger.SetSampleRate(cfg.EffectiveDetailedRequestLogSampleRate())
+		}
+		if oldCfg == nil || !reflect.DeepEqual(oldCfg.DetailedRequestLogRedactPaths, cfg.DetailedRequestLogRedactPaths) {
+			s.detailedLogger.SetRedactPaths(cfg.DetailedRequestLogRedactPaths)
+		}
+		if oldCfg == nil || oldCfg.DetailedRequestLogMaxAgeHours != cfg.DetailedRequestLogMaxAgeHours {
+			s.detailedLogger.SetMaxAge(time.Duration(cfg.DetailedRequestLogMaxAgeHours) * time.Hour)
+		}
+		if oldCfg == nil || oldCfg.DetailedRequestLogCleanupIntervalSeconds != cfg.DetailedRequestLogCleanupIntervalSeconds {
+			s.detailedLogger.SetCleanupInterval(time.Duration(cfg.DetailedRequestLogCleanupIntervalSeconds) * time.Second)
+		}
+		if oldCfg == nil || oldCfg.DetailedRequestLogWriteBatchSize != cfg.DetailedRequestLogWriteBatchSize {
+			s.detailedLogger.SetWriteBatchSize(cfg.DetailedRequestLogWriteBatchSize)
+		}
+		if oldCfg == nil || oldCfg.DetailedRequestLogFilenameTemplate != cfg.DetailedRequestLogFilenameTemplate {
+			if err := s.detailedLogger.SetFilenameTemplate(cfg.DetailedRequestLogFilenameTemplate); err != nil {
+				log.WithError(err).Warn("ignoring detailed request log filename template")
+			}
+		}
+		if oldCfg == nil || oldCfg.DetailedRequestLogCompressAfterFiles != cfg.DetailedRequestLogCompressAfterFiles {
+			s.detailedLogger.SetCompressAfterFiles(cfg.DetailedRequestLogCompressAfterFiles)
+		}
+		if oldCfg == nil || oldCfg.DetailedRequestLogIncludeManagement != cfg.DetailedRequestLogIncludeManagement {
+			s.detailedLogger.SetIncludeManagement(cfg.DetailedRequestLogIncludeManagement)
+		}
+		if oldCfg == nil || oldCfg.EffectiveMaskAuthorizationInLogs() != cfg.EffectiveMaskAuthorizationInLogs() {
+			s.detailedLogger.SetMaskAuthorization(cfg.EffectiveMaskAuthorizationInLogs())
+		}
+		if oldCfg == nil || oldCfg.LogGetRequests != cfg.LogGetRequests {
+			s.detailedLogger.SetLogGetRequests(cfg.LogGetRequests)
+		}
+		if oldCfg == nil || oldCfg.LogHeadOptionsRequests != cfg.LogHeadOptionsRequests {
+			s.detailedLogger.SetLogHeadOptionsRequests(cfg.LogHeadOptionsRequests)
+		}
+		if oldCfg == nil || oldCfg.DetailedRequestLogMaxBodyKB != cfg.DetailedRequestLogMaxBodyKB {
+			s.detailedLogger.SetMaxBodyBytes(int64(cfg.DetailedRequestLogMaxBodyKB) * 1024)
+		}
+		if oldCfg == nil || !reflect.DeepEqual(oldCfg.DetailedLogIncludePaths, cfg.DetailedLogIncludePaths) || !reflect.DeepEqual(oldCfg.DetailedLogExcludePaths, cfg.DetailedLogExcludePaths) {
+			s.detailedLogger.SetPathFilters(cfg.DetailedLogIncludePaths, cfg.DetailedLogExcludePaths)
+		}
 	}
 
 	if oldCfg == nil || oldCfg.LoggingToFile != cfg.LoggingToFile || oldCfg.LogsMaxTotalSizeMB != cfg.LogsMaxTotalSizeMB {
@@ -1720,6 +1840,10 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		util.SetLogLevel(cfg)
 	}
 
+	if oldCfg == nil || oldCfg.DNSResolver != cfg.DNSResolver || oldCfg.DNSResolverStrict != cfg.DNSResolverStrict {
+		util.ConfigureDNSResolver(&cfg.SDKConfig)
+	}
+
 	prevSecretEmpty := true
 	if oldCfg != nil {
 		prevSecretEmpty = oldCfg.RemoteManagement.SecretKey == ""
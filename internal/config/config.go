@@ -666,6 +666,18 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	// Validate raw payload rules and drop invalid entries.
 	cfg.SanitizePayloadRules()
 
+	// Reject proxy URLs that cannot be dialed, instead of failing later with a
+	// confusing connection or TLS error on the first upstream request.
+	if err = cfg.ValidateProxyURLs(); err != nil {
+		return nil, fmt.Errorf("invalid proxy configuration: %w", err)
+	}
+	if err = ValidateDNSResolver(cfg.DNSResolver); err != nil {
+		return nil, fmt.Errorf("invalid dns-resolver: %w", err)
+	}
+	if err = ValidateDetailFilenameTemplate(cfg.DetailedRequestLogFilenameTemplate); err != nil {
+		return nil, fmt.Errorf("invalid detailed-request-log-filename-template: %w", err)
+	}
+
 	// NOTE: Legacy migration persistence is intentionally disabled together with
 	// startup legacy migration to keep startup read-only for config.yaml.
 	// Re-enable the block below if automatic startup migration is needed again.
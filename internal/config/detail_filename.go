@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultDetailFilenameTemplate is the detail file layout used when
+// detailed-request-log-filename-template is empty.
+const DefaultDetailFilenameTemplate = "{prefix}{path}-{ts}-{id}{suffix}"
+
+// DetailFilenamePlaceholders documents the placeholders a detail filename
+// template may use. Values are sanitized so they are safe in a filename.
+var DetailFilenamePlaceholders = map[string]string{
+	"prefix": `the fixed "detail-" prefix; must start the template`,
+	"suffix": `the fixed ".json" suffix; must end the template`,
+	"path":   "request path, e.g. v1-chat-completions",
+	"ts":     "request time, e.g. 2026-02-08T130145",
+	"id":     "request ID; required",
+	"status": "response status code, e.g. 200 or 502",
+	"model":  "requested model, or unknown",
+	"method": "HTTP method, e.g. POST",
+}
+
+// ValidateDetailFilenameTemplate checks a detail filename template. The
+// template must start with {prefix}, end with {suffix} and include {id}, so
+// detail files stay recognizable and can be found by ID; literal text between
+// placeholders is limited to letters, digits, '.', '_' and '-'. An empty
+// template is valid and selects DefaultDetailFilenameTemplate.
+func ValidateDetailFilenameTemplate(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	if !strings.HasPrefix(raw, "{prefix}") || !strings.HasSuffix(raw, "{suffix}") {
+		return fmt.Errorf("template %q must start with {prefix} and end with {suffix}", raw)
+	}
+	seen := make(map[string]int)
+	for rest := raw; rest != ""; {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			open = len(rest)
+		}
+		for _, r := range rest[:open] {
+			if !isFilenameLiteral(r) {
+				return fmt.Errorf("template %q contains %q; only letters, digits, '.', '_' and '-' are allowed between placeholders", raw, r)
+			}
+		}
+		if open == len(rest) {
+			break
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return fmt.Errorf("template %q has an unclosed placeholder", raw)
+		}
+		name := rest[open+1 : open+end]
+		if _, ok := DetailFilenamePlaceholders[name]; !ok {
+			return fmt.Errorf("template %q uses unknown placeholder {%s}", raw, name)
+		}
+		seen[name]++
+		rest = rest[open+end+1:]
+	}
+	if seen["prefix"] != 1 || seen["suffix"] != 1 {
+		return fmt.Errorf("template %q must use {prefix} and {suffix} once each", raw)
+	}
+	if seen["id"] == 0 {
+		return fmt.Errorf("template %q must include {id}", raw)
+	}
+	return nil
+}
+
+func isFilenameLiteral(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-'
+}
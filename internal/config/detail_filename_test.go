@@ -0,0 +1,29 @@
+package config
+
+import "testing"
+
+func TestValidateDetailFilenameTemplate(t *testing.T) {
+	tests := []struct {
+		raw     string
+		wantErr bool
+	}{
+		{"", false},
+		{DefaultDetailFilenameTemplate, false},
+		{"{prefix}{ts}-{status}-{model}-{id}{suffix}", false},
+		{"{prefix}{method}_{path}.{id}{suffix}", false},
+		{"{ts}-{id}{suffix}", true},             // no prefix
+		{"{prefix}{ts}-{id}", true},             // no suffix
+		{"{prefix}{ts}-{status}{suffix}", true}, // no id
+		{"{prefix}{id}-{user}{suffix}", true},   // unknown placeholder
+		{"{prefix}{id}/{ts}{suffix}", true},     // path separator
+		{"{prefix}{id}-{ts{suffix}", true},      // placeholder inside placeholder
+		{"{prefix}{id}-{ts", true},              // unclosed
+		{"{prefix}{prefix}{id}{suffix}", true},
+	}
+	for _, tt := range tests {
+		err := ValidateDetailFilenameTemplate(tt.raw)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ValidateDetailFilenameTemplate(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ValidateDNSResolver checks that raw is a resolver the server can query:
+// tls://host:port (DNS-over-TLS) or https://host/path (DNS-over-HTTPS). An
+// empty string is valid and means the system resolver.
+func ValidateDNSResolver(raw string) error {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid resolver URL: %w", err)
+	}
+	switch u.Scheme {
+	case "tls":
+		if _, port, errSplit := net.SplitHostPort(u.Host); errSplit != nil || port == "" {
+			return fmt.Errorf("DoT resolver %q must be tls://host:port", raw)
+		}
+	case "https":
+		if u.Hostname() == "" {
+			return fmt.Errorf("DoH resolver %q must include a host", raw)
+		}
+	default:
+		return fmt.Errorf("unsupported resolver scheme %q (want tls or https)", u.Scheme)
+	}
+	return nil
+}
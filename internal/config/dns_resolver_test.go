@@ -0,0 +1,22 @@
+package config
+
+import "testing"
+
+func TestValidateDNSResolver(t *testing.T) {
+	tests := []struct {
+		raw     string
+		wantErr bool
+	}{
+		{"", false},
+		{"tls://1.1.1.1:853", false},
+		{"tls://1.1.1.1", true},
+		{"https://dns.google/dns-query", false},
+		{"https:///dns-query", true},
+		{"udp://8.8.8.8:53", true},
+	}
+	for _, tt := range tests {
+		if err := ValidateDNSResolver(tt.raw); (err != nil) != tt.wantErr {
+			t.Fatalf("ValidateDNSResolver(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+		}
+	}
+}
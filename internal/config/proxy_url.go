@@ -0,0 +1,134 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	sscore "github.com/shadowsocks/go-shadowsocks2/core"
+)
+
+// ValidateProxyURL checks that raw is an outbound proxy URL the server can dial:
+// socks5://[user:pass@]host:port, http(s)://host[:port], or
+// ss://method:password@host:port (SIP002 base64 userinfo is accepted too).
+// An empty string is valid and means no proxy. For ss:// the cipher is built
+// once so a bad method or password fails at load rather than on first use.
+// Credentials are never echoed back in the error.
+func ValidateProxyURL(raw string) error {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "socks5", "ss":
+		if _, port, errSplit := net.SplitHostPort(u.Host); errSplit != nil || port == "" {
+			return fmt.Errorf("%s proxy URL %q must include host:port", u.Scheme, u.Redacted())
+		}
+		if u.Scheme == "ss" && (u.User == nil || u.User.Username() == "") {
+			return fmt.Errorf("ss proxy URL %q must include method and password", u.Redacted())
+		}
+		if u.Scheme == "ss" {
+			method, password, _, errSS := ParseShadowsocksURL(raw)
+			if errSS != nil {
+				return fmt.Errorf("ss proxy URL %q: %w", u.Redacted(), errSS)
+			}
+			if _, errCipher := sscore.PickCipher(method, nil, password); errCipher != nil {
+				return fmt.Errorf("ss proxy URL %q: cipher %q: %w", u.Redacted(), method, errCipher)
+			}
+		}
+	case "http", "https":
+		if u.Hostname() == "" {
+			return fmt.Errorf("%s proxy URL %q must include a host", u.Scheme, u.Redacted())
+		}
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q (want socks5, ss, http or https)", u.Scheme)
+	}
+	return nil
+}
+
+// ValidateProxyURLs checks the global proxy-url and every per-key proxy-url.
+func (cfg *Config) ValidateProxyURLs() error {
+	if cfg == nil {
+		return nil
+	}
+	if err := ValidateProxyURL(cfg.ProxyURL); err != nil {
+		return fmt.Errorf("proxy-url: %w", err)
+	}
+	for i, key := range cfg.GeminiKey {
+		if err := ValidateProxyURL(key.ProxyURL); err != nil {
+			return fmt.Errorf("gemini-api-key[%d].proxy-url: %w", i, err)
+		}
+	}
+	for i, key := range cfg.CodexKey {
+		if err := ValidateProxyURL(key.ProxyURL); err != nil {
+			return fmt.Errorf("codex-api-key[%d].proxy-url: %w", i, err)
+		}
+	}
+	for i, key := range cfg.ClaudeKey {
+		if err := ValidateProxyURL(key.ProxyURL); err != nil {
+			return fmt.Errorf("claude-api-key[%d].proxy-url: %w", i, err)
+		}
+	}
+	for i, key := range cfg.VertexCompatAPIKey {
+		if err := ValidateProxyURL(key.ProxyURL); err != nil {
+			return fmt.Errorf("vertex-api-key[%d].proxy-url: %w", i, err)
+		}
+	}
+	for i, compat := range cfg.OpenAICompatibility {
+		for j, entry := range compat.APIKeyEntries {
+			if err := ValidateProxyURL(entry.ProxyURL); err != nil {
+				return fmt.Errorf("openai-compatibility[%d].api-key-entries[%d].proxy-url: %w", i, j, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ParseShadowsocksURL returns the method, password and server host:port of a
+// Shadowsocks URL. Supports formats:
+//   - ss://method:password@host:port
+//   - ss://BASE64(method:password)@host:port (SIP002 format)
+func ParseShadowsocksURL(raw string) (method, password, server string, err error) {
+	u, errParse := url.Parse(raw)
+	if errParse != nil {
+		return "", "", "", fmt.Errorf("parse URL: %w", errParse)
+	}
+	if u.Scheme != "ss" {
+		return "", "", "", fmt.Errorf("not a Shadowsocks URL")
+	}
+	server = u.Host
+	if server == "" {
+		return "", "", "", fmt.Errorf("missing server address")
+	}
+	if u.User != nil {
+		method = u.User.Username()
+		password, _ = u.User.Password()
+		if method != "" && password != "" {
+			return method, password, server, nil
+		}
+		// A lone username may be base64(method:password).
+		if decoded, ok := decodeShadowsocksUserinfo(method); ok {
+			if parts := strings.SplitN(decoded, ":", 2); len(parts) == 2 && parts[0] != "" {
+				return parts[0], parts[1], server, nil
+			}
+		}
+	}
+	return "", "", "", fmt.Errorf("cannot parse method and password from URL")
+}
+
+// decodeShadowsocksUserinfo decodes URL-safe or standard base64, with or
+// without padding.
+func decodeShadowsocksUserinfo(encoded string) (string, bool) {
+	for _, enc := range []*base64.Encoding{base64.RawURLEncoding, base64.URLEncoding, base64.StdEncoding, base64.RawStdEncoding} {
+		if decoded, err := enc.DecodeString(encoded); err == nil {
+			return string(decoded), true
+		}
+	}
+	return "", false
+}
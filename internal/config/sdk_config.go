@@ -13,6 +13,13 @@ type SDKConfig struct {
 	// Only used when proxy-url uses the ss:// scheme. Leave empty to use system DNS.
 	ProxyDNS string `yaml:"proxy-dns,omitempty" json:"proxy-dns,omitempty"`
 
+	// DNSResolver replaces the system resolver for upstream and auth hostnames when set:
+	// tls://ip:port for DNS-over-TLS or https://host/dns-query for DNS-over-HTTPS.
+	DNSResolver string `yaml:"dns-resolver,omitempty" json:"dns-resolver,omitempty"`
+
+	// DNSResolverStrict fails lookups when dns-resolver errors instead of falling back to system DNS.
+	DNSResolverStrict bool `yaml:"dns-resolver-strict,omitempty" json:"dns-resolver-strict,omitempty"`
+
 	// ForceModelPrefix requires explicit model prefixes (e.g., "teamA/gemini-3-pro-preview")
 	// to target prefixed credentials. When false, unprefixed model requests may use prefixed
 	// credentials as well.
@@ -22,15 +29,50 @@ type SDKConfig struct {
 	// Independent of DetailedRequestLog.
 	RequestLog bool `yaml:"request-log" json:"request-log"`
 
+	// RequestLogMaxUpstreamKB caps the upstream request and response text the request log keeps
+	// in memory per client request, across all retry attempts. 0 uses 16 MB.
+	RequestLogMaxUpstreamKB int `yaml:"request-log-max-upstream-kb,omitempty" json:"request-log-max-upstream-kb,omitempty"`
+
 	// DetailedRequestLog enables structured detailed request logging (one JSON file per request in
 	// logs/detailed-requests/), with retry/attempt recording. Fully independent of RequestLog:
 	// when on, upstream attempts are recorded for the detailed log without requiring RequestLog.
 	DetailedRequestLog bool `yaml:"detailed-request-log" json:"detailed-request-log"`
 
-	// DetailedRequestLogMaxSizeMB limits the total size (in MB) of the detailed request log file.
+	// DetailedRequestLogMaxSizeMB limits the total size (in MB) of the detailed request log files,
+	// or of the SQLite database when that record store is used.
 	// When exceeded, the oldest records are removed. Default is 100 MB. Set to 0 for default.
 	DetailedRequestLogMaxSizeMB int `yaml:"detailed-request-log-max-size-mb,omitempty" json:"detailed-request-log-max-size-mb,omitempty"`
 
+	// DetailedRequestLogSampleRate is the fraction (0.0–1.0) of successful requests recorded in the
+	// detailed log; failed requests are always recorded. Unset means 1.0 (record everything).
+	DetailedRequestLogSampleRate *float64 `yaml:"detailed-request-log-sample-rate,omitempty" json:"detailed-request-log-sample-rate,omitempty"`
+
+	// DetailedRequestLogMaxAgeHours removes detailed records older than this many hours,
+	// regardless of the size and count limits. 0 disables age-based retention.
+	DetailedRequestLogMaxAgeHours int `yaml:"detailed-request-log-max-age-hours,omitempty" json:"detailed-request-log-max-age-hours,omitempty"`
+
+	// DetailedRequestLogCleanupIntervalSeconds is how often the size, count and age limits of
+	// the detailed log are enforced. 0 uses 30 seconds.
+	DetailedRequestLogCleanupIntervalSeconds int `yaml:"detailed-request-log-cleanup-interval-seconds,omitempty" json:"detailed-request-log-cleanup-interval-seconds,omitempty"`
+
+	// DetailedRequestLogWriteBatchSize is how many queued detailed records are written per wakeup
+	// of the background writer, sharing one index update. 0 uses 64; 1 writes records one at a time.
+	DetailedRequestLogWriteBatchSize int `yaml:"detailed-request-log-write-batch-size,omitempty" json:"detailed-request-log-write-batch-size,omitempty"`
+
+	// DetailedRequestLogFilenameTemplate lays out detail file names, e.g.
+	// "{prefix}{ts}-{status}-{model}-{id}{suffix}". See DetailFilenamePlaceholders for the
+	// placeholders. Empty uses DefaultDetailFilenameTemplate. Existing files keep their names.
+	DetailedRequestLogFilenameTemplate string `yaml:"detailed-request-log-filename-template,omitempty" json:"detailed-request-log-filename-template,omitempty"`
+
+	// DetailedRequestLogStore selects where completed detailed records are kept: "file" (default,
+	// one JSON file per record) or "sqlite" (a single indexed database in the same directory).
+	// Changing it requires a restart.
+	DetailedRequestLogStore string `yaml:"detailed-request-log-store,omitempty" json:"detailed-request-log-store,omitempty"`
+
+	// DetailedRequestLogCompressAfterFiles gzips all but the newest N detail records on disk.
+	// Compressed records stay readable through the management API. 0 disables compression.
+	DetailedRequestLogCompressAfterFiles int `yaml:"detailed-request-log-compress-after-files,omitempty" json:"detailed-request-log-compress-after-files,omitempty"`
+
 	// DetailedRequestLogShowRetries controls whether the management UI shows the retries section in detailed request cards.
 	// Stored with other detailed-log settings; does not affect backend logging behavior.
 	DetailedRequestLogShowRetries bool `yaml:"detailed-request-log-show-retries" json:"detailed-request-log-show-retries"`
@@ -39,6 +81,43 @@ type SDKConfig struct {
 	// Stored with other detailed-log settings; does not affect backend logging behavior.
 	DetailedRequestLogShowSimulated bool `yaml:"detailed-request-log-show-simulated" json:"detailed-request-log-show-simulated"`
 
+	// DetailedRequestLogRedactPaths lists gjson-style paths (e.g. "messages.#.content", "metadata.user_id")
+	// whose values are replaced with "[REDACTED]" in logged JSON bodies and SSE events before they are
+	// written. Bodies the paths cannot be applied to are stored as "[unredactable body omitted]".
+	DetailedRequestLogRedactPaths []string `yaml:"detailed-request-log-redact-paths,omitempty" json:"detailed-request-log-redact-paths,omitempty"`
+
+	// DetailedRequestLogIncludeManagement also records management API traffic in the detailed log.
+	// Intended for developing the management API; off by default. Management credentials are fully masked.
+	DetailedRequestLogIncludeManagement bool `yaml:"detailed-request-log-include-management,omitempty" json:"detailed-request-log-include-management,omitempty"`
+
+	// DetailedRequestLogMaxBodyKB caps how much of a client request body the detailed log reads
+	// into memory; larger bodies are stored truncated and streamed on to the handler. The same
+	// cap applies to every stored response and upstream attempt body. 0 uses 10 MB.
+	DetailedRequestLogMaxBodyKB int `yaml:"detailed-request-log-max-body-kb,omitempty" json:"detailed-request-log-max-body-kb,omitempty"`
+
+	// DetailedLogIncludePaths and DetailedLogExcludePaths are path globs ("*" within a segment,
+	// "**" across segments) selecting which routes the detailed log records. A path is recorded
+	// unless it matches an exclude glob and no include glob. Empty lists keep the defaults:
+	// exclude "/api**", include "/api/provider**"; custom includes are added to the default
+	// include while the default exclude is in effect. Management paths follow
+	// detailed-request-log-include-management instead.
+	DetailedLogIncludePaths []string `yaml:"detailed-request-log-include-paths,omitempty" json:"detailed-request-log-include-paths,omitempty"`
+	DetailedLogExcludePaths []string `yaml:"detailed-request-log-exclude-paths,omitempty" json:"detailed-request-log-exclude-paths,omitempty"`
+
+	// LogGetRequests also records GET requests (e.g. /v1/models) in the detailed log; they usually
+	// have no body. Management API GETs are never recorded.
+	LogGetRequests bool `yaml:"detailed-request-log-get-requests,omitempty" json:"detailed-request-log-get-requests,omitempty"`
+
+	// LogHeadOptionsRequests also records HEAD requests and OPTIONS (CORS preflight) requests in
+	// the detailed log, with their headers and status; useful when debugging auth. Management API
+	// ones are never recorded.
+	LogHeadOptionsRequests bool `yaml:"detailed-request-log-head-options-requests,omitempty" json:"detailed-request-log-head-options-requests,omitempty"`
+
+	// MaskAuthorizationInLogs masks client credentials (Authorization and API-key headers) in the
+	// request headers stored by the detailed log. Unset means true. Upstream attempt headers are
+	// always masked. The management cURL export can restore the client key at replay time.
+	MaskAuthorizationInLogs *bool `yaml:"mask-authorization-in-logs,omitempty" json:"mask-authorization-in-logs,omitempty"`
+
 	// APIKeys is a list of keys for authenticating clients to this proxy server.
 	APIKeys []string `yaml:"api-keys" json:"api-keys"`
 
@@ -65,3 +144,28 @@ type StreamingConfig struct {
 	// <= 0 disables bootstrap retries. Default is 0.
 	BootstrapRetries int `yaml:"bootstrap-retries,omitempty" json:"bootstrap-retries,omitempty"`
 }
+
+// EffectiveMaskAuthorizationInLogs returns whether detailed logs mask client credentials,
+// defaulting to true when unset.
+func (c *SDKConfig) EffectiveMaskAuthorizationInLogs() bool {
+	if c == nil || c.MaskAuthorizationInLogs == nil {
+		return true
+	}
+	return *c.MaskAuthorizationInLogs
+}
+
+// EffectiveDetailedRequestLogSampleRate returns the configured detailed-log sample rate
+// clamped to [0, 1], defaulting to 1 when unset.
+func (c *SDKConfig) EffectiveDetailedRequestLogSampleRate() float64 {
+	if c == nil || c.DetailedRequestLogSampleRate == nil {
+		return 1
+	}
+	rate := *c.DetailedRequestLogSampleRate
+	if rate < 0 {
+		return 0
+	}
+	if rate > 1 {
+		return 1
+	}
+	return rate
+}
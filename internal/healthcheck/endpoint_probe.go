@@ -0,0 +1,172 @@
+package healthcheck
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// defaultBaseURLs lists the upstream used by each provider when the credential
+// carries no base_url attribute.
+var defaultBaseURLs = map[string]string{
+	"claude":      "https://api.anthropic.com",
+	"gemini":      "https://generativelanguage.googleapis.com",
+	"vertex":      "https://aiplatform.googleapis.com",
+	"gemini-cli":  "https://cloudcode-pa.googleapis.com",
+	"antigravity": "https://cloudcode-pa.googleapis.com",
+	"codex":       "https://chatgpt.com/backend-api/codex",
+	"qwen":        "https://portal.qwen.ai/v1",
+	"iflow":       "https://apis.iflow.cn/v1",
+	"kimi":        "https://api.kimi.com/coding",
+}
+
+// UpstreamBaseURL returns the base URL requests for auth are sent to.
+func UpstreamBaseURL(auth *coreauth.Auth) string {
+	if auth == nil {
+		return ""
+	}
+	if auth.Attributes != nil {
+		if base := strings.TrimSpace(auth.Attributes["base_url"]); base != "" {
+			return strings.TrimSuffix(base, "/")
+		}
+	}
+	return defaultBaseURLs[strings.ToLower(strings.TrimSpace(auth.Provider))]
+}
+
+// buildModelsRequest builds the models-list request for an API-key credential.
+// It returns an error for credentials whose provider exposes no such endpoint
+// or that authenticate with OAuth tokens.
+func buildModelsRequest(ctx context.Context, auth *coreauth.Auth) (*http.Request, error) {
+	apiKey := ""
+	if auth != nil && auth.Attributes != nil {
+		apiKey = strings.TrimSpace(auth.Attributes["api_key"])
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("models probe needs an API key credential")
+	}
+	base := UpstreamBaseURL(auth)
+	if base == "" {
+		return nil, fmt.Errorf("no upstream base URL for provider %q", authProvider(auth))
+	}
+
+	var endpoint string
+	header := http.Header{}
+	switch {
+	case auth.Attributes["compat_name"] != "":
+		endpoint = base + "/models"
+		header.Set("Authorization", "Bearer "+apiKey)
+	case strings.EqualFold(auth.Provider, "claude"):
+		endpoint = base + "/v1/models?limit=1"
+		header.Set("x-api-key", apiKey)
+		header.Set("anthropic-version", "2023-06-01")
+	case strings.EqualFold(auth.Provider, "gemini"):
+		endpoint = base + "/v1beta/models?pageSize=1"
+		header.Set("x-goog-api-key", apiKey)
+	case strings.EqualFold(auth.Provider, "codex"):
+		// API-key Codex credentials point base_url at an OpenAI-style /v1 root.
+		endpoint = base + "/models"
+		header.Set("Authorization", "Bearer "+apiKey)
+	default:
+		return nil, fmt.Errorf("models probe unsupported for provider %q", authProvider(auth))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	return req, nil
+}
+
+// ProbeModels sends a GET to the provider's models-list endpoint and succeeds
+// on HTTP 200. It costs no quota, unlike a completion probe.
+func ProbeModels(ctx context.Context, auth *coreauth.Auth) error {
+	req, err := buildModelsRequest(ctx, auth)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{}
+	if transport := probeTransport(ctx, auth); transport != nil {
+		client.Transport = transport
+	} else if transport = util.DNSTransport(); transport != nil {
+		client.Transport = transport
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// ProbeTCP dials the upstream host and, for https upstreams, completes a TLS
+// handshake. It proves only that the upstream is reachable, not that the
+// credential works. The dial goes through the effective proxy when it is a
+// SOCKS5 or Shadowsocks proxy and is direct otherwise.
+func ProbeTCP(ctx context.Context, auth *coreauth.Auth) error {
+	base := UpstreamBaseURL(auth)
+	if base == "" {
+		return fmt.Errorf("no upstream base URL for provider %q", authProvider(auth))
+	}
+	u, err := url.Parse(base)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid upstream base URL %q", base)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	addr := net.JoinHostPort(u.Hostname(), port)
+
+	dial := util.DialContext
+	if transport := probeTransport(ctx, auth); transport != nil && transport.DialContext != nil {
+		dial = transport.DialContext
+	}
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if u.Scheme == "http" {
+		return nil
+	}
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return fmt.Errorf("tls handshake with %s: %w", addr, err)
+	}
+	return nil
+}
+
+// probeTransport returns the proxy transport a probe for auth should use: the
+// per-request upstream proxy on ctx, else the credential's own proxy. It
+// returns nil when neither is set.
+func probeTransport(ctx context.Context, auth *coreauth.Auth) *http.Transport {
+	proxyURL := util.UpstreamProxyFrom(ctx)
+	var proxyDNS string
+	if auth != nil {
+		if proxyURL == "" {
+			proxyURL = auth.ProxyURL
+		}
+		proxyDNS = auth.ProxyDNS
+	}
+	if proxyURL == "" {
+		return nil
+	}
+	return util.ProxyTransport(proxyURL, proxyDNS)
+}
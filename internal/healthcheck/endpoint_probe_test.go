@@ -0,0 +1,60 @@
+package healthcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestProbeModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	compat := func(key string) *coreauth.Auth {
+		return &coreauth.Auth{Provider: "openrouter", Attributes: map[string]string{
+			"compat_name": "openrouter",
+			"base_url":    server.URL + "/v1/",
+			"api_key":     key,
+		}}
+	}
+
+	if err := ProbeModels(context.Background(), compat("good")); err != nil {
+		t.Fatalf("ProbeModels with valid key: %v", err)
+	}
+	if err := ProbeModels(context.Background(), compat("bad")); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("ProbeModels with invalid key = %v, want HTTP 401", err)
+	}
+	if err := ProbeModels(context.Background(), &coreauth.Auth{Provider: "codex"}); err == nil {
+		t.Fatalf("ProbeModels accepted an OAuth credential")
+	}
+}
+
+func TestProbeTCP(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	auth := &coreauth.Auth{Provider: "claude", Attributes: map[string]string{"base_url": server.URL}}
+
+	if err := ProbeTCP(context.Background(), auth); err != nil {
+		t.Fatalf("ProbeTCP on listening upstream: %v", err)
+	}
+	server.Close()
+	if err := ProbeTCP(context.Background(), auth); err == nil {
+		t.Fatalf("ProbeTCP succeeded on closed upstream")
+	}
+	if err := ProbeTCP(context.Background(), &coreauth.Auth{Provider: "unknown"}); err == nil {
+		t.Fatalf("ProbeTCP succeeded without an upstream address")
+	}
+}
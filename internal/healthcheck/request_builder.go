@@ -9,12 +9,29 @@ import (
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 )
 
+// defaultProbePrompt is the user message sent when ProbeOptions.Prompt is empty.
+const defaultProbePrompt = "hi"
+
+// ProbeOptions customises a health-check request. Zero values keep the defaults:
+// a streaming "hi" with no output token cap.
+type ProbeOptions struct {
+	Prompt    string
+	MaxTokens int
+	NoStream  bool
+}
+
 // BuildProbeRequest creates a provider-aware health-check request that follows
 // the same translator entry format as real traffic as closely as possible.
 func BuildProbeRequest(auth *coreauth.Auth, model string) (cliproxyexecutor.Request, cliproxyexecutor.Options, error) {
+	return BuildProbeRequestWithOptions(auth, model, ProbeOptions{})
+}
+
+// BuildProbeRequestWithOptions is BuildProbeRequest with a custom prompt, token cap,
+// or streaming mode.
+func BuildProbeRequestWithOptions(auth *coreauth.Auth, model string, probe ProbeOptions) (cliproxyexecutor.Request, cliproxyexecutor.Options, error) {
 	sourceFormat := preferredSourceFormat(auth)
 
-	payload, err := buildProbePayload(sourceFormat, model)
+	payload, err := buildProbePayload(sourceFormat, model, probe)
 	if err != nil {
 		return cliproxyexecutor.Request{}, cliproxyexecutor.Options{}, err
 	}
@@ -25,7 +42,7 @@ func BuildProbeRequest(auth *coreauth.Auth, model string) (cliproxyexecutor.Requ
 		Format:  sourceFormat,
 	}
 	opts := cliproxyexecutor.Options{
-		Stream:          true,
+		Stream:          !probe.NoStream,
 		SourceFormat:    sourceFormat,
 		OriginalRequest: payload,
 	}
@@ -54,10 +71,15 @@ func authProvider(auth *coreauth.Auth) string {
 	return auth.Provider
 }
 
-func buildProbePayload(sourceFormat sdktranslator.Format, model string) ([]byte, error) {
+func buildProbePayload(sourceFormat sdktranslator.Format, model string, probe ProbeOptions) ([]byte, error) {
+	prompt := probe.Prompt
+	if strings.TrimSpace(prompt) == "" {
+		prompt = defaultProbePrompt
+	}
+
 	switch sourceFormat {
 	case sdktranslator.FormatOpenAIResponse:
-		return json.Marshal(map[string]any{
+		payload := map[string]any{
 			"model": model,
 			"input": []map[string]any{
 				{
@@ -66,23 +88,31 @@ func buildProbePayload(sourceFormat sdktranslator.Format, model string) ([]byte,
 					"content": []map[string]any{
 						{
 							"type": "input_text",
-							"text": "hi",
+							"text": prompt,
 						},
 					},
 				},
 			},
-			"stream": true,
-		})
+			"stream": !probe.NoStream,
+		}
+		if probe.MaxTokens > 0 {
+			payload["max_output_tokens"] = probe.MaxTokens
+		}
+		return json.Marshal(payload)
 	default:
-		return json.Marshal(map[string]any{
+		payload := map[string]any{
 			"model": model,
 			"messages": []map[string]any{
 				{
 					"role":    "user",
-					"content": "hi",
+					"content": prompt,
 				},
 			},
-			"stream": true,
-		})
+			"stream": !probe.NoStream,
+		}
+		if probe.MaxTokens > 0 {
+			payload["max_tokens"] = probe.MaxTokens
+		}
+		return json.Marshal(payload)
 	}
 }
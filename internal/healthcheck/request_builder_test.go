@@ -61,3 +61,32 @@ func TestBuildProbeRequestUsesChatCompletionsForClaude(t *testing.T) {
 		t.Fatalf("did not expect max_tokens in chat probe payload")
 	}
 }
+
+func TestBuildProbeRequestWithOptions(t *testing.T) {
+	probe := ProbeOptions{Prompt: "ping", MaxTokens: 16, NoStream: true}
+
+	req, opts, err := BuildProbeRequestWithOptions(&coreauth.Auth{Provider: "claude"}, "claude-sonnet", probe)
+	if err != nil {
+		t.Fatalf("BuildProbeRequestWithOptions returned error: %v", err)
+	}
+	if opts.Stream || gjson.GetBytes(req.Payload, "stream").Bool() {
+		t.Fatalf("expected a non-streaming probe")
+	}
+	if gjson.GetBytes(req.Payload, "messages.0.content").String() != "ping" {
+		t.Fatalf("expected custom prompt in chat probe payload")
+	}
+	if gjson.GetBytes(req.Payload, "max_tokens").Int() != 16 {
+		t.Fatalf("expected max_tokens 16 in chat probe payload")
+	}
+
+	req, _, err = BuildProbeRequestWithOptions(&coreauth.Auth{Provider: "codex"}, "gpt-5", probe)
+	if err != nil {
+		t.Fatalf("BuildProbeRequestWithOptions returned error: %v", err)
+	}
+	if gjson.GetBytes(req.Payload, "input.0.content.0.text").String() != "ping" {
+		t.Fatalf("expected custom prompt in responses probe payload")
+	}
+	if gjson.GetBytes(req.Payload, "max_output_tokens").Int() != 16 {
+		t.Fatalf("expected max_output_tokens 16 in responses probe payload")
+	}
+}
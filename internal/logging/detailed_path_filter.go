@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Default path globs for the detailed log: everything outside /api is recorded,
+// and under /api only the provider-prefixed routes.
+var (
+	DefaultDetailedLogIncludePaths = []string{"/api/provider**"}
+	DefaultDetailedLogExcludePaths = []string{"/api**"}
+)
+
+// DetailedPathFilter decides which request paths the detailed log records.
+// A path is recorded unless it matches an exclude glob and no include glob.
+// In a glob, "*" matches within one path segment and "**" matches across
+// segments; "?" matches one non-slash character.
+type DetailedPathFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// NewDetailedPathFilter compiles include and exclude globs. An empty list
+// selects the corresponding default. While the default excludes apply, the
+// default includes are kept alongside any custom ones, so adding an include
+// does not stop provider routes from being recorded.
+func NewDetailedPathFilter(include, exclude []string) *DetailedPathFilter {
+	if len(exclude) == 0 {
+		exclude = DefaultDetailedLogExcludePaths
+		include = append(append([]string(nil), DefaultDetailedLogIncludePaths...), include...)
+	} else if len(include) == 0 {
+		include = DefaultDetailedLogIncludePaths
+	}
+	return &DetailedPathFilter{include: compilePathGlobs(include), exclude: compilePathGlobs(exclude)}
+}
+
+// Match reports whether path should be recorded. A nil filter uses the defaults.
+func (f *DetailedPathFilter) Match(path string) bool {
+	if f == nil {
+		f = defaultDetailedPathFilter
+	}
+	if !matchAnyGlob(f.exclude, path) {
+		return true
+	}
+	return matchAnyGlob(f.include, path)
+}
+
+var defaultDetailedPathFilter = NewDetailedPathFilter(nil, nil)
+
+func matchAnyGlob(globs []*regexp.Regexp, path string) bool {
+	for _, re := range globs {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// compilePathGlobs turns globs into anchored regular expressions. Every other
+// character is matched literally, so compilation cannot fail.
+func compilePathGlobs(patterns []string) []*regexp.Regexp {
+	out := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		var b strings.Builder
+		b.WriteString("^")
+		for i := 0; i < len(pattern); i++ {
+			switch c := pattern[i]; c {
+			case '*':
+				if i+1 < len(pattern) && pattern[i+1] == '*' {
+					b.WriteString(".*")
+					i++
+				} else {
+					b.WriteString("[^/]*")
+				}
+			case '?':
+				b.WriteString("[^/]")
+			default:
+				b.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		}
+		b.WriteString("$")
+		out = append(out, regexp.MustCompile(b.String()))
+	}
+	return out
+}
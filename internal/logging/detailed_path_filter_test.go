@@ -0,0 +1,36 @@
+package logging
+
+import "testing"
+
+func TestDetailedPathFilter(t *testing.T) {
+	includeOnly := NewDetailedPathFilter([]string{"/api/llm/**"}, nil)
+	custom := NewDetailedPathFilter([]string{"/api/llm/**", "/api/models"}, []string{"/api/**", "/healthz", "/v1/*/debug"})
+	tests := []struct {
+		name   string
+		filter *DetailedPathFilter
+		path   string
+		want   bool
+	}{
+		{name: "default api request", path: "/v1/chat/completions", want: true},
+		{name: "default provider route", path: "/api/provider/openai/v1/chat/completions", want: true},
+		{name: "default other api", path: "/api/event_logging/batch", want: false},
+		{name: "default bare api", path: "/api", want: false},
+		{name: "custom include under exclude", filter: custom, path: "/api/llm/openai/v1/chat/completions", want: true},
+		{name: "custom exact include", filter: custom, path: "/api/models", want: true},
+		{name: "custom excluded sibling", filter: custom, path: "/api/provider/openai/v1/chat/completions", want: false},
+		{name: "custom exact exclude", filter: custom, path: "/healthz", want: false},
+		{name: "single star stays in segment", filter: custom, path: "/v1/foo/debug", want: false},
+		{name: "single star does not cross segments", filter: custom, path: "/v1/foo/bar/debug", want: true},
+		{name: "custom unmatched", filter: custom, path: "/v1/messages", want: true},
+		{name: "include only adds to default", filter: includeOnly, path: "/api/llm/openai/v1/chat/completions", want: true},
+		{name: "include only keeps default provider", filter: includeOnly, path: "/api/provider/openai/v1/chat/completions", want: true},
+		{name: "include only keeps default exclude", filter: includeOnly, path: "/api/event_logging/batch", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(tt.path); got != tt.want {
+				t.Fatalf("Match(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package logging
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// DetailedBodyTruncatedMarker ends a stored body that was cut at the body size cap.
+	DetailedBodyTruncatedMarker = "\n<truncated: detailed log body size limit reached>"
+
+	// redactedValue replaces values matched by the configured redaction paths.
+	redactedValue = "[REDACTED]"
+
+	// unredactableBodyPlaceholder replaces a body the redaction paths cannot be
+	// applied to, so it is never stored with its secrets intact.
+	unredactableBodyPlaceholder = "[unredactable body omitted]"
+)
+
+// AppendResponseChunk appends a streamed upstream chunk to ResponseBody,
+// separated from the previous chunk by a blank line. At most maxBytes are kept
+// (maxBytes <= 0 selects DefaultDetailedMaxBodyBytes): the chunk that crosses
+// the cap is cut and marked with DetailedBodyTruncatedMarker, later chunks are
+// dropped.
+func (a *DetailedAttempt) AppendResponseChunk(data []byte, maxBytes int) {
+	if a.responseTruncated || len(data) == 0 {
+		return
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultDetailedMaxBodyBytes
+	}
+	if a.response == nil {
+		a.response = &strings.Builder{}
+		a.response.WriteString(a.ResponseBody)
+	}
+	chunk := string(data)
+	if a.response.Len() > 0 {
+		chunk = "\n\n" + chunk
+	}
+	if remaining := maxBytes - a.response.Len(); len(chunk) > remaining {
+		a.response.WriteString(cutUTF8(chunk, remaining))
+		a.response.WriteString(DetailedBodyTruncatedMarker)
+		a.responseTruncated = true
+	} else {
+		a.response.WriteString(chunk)
+	}
+	// strings.Builder.String does not copy, so this stays linear in the stream size.
+	a.ResponseBody = a.response.String()
+}
+
+// cutUTF8 returns the longest prefix of s that is at most n bytes and does not
+// split a UTF-8 sequence.
+func cutUTF8(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// SanitizeBodies prepares a finished record's bodies for storage. The
+// configured redaction paths are applied first, so the size cap never leaves
+// part of a secret behind, then every body is cut to MaxBodyBytes.
+// requestTruncated and responseTruncated report client bodies that were
+// already cut while being captured.
+func (dl *DetailedRequestLogger) SanitizeBodies(record *DetailedRequestRecord, requestTruncated, responseTruncated bool) {
+	if record == nil {
+		return
+	}
+	if paths := dl.RedactPaths(); len(paths) > 0 {
+		record.redactBodies(paths, requestTruncated, responseTruncated)
+	}
+	record.capBodies(int(dl.MaxBodyBytes()))
+}
+
+// capBodies cuts the client and every attempt request/response body of the
+// record to maxBytes (maxBytes <= 0 selects DefaultDetailedMaxBodyBytes),
+// marking cut bodies with DetailedBodyTruncatedMarker.
+func (r *DetailedRequestRecord) capBodies(maxBytes int) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultDetailedMaxBodyBytes
+	}
+	r.RequestBody = capBody(r.RequestBody, maxBytes)
+	r.ResponseBody = capBody(r.ResponseBody, maxBytes)
+	for i := range r.Attempts {
+		r.Attempts[i].RequestBody = capBody(r.Attempts[i].RequestBody, maxBytes)
+		r.Attempts[i].ResponseBody = capBody(r.Attempts[i].ResponseBody, maxBytes)
+	}
+}
+
+// capBody cuts body to maxBytes and appends DetailedBodyTruncatedMarker. A body
+// that AppendResponseChunk already cut is left as is.
+func capBody(body string, maxBytes int) string {
+	if len(body) <= maxBytes {
+		return body
+	}
+	if strings.HasSuffix(body, DetailedBodyTruncatedMarker) && len(body)-len(DetailedBodyTruncatedMarker) <= maxBytes {
+		return body
+	}
+	return cutUTF8(body, maxBytes) + DetailedBodyTruncatedMarker
+}
+
+// redactBodies applies the redaction paths to the client and every attempt
+// request/response body of the record.
+func (r *DetailedRequestRecord) redactBodies(paths []string, requestTruncated, responseTruncated bool) {
+	r.RequestBody = redactBody(r.RequestBody, paths, requestTruncated)
+	r.ResponseBody = redactBody(r.ResponseBody, paths, responseTruncated)
+	for i := range r.Attempts {
+		r.Attempts[i].RequestBody = redactBody(r.Attempts[i].RequestBody, paths, false)
+		r.Attempts[i].ResponseBody = redactBody(r.Attempts[i].ResponseBody, paths, false)
+	}
+}
+
+// redactBody redacts a JSON body, or every event of an SSE / newline-delimited
+// JSON stream. The cut-off last line of a truncated stream is dropped; a body
+// that still cannot be parsed is replaced by unredactableBodyPlaceholder.
+func redactBody(body string, paths []string, truncated bool) string {
+	marker := ""
+	if strings.HasSuffix(body, DetailedBodyTruncatedMarker) {
+		body, marker, truncated = strings.TrimSuffix(body, DetailedBodyTruncatedMarker), DetailedBodyTruncatedMarker, true
+	}
+	if strings.TrimSpace(body) == "" {
+		return body + marker
+	}
+	if gjson.Valid(body) {
+		return redactJSONPaths(body, paths) + marker
+	}
+	if truncated {
+		cut := strings.LastIndexByte(body, '\n')
+		if cut < 0 {
+			return unredactableBodyPlaceholder
+		}
+		body = body[:cut]
+	}
+	if redacted, ok := redactStream(body, paths); ok {
+		return redacted + marker
+	}
+	return unredactableBodyPlaceholder
+}
+
+// redactStream redacts the JSON payload of every line of a stream. It reports
+// false when a line is neither JSON nor SSE framing.
+func redactStream(body string, paths []string) (string, bool) {
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "", strings.HasPrefix(trimmed, ":"), strings.HasPrefix(trimmed, "event:"),
+			strings.HasPrefix(trimmed, "id:"), strings.HasPrefix(trimmed, "retry:"):
+		case strings.HasPrefix(trimmed, "data:"):
+			payload := strings.TrimSpace(trimmed[len("data:"):])
+			if payload == "" || payload == "[DONE]" {
+				continue
+			}
+			if !gjson.Valid(payload) {
+				return "", false
+			}
+			lines[i] = "data: " + redactJSONPaths(payload, paths)
+		case gjson.Valid(trimmed):
+			lines[i] = redactJSONPaths(trimmed, paths)
+		default:
+			return "", false
+		}
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+// redactJSONPaths replaces every value of a valid JSON body matched by paths
+// with redactedValue.
+func redactJSONPaths(body string, paths []string) string {
+	for _, path := range paths {
+		result := gjson.Get(body, path)
+		if !result.Exists() {
+			continue
+		}
+		// Queries such as "messages.#.content" resolve to one concrete path per element.
+		concrete := result.Paths(body)
+		if len(concrete) == 0 {
+			if p := result.Path(body); p != "" {
+				concrete = []string{p}
+			}
+		}
+		for _, p := range concrete {
+			if updated, err := sjson.Set(body, p, redactedValue); err == nil {
+				body = updated
+			}
+		}
+	}
+	return body
+}
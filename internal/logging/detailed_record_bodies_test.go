@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAppendResponseChunkCapsBody(t *testing.T) {
+	attempt := &DetailedAttempt{}
+	for i := 0; i < 100; i++ {
+		attempt.AppendResponseChunk([]byte("data: {\"n\":1}"), 64)
+	}
+	if !strings.HasSuffix(attempt.ResponseBody, DetailedBodyTruncatedMarker) {
+		t.Fatalf("response body = %q, want the truncation marker", attempt.ResponseBody)
+	}
+	if got := len(attempt.ResponseBody) - len(DetailedBodyTruncatedMarker); got > 64 {
+		t.Fatalf("kept %d body bytes, want at most 64", got)
+	}
+	if strings.Count(attempt.ResponseBody, DetailedBodyTruncatedMarker) != 1 {
+		t.Fatalf("response body = %q, want a single marker", attempt.ResponseBody)
+	}
+}
+
+func TestAppendResponseChunkSeparatesChunks(t *testing.T) {
+	attempt := &DetailedAttempt{}
+	attempt.AppendResponseChunk([]byte("a"), 0)
+	attempt.AppendResponseChunk([]byte("b"), 0)
+	if attempt.ResponseBody != "a\n\nb" {
+		t.Fatalf("response body = %q, want %q", attempt.ResponseBody, "a\n\nb")
+	}
+}
+
+func TestCapBodiesKeepsChunkCap(t *testing.T) {
+	attempt := DetailedAttempt{}
+	attempt.AppendResponseChunk([]byte(strings.Repeat("a", 100)), 32)
+	record := &DetailedRequestRecord{RequestBody: "short", Attempts: []DetailedAttempt{attempt}}
+	record.capBodies(32)
+	if record.RequestBody != "short" {
+		t.Fatalf("request body = %q, want it untouched", record.RequestBody)
+	}
+	if got := record.Attempts[0].ResponseBody; got != strings.Repeat("a", 32)+DetailedBodyTruncatedMarker {
+		t.Fatalf("response body = %q, want one marker after 32 bytes", got)
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	paths := []string{"messages.#.content", "metadata.user_id"}
+	tests := []struct {
+		name      string
+		body      string
+		truncated bool
+		want      string
+	}{
+		{
+			name: "array elements and nested field",
+			body: `{"messages":[{"role":"user","content":"a"},{"role":"assistant","content":"b"}],"metadata":{"user_id":"u1"}}`,
+			want: `{"messages":[{"role":"user","content":"[REDACTED]"},{"role":"assistant","content":"[REDACTED]"}],"metadata":{"user_id":"[REDACTED]"}}`,
+		},
+		{
+			name: "missing paths untouched",
+			body: `{"model":"m"}`,
+			want: `{"model":"m"}`,
+		},
+		{
+			name: "sse events redacted one by one",
+			body: "event: chunk\ndata: {\"messages\":[{\"content\":\"a\"}]}\n\ndata: [DONE]\n\n",
+			want: "event: chunk\ndata: {\"messages\":[{\"content\":\"[REDACTED]\"}]}\n\ndata: [DONE]\n\n",
+		},
+		{
+			name: "cut-off last event of a capped stream dropped",
+			body: "data: {\"metadata\":{\"user_id\":\"u1\"}}\n\ndata: {\"metadata\":{\"user_id\":\"u2" + DetailedBodyTruncatedMarker,
+			want: "data: {\"metadata\":{\"user_id\":\"[REDACTED]\"}}\n" + DetailedBodyTruncatedMarker,
+		},
+		{
+			name:      "truncated json omitted",
+			body:      `{"messages":[{"content":"sec`,
+			truncated: true,
+			want:      unredactableBodyPlaceholder,
+		},
+		{
+			name: "unparseable body omitted",
+			body: "user_id=u1&content=secret",
+			want: unredactableBodyPlaceholder,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactBody(tt.body, paths, tt.truncated); got != tt.want {
+				t.Fatalf("redactBody() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeBodiesRedactsBeforeCapping(t *testing.T) {
+	logger := NewDetailedRequestLogger(false, t.TempDir(), 0, 0, nil)
+	defer logger.Close()
+	logger.SetRedactPaths([]string{"metadata.user_id"})
+	logger.SetMaxBodyBytes(48)
+
+	body := `{"metadata":{"user_id":"` + strings.Repeat("s", 64) + `"}}`
+	record := &DetailedRequestRecord{Attempts: []DetailedAttempt{{RequestBody: body}}}
+	logger.SanitizeBodies(record, false, false)
+	if got := record.Attempts[0].RequestBody; got != `{"metadata":{"user_id":"[REDACTED]"}}` {
+		t.Fatalf("attempt request body = %q, want the secret redacted before the cap", got)
+	}
+}
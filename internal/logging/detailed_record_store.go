@@ -0,0 +1,77 @@
+package logging
+
+import "time"
+
+// RecordStore persists completed detailed request records.
+// In-flight placeholders are not part of the store; the logger keeps those as
+// plain files in its logs directory whichever store is used.
+type RecordStore interface {
+	// Write stores a completed record, replacing any record with the same ID.
+	Write(record *DetailedRequestRecord) error
+	// Read returns the records matching filter, newest first, paginated by
+	// filter.Limit and either the filter's cursor or filter.Offset, along with
+	// the total before pagination.
+	Read(filter RecordFilter) ([]DetailedRequestRecord, int, error)
+	// ReadByID returns the record with the given ID, or nil if there is none.
+	ReadByID(id string) (*DetailedRequestRecord, error)
+	// DeleteAll removes every stored record.
+	DeleteAll() error
+	// Stats returns the stored size in bytes and the number of records.
+	Stats() (int64, int, error)
+	// Close releases any resources held by the store.
+	Close() error
+}
+
+// RecordPruner is implemented by stores that can enforce retention limits.
+// Prune keeps at most maxRecords of the newest records (0 means no limit),
+// removes records older than olderThan (zero means no age limit) and then
+// removes the oldest records until the store holds at most maxBytes (0 means
+// no size limit).
+type RecordPruner interface {
+	Prune(maxRecords int, maxBytes int64, olderThan time.Time) error
+}
+
+// RecordIterator is implemented by stores that can visit matching records
+// without loading them all at once. Each calls fn for every record matching
+// filter, newest first, ignoring pagination, and stops at fn's first error.
+type RecordIterator interface {
+	Each(filter RecordFilter, fn func(*DetailedRequestRecord) error) error
+}
+
+// FileRecordStore is the default RecordStore: each record is a meta JSON file plus
+// a bodies companion file in the logger's directory, with an index.jsonl for fast
+// listing. It is created by NewDetailedRequestLogger when no store is supplied.
+type FileRecordStore struct {
+	dl *DetailedRequestLogger
+}
+
+// Write stores the record as meta and bodies files and updates the index.
+func (s *FileRecordStore) Write(record *DetailedRequestRecord) error {
+	return s.dl.writeRecordFile(record)
+}
+
+// Read scans the detail files and applies filter.
+func (s *FileRecordStore) Read(filter RecordFilter) ([]DetailedRequestRecord, int, error) {
+	records, total, _, _, err := s.dl.readFileRecords(filter)
+	return records, total, err
+}
+
+// ReadByID returns the record with the given ID, falling back to its pending placeholder.
+func (s *FileRecordStore) ReadByID(id string) (*DetailedRequestRecord, error) {
+	return s.dl.readFileRecordByID(id)
+}
+
+// DeleteAll removes all detail files and the index.
+func (s *FileRecordStore) DeleteAll() error {
+	return s.dl.deleteAllFiles()
+}
+
+// Stats returns the size of all detail files and the number of meta files.
+func (s *FileRecordStore) Stats() (int64, int, error) {
+	return s.dl.fileStats()
+}
+
+// Close is a no-op; files need no teardown.
+func (s *FileRecordStore) Close() error {
+	return nil
+}
@@ -0,0 +1,377 @@
+package logging
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteRecordStore keeps detailed request records in a single SQLite database.
+// The columns used by RecordFilter (timestamp, status code, API key hash, model)
+// are indexed so filtered listing touches only matching rows; status patterns and
+// URL regexps are evaluated on those rows before any record JSON is decoded.
+type SQLiteRecordStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteRecordStore opens (creating if needed) the database at path and
+// prepares its schema.
+func NewSQLiteRecordStore(path string) (*SQLiteRecordStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("sqlite record store: create directory: %w", err)
+	}
+	// auto_vacuum only takes effect on a new database; existing ones reuse
+	// the pages Prune frees instead of returning them to the filesystem.
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=auto_vacuum(INCREMENTAL)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("sqlite record store: open database: %w", err)
+	}
+	// A single connection serialises writers and avoids SQLITE_BUSY between them.
+	db.SetMaxOpenConns(1)
+
+	schema := []string{
+		`CREATE TABLE IF NOT EXISTS detailed_requests (
+			id TEXT PRIMARY KEY,
+			ts INTEGER NOT NULL,
+			status_code INTEGER NOT NULL,
+			api_key TEXT NOT NULL DEFAULT '',
+			api_key_hash TEXT NOT NULL DEFAULT '',
+			model TEXT NOT NULL DEFAULT '',
+			url TEXT NOT NULL DEFAULT '',
+			is_simulated INTEGER NOT NULL DEFAULT 0,
+			has_tool_calls INTEGER NOT NULL DEFAULT 0,
+			has_error INTEGER NOT NULL DEFAULT 0,
+			record BLOB NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_detailed_requests_ts ON detailed_requests (ts)`,
+		`CREATE INDEX IF NOT EXISTS idx_detailed_requests_status ON detailed_requests (status_code)`,
+		`CREATE INDEX IF NOT EXISTS idx_detailed_requests_api_key_hash ON detailed_requests (api_key_hash)`,
+		`CREATE INDEX IF NOT EXISTS idx_detailed_requests_model ON detailed_requests (model)`,
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("sqlite record store: prepare schema: %w", err)
+		}
+	}
+	return &SQLiteRecordStore{db: db}, nil
+}
+
+// Write inserts or replaces the record.
+func (s *SQLiteRecordStore) Write(record *DetailedRequestRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("sqlite record store: marshal record: %w", err)
+	}
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO detailed_requests
+		(id, ts, status_code, api_key, api_key_hash, model, url, is_simulated, has_tool_calls, has_error, record)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		record.ID, record.Timestamp.UnixNano(), record.StatusCode, record.APIKey, record.APIKeyHash,
+		record.Model, record.URL, record.IsSimulated, record.HasToolCalls, record.HasError, data)
+	if err != nil {
+		return fmt.Errorf("sqlite record store: write record: %w", err)
+	}
+	return nil
+}
+
+// Read returns the page of records matching filter and the total match count.
+// The page starts past the filter's cursor when one is set, otherwise at Offset.
+func (s *SQLiteRecordStore) Read(filter RecordFilter) ([]DetailedRequestRecord, int, error) {
+	where, args := sqliteFilterClause(filter)
+	rows, err := s.db.Query(`SELECT id, ts, status_code, url FROM detailed_requests`+where+` ORDER BY ts DESC, id DESC`, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("sqlite record store: query records: %w", err)
+	}
+	var ids []string
+	var timestamps []int64
+	for rows.Next() {
+		var id, url string
+		var ts int64
+		var status int
+		if err := rows.Scan(&id, &ts, &status, &url); err != nil {
+			rows.Close()
+			return nil, 0, fmt.Errorf("sqlite record store: scan record: %w", err)
+		}
+		if matchStatusCode(status, filter.StatusCode) && filter.matchURL(url) {
+			ids = append(ids, id)
+			timestamps = append(timestamps, ts)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("sqlite record store: query records: %w", err)
+	}
+
+	total := len(ids)
+	offset := filter.Offset
+	if filter.HasCursor() {
+		offset = filter.cursorStart(len(ids),
+			func(i int) string { return ids[i] },
+			func(i int) time.Time { return time.Unix(0, timestamps[i]) })
+	}
+	if offset > 0 {
+		if offset >= len(ids) {
+			ids = nil
+		} else {
+			ids = ids[offset:]
+		}
+	}
+	if filter.Limit > 0 && len(ids) > filter.Limit {
+		ids = ids[:filter.Limit]
+	}
+
+	records := make([]DetailedRequestRecord, 0, len(ids))
+	for _, id := range ids {
+		record, errRead := s.ReadByID(id)
+		if errRead != nil {
+			return nil, 0, errRead
+		}
+		if record != nil {
+			records = append(records, *record)
+		}
+	}
+	return records, total, nil
+}
+
+// sqliteEachBatch is how many rows Each reads per query.
+const sqliteEachBatch = 256
+
+// Each calls fn for every record matching filter, newest first, ignoring
+// pagination. Rows are decoded one at a time as they are scanned, and read in
+// keyset-paged batches of sqliteEachBatch: memory stays bounded by the batch
+// rather than the history, and the store's single connection is not held
+// while fn writes to a slow client, which would stall every Write.
+func (s *SQLiteRecordStore) Each(filter RecordFilter, fn func(*DetailedRequestRecord) error) error {
+	where, args := sqliteFilterClause(filter)
+	var lastTS int64
+	var lastID string
+	for first := true; ; first = false {
+		clause, queryArgs := where, append([]any(nil), args...)
+		if !first {
+			after := "(ts < ? OR (ts = ? AND id < ?))"
+			if clause == "" {
+				clause = " WHERE " + after
+			} else {
+				clause += " AND " + after
+			}
+			queryArgs = append(queryArgs, lastTS, lastTS, lastID)
+		}
+		queryArgs = append(queryArgs, sqliteEachBatch)
+		rows, err := s.db.Query(`SELECT id, ts, status_code, url, record FROM detailed_requests`+clause+` ORDER BY ts DESC, id DESC LIMIT ?`, queryArgs...)
+		if err != nil {
+			return fmt.Errorf("sqlite record store: query records: %w", err)
+		}
+		batch := make([]*DetailedRequestRecord, 0, sqliteEachBatch)
+		scanned := 0
+		for rows.Next() {
+			var id, url string
+			var ts int64
+			var status int
+			var data []byte
+			if err := rows.Scan(&id, &ts, &status, &url, &data); err != nil {
+				rows.Close()
+				return fmt.Errorf("sqlite record store: scan record: %w", err)
+			}
+			scanned++
+			lastTS, lastID = ts, id
+			if !matchStatusCode(status, filter.StatusCode) || !filter.matchURL(url) {
+				continue
+			}
+			var record DetailedRequestRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				rows.Close()
+				return fmt.Errorf("sqlite record store: decode record %s: %w", id, err)
+			}
+			batch = append(batch, &record)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("sqlite record store: query records: %w", err)
+		}
+		for _, record := range batch {
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		if scanned < sqliteEachBatch {
+			return nil
+		}
+	}
+}
+
+// sqliteFilterClause translates the indexed RecordFilter fields into a WHERE clause.
+// StatusCode and URLPattern are left to the caller.
+func sqliteFilterClause(filter RecordFilter) (string, []any) {
+	var conds []string
+	var args []any
+	if !filter.IncludeSimulated {
+		conds = append(conds, "is_simulated = 0")
+	}
+	if filter.APIKeyHash != "" {
+		conds = append(conds, "(api_key_hash = ? OR api_key = ?)")
+		args = append(args, filter.APIKeyHash, filter.APIKeyHash)
+	}
+	if !filter.After.IsZero() {
+		conds = append(conds, "ts >= ?")
+		args = append(args, filter.After.UnixNano())
+	}
+	if !filter.Before.IsZero() {
+		conds = append(conds, "ts <= ?")
+		args = append(args, filter.Before.UnixNano())
+	}
+	if filter.HasToolCalls {
+		conds = append(conds, "has_tool_calls = 1")
+	}
+	if filter.HasError {
+		conds = append(conds, "has_error = 1")
+	}
+	if filter.ExcludeCanceled {
+		conds = append(conds, `json_extract(CAST(record AS TEXT), '$.client_canceled') IS NOT 1`)
+	}
+	if filter.ModelPrefix != "" {
+		// LIKE is case-insensitive for ASCII, matching matchModelPrefix; a
+		// pattern with * wildcards matches the whole name instead of a prefix.
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`).Replace(filter.ModelPrefix)
+		if !strings.Contains(filter.ModelPrefix, "*") {
+			escaped += "%"
+		}
+		conds = append(conds, `model LIKE ? ESCAPE '\'`)
+		args = append(args, escaped)
+	}
+	if filter.MinDurationMs > 0 {
+		conds = append(conds, `json_extract(CAST(record AS TEXT), '$.total_duration_ms') >= ?`)
+		args = append(args, filter.MinDurationMs)
+	}
+	if filter.RouteID != "" {
+		conds = append(conds, `json_extract(CAST(record AS TEXT), '$.route_id') = ?`)
+		args = append(args, filter.RouteID)
+	}
+	if filter.Tag != "" {
+		// Records are stored as JSON text; CAST keeps json_each from reading the blob as JSONB.
+		conds = append(conds, `EXISTS (SELECT 1 FROM json_each(CAST(record AS TEXT), '$.tags') WHERE value = ?)`)
+		args = append(args, filter.Tag)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// ReadByID returns the record with the given ID, or nil if there is none.
+func (s *SQLiteRecordStore) ReadByID(id string) (*DetailedRequestRecord, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT record FROM detailed_requests WHERE id = ?`, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sqlite record store: read record: %w", err)
+	}
+	var record DetailedRequestRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("sqlite record store: decode record %s: %w", id, err)
+	}
+	return &record, nil
+}
+
+// DeleteAll removes every record.
+func (s *SQLiteRecordStore) DeleteAll() error {
+	if _, err := s.db.Exec(`DELETE FROM detailed_requests`); err != nil {
+		return fmt.Errorf("sqlite record store: delete records: %w", err)
+	}
+	return nil
+}
+
+// Stats returns the summed size of stored record JSON and the record count.
+func (s *SQLiteRecordStore) Stats() (int64, int, error) {
+	var size int64
+	var count int
+	err := s.db.QueryRow(`SELECT COALESCE(SUM(LENGTH(record)), 0), COUNT(*) FROM detailed_requests`).Scan(&size, &count)
+	if err != nil {
+		return 0, 0, fmt.Errorf("sqlite record store: stats: %w", err)
+	}
+	return size, count, nil
+}
+
+// Prune removes records older than olderThan, all but the newest maxRecords,
+// and then the oldest records until the pages in use take at most maxBytes.
+func (s *SQLiteRecordStore) Prune(maxRecords int, maxBytes int64, olderThan time.Time) error {
+	if !olderThan.IsZero() {
+		if _, err := s.db.Exec(`DELETE FROM detailed_requests WHERE ts < ?`, olderThan.UnixNano()); err != nil {
+			return fmt.Errorf("sqlite record store: prune by age: %w", err)
+		}
+	}
+	if maxRecords > 0 {
+		_, err := s.db.Exec(`DELETE FROM detailed_requests WHERE id NOT IN
+			(SELECT id FROM detailed_requests ORDER BY ts DESC LIMIT ?)`, maxRecords)
+		if err != nil {
+			return fmt.Errorf("sqlite record store: prune by count: %w", err)
+		}
+	}
+	if maxBytes > 0 {
+		if err := s.pruneToSize(maxBytes); err != nil {
+			return fmt.Errorf("sqlite record store: prune by size: %w", err)
+		}
+	}
+	return nil
+}
+
+// pruneToSize deletes the oldest records until the pages in use fit in
+// maxBytes, then returns the freed pages to the filesystem when the database
+// was created with incremental auto_vacuum.
+func (s *SQLiteRecordStore) pruneToSize(maxBytes int64) error {
+	deleted := false
+	for {
+		used, err := s.usedBytes()
+		if err != nil {
+			return err
+		}
+		var count int64
+		if err := s.db.QueryRow(`SELECT COUNT(*) FROM detailed_requests`).Scan(&count); err != nil {
+			return err
+		}
+		if used <= maxBytes || count == 0 {
+			break // an empty table leaves only schema and indexes
+		}
+		// Delete about as many records as the excess holds at the average record size.
+		batch := (used-maxBytes)/(used/count) + 1
+		if _, err := s.db.Exec(`DELETE FROM detailed_requests WHERE id IN
+			(SELECT id FROM detailed_requests ORDER BY ts ASC LIMIT ?)`, batch); err != nil {
+			return err
+		}
+		deleted = true
+	}
+	if deleted {
+		if _, err := s.db.Exec(`PRAGMA incremental_vacuum`); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// usedBytes returns the size of the database pages holding data, excluding
+// free pages left behind by deletes.
+func (s *SQLiteRecordStore) usedBytes() (int64, error) {
+	var pageCount, freePages, pageSize int64
+	if err := s.db.QueryRow(`PRAGMA page_count`).Scan(&pageCount); err != nil {
+		return 0, err
+	}
+	if err := s.db.QueryRow(`PRAGMA freelist_count`).Scan(&freePages); err != nil {
+		return 0, err
+	}
+	if err := s.db.QueryRow(`PRAGMA page_size`).Scan(&pageSize); err != nil {
+		return 0, err
+	}
+	return (pageCount - freePages) * pageSize, nil
+}
+
+// Close closes the database.
+func (s *SQLiteRecordStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,193 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQLiteRecordStoreThroughLogger(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewSQLiteRecordStore(filepath.Join(dir, "detailed-requests.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRecordStore: %v", err)
+	}
+	dl := NewDetailedRequestLogger(true, dir, 0, 0, store)
+	defer dl.Close()
+
+	now := time.Now()
+	records := []*DetailedRequestRecord{
+		{ID: "sq-1", Timestamp: now.Add(-3 * time.Minute), URL: "/v1/messages", Method: "POST", StatusCode: 200, Model: "claude-sonnet", TotalDurationMs: 12000, APIKeyHash: "k1", ResponseBody: `{"ok":true}`},
+		{ID: "sq-2", Timestamp: now.Add(-2 * time.Minute), URL: "/v1/chat/completions", Method: "POST", StatusCode: 429, Model: "gpt-4o", APIKeyHash: "k1", HasError: true},
+		{ID: "sq-3", Timestamp: now.Add(-time.Minute), URL: "/v1/messages", Method: "POST", StatusCode: 500, Model: "Claude-Opus", APIKeyHash: "k2", HasError: true, ClientCanceled: true, ErrorClass: ErrorClassClientCanceled},
+	}
+	for _, r := range records {
+		if err := dl.writeCompleted(r); err != nil {
+			t.Fatalf("writeCompleted: %v", err)
+		}
+	}
+	if _, err := dl.UpdateRecordTags("sq-2", []string{"incident"}, nil); err != nil {
+		t.Fatalf("UpdateRecordTags: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter RecordFilter
+		want   []string
+	}{
+		{"all newest first", RecordFilter{}, []string{"sq-3", "sq-2", "sq-1"}},
+		{"status list", RecordFilter{StatusCode: "200,500"}, []string{"sq-3", "sq-1"}},
+		{"model prefix ignores case", RecordFilter{ModelPrefix: "claude"}, []string{"sq-3", "sq-1"}},
+		{"model wildcard", RecordFilter{ModelPrefix: "*-sonnet"}, []string{"sq-1"}},
+		{"min duration", RecordFilter{MinDurationMs: 10000}, []string{"sq-1"}},
+		{"api key and error", RecordFilter{APIKeyHash: "k1", HasError: true}, []string{"sq-2"}},
+		{"url pattern", RecordFilter{URLPattern: "^/v1/chat"}, []string{"sq-2"}},
+		{"tag", RecordFilter{Tag: "incident"}, []string{"sq-2"}},
+		{"exclude canceled", RecordFilter{HasError: true, ExcludeCanceled: true}, []string{"sq-2"}},
+		{"paginated", RecordFilter{Offset: 1, Limit: 1}, []string{"sq-2"}},
+		{"cursor", RecordFilter{BeforeID: "sq-3", Limit: 1}, []string{"sq-2"}},
+		{"cursor by timestamp", RecordFilter{BeforeTS: now.Add(-150 * time.Second)}, []string{"sq-1"}},
+		{"removed cursor falls back to timestamp", RecordFilter{BeforeID: "gone", BeforeTS: now.Add(-90 * time.Second)}, []string{"sq-2", "sq-1"}},
+		{"unknown cursor", RecordFilter{BeforeID: "gone"}, []string{}},
+	}
+	for _, tt := range tests {
+		got, _, _, _, err := dl.ReadRecords(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: ReadRecords: %v", tt.name, err)
+		}
+		ids := make([]string, 0, len(got))
+		for _, r := range got {
+			ids = append(ids, r.ID)
+		}
+		if len(ids) != len(tt.want) {
+			t.Fatalf("%s: got %v, want %v", tt.name, ids, tt.want)
+		}
+		for i := range ids {
+			if ids[i] != tt.want[i] {
+				t.Fatalf("%s: got %v, want %v", tt.name, ids, tt.want)
+			}
+		}
+	}
+
+	rec, err := dl.ReadRecordByID("sq-1")
+	if err != nil || rec == nil || rec.ResponseBody != `{"ok":true}` {
+		t.Fatalf("ReadRecordByID = %+v, %v", rec, err)
+	}
+	if _, count, err := dl.GetStats(); err != nil || count != 3 {
+		t.Fatalf("GetStats count=%d err=%v, want 3", count, err)
+	}
+
+	if err := store.Prune(2, 0, now.Add(-150*time.Second)); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if _, count, _ := dl.GetStats(); count != 2 {
+		t.Fatalf("after Prune count=%d, want 2", count)
+	}
+
+	if err := dl.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll: %v", err)
+	}
+	if _, count, _ := dl.GetStats(); count != 0 {
+		t.Fatalf("after DeleteAll count=%d, want 0", count)
+	}
+}
+
+func TestSQLiteRecordStoreEachPagesThroughBatches(t *testing.T) {
+	store, err := NewSQLiteRecordStore(filepath.Join(t.TempDir(), "detailed-requests.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRecordStore: %v", err)
+	}
+	defer store.Close()
+
+	// Pairs of records share a timestamp so batch boundaries fall inside ties.
+	base := time.Now()
+	total := 2*sqliteEachBatch + 10
+	for i := 0; i < total; i++ {
+		status := 200
+		if i%3 == 0 {
+			status = 500
+		}
+		r := &DetailedRequestRecord{ID: fmt.Sprintf("r-%04d", i), Timestamp: base.Add(time.Duration(i/2) * time.Second), URL: "/v1/messages", StatusCode: status}
+		if err := store.Write(r); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	var got []string
+	if err := store.Each(RecordFilter{StatusCode: "200"}, func(r *DetailedRequestRecord) error {
+		got = append(got, r.ID)
+		return nil
+	}); err != nil {
+		t.Fatalf("Each: %v", err)
+	}
+	want, _, err := store.Read(RecordFilter{StatusCode: "200"})
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Each visited %d records, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i].ID {
+			t.Fatalf("Each order differs from Read at %d: %s vs %s", i, got[i], want[i].ID)
+		}
+	}
+
+	stop := errors.New("stop")
+	visited := 0
+	err = store.Each(RecordFilter{}, func(*DetailedRequestRecord) error {
+		visited++
+		if visited == 3 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || visited != 3 {
+		t.Fatalf("Each after callback error = %v with %d visits, want stop after 3", err, visited)
+	}
+}
+
+func TestSQLiteRecordStorePrunesToSize(t *testing.T) {
+	store, err := NewSQLiteRecordStore(filepath.Join(t.TempDir(), "detailed-requests.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRecordStore: %v", err)
+	}
+	defer store.Close()
+
+	base := time.Now()
+	body := strings.Repeat("x", 8*1024)
+	for i := 0; i < 200; i++ {
+		record := &DetailedRequestRecord{ID: fmt.Sprintf("r-%03d", i), Timestamp: base.Add(time.Duration(i) * time.Second), ResponseBody: body}
+		if err := store.Write(record); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	var pagesBefore int64
+	_ = store.db.QueryRow(`PRAGMA page_count`).Scan(&pagesBefore)
+
+	const maxBytes = 512 * 1024
+	if err := store.Prune(0, maxBytes, time.Time{}); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if used, err := store.usedBytes(); err != nil || used > maxBytes {
+		t.Fatalf("used bytes after Prune = %d, %v, want at most %d", used, err, maxBytes)
+	}
+	_, count, _ := store.Stats()
+	if count == 0 || count >= 200 {
+		t.Fatalf("after Prune count = %d, want some but not all records", count)
+	}
+	if rec, _ := store.ReadByID("r-199"); rec == nil {
+		t.Fatalf("newest record was pruned")
+	}
+	if rec, _ := store.ReadByID("r-000"); rec != nil {
+		t.Fatalf("oldest record survived the size limit")
+	}
+	var pagesAfter int64
+	_ = store.db.QueryRow(`PRAGMA page_count`).Scan(&pagesAfter)
+	if pagesAfter >= pagesBefore {
+		t.Fatalf("page count %d -> %d, want freed pages returned", pagesBefore, pagesAfter)
+	}
+}
@@ -0,0 +1,167 @@
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrRecordNotFound is returned by UpdateRecordTags when no stored record has the ID.
+	ErrRecordNotFound = errors.New("record not found")
+	// ErrRecordPending is returned by UpdateRecordTags for a request still in flight.
+	ErrRecordPending = errors.New("record is still in flight")
+	// ErrWriteQueueFull is returned by UpdateRecordTags when the write loop is
+	// too far behind to take the update.
+	ErrWriteQueueFull = errors.New("detailed request log write queue is full")
+)
+
+// tagUpdate is a tag change queued to the write loop, so the rewrite of the
+// record and its index entry never interleaves with record writes or cleanup.
+type tagUpdate struct {
+	id     string
+	add    []string
+	remove []string
+	tags   []string
+	err    error
+	done   chan struct{}
+}
+
+// UpdateRecordTags adds and then removes tags on a completed record and returns
+// its resulting tags, sorted. Tags are trimmed; empty and duplicate tags are dropped.
+func (dl *DetailedRequestLogger) UpdateRecordTags(id string, add, remove []string) ([]string, error) {
+	update := &tagUpdate{id: id, add: add, remove: remove, done: make(chan struct{})}
+	// Queue under mu so Close cannot close writeCh between the check and the send.
+	dl.mu.Lock()
+	if dl.stopped {
+		dl.mu.Unlock()
+		return nil, errors.New("detailed request logger is closed")
+	}
+	select {
+	case dl.writeCh <- &writeOp{opType: writeOpTags, tags: update}:
+	default:
+		dl.mu.Unlock()
+		return nil, ErrWriteQueueFull
+	}
+	dl.mu.Unlock()
+
+	<-update.done
+	return update.tags, update.err
+}
+
+// apply runs the update on the write loop and signals completion.
+func (u *tagUpdate) apply(dl *DetailedRequestLogger) {
+	defer close(u.done)
+
+	record, err := dl.store.ReadByID(u.id)
+	if err != nil {
+		u.err = err
+		return
+	}
+	if record == nil {
+		u.err = ErrRecordNotFound
+		return
+	}
+	if record.Pending {
+		u.err = ErrRecordPending
+		return
+	}
+	record.Tags = mergeTags(record.Tags, u.add, u.remove)
+	u.tags = record.Tags
+
+	if dl.usesFileStore() {
+		u.err = dl.rewriteRecordTags(record.ID, record.Tags)
+		return
+	}
+	u.err = dl.store.Write(record)
+}
+
+// rewriteRecordTags stores tags in the record's meta file and index entry. The
+// bodies file is left alone, and the meta file keeps its compression and mod
+// time so listing order and retention are unaffected.
+func (dl *DetailedRequestLogger) rewriteRecordTags(id string, tags []string) error {
+	name := dl.detailFilenameForID(id, isMetaFile)
+	if name == "" {
+		return ErrRecordNotFound
+	}
+	path := filepath.Join(dl.logsDir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := dl.readDetailFile(name)
+	if err != nil {
+		return err
+	}
+	// Decode into a generic map so fields this build does not know survive the rewrite.
+	var meta map[string]any
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return fmt.Errorf("failed to parse meta file: %w", err)
+	}
+	if len(tags) > 0 {
+		meta["tags"] = tags
+	} else {
+		delete(meta, "tags")
+	}
+	data, err = json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal meta: %w", err)
+	}
+	data = append(data, '\n')
+
+	plainPath := strings.TrimSuffix(path, detailedGzipSuffix)
+	if err := writeFileAtomic(plainPath, data); err != nil {
+		return fmt.Errorf("failed to write meta file: %w", err)
+	}
+	_ = os.Chtimes(plainPath, info.ModTime(), info.ModTime())
+	if plainPath != path {
+		if err := gzipDetailFile(plainPath); err != nil {
+			return fmt.Errorf("failed to compress meta file: %w", err)
+		}
+	}
+
+	index, _ := dl.loadIndex()
+	for i := range index {
+		if index[i].ID == id {
+			index[i].Tags = tags
+			return dl.saveIndex(index)
+		}
+	}
+	return dl.RebuildIndex()
+}
+
+// mergeTags returns current plus add minus remove, trimmed, deduplicated and sorted.
+func mergeTags(current, add, remove []string) []string {
+	set := make(map[string]struct{}, len(current)+len(add))
+	for _, tag := range append(append([]string(nil), current...), add...) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			set[tag] = struct{}{}
+		}
+	}
+	for _, tag := range remove {
+		delete(set, strings.TrimSpace(tag))
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	tags := make([]string, 0, len(set))
+	for tag := range set {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// hasTag reports whether tags contains tag.
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
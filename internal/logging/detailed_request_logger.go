@@ -4,17 +4,26 @@
 package logging
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -31,6 +40,16 @@ const (
 	// detailedPendingSuffix is the suffix for in-flight request placeholder files.
 	detailedPendingSuffix = ".pending.json"
 
+	// detailedGzipSuffix is appended to meta and bodies files once they are compressed.
+	detailedGzipSuffix = ".gz"
+
+	// detailedTempSuffix marks a file that is still being written; it is renamed
+	// into place once complete so readers never see a partial file.
+	detailedTempSuffix = ".tmp"
+
+	// staleTempFileAge is how old a leftover temp file must be before cleanup removes it.
+	staleTempFileAge = 10 * time.Minute
+
 	// legacyDetailedLogFileName is the old JSONL file name (for backward compatibility).
 	legacyDetailedLogFileName = "detailed-requests.jsonl"
 
@@ -40,11 +59,22 @@ const (
 	// defaultDetailedMaxFiles is the default maximum number of detail files to keep.
 	defaultDetailedMaxFiles = 500
 
+	// DefaultDetailedMaxBodyBytes is the default cap on captured request bodies.
+	DefaultDetailedMaxBodyBytes = 10 * 1024 * 1024
+
 	// detailedWriteBufferSize is the buffer size for the async write channel.
 	detailedWriteBufferSize = 256
 
-	// cleanupInterval controls how often cleanup runs (every N writes).
-	cleanupInterval = 20
+	// DefaultDetailedCleanupInterval is how often retention limits are enforced
+	// when no interval is configured.
+	DefaultDetailedCleanupInterval = 30 * time.Second
+
+	// retentionSweepInterval is how often age-based retention runs without writes.
+	retentionSweepInterval = 10 * time.Minute
+
+	// DefaultDetailedWriteBatchSize is how many queued writes the write loop
+	// handles per wakeup when no batch size is configured.
+	DefaultDetailedWriteBatchSize = 64
 )
 
 // FormatInfo holds the endpoint format and optional compatibility-layer info for a request.
@@ -55,6 +85,57 @@ type FormatInfo struct {
 	CompatError    string `json:"compat_error,omitempty"`
 }
 
+// RoutingAttemptsKey is the Gin context key under which unified routing stores
+// the RoutingAttempts of a request.
+const RoutingAttemptsKey = "ROUTING_ATTEMPTS"
+
+// RoutingTargetKey is the Gin context key under which unified routing stores
+// the RoutingTarget of a request.
+const RoutingTargetKey = "ROUTING_TARGET"
+
+// ErrorClassKey is the Gin context key under which executors store the class
+// of the last upstream error of a request, one of the ErrorClass* values.
+const ErrorClassKey = "DETAILED_LOG_ERROR_CLASS"
+
+// Error classes recorded in DetailedRequestRecord.ErrorClass.
+const (
+	// ErrorClassClientCanceled marks a request the client abandoned before it completed.
+	ErrorClassClientCanceled = "client_canceled"
+	// ErrorClassTimeout marks an upstream call that ran past its deadline.
+	ErrorClassTimeout = "timeout"
+	// ErrorClassUpstream covers every other upstream failure.
+	ErrorClassUpstream = "upstream"
+)
+
+// ClassifyRequestError returns the ErrorClass* value for an upstream error.
+func ClassifyRequestError(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorClassClientCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTimeout
+	default:
+		return ErrorClassUpstream
+	}
+}
+
+// RoutingAttempts is the number of targets unified routing tried for a request
+// and the pipeline's attempt cap (0 when uncapped).
+type RoutingAttempts struct {
+	Used int `json:"used"`
+	Max  int `json:"max,omitempty"`
+}
+
+// RoutingTarget is the unified routing route a request matched and the target
+// it was last sent to. The target fields are empty if none was tried.
+type RoutingTarget struct {
+	RouteID      string
+	RouteName    string
+	TargetID     string
+	CredentialID string
+	Layer        int
+}
+
 // DetailedRequestRecord represents a single proxied request with all retry attempts.
 type DetailedRequestRecord struct {
 	ID              string              `json:"id"`
@@ -68,17 +149,55 @@ type DetailedRequestRecord struct {
 	Format          *FormatInfo         `json:"format,omitempty"`
 	RequestHeaders  map[string][]string `json:"request_headers,omitempty"`
 	RequestBody     string              `json:"request_body,omitempty"`
+	// BodyTruncated is set when the client request body exceeded the capture
+	// cap and RequestBody holds only its beginning.
+	BodyTruncated   bool                `json:"body_truncated,omitempty"`
 	ResponseHeaders map[string][]string `json:"response_headers,omitempty"`
 	ResponseBody    string              `json:"response_body,omitempty"`
 	Attempts        []DetailedAttempt   `json:"attempts,omitempty"`
+	// RoutingAttempts is set for requests served through unified routing.
+	RoutingAttempts *RoutingAttempts    `json:"routing_attempts,omitempty"`
+	// RouteID and RouteName identify the unified routing route the request
+	// matched; TargetID, CredentialID and RouteLayer the target that served it
+	// (or, if all failed, was tried last).
+	RouteID         string              `json:"route_id,omitempty"`
+	RouteName       string              `json:"route_name,omitempty"`
+	TargetID        string              `json:"target_id,omitempty"`
+	CredentialID    string              `json:"credential_id,omitempty"`
+	RouteLayer      int                 `json:"route_layer,omitempty"`
 	TotalDurationMs int64               `json:"total_duration_ms"`
 	IsStreaming     bool                `json:"is_streaming"`
+	// StreamedBytes and StreamChunks count the full streamed output, including
+	// data beyond the ResponseBody capture cap. Only set for streaming responses.
+	StreamedBytes   int64               `json:"streamed_bytes,omitempty"`
+	StreamChunks    int                 `json:"stream_chunks,omitempty"`
+	// HasToolCalls is set at write time when the request declared tools/functions
+	// or the response contained tool calls.
+	HasToolCalls    bool                `json:"has_tool_calls,omitempty"`
+	// HasError is set at write time when the request failed (error or status >= 400).
+	HasError        bool                `json:"has_error,omitempty"`
+	// Token usage parsed from the response body (final usage object for streams).
+	PromptTokens     int                `json:"prompt_tokens,omitempty"`
+	CompletionTokens int                `json:"completion_tokens,omitempty"`
+	TotalTokens      int                `json:"total_tokens,omitempty"`
 	IsSimulated     bool                `json:"is_simulated,omitempty"`
+	// IsShadow marks a mirrored request sent to a shadow routing target; ShadowOf
+	// is the ID of the client request it mirrors.
+	IsShadow        bool                `json:"is_shadow,omitempty"`
+	ShadowOf        string              `json:"shadow_of,omitempty"`
 	Pending         bool                `json:"pending,omitempty"`
 	// AttemptCount is only populated when reading back lightweight simulated records
 	// that store attempt_count directly instead of a full attempts array.
 	AttemptCount    int                 `json:"attempt_count,omitempty"`
 	Error           string              `json:"error,omitempty"`
+	// ErrorClass is one of the ErrorClass* values when the request failed in an
+	// executor or was abandoned by the client.
+	ErrorClass      string              `json:"error_class,omitempty"`
+	// ClientCanceled is set when the client disconnected before the response
+	// completed, so the viewer can tell these apart from real failures.
+	ClientCanceled  bool                `json:"client_canceled,omitempty"`
+	// Tags are labels added through the management API for triage; see UpdateRecordTags.
+	Tags            []string            `json:"tags,omitempty"`
 }
 
 // DetailedRequestSummary is a lightweight projection of DetailedRequestRecord
@@ -95,13 +214,48 @@ type DetailedRequestSummary struct {
 	Format          *FormatInfo `json:"format,omitempty"`
 	TotalDurationMs int64       `json:"total_duration_ms"`
 	IsStreaming     bool        `json:"is_streaming"`
+	StreamedBytes   int64       `json:"streamed_bytes,omitempty"`
+	StreamChunks    int         `json:"stream_chunks,omitempty"`
+	HasToolCalls    bool        `json:"has_tool_calls,omitempty"`
+	HasError        bool        `json:"has_error,omitempty"`
+	PromptTokens     int        `json:"prompt_tokens,omitempty"`
+	CompletionTokens int        `json:"completion_tokens,omitempty"`
+	TotalTokens      int        `json:"total_tokens,omitempty"`
 	IsSimulated     bool        `json:"is_simulated,omitempty"`
+	IsShadow        bool        `json:"is_shadow,omitempty"`
+	ShadowOf        string      `json:"shadow_of,omitempty"`
 	Pending         bool        `json:"pending,omitempty"`
 	Error           string      `json:"error,omitempty"`
+	ErrorClass      string      `json:"error_class,omitempty"`
+	ClientCanceled  bool        `json:"client_canceled,omitempty"`
 	AttemptCount    int         `json:"attempt_count"`
+	RoutingAttempts *RoutingAttempts `json:"routing_attempts,omitempty"`
+	RouteID         string      `json:"route_id,omitempty"`
+	RouteName       string      `json:"route_name,omitempty"`
+	TargetID        string      `json:"target_id,omitempty"`
+	CredentialID    string      `json:"credential_id,omitempty"`
+	RouteLayer      int         `json:"route_layer,omitempty"`
 	// NodeCount is the number of unique upstream nodes (url+auth combinations) used.
 	// A node that is internally retried multiple times still counts as one node.
 	NodeCount       int         `json:"node_count,omitempty"`
+	Tags            []string    `json:"tags,omitempty"`
+}
+
+// DetailedRequestCompact is the minimal row needed by the viewer's table.
+// Returned by the list endpoint in summary mode; full detail is fetched by ID.
+type DetailedRequestCompact struct {
+	ID              string    `json:"id"`
+	Timestamp       time.Time `json:"timestamp"`
+	Model           string    `json:"model,omitempty"`
+	Method          string    `json:"method"`
+	StatusCode      int       `json:"status_code"`
+	TotalDurationMs int64     `json:"total_duration_ms"`
+	AttemptCount    int       `json:"attempt_count"`
+	TotalTokens     int       `json:"total_tokens,omitempty"`
+	HasError        bool      `json:"has_error,omitempty"`
+	ClientCanceled  bool      `json:"client_canceled,omitempty"`
+	Pending         bool      `json:"pending,omitempty"`
+	Tags            []string  `json:"tags,omitempty"`
 }
 
 // attemptCount returns the number of upstream attempts.
@@ -143,11 +297,47 @@ func (r *DetailedRequestRecord) ToSummary() DetailedRequestSummary {
 		Format:          r.Format,
 		TotalDurationMs: r.TotalDurationMs,
 		IsStreaming:     r.IsStreaming,
+		StreamedBytes:   r.StreamedBytes,
+		StreamChunks:    r.StreamChunks,
+		HasToolCalls:    r.HasToolCalls,
+		HasError:        r.HasError,
+		PromptTokens:     r.PromptTokens,
+		CompletionTokens: r.CompletionTokens,
+		TotalTokens:      r.TotalTokens,
 		IsSimulated:     r.IsSimulated,
+		IsShadow:        r.IsShadow,
+		ShadowOf:        r.ShadowOf,
 		Pending:         r.Pending,
 		Error:           r.Error,
+		ErrorClass:      r.ErrorClass,
+		ClientCanceled:  r.ClientCanceled,
 		AttemptCount:    r.attemptCount(),
+		RoutingAttempts: r.RoutingAttempts,
+		RouteID:         r.RouteID,
+		RouteName:       r.RouteName,
+		TargetID:        r.TargetID,
+		CredentialID:    r.CredentialID,
+		RouteLayer:      r.RouteLayer,
 		NodeCount:       r.nodeCount(),
+		Tags:            r.Tags,
+	}
+}
+
+// ToCompact converts a full record to a compact list row.
+func (r *DetailedRequestRecord) ToCompact() DetailedRequestCompact {
+	return DetailedRequestCompact{
+		ID:              r.ID,
+		Timestamp:       r.Timestamp,
+		Model:           r.Model,
+		Method:          r.Method,
+		StatusCode:      r.StatusCode,
+		TotalDurationMs: r.TotalDurationMs,
+		AttemptCount:    r.attemptCount(),
+		TotalTokens:     r.TotalTokens,
+		HasError:        r.HasError || r.Error != "",
+		ClientCanceled:  r.ClientCanceled,
+		Pending:         r.Pending,
+		Tags:            r.Tags,
 	}
 }
 
@@ -165,8 +355,15 @@ type DetailedAttempt struct {
 	ResponseBody    string              `json:"response_body,omitempty"`
 	Error           string              `json:"error,omitempty"`
 	DurationMs      int64               `json:"duration_ms,omitempty"`
+
+	response          *strings.Builder // streamed ResponseBody, see AppendResponseChunk
+	responseTruncated bool
 }
 
+// DetailedAttemptsKey is the Gin context key under which executors store the
+// []*DetailedAttempt of a request while detailed logging is enabled.
+const DetailedAttemptsKey = "DETAILED_LOG_ATTEMPTS"
+
 // DetailedAttemptBodies holds body data for a single attempt.
 type DetailedAttemptBodies struct {
 	Index        int    `json:"index"`
@@ -259,61 +456,152 @@ func mergeBodies(meta *DetailedRequestRecord, bodies *DetailedRecordBodies) {
 // IndexEntry is a lightweight record stored in the index file for fast filtering
 // without reading individual meta files.
 type IndexEntry struct {
-	ID           string `json:"id"`
-	Filename     string `json:"file"`
-	APIKey       string `json:"api_key"`
-	APIKeyHash   string `json:"api_key_hash"`
-	StatusCode   int    `json:"status"`
-	IsSimulated  bool   `json:"sim,omitempty"`
-	Timestamp    int64  `json:"ts"`
-	Model        string `json:"model,omitempty"`
+	ID            string `json:"id"`
+	Filename      string `json:"file"`
+	APIKey        string `json:"api_key"`
+	APIKeyHash    string `json:"api_key_hash"`
+	StatusCode    int    `json:"status"`
+	IsSimulated   bool   `json:"sim,omitempty"`
+	Timestamp     int64  `json:"ts"`
+	Model         string `json:"model,omitempty"`
+	URL           string `json:"url,omitempty"`
+	StreamedBytes int64  `json:"sbytes,omitempty"`
+	StreamChunks  int    `json:"schunks,omitempty"`
+	HasToolCalls  bool   `json:"tools,omitempty"`
+	HasError      bool   `json:"err,omitempty"`
+	Canceled      bool   `json:"cancel,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+	RouteID       string `json:"route,omitempty"`
+	// DurationMs is nil in entries written before durations were indexed.
+	DurationMs    *int64 `json:"dur,omitempty"`
+}
+
+// newIndexEntry builds the index entry for a record stored under filename.
+func newIndexEntry(record *DetailedRequestRecord, filename string) IndexEntry {
+	durationMs := record.TotalDurationMs
+	return IndexEntry{
+		ID:            record.ID,
+		Filename:      filename,
+		APIKey:        record.APIKey,
+		APIKeyHash:    record.APIKeyHash,
+		StatusCode:    record.StatusCode,
+		IsSimulated:   record.IsSimulated,
+		Timestamp:     record.Timestamp.Unix(),
+		Model:         record.Model,
+		URL:           record.URL,
+		StreamedBytes: record.StreamedBytes,
+		StreamChunks:  record.StreamChunks,
+		HasToolCalls:  record.HasToolCalls,
+		HasError:      record.HasError,
+		Canceled:      record.ClientCanceled,
+		Tags:          record.Tags,
+		RouteID:       record.RouteID,
+		DurationMs:    &durationMs,
+	}
 }
 
-const indexFileName = "index.json"
+// indexFileName holds one IndexEntry per line in write order, so a new record
+// is an append rather than a rewrite. legacyIndexFileName is the JSON array
+// index it replaces, dropped when the index is next rebuilt.
+const (
+	indexFileName       = "index.jsonl"
+	legacyIndexFileName = "index.json"
+)
 
 type writeOpType int
 
 const (
 	writeOpComplete writeOpType = iota
 	writeOpPending
+	writeOpDiscard // record was sampled out; drop its pending placeholder
+	writeOpTags    // change a stored record's tags; see UpdateRecordTags
 )
 
 type writeOp struct {
 	opType writeOpType
 	record *DetailedRequestRecord
+	tags   *tagUpdate // set for writeOpTags
 }
 
-// DetailedRequestLogger handles structured logging of detailed request records
-// as individual JSON files in the logs directory.
+// DetailedRequestLogger handles structured logging of detailed request records.
+// Completed records go to a RecordStore (individual JSON files in the logs directory
+// by default); in-flight placeholders are always plain files in the logs directory.
 type DetailedRequestLogger struct {
-	mu           sync.Mutex
-	enabled      bool
-	logsDir      string
-	maxSizeMB    int
-	maxFiles     int
-	writeCh      chan *writeOp
-	stopCh       chan struct{}
-	stopped      bool
-	writeCount   int64 // counts writes for periodic cleanup
+	mu            sync.Mutex
+	enabled       bool
+	includeMgmt   bool // also record management API traffic (developer opt-in)
+	maskAuth      bool // mask client credentials in stored request headers
+	logGets       bool // also record GET requests (e.g. /v1/models)
+	logHeadOpts   bool // also record HEAD and OPTIONS (CORS preflight) requests
+	pathFilter    *DetailedPathFilter
+	logsDir       string
+	maxSizeMB     int
+	maxFiles      int
+	compressAfter int           // gzip files older than the newest N; 0 disables compression
+	maxAge        time.Duration // records older than this are removed; 0 disables age retention
+	redactPaths   []string      // gjson paths whose values are replaced before bodies are stored
+	sampleRate    float64       // fraction of successful records kept (0.0–1.0); failures are always kept
+	maxBodyBytes  int64         // request bodies are captured up to this size
+	store         RecordStore
+	writeCh       chan *writeOp
+	stopCh        chan struct{}
+	stopped       bool
+	cleanupEvery  time.Duration // how often the write loop enforces retention
+	cleanupDue    bool          // records were written since the last cleanup
+	lastCleanup   time.Time     // when retention last ran; paces the idle age sweep
+	cleanupReset  chan struct{} // signals the write loop that cleanupEvery changed
+	batchSize     int           // queued writes drained per write loop wakeup; 1 disables batching
+	filenameTmpl  string        // layout of completed record filenames; see SetFilenameTemplate
+	tail          detailedTail // live subscribers; see Subscribe
 }
 
 // NewDetailedRequestLogger creates a new detailed request logger.
-func NewDetailedRequestLogger(enabled bool, logsDir string, maxSizeMB int) *DetailedRequestLogger {
+// When compressAfterFiles > 0, all but the newest compressAfterFiles records are
+// gzip-compressed during periodic cleanup (file store only).
+// A nil store selects the built-in FileRecordStore rooted at logsDir.
+func NewDetailedRequestLogger(enabled bool, logsDir string, maxSizeMB int, compressAfterFiles int, store RecordStore) *DetailedRequestLogger {
 	if maxSizeMB <= 0 {
 		maxSizeMB = defaultDetailedMaxSizeMB
 	}
+	if compressAfterFiles < 0 {
+		compressAfterFiles = 0
+	}
 	dl := &DetailedRequestLogger{
-		enabled:   enabled,
-		logsDir:   logsDir,
-		maxSizeMB: maxSizeMB,
-		maxFiles:  defaultDetailedMaxFiles,
-		writeCh:   make(chan *writeOp, detailedWriteBufferSize),
-		stopCh:    make(chan struct{}),
+		enabled:       enabled,
+		logsDir:       logsDir,
+		maxSizeMB:     maxSizeMB,
+		maxFiles:      defaultDetailedMaxFiles,
+		compressAfter: compressAfterFiles,
+		sampleRate:    1,
+		maxBodyBytes:  DefaultDetailedMaxBodyBytes,
+		maskAuth:      true,
+		cleanupEvery:  DefaultDetailedCleanupInterval,
+		batchSize:     DefaultDetailedWriteBatchSize,
+		cleanupReset:  make(chan struct{}, 1),
+		writeCh:       make(chan *writeOp, detailedWriteBufferSize),
+		stopCh:        make(chan struct{}),
+	}
+	if store == nil {
+		store = &FileRecordStore{dl: dl}
+	}
+	dl.store = store
+	// Create the directory once here rather than on every write; writes
+	// recreate it only if it has since been removed.
+	if enabled && logsDir != "" {
+		if err := os.MkdirAll(logsDir, 0755); err != nil {
+			log.WithError(err).Warn("failed to create detailed request logs directory")
+		}
 	}
 	go dl.writeLoop()
 	return dl
 }
 
+// usesFileStore reports whether completed records are stored as files in logsDir.
+func (dl *DetailedRequestLogger) usesFileStore() bool {
+	_, ok := dl.store.(*FileRecordStore)
+	return ok
+}
+
 // IsEnabled returns whether detailed request logging is enabled.
 func (dl *DetailedRequestLogger) IsEnabled() bool {
 	dl.mu.Lock()
@@ -328,6 +616,228 @@ func (dl *DetailedRequestLogger) SetEnabled(enabled bool) {
 	dl.enabled = enabled
 }
 
+// SampleRate returns the fraction of successful records that are kept.
+func (dl *DetailedRequestLogger) SampleRate() float64 {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return dl.sampleRate
+}
+
+// SetSampleRate sets the fraction (0.0–1.0) of successful records to keep.
+// Records with StatusCode >= 400 or an Error are always kept.
+func (dl *DetailedRequestLogger) SetSampleRate(rate float64) {
+	if rate < 0 {
+		rate = 0
+	}
+	if rate > 1 {
+		rate = 1
+	}
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.sampleRate = rate
+}
+
+// sampleKeep decides deterministically whether a record is kept at the given rate
+// by hashing its ID into one of 10000 buckets, so the same request ID is always
+// either kept or dropped.
+func sampleKeep(id string, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return float64(h.Sum32()%10000) < rate*10000
+}
+
+// QueueDepth returns the number of records waiting in the async write channel
+// and the channel capacity.
+func (dl *DetailedRequestLogger) QueueDepth() (int, int) {
+	return len(dl.writeCh), cap(dl.writeCh)
+}
+
+// IncludeManagement reports whether management API requests should be recorded.
+func (dl *DetailedRequestLogger) IncludeManagement() bool {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return dl.includeMgmt
+}
+
+// SetIncludeManagement toggles recording of management API requests.
+func (dl *DetailedRequestLogger) SetIncludeManagement(include bool) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.includeMgmt = include
+}
+
+// PathFilter returns the compiled include/exclude path globs; nil means the defaults.
+func (dl *DetailedRequestLogger) PathFilter() *DetailedPathFilter {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return dl.pathFilter
+}
+
+// SetPathFilters compiles the include and exclude path globs once so each
+// request only matches against them. Empty lists select the defaults.
+func (dl *DetailedRequestLogger) SetPathFilters(include, exclude []string) {
+	filter := NewDetailedPathFilter(include, exclude)
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.pathFilter = filter
+}
+
+// LogGetRequests reports whether GET requests are recorded.
+func (dl *DetailedRequestLogger) LogGetRequests() bool {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return dl.logGets
+}
+
+// SetLogGetRequests toggles recording of GET requests.
+func (dl *DetailedRequestLogger) SetLogGetRequests(enabled bool) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.logGets = enabled
+}
+
+// LogHeadOptionsRequests reports whether HEAD and OPTIONS requests are recorded.
+func (dl *DetailedRequestLogger) LogHeadOptionsRequests() bool {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return dl.logHeadOpts
+}
+
+// SetLogHeadOptionsRequests toggles recording of HEAD and OPTIONS requests.
+func (dl *DetailedRequestLogger) SetLogHeadOptionsRequests(enabled bool) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.logHeadOpts = enabled
+}
+
+// MaskAuthorization reports whether client credential headers (Authorization,
+// API-key headers) are masked before records are stored. On by default.
+func (dl *DetailedRequestLogger) MaskAuthorization() bool {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return dl.maskAuth
+}
+
+// SetMaskAuthorization toggles masking of client credential headers.
+func (dl *DetailedRequestLogger) SetMaskAuthorization(mask bool) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.maskAuth = mask
+}
+
+// MaxBodyBytes returns how many bytes of a request body are captured, which
+// is also the size limit of every stored body.
+func (dl *DetailedRequestLogger) MaxBodyBytes() int64 {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return dl.maxBodyBytes
+}
+
+// SetMaxBodyBytes sets the body capture and storage cap; n <= 0 selects
+// DefaultDetailedMaxBodyBytes.
+func (dl *DetailedRequestLogger) SetMaxBodyBytes(n int64) {
+	if n <= 0 {
+		n = DefaultDetailedMaxBodyBytes
+	}
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.maxBodyBytes = n
+}
+
+// SetCompressAfterFiles updates how many of the newest records stay uncompressed.
+// 0 disables compression for future cleanups; already compressed files are kept.
+func (dl *DetailedRequestLogger) SetCompressAfterFiles(n int) {
+	if n < 0 {
+		n = 0
+	}
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.compressAfter = n
+}
+
+// RedactPaths returns a copy of the configured body redaction paths.
+func (dl *DetailedRequestLogger) RedactPaths() []string {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	if len(dl.redactPaths) == 0 {
+		return nil
+	}
+	return append([]string(nil), dl.redactPaths...)
+}
+
+// SetRedactPaths sets the gjson-style paths (e.g. "messages.#.content") whose
+// values are redacted from logged JSON bodies. Empty entries are ignored.
+func (dl *DetailedRequestLogger) SetRedactPaths(paths []string) {
+	cleaned := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if p = strings.TrimSpace(p); p != "" {
+			cleaned = append(cleaned, p)
+		}
+	}
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.redactPaths = cleaned
+}
+
+// SetMaxAge sets the retention window; records whose files are older than
+// now - maxAge are removed on the next cleanup. 0 disables age-based retention.
+func (dl *DetailedRequestLogger) SetMaxAge(maxAge time.Duration) {
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.maxAge = maxAge
+}
+
+// SetCleanupInterval sets how often retention limits are enforced; d <= 0
+// selects DefaultDetailedCleanupInterval. The change applies from the next tick.
+func (dl *DetailedRequestLogger) SetCleanupInterval(d time.Duration) {
+	if d <= 0 {
+		d = DefaultDetailedCleanupInterval
+	}
+	dl.mu.Lock()
+	dl.cleanupEvery = d
+	dl.mu.Unlock()
+	select {
+	case dl.cleanupReset <- struct{}{}:
+	default:
+	}
+}
+
+// SetWriteBatchSize sets how many queued writes the write loop handles per
+// wakeup. A batch of completed records shares one index update. n <= 0 selects
+// DefaultDetailedWriteBatchSize; 1 writes records one at a time.
+func (dl *DetailedRequestLogger) SetWriteBatchSize(n int) {
+	if n <= 0 {
+		n = DefaultDetailedWriteBatchSize
+	}
+	dl.mu.Lock()
+	dl.batchSize = n
+	dl.mu.Unlock()
+}
+
+// SetFilenameTemplate sets the layout of completed record filenames; see
+// config.DetailFilenamePlaceholders. An empty template selects
+// config.DefaultDetailFilenameTemplate. Files already written keep their names,
+// and in-flight placeholders always use the default layout, since status and
+// model are not known until the request completes.
+func (dl *DetailedRequestLogger) SetFilenameTemplate(tmpl string) error {
+	if err := config.ValidateDetailFilenameTemplate(tmpl); err != nil {
+		return err
+	}
+	dl.mu.Lock()
+	dl.filenameTmpl = tmpl
+	dl.mu.Unlock()
+	return nil
+}
+
 // SetMaxSizeMB updates the maximum total log size in MB.
 func (dl *DetailedRequestLogger) SetMaxSizeMB(maxSizeMB int) {
 	dl.mu.Lock()
@@ -338,7 +848,8 @@ func (dl *DetailedRequestLogger) SetMaxSizeMB(maxSizeMB int) {
 	dl.maxSizeMB = maxSizeMB
 }
 
-// LogRecord writes a detailed request record as an individual JSON file asynchronously.
+// LogRecord writes a detailed request record as an individual JSON file asynchronously
+// and publishes its summary to tail subscribers.
 func (dl *DetailedRequestLogger) LogRecord(record *DetailedRequestRecord) {
 	if record == nil {
 		return
@@ -348,10 +859,18 @@ func (dl *DetailedRequestLogger) LogRecord(record *DetailedRequestRecord) {
 		dl.mu.Unlock()
 		return
 	}
+	rate := dl.sampleRate
 	dl.mu.Unlock()
 
+	opType := writeOpComplete
+	isFailure := record.StatusCode >= 400 || record.Error != ""
+	if !isFailure && !sampleKeep(record.ID, rate) {
+		opType = writeOpDiscard
+	}
+	dl.tail.publish(record)
+
 	select {
-	case dl.writeCh <- &writeOp{opType: writeOpComplete, record: record}:
+	case dl.writeCh <- &writeOp{opType: opType, record: record}:
 	default:
 		log.Warn("detailed request log write channel full, dropping record")
 	}
@@ -385,52 +904,251 @@ func (dl *DetailedRequestLogger) Close() {
 	}
 	dl.stopped = true
 	dl.mu.Unlock()
+	dl.tail.close()
 	close(dl.writeCh)
 	<-dl.stopCh
+	if err := dl.store.Close(); err != nil {
+		log.WithError(err).Warn("failed to close detailed request store")
+	}
 }
 
 // writeLoop is the background goroutine that writes records to disk.
+// Retention is enforced here on a ticker rather than per write, so its cost does
+// not follow the request rate. Cleanup only ever runs on this goroutine, so runs
+// never overlap, and ticks missed during a slow cleanup are dropped.
 func (dl *DetailedRequestLogger) writeLoop() {
 	defer close(dl.stopCh)
-	for op := range dl.writeCh {
+	dl.mu.Lock()
+	every := dl.cleanupEvery
+	dl.mu.Unlock()
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case op, ok := <-dl.writeCh:
+			if !ok {
+				// Final cleanup so records written just before Close are within limits.
+				dl.cleanupIfDue()
+				return
+			}
+			dl.writeBatch(dl.drainWrites(op))
+		case <-dl.cleanupReset:
+			dl.mu.Lock()
+			every = dl.cleanupEvery
+			dl.mu.Unlock()
+			ticker.Reset(every)
+		case <-ticker.C:
+			dl.cleanupIfDue()
+		}
+	}
+}
+
+// drainWrites returns first followed by the writes already queued behind it,
+// up to the batch size, without waiting for more. If the channel is closed
+// meanwhile, the next receive in writeLoop sees it.
+func (dl *DetailedRequestLogger) drainWrites(first *writeOp) []*writeOp {
+	dl.mu.Lock()
+	limit := dl.batchSize
+	dl.mu.Unlock()
+	ops := []*writeOp{first}
+	for len(ops) < limit {
+		select {
+		case op, ok := <-dl.writeCh:
+			if !ok {
+				return ops
+			}
+			ops = append(ops, op)
+		default:
+			return ops
+		}
+	}
+	return ops
+}
+
+// writeBatch performs queued writes in order. Completed records going to the
+// file store share one encode buffer and are added to the index in one append.
+func (dl *DetailedRequestLogger) writeBatch(ops []*writeOp) {
+	var buf bytes.Buffer
+	var indexed []IndexEntry
+	flushIndex := func() {
+		if len(indexed) > 0 {
+			dl.appendToIndex(indexed...)
+			indexed = nil
+		}
+	}
+	for _, op := range ops {
 		switch op.opType {
 		case writeOpPending:
 			if err := dl.writePendingFile(op.record); err != nil {
 				log.WithError(err).Warn("failed to write pending record")
 			}
 		case writeOpComplete:
-			if err := dl.writeRecordFile(op.record); err != nil {
+			if !dl.usesFileStore() {
+				if err := dl.writeCompleted(op.record); err != nil {
+					log.WithError(err).Warn("failed to write detailed request record")
+				}
+				continue
+			}
+			filename, err := dl.writeRecordFiles(op.record, &buf)
+			if err != nil {
 				log.WithError(err).Warn("failed to write detailed request record")
+				continue
 			}
+			indexed = append(indexed, newIndexEntry(op.record, filename))
+			dl.markCleanupDue()
+		case writeOpDiscard:
+			pendingName := dl.pendingFilename(op.record)
+			_ = os.Remove(filepath.Join(dl.logsDir, pendingName))
+		case writeOpTags:
+			// Tag updates read and rewrite the index, so earlier records must be in it.
+			flushIndex()
+			op.tags.apply(dl)
 		}
 	}
+	flushIndex()
+}
+
+// markCleanupDue records that a write happened since the last cleanup.
+func (dl *DetailedRequestLogger) markCleanupDue() {
+	dl.mu.Lock()
+	dl.cleanupDue = true
+	dl.mu.Unlock()
+}
+
+// cleanupIfDue enforces retention when records were written since the last run.
+// Age-based retention must hold even when no new records arrive, so while a max
+// age is set it also runs every retentionSweepInterval.
+func (dl *DetailedRequestLogger) cleanupIfDue() {
+	dl.mu.Lock()
+	now := time.Now()
+	due := dl.cleanupDue || (dl.maxAge > 0 && now.Sub(dl.lastCleanup) >= retentionSweepInterval)
+	dl.cleanupDue = false
+	if due {
+		dl.lastCleanup = now
+	}
+	dl.mu.Unlock()
+	if due {
+		dl.enforceRetention()
+	}
+}
+
+// writeCompleted stores a completed record. The file store handles its own
+// placeholder removal, indexing and cleanup; other stores get the same
+// treatment here.
+func (dl *DetailedRequestLogger) writeCompleted(record *DetailedRequestRecord) error {
+	if dl.usesFileStore() {
+		return dl.writeRecordFile(record)
+	}
+	if err := dl.store.Write(record); err != nil {
+		return err
+	}
+	pendingName := dl.pendingFilename(record)
+	os.Remove(filepath.Join(dl.logsDir, pendingName))
+
+	dl.markCleanupDue()
+	return nil
+}
+
+// enforceRetention applies the count, size and age limits to the active store.
+// Stores other than the file store are pruned only if they implement RecordPruner.
+func (dl *DetailedRequestLogger) enforceRetention() {
+	if dl.usesFileStore() {
+		dl.cleanupOldFiles()
+		return
+	}
+	pruner, ok := dl.store.(RecordPruner)
+	if !ok {
+		return
+	}
+	dl.mu.Lock()
+	maxFiles := dl.maxFiles
+	maxBytes := int64(dl.maxSizeMB) * 1024 * 1024
+	maxAge := dl.maxAge
+	dl.mu.Unlock()
+
+	var cutoff time.Time
+	if maxAge > 0 {
+		cutoff = time.Now().Add(-maxAge)
+	}
+	if err := pruner.Prune(maxFiles, maxBytes, cutoff); err != nil {
+		log.WithError(err).Warn("failed to prune detailed request store")
+	}
 }
 
 // writePendingFile writes a lightweight placeholder JSON file for an in-flight request.
 func (dl *DetailedRequestLogger) writePendingFile(record *DetailedRequestRecord) error {
-	if err := os.MkdirAll(dl.logsDir, 0755); err != nil {
-		return fmt.Errorf("failed to create logs directory: %w", err)
-	}
-	baseFilename := dl.generateDetailFilename(record)
-	pendingName := strings.TrimSuffix(baseFilename, detailedFileSuffix) + detailedPendingSuffix
+	pendingName := dl.pendingFilename(record)
 	data, err := json.MarshalIndent(record, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal pending record: %w", err)
 	}
 	data = append(data, '\n')
-	return os.WriteFile(filepath.Join(dl.logsDir, pendingName), data, 0644)
+	return dl.writeLogsFile(filepath.Join(dl.logsDir, pendingName), data)
 }
 
-// writeRecordFile writes a record to disk.
+// writeLogsFile writes a file in the logs directory with writeFileAtomic. The
+// directory is created at startup, so it is only created here when a write
+// finds it missing.
+func (dl *DetailedRequestLogger) writeLogsFile(path string, data []byte) error {
+	err := writeFileAtomic(path, data)
+	if errors.Is(err, fs.ErrNotExist) {
+		if errDir := os.MkdirAll(dl.logsDir, 0755); errDir != nil {
+			return fmt.Errorf("failed to create logs directory: %w", errDir)
+		}
+		err = writeFileAtomic(path, data)
+	}
+	return err
+}
+
+// encodeIndented encodes v into buf, replacing its contents, with the same
+// output as json.MarshalIndent(v, "", "  ") plus a trailing newline. The
+// returned slice is only valid until buf is reused.
+func encodeIndented(buf *bytes.Buffer, v any) ([]byte, error) {
+	buf.Reset()
+	enc := json.NewEncoder(buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeFileAtomic writes data to path+".tmp" and renames it over path, so concurrent
+// readers see either the previous file or the complete new one, never a partial write.
+func writeFileAtomic(path string, data []byte) error {
+	tmpPath := path + detailedTempSuffix
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// writeRecordFile writes a record to disk and adds it to the index.
 // Simulated records are stored as a single lightweight file (no bodies companion).
 // Regular records are stored as two files: meta (no bodies) and bodies.
 func (dl *DetailedRequestLogger) writeRecordFile(record *DetailedRequestRecord) error {
-	if err := os.MkdirAll(dl.logsDir, 0755); err != nil {
-		return fmt.Errorf("failed to create logs directory: %w", err)
+	var buf bytes.Buffer
+	filename, err := dl.writeRecordFiles(record, &buf)
+	if err != nil {
+		return err
 	}
+	dl.appendToIndex(newIndexEntry(record, filename))
+
+	dl.markCleanupDue()
+	return nil
+}
 
+// writeRecordFiles writes a record's files and removes its pending placeholder,
+// leaving the index to the caller. buf is scratch space for encoding. It
+// returns the name of the record's meta file.
+func (dl *DetailedRequestLogger) writeRecordFiles(record *DetailedRequestRecord, buf *bytes.Buffer) (string, error) {
 	if record.IsSimulated {
-		return dl.writeSimulatedRecordFile(record)
+		return dl.writeSimulatedRecordFile(record, buf)
 	}
 
 	baseFilename := dl.generateDetailFilename(record)
@@ -440,40 +1158,25 @@ func (dl *DetailedRequestLogger) writeRecordFile(record *DetailedRequestRecord)
 
 	meta, bodies := stripBodies(record)
 
-	metaData, err := json.MarshalIndent(meta, "", "  ")
+	metaData, err := encodeIndented(buf, meta)
 	if err != nil {
-		return fmt.Errorf("failed to marshal meta: %w", err)
+		return "", fmt.Errorf("failed to marshal meta: %w", err)
 	}
-	metaData = append(metaData, '\n')
-	if err := os.WriteFile(metaPath, metaData, 0644); err != nil {
-		return fmt.Errorf("failed to write meta file: %w", err)
+	if err := dl.writeLogsFile(metaPath, metaData); err != nil {
+		return "", fmt.Errorf("failed to write meta file: %w", err)
 	}
 
-	bodiesData, err := json.MarshalIndent(bodies, "", "  ")
+	bodiesData, err := encodeIndented(buf, bodies)
 	if err != nil {
-		return fmt.Errorf("failed to marshal bodies: %w", err)
+		return "", fmt.Errorf("failed to marshal bodies: %w", err)
 	}
-	bodiesData = append(bodiesData, '\n')
-	if err := os.WriteFile(bodiesPath, bodiesData, 0644); err != nil {
-		return fmt.Errorf("failed to write bodies file: %w", err)
+	if err := dl.writeLogsFile(bodiesPath, bodiesData); err != nil {
+		return "", fmt.Errorf("failed to write bodies file: %w", err)
 	}
 
 	// Remove the pending placeholder now that the complete record is written.
-	pendingName := strings.TrimSuffix(baseFilename, detailedFileSuffix) + detailedPendingSuffix
-	os.Remove(filepath.Join(dl.logsDir, pendingName))
-
-	dl.appendToIndex(record, baseFilename)
-
-	dl.mu.Lock()
-	dl.writeCount++
-	shouldCleanup := dl.writeCount%cleanupInterval == 0
-	dl.mu.Unlock()
-
-	if shouldCleanup {
-		dl.cleanupOldFiles()
-	}
-
-	return nil
+	os.Remove(filepath.Join(dl.logsDir, dl.pendingFilename(record)))
+	return baseFilename, nil
 }
 
 // simulatedRecordSummary is the lightweight structure written to disk for simulated records.
@@ -491,7 +1194,7 @@ type simulatedRecordSummary struct {
 }
 
 // writeSimulatedRecordFile writes a single lightweight JSON file for a simulated record.
-func (dl *DetailedRequestLogger) writeSimulatedRecordFile(record *DetailedRequestRecord) error {
+func (dl *DetailedRequestLogger) writeSimulatedRecordFile(record *DetailedRequestRecord, buf *bytes.Buffer) (string, error) {
 	baseFilename := dl.generateDetailFilename(record)
 	metaPath := filepath.Join(dl.logsDir, baseFilename)
 
@@ -507,35 +1210,42 @@ func (dl *DetailedRequestLogger) writeSimulatedRecordFile(record *DetailedReques
 		AttemptCount:    len(record.Attempts),
 	}
 
-	data, err := json.MarshalIndent(summary, "", "  ")
+	data, err := encodeIndented(buf, summary)
 	if err != nil {
-		return fmt.Errorf("failed to marshal simulated record: %w", err)
-	}
-	data = append(data, '\n')
-	if err := os.WriteFile(metaPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write simulated record file: %w", err)
+		return "", fmt.Errorf("failed to marshal simulated record: %w", err)
 	}
-
-	dl.appendToIndex(record, baseFilename)
-
-	dl.mu.Lock()
-	dl.writeCount++
-	shouldCleanup := dl.writeCount%cleanupInterval == 0
-	dl.mu.Unlock()
-
-	if shouldCleanup {
-		dl.cleanupOldFiles()
+	if err := dl.writeLogsFile(metaPath, data); err != nil {
+		return "", fmt.Errorf("failed to write simulated record file: %w", err)
 	}
-
-	return nil
+	return baseFilename, nil
 }
 
-// generateDetailFilename creates a filename for a detail log file.
-// Format: detail-v1-chat-completions-2026-02-08T130145-42cf8292.json
+// generateDetailFilename creates a filename for a detail log file from the
+// configured template. The default layout is
+// detail-v1-chat-completions-2026-02-08T130145-42cf8292.json
 //
 // For simulated records whose ID follows the pattern "sim-YYYYMMDDTHHMMSS-<hex>",
 // only the trailing hex part is used to keep filenames short.
 func (dl *DetailedRequestLogger) generateDetailFilename(record *DetailedRequestRecord) string {
+	dl.mu.Lock()
+	tmpl := dl.filenameTmpl
+	dl.mu.Unlock()
+	return renderDetailFilename(tmpl, record)
+}
+
+// pendingFilename returns the name of a record's in-flight placeholder. It
+// always uses the default layout, so it is the same when the placeholder is
+// written and when it is removed, whatever the status and model turn out to be.
+func (dl *DetailedRequestLogger) pendingFilename(record *DetailedRequestRecord) string {
+	return strings.TrimSuffix(renderDetailFilename("", record), detailedFileSuffix) + detailedPendingSuffix
+}
+
+// renderDetailFilename fills a template validated by
+// config.ValidateDetailFilenameTemplate; "" selects the default layout.
+func renderDetailFilename(tmpl string, record *DetailedRequestRecord) string {
+	if tmpl == "" {
+		tmpl = config.DefaultDetailFilenameTemplate
+	}
 	path := record.URL
 	if strings.Contains(path, "?") {
 		path = strings.Split(path, "?")[0]
@@ -543,9 +1253,7 @@ func (dl *DetailedRequestLogger) generateDetailFilename(record *DetailedRequestR
 	if strings.HasPrefix(path, "/") {
 		path = path[1:]
 	}
-	sanitized := sanitizePathForFilename(path)
 
-	timestamp := record.Timestamp.Format("2006-01-02T150405")
 	id := record.ID
 	if id == "" {
 		id = fmt.Sprintf("%d", time.Now().UnixNano())
@@ -556,8 +1264,26 @@ func (dl *DetailedRequestLogger) generateDetailFilename(record *DetailedRequestR
 		}
 	}
 
-	return fmt.Sprintf("%s%s-%s-%s%s", detailedFilePrefix, sanitized, timestamp, id, detailedFileSuffix)
-}
+	model := "unknown"
+	if record.Model != "" {
+		model = sanitizePathForFilename(record.Model)
+	}
+	method := "unknown"
+	if record.Method != "" {
+		method = sanitizePathForFilename(record.Method)
+	}
+
+	return strings.NewReplacer(
+		"{prefix}", detailedFilePrefix,
+		"{suffix}", detailedFileSuffix,
+		"{path}", sanitizePathForFilename(path),
+		"{ts}", record.Timestamp.Format("2006-01-02T150405"),
+		"{id}", id,
+		"{status}", strconv.Itoa(record.StatusCode),
+		"{model}", model,
+		"{method}", method,
+	).Replace(tmpl)
+}
 
 // sanitizePathForFilename replaces characters that are not safe for filenames.
 func sanitizePathForFilename(path string) string {
@@ -576,8 +1302,11 @@ func sanitizePathForFilename(path string) string {
 	return sanitized
 }
 
-// cleanupOldFiles removes the oldest detail file pairs when limits are exceeded.
+// cleanupOldFiles compresses aged records and removes the oldest detail file pairs
+// when limits are exceeded.
 func (dl *DetailedRequestLogger) cleanupOldFiles() {
+	renamed := dl.compressOldFiles()
+
 	entries, err := os.ReadDir(dl.logsDir)
 	if err != nil {
 		return
@@ -597,7 +1326,14 @@ func (dl *DetailedRequestLogger) cleanupOldFiles() {
 			continue
 		}
 		name := entry.Name()
-		if !strings.HasPrefix(name, detailedFilePrefix) || !strings.HasSuffix(name, detailedFileSuffix) {
+		if isTempFile(name) {
+			// Leftovers from a crash mid-write; in-progress writes are much younger.
+			if info, errInfo := entry.Info(); errInfo == nil && time.Since(info.ModTime()) > staleTempFileAge {
+				os.Remove(filepath.Join(dl.logsDir, name))
+			}
+			continue
+		}
+		if !isDetailFile(name) {
 			continue
 		}
 		info, errInfo := entry.Info()
@@ -612,6 +1348,7 @@ func (dl *DetailedRequestLogger) cleanupOldFiles() {
 	}
 
 	if len(metaFiles) == 0 {
+		dl.pruneIndex(renamed, nil)
 		return
 	}
 
@@ -622,20 +1359,29 @@ func (dl *DetailedRequestLogger) cleanupOldFiles() {
 	dl.mu.Lock()
 	maxFiles := dl.maxFiles
 	maxBytes := int64(dl.maxSizeMB) * 1024 * 1024
+	maxAge := dl.maxAge
 	dl.mu.Unlock()
 
+	var cutoff time.Time
+	if maxAge > 0 {
+		cutoff = time.Now().Add(-maxAge)
+	}
+
 	var totalSize int64
 	for _, sz := range allSizes {
 		totalSize += sz
 	}
 
-	for len(metaFiles) > maxFiles || (totalSize > maxBytes && len(metaFiles) > 0) {
+	removed := make(map[string]bool)
+	for len(metaFiles) > maxFiles || (totalSize > maxBytes && len(metaFiles) > 0) ||
+		(len(metaFiles) > 0 && !cutoff.IsZero() && metaFiles[0].modTime.Before(cutoff)) {
 		oldest := metaFiles[0]
 		if err := os.Remove(filepath.Join(dl.logsDir, oldest.name)); err == nil {
 			totalSize -= oldest.size
+			removed[oldest.name] = true
 		}
 		// Also remove companion bodies file
-		bodiesName := strings.TrimSuffix(oldest.name, detailedFileSuffix) + detailedBodiesSuffix
+		bodiesName := bodiesFileFor(oldest.name)
 		if sz, ok := allSizes[bodiesName]; ok {
 			if err := os.Remove(filepath.Join(dl.logsDir, bodiesName)); err == nil {
 				totalSize -= sz
@@ -644,12 +1390,149 @@ func (dl *DetailedRequestLogger) cleanupOldFiles() {
 		metaFiles = metaFiles[1:]
 	}
 
-	dl.RebuildIndex()
+	dl.pruneIndex(renamed, removed)
+}
+
+// pruneIndex brings the index in line with a cleanup: entries whose files were
+// compressed take the new names in renamed, and entries for removed files are
+// dropped. It does nothing when the cleanup changed no files, and falls back to
+// a full rebuild when the index cannot be read.
+func (dl *DetailedRequestLogger) pruneIndex(renamed map[string]string, removed map[string]bool) {
+	if len(renamed) == 0 && len(removed) == 0 {
+		return
+	}
+	index, err := dl.loadIndex()
+	if err != nil || index == nil {
+		if errRebuild := dl.RebuildIndex(); errRebuild != nil {
+			log.WithError(errRebuild).Warn("failed to rebuild detailed request index")
+		}
+		return
+	}
+	kept := index[:0]
+	for _, e := range index {
+		if name, ok := renamed[e.Filename]; ok {
+			e.Filename = name
+		}
+		if removed[e.Filename] {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	if err := dl.saveIndex(kept); err != nil {
+		log.WithError(err).Warn("failed to prune detailed request index")
+	}
+}
+
+// compressOldFiles gzips every meta/bodies pair beyond the newest compressAfter
+// records and returns the new names of the compressed meta files, keyed by their
+// old names. Modification times are carried over so age-based ordering is unchanged.
+func (dl *DetailedRequestLogger) compressOldFiles() map[string]string {
+	dl.mu.Lock()
+	keep := dl.compressAfter
+	dl.mu.Unlock()
+	if keep <= 0 {
+		return nil
+	}
+
+	detailFiles, err := dl.listDetailFiles()
+	if err != nil || len(detailFiles) <= keep {
+		return nil
+	}
+	renamed := make(map[string]string)
+	for _, entry := range detailFiles[keep:] {
+		name := entry.Name()
+		if strings.HasSuffix(name, detailedGzipSuffix) {
+			continue
+		}
+		if err := gzipDetailFile(filepath.Join(dl.logsDir, name)); err != nil {
+			log.Warnf("failed to compress detail file %s: %v", name, err)
+			continue
+		}
+		renamed[name] = name + detailedGzipSuffix
+		bodiesPath := filepath.Join(dl.logsDir, bodiesFileFor(name))
+		if _, errStat := os.Stat(bodiesPath); errStat == nil {
+			if err := gzipDetailFile(bodiesPath); err != nil {
+				log.Warnf("failed to compress detail bodies file %s: %v", bodiesPath, err)
+			}
+		}
+	}
+	return renamed
+}
+
+// gzipDetailFile replaces path with a gzip-compressed path+".gz", preserving its mod time.
+func gzipDetailFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	gzPath := path + detailedGzipSuffix
+	if err := writeFileAtomic(gzPath, buf.Bytes()); err != nil {
+		return err
+	}
+	_ = os.Chtimes(gzPath, info.ModTime(), info.ModTime())
+	return os.Remove(path)
+}
+
+// readDetailFile reads a detail file, transparently decompressing .gz files.
+func (dl *DetailedRequestLogger) readDetailFile(filename string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(dl.logsDir, filename))
+	if err != nil || !strings.HasSuffix(filename, detailedGzipSuffix) {
+		return data, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// isTempFile checks if a filename is an in-progress write that must not be read.
+func isTempFile(name string) bool {
+	return strings.HasSuffix(name, detailedTempSuffix)
+}
+
+// isDetailFile checks if a filename belongs to the detail log, compressed or not.
+// In-progress temp files are excluded.
+func isDetailFile(name string) bool {
+	if isTempFile(name) {
+		return false
+	}
+	name = strings.TrimSuffix(name, detailedGzipSuffix)
+	return strings.HasPrefix(name, detailedFilePrefix) && strings.HasSuffix(name, detailedFileSuffix)
+}
+
+// bodiesFileFor returns the bodies companion filename for a meta filename.
+// A compressed meta file maps to a compressed companion.
+func bodiesFileFor(metaName string) string {
+	if strings.HasSuffix(metaName, detailedGzipSuffix) {
+		base := strings.TrimSuffix(metaName, detailedFileSuffix+detailedGzipSuffix)
+		return base + detailedBodiesSuffix + detailedGzipSuffix
+	}
+	return strings.TrimSuffix(metaName, detailedFileSuffix) + detailedBodiesSuffix
 }
 
 // isMetaFile checks if a filename is a completed meta file
-// (not a bodies companion or a pending placeholder).
+// (not a bodies companion, a pending placeholder or a temp file), compressed or not.
 func isMetaFile(name string) bool {
+	if isTempFile(name) {
+		return false
+	}
+	name = strings.TrimSuffix(name, detailedGzipSuffix)
 	return strings.HasPrefix(name, detailedFilePrefix) &&
 		strings.HasSuffix(name, detailedFileSuffix) &&
 		!strings.HasSuffix(name, detailedBodiesSuffix) &&
@@ -658,7 +1541,7 @@ func isMetaFile(name string) bool {
 
 // isPendingFile checks if a filename is a pending placeholder file.
 func isPendingFile(name string) bool {
-	return strings.HasPrefix(name, detailedFilePrefix) &&
+	return !isTempFile(name) && strings.HasPrefix(name, detailedFilePrefix) &&
 		strings.HasSuffix(name, detailedPendingSuffix)
 }
 
@@ -682,18 +1565,59 @@ func (dl *DetailedRequestLogger) listDetailFiles() ([]os.DirEntry, error) {
 		}
 	}
 
-	// Sort newest first by filename (timestamps in filenames ensure correct ordering)
-	sort.Slice(detailFiles, func(i, j int) bool {
-		// Use Info().ModTime for reliable sorting
-		infoI, errI := detailFiles[i].Info()
-		infoJ, errJ := detailFiles[j].Info()
-		if errI != nil || errJ != nil {
-			return detailFiles[i].Name() > detailFiles[j].Name()
+	sortDetailFilesNewestFirst(detailFiles)
+	return detailFiles, nil
+}
+
+// detailFilenameTimeRe matches the {ts} part of a detail filename.
+var detailFilenameTimeRe = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{6}`)
+
+// detailFilenameTime returns the request time embedded in a detail filename,
+// or false if it has none (e.g. a filename template without {ts}).
+func detailFilenameTime(name string) (time.Time, bool) {
+	match := detailFilenameTimeRe.FindString(name)
+	if match == "" {
+		return time.Time{}, false
+	}
+	ts, err := time.ParseInLocation("2006-01-02T150405", match, time.Local)
+	return ts, err == nil
+}
+
+// sortDetailFilesNewestFirst orders files by the request time in their names,
+// which unlike the modification time survives clock jumps and copies. Files
+// without one fall back to their modification time; ties within a second go by
+// modification time, then name.
+func sortDetailFilesNewestFirst(files []os.DirEntry) {
+	type keyed struct {
+		entry   os.DirEntry
+		ts      time.Time
+		modTime time.Time
+	}
+	keys := make([]keyed, len(files))
+	for i, f := range files {
+		k := keyed{entry: f}
+		if info, err := f.Info(); err == nil {
+			k.modTime = info.ModTime()
+		}
+		if ts, ok := detailFilenameTime(f.Name()); ok {
+			k.ts = ts
+		} else {
+			k.ts = k.modTime
+		}
+		keys[i] = k
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		if !keys[i].ts.Equal(keys[j].ts) {
+			return keys[i].ts.After(keys[j].ts)
 		}
-		return infoI.ModTime().After(infoJ.ModTime())
+		if !keys[i].modTime.Equal(keys[j].modTime) {
+			return keys[i].modTime.After(keys[j].modTime)
+		}
+		return keys[i].entry.Name() > keys[j].entry.Name()
 	})
-
-	return detailFiles, nil
+	for i, k := range keys {
+		files[i] = k.entry
+	}
 }
 
 // listPendingFiles returns all .pending.json files sorted newest first.
@@ -708,20 +1632,13 @@ func (dl *DetailedRequestLogger) listPendingFiles() []os.DirEntry {
 			pending = append(pending, entry)
 		}
 	}
-	sort.Slice(pending, func(i, j int) bool {
-		infoI, errI := pending[i].Info()
-		infoJ, errJ := pending[j].Info()
-		if errI != nil || errJ != nil {
-			return pending[i].Name() > pending[j].Name()
-		}
-		return infoI.ModTime().After(infoJ.ModTime())
-	})
+	sortDetailFilesNewestFirst(pending)
 	return pending
 }
 
 // readRecordFromFile reads and parses a single detail JSON file.
 func (dl *DetailedRequestLogger) readRecordFromFile(filename string) (*DetailedRequestRecord, error) {
-	data, err := os.ReadFile(filepath.Join(dl.logsDir, filename))
+	data, err := dl.readDetailFile(filename)
 	if err != nil {
 		return nil, err
 	}
@@ -732,56 +1649,148 @@ func (dl *DetailedRequestLogger) readRecordFromFile(filename string) (*DetailedR
 	return &record, nil
 }
 
-// ReadRecords reads full records (meta + bodies) from individual detail files,
-// applying optional filters. Returns records in reverse chronological order.
-func (dl *DetailedRequestLogger) ReadRecords(filter RecordFilter) ([]DetailedRequestRecord, int, []string, error) {
-	detailFiles, err := dl.listDetailFiles()
+// ReadRecords reads full records (meta + bodies) from the store, applying optional
+// filters. Returns records in reverse chronological order, the total before
+// pagination, the API keys seen, and the number of files that could not be read.
+func (dl *DetailedRequestLogger) ReadRecords(filter RecordFilter) ([]DetailedRequestRecord, int, []string, int, error) {
+	if dl.usesFileStore() {
+		return dl.readFileRecords(filter)
+	}
+	records, total, err := dl.store.Read(filter)
 	if err != nil {
-		return nil, 0, nil, fmt.Errorf("failed to list detail files: %w", err)
+		return nil, 0, nil, 0, err
 	}
-
-	var allRecords []DetailedRequestRecord
 	apiKeySet := make(map[string]struct{})
-
-	for _, entry := range detailFiles {
-		record, errRead := dl.readRecordFromFile(entry.Name())
-		if errRead != nil {
-			continue
-		}
-		// Try loading companion bodies file
-		bodiesName := strings.TrimSuffix(entry.Name(), detailedFileSuffix) + detailedBodiesSuffix
-		if bodies, errBodies := dl.readBodiesFromFile(bodiesName); errBodies == nil {
-			mergeBodies(record, bodies)
-		}
-		allRecords = append(allRecords, *record)
-		if record.APIKey != "" {
-			apiKeySet[record.APIKey] = struct{}{}
+	for i := range records {
+		if records[i].APIKey != "" {
+			apiKeySet[records[i].APIKey] = struct{}{}
 		}
 	}
+	apiKeys := make([]string, 0, len(apiKeySet))
+	for k := range apiKeySet {
+		apiKeys = append(apiKeys, k)
+	}
+	return records, total, apiKeys, 0, nil
+}
+
+// readFileRecords is the file store implementation of ReadRecords. Filtering
+// and pagination run on the index; only the files of the returned page are read.
+func (dl *DetailedRequestLogger) readFileRecords(filter RecordFilter) ([]DetailedRequestRecord, int, []string, int, error) {
+	index, err := dl.currentIndex(filter)
+	if err != nil {
+		return nil, 0, nil, 0, err
+	}
 
+	apiKeySet := make(map[string]struct{})
+	for _, e := range index {
+		if e.APIKey != "" {
+			apiKeySet[e.APIKey] = struct{}{}
+		}
+	}
 	apiKeys := make([]string, 0, len(apiKeySet))
 	for k := range apiKeySet {
 		apiKeys = append(apiKeys, k)
 	}
 
-	filtered := dl.applyFilters(allRecords, filter)
+	filtered := applyIndexFilters(index, filter)
 	total := len(filtered)
+	start := filter.Offset
+	if filter.HasCursor() {
+		start = filter.cursorStart(total,
+			func(i int) string { return filtered[i].ID },
+			func(i int) time.Time { return time.Unix(filtered[i].Timestamp, 0) })
+	}
+	if start > total {
+		start = total
+	}
+	page := filtered[start:]
+	if filter.Limit > 0 && len(page) > filter.Limit {
+		page = page[:filter.Limit]
+	}
 
-	if filter.Offset > 0 {
-		if filter.Offset >= len(filtered) {
-			filtered = []DetailedRequestRecord{}
-		} else {
-			filtered = filtered[filter.Offset:]
+	records := make([]DetailedRequestRecord, 0, len(page))
+	skipped := 0
+	for _, entry := range page {
+		record, errRead := dl.readRecordFromFile(entry.Filename)
+		if errRead != nil {
+			skipped++
+			continue
+		}
+		if bodies, errBodies := dl.readBodiesFromFile(bodiesFileFor(entry.Filename)); errBodies == nil {
+			mergeBodies(record, bodies)
 		}
+		records = append(records, *record)
 	}
-	if filter.Limit > 0 && len(filtered) > filter.Limit {
-		filtered = filtered[:filter.Limit]
+	return records, total, apiKeys, skipped, nil
+}
+
+// StreamRecords writes every record matching filter to w as newline-delimited JSON,
+// newest first. With the file store, files are read and encoded one at a time so
+// memory use stays flat regardless of history size. Offset and Limit are ignored;
+// unreadable files are skipped.
+func (dl *DetailedRequestLogger) StreamRecords(filter RecordFilter, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	return dl.eachMatchingRecord(filter, true, func(record *DetailedRequestRecord) error {
+		if err := enc.Encode(record); err != nil {
+			return fmt.Errorf("failed to write record %s: %w", record.ID, err)
+		}
+		return nil
+	})
+}
+
+// eachMatchingRecord calls fn for every completed record matching filter, newest
+// first, ignoring pagination. Bodies are merged in only when withBodies is set
+// (other stores always return them). Iteration stops at the first error from fn.
+func (dl *DetailedRequestLogger) eachMatchingRecord(filter RecordFilter, withBodies bool, fn func(*DetailedRequestRecord) error) error {
+	if !dl.usesFileStore() {
+		filter.Offset, filter.Limit = 0, 0
+		if it, ok := dl.store.(RecordIterator); ok && !filter.HasCursor() {
+			return it.Each(filter, fn)
+		}
+		records, _, err := dl.store.Read(filter)
+		if err != nil {
+			return err
+		}
+		for i := range records {
+			if err := fn(&records[i]); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
-	return filtered, total, apiKeys, nil
+	detailFiles, err := dl.listDetailFiles()
+	if err != nil {
+		return fmt.Errorf("failed to list detail files: %w", err)
+	}
+	for _, entry := range detailFiles {
+		record, errRead := dl.readRecordFromFile(entry.Name())
+		if errRead != nil {
+			continue
+		}
+		if !matchRecordFilter(record, filter) {
+			continue
+		}
+		if withBodies {
+			bodiesName := bodiesFileFor(entry.Name())
+			if bodies, errBodies := dl.readBodiesFromFile(bodiesName); errBodies == nil {
+				mergeBodies(record, bodies)
+			}
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// loadIndex reads the index file and returns all entries (newest first).
+// errIndexDamaged reports an index line that could not be parsed, e.g. one cut
+// short by a crash mid-append.
+var errIndexDamaged = errors.New("detailed request index is damaged")
+
+// loadIndex reads the index file and returns all entries (newest first). If a
+// record appears more than once, its last line wins. A missing index returns
+// nil; a damaged one returns the readable entries with errIndexDamaged.
 func (dl *DetailedRequestLogger) loadIndex() ([]IndexEntry, error) {
 	data, err := os.ReadFile(filepath.Join(dl.logsDir, indexFileName))
 	if err != nil {
@@ -790,49 +1799,126 @@ func (dl *DetailedRequestLogger) loadIndex() ([]IndexEntry, error) {
 		}
 		return nil, err
 	}
-	if len(data) == 0 {
-		return nil, nil
+	var lines []IndexEntry
+	var damaged bool
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var e IndexEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			damaged = true
+			continue
+		}
+		lines = append(lines, e)
+	}
+	entries := make([]IndexEntry, 0, len(lines))
+	seen := make(map[string]bool, len(lines))
+	for i := len(lines) - 1; i >= 0; i-- {
+		if seen[lines[i].ID] {
+			continue
+		}
+		seen[lines[i].ID] = true
+		entries = append(entries, lines[i])
 	}
-	var entries []IndexEntry
-	if err := json.Unmarshal(data, &entries); err != nil {
-		return nil, err
+	if damaged {
+		return entries, errIndexDamaged
 	}
 	return entries, nil
 }
 
-// saveIndex writes the full index to disk.
+// currentIndex returns the index for answering filter, rebuilding it from the
+// files on disk when it is missing or damaged, or predates a field filter needs.
+func (dl *DetailedRequestLogger) currentIndex(filter RecordFilter) ([]IndexEntry, error) {
+	index, err := dl.loadIndex()
+	if err != nil || index == nil ||
+		(filter.URLPattern != "" && indexMissingURLs(index)) || (filter.MinDurationMs > 0 && indexMissingDurations(index)) {
+		if rebuildErr := dl.RebuildIndex(); rebuildErr != nil {
+			return nil, fmt.Errorf("index rebuild failed: %w", rebuildErr)
+		}
+		index, _ = dl.loadIndex()
+	}
+	if index == nil {
+		index = []IndexEntry{}
+	}
+	return index, nil
+}
+
+// saveIndex replaces the index with entries, given newest first.
 func (dl *DetailedRequestLogger) saveIndex(entries []IndexEntry) error {
-	if err := os.MkdirAll(dl.logsDir, 0755); err != nil {
-		return err
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := len(entries) - 1; i >= 0; i-- {
+		if err := enc.Encode(entries[i]); err != nil {
+			return err
+		}
+	}
+	return dl.writeLogsFile(filepath.Join(dl.logsDir, indexFileName), buf.Bytes())
+}
+
+// appendToIndex appends entries, given in write order, to the index. Without
+// an index it is first built from the other files on disk; the new records are
+// left out of that, as modification times can tie within a batch, and appended.
+func (dl *DetailedRequestLogger) appendToIndex(added ...IndexEntry) {
+	path := filepath.Join(dl.logsDir, indexFileName)
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		skip := make(map[string]bool, len(added))
+		for _, e := range added {
+			skip[e.ID] = true
+		}
+		if err := dl.rebuildIndexExcept(skip); err != nil {
+			log.WithError(err).Warn("failed to build detailed request index")
+			return
+		}
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range added {
+		if err := enc.Encode(e); err != nil {
+			log.WithError(err).Warn("failed to encode detailed request index entry")
+			return
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err == nil {
+		_, err = f.Write(buf.Bytes())
+		if errClose := f.Close(); err == nil {
+			err = errClose
+		}
 	}
-	data, err := json.Marshal(entries)
 	if err != nil {
-		return err
+		log.WithError(err).Warn("failed to update detailed request index")
 	}
-	return os.WriteFile(filepath.Join(dl.logsDir, indexFileName), data, 0644)
 }
 
-// appendToIndex adds a new record entry to the front of the index (newest first).
-func (dl *DetailedRequestLogger) appendToIndex(record *DetailedRequestRecord, filename string) {
-	entries, _ := dl.loadIndex()
-	entry := IndexEntry{
-		ID:          record.ID,
-		Filename:    filename,
-		APIKey:      record.APIKey,
-		APIKeyHash:  record.APIKeyHash,
-		StatusCode:  record.StatusCode,
-		IsSimulated: record.IsSimulated,
-		Timestamp:   record.Timestamp.Unix(),
-		Model:       record.Model,
+// indexMissingURLs reports whether any entry predates URL indexing.
+func indexMissingURLs(entries []IndexEntry) bool {
+	for _, e := range entries {
+		if e.URL == "" {
+			return true
+		}
 	}
-	entries = append([]IndexEntry{entry}, entries...)
-	if err := dl.saveIndex(entries); err != nil {
-		log.WithError(err).Warn("failed to update detailed request index")
+	return false
+}
+
+// indexMissingDurations reports whether any entry predates duration indexing.
+func indexMissingDurations(entries []IndexEntry) bool {
+	for _, e := range entries {
+		if e.DurationMs == nil {
+			return true
+		}
 	}
+	return false
 }
 
 // RebuildIndex rebuilds the index from meta files on disk.
 func (dl *DetailedRequestLogger) RebuildIndex() error {
+	return dl.rebuildIndexExcept(nil)
+}
+
+// rebuildIndexExcept rebuilds the index from meta files on disk, leaving out
+// the records whose IDs are in skip.
+func (dl *DetailedRequestLogger) rebuildIndexExcept(skip map[string]bool) error {
 	detailFiles, err := dl.listDetailFiles()
 	if err != nil {
 		return err
@@ -840,21 +1926,16 @@ func (dl *DetailedRequestLogger) RebuildIndex() error {
 	entries := make([]IndexEntry, 0, len(detailFiles))
 	for _, f := range detailFiles {
 		record, errRead := dl.readRecordFromFile(f.Name())
-		if errRead != nil {
+		if errRead != nil || skip[record.ID] {
 			continue
 		}
-		entries = append(entries, IndexEntry{
-			ID:          record.ID,
-			Filename:    f.Name(),
-			APIKey:      record.APIKey,
-			APIKeyHash:  record.APIKeyHash,
-			StatusCode:  record.StatusCode,
-			IsSimulated: record.IsSimulated,
-			Timestamp:   record.Timestamp.Unix(),
-			Model:       record.Model,
-		})
+		entries = append(entries, newIndexEntry(record, f.Name()))
 	}
-	return dl.saveIndex(entries)
+	if err := dl.saveIndex(entries); err != nil {
+		return err
+	}
+	_ = os.Remove(filepath.Join(dl.logsDir, legacyIndexFileName))
+	return nil
 }
 
 // applyIndexFilters filters index entries based on the given criteria.
@@ -877,26 +1958,50 @@ func applyIndexFilters(entries []IndexEntry, filter RecordFilter) []IndexEntry {
 		if !filter.Before.IsZero() && ts.After(filter.Before) {
 			continue
 		}
+		if filter.HasToolCalls && !e.HasToolCalls {
+			continue
+		}
+		if filter.HasError && !e.HasError {
+			continue
+		}
+		if filter.ExcludeCanceled && e.Canceled {
+			continue
+		}
+		if !matchModelPrefix(e.Model, filter.ModelPrefix) {
+			continue
+		}
+		if filter.MinDurationMs > 0 && (e.DurationMs == nil || *e.DurationMs < filter.MinDurationMs) {
+			continue
+		}
+		if !filter.matchURL(e.URL) {
+			continue
+		}
+		if filter.RouteID != "" && e.RouteID != filter.RouteID {
+			continue
+		}
+		if filter.Tag != "" && !hasTag(e.Tags, filter.Tag) {
+			continue
+		}
 		filtered = append(filtered, e)
 	}
 	return filtered
 }
 
 // ReadRecordSummaries returns paginated summaries using the index file.
+// Pages are selected by the filter's cursor when set, otherwise by Offset; the
+// total always counts every match.
 // Records with IDs in knownIDs are returned as cached stubs ({id, cached: true})
 // instead of reading the meta file from disk.
 // Pending (in-flight) records are prepended before completed records and
 // deduplicated: if a completed version exists, the pending file is skipped.
-func (dl *DetailedRequestLogger) ReadRecordSummaries(filter RecordFilter, knownIDs map[string]bool) ([]any, int, error) {
-	index, err := dl.loadIndex()
-	if err != nil || index == nil {
-		if rebuildErr := dl.RebuildIndex(); rebuildErr != nil {
-			return nil, 0, fmt.Errorf("index rebuild failed: %w", rebuildErr)
-		}
-		index, _ = dl.loadIndex()
-		if index == nil {
-			index = []IndexEntry{}
-		}
+// The third return value counts files that were listed but could not be read.
+func (dl *DetailedRequestLogger) ReadRecordSummaries(filter RecordFilter, knownIDs map[string]bool) ([]any, int, int, error) {
+	if !dl.usesFileStore() {
+		return dl.readStoreSummaries(filter, knownIDs)
+	}
+	index, err := dl.currentIndex(filter)
+	if err != nil {
+		return nil, 0, 0, err
 	}
 
 	// Build set of completed IDs for deduplication.
@@ -905,18 +2010,32 @@ func (dl *DetailedRequestLogger) ReadRecordSummaries(filter RecordFilter, knownI
 		completedIDs[e.ID] = true
 	}
 
-	// Scan pending files (typically 0–5 in-flight requests).
-	pendingFiles := dl.listPendingFiles()
-	var pendingSummaries []DetailedRequestSummary
+	summarize := func(rec *DetailedRequestRecord) any {
+		if filter.Compact {
+			return rec.ToCompact()
+		}
+		return rec.ToSummary()
+	}
+
+	skipped := 0
+
+	// Scan pending files (typically 0–5 in-flight requests). They sit above the
+	// newest completed record, so cursor pages never include them.
+	var pendingFiles []os.DirEntry
+	if !filter.HasCursor() {
+		pendingFiles = dl.listPendingFiles()
+	}
+	var pendingSummaries []any
 	for _, pf := range pendingFiles {
 		rec, errRead := dl.readRecordFromFile(pf.Name())
 		if errRead != nil {
+			skipped++
 			continue
 		}
 		if completedIDs[rec.ID] {
 			continue
 		}
-		pendingSummaries = append(pendingSummaries, rec.ToSummary())
+		pendingSummaries = append(pendingSummaries, summarize(rec))
 	}
 
 	filteredIndex := applyIndexFilters(index, filter)
@@ -926,6 +2045,11 @@ func (dl *DetailedRequestLogger) ReadRecordSummaries(filter RecordFilter, knownI
 
 	// Paginate across the virtual list: [pending...] + [completed...]
 	offset := filter.Offset
+	if filter.HasCursor() {
+		offset = filter.cursorStart(completedCount,
+			func(i int) string { return filteredIndex[i].ID },
+			func(i int) time.Time { return time.Unix(filteredIndex[i].Timestamp, 0) })
+	}
 	limit := filter.Limit
 	if limit <= 0 {
 		limit = total
@@ -956,18 +2080,72 @@ func (dl *DetailedRequestLogger) ReadRecordSummaries(filter RecordFilter, knownI
 		} else {
 			record, errRead := dl.readRecordFromFile(entry.Filename)
 			if errRead != nil {
+				skipped++
 				continue
 			}
-			results = append(results, record.ToSummary())
+			results = append(results, summarize(record))
 		}
 	}
 
-	return results, total, nil
+	return results, total, skipped, nil
+}
+
+// readStoreSummaries implements ReadRecordSummaries for stores other than the file
+// store. The store paginates completed records itself; pending placeholders are
+// prepended to the first page and counted in the total.
+func (dl *DetailedRequestLogger) readStoreSummaries(filter RecordFilter, knownIDs map[string]bool) ([]any, int, int, error) {
+	summarize := func(rec *DetailedRequestRecord) any {
+		if filter.Compact {
+			return rec.ToCompact()
+		}
+		return rec.ToSummary()
+	}
+
+	records, total, err := dl.store.Read(filter)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	completedIDs := make(map[string]bool, len(records))
+	for i := range records {
+		completedIDs[records[i].ID] = true
+	}
+
+	skipped := 0
+	var results []any
+	for _, pf := range dl.listPendingFiles() {
+		rec, errRead := dl.readRecordFromFile(pf.Name())
+		if errRead != nil {
+			skipped++
+			continue
+		}
+		if completedIDs[rec.ID] {
+			continue
+		}
+		if existing, _ := dl.store.ReadByID(rec.ID); existing != nil {
+			continue
+		}
+		total++
+		if filter.Offset == 0 && !filter.HasCursor() {
+			results = append(results, summarize(rec))
+		}
+	}
+
+	for i := range records {
+		if len(knownIDs) > 0 && knownIDs[records[i].ID] {
+			results = append(results, map[string]any{"id": records[i].ID, "cached": true})
+		} else {
+			results = append(results, summarize(&records[i]))
+		}
+	}
+	if results == nil {
+		results = []any{}
+	}
+	return results, total, skipped, nil
 }
 
 // readBodiesFromFile reads and parses a bodies companion file.
 func (dl *DetailedRequestLogger) readBodiesFromFile(filename string) (*DetailedRecordBodies, error) {
-	data, err := os.ReadFile(filepath.Join(dl.logsDir, filename))
+	data, err := dl.readDetailFile(filename)
 	if err != nil {
 		return nil, err
 	}
@@ -979,8 +2157,84 @@ func (dl *DetailedRequestLogger) readBodiesFromFile(filename string) (*DetailedR
 }
 
 // ReadRecordByID reads a single full record (meta + bodies) by its ID.
-// Completed meta files are preferred; pending files are used as fallback.
+// Completed records are preferred; pending files are used as fallback.
 func (dl *DetailedRequestLogger) ReadRecordByID(id string) (*DetailedRequestRecord, error) {
+	record, err := dl.store.ReadByID(id)
+	if err != nil || record != nil || dl.usesFileStore() {
+		return record, err
+	}
+	for _, pf := range dl.listPendingFiles() {
+		if !strings.Contains(pf.Name(), id) {
+			continue
+		}
+		if pending, errRead := dl.readRecordFromFile(pf.Name()); errRead == nil && pending.ID == id {
+			return pending, nil
+		}
+	}
+	return nil, nil
+}
+
+// ReadRawRecord returns a record as stored, for download. With the file store
+// this is the meta file, or its bodies companion when bodies is set,
+// decompressed; in-flight records return their pending placeholder. Records in
+// other stores are re-encoded as indented JSON. The returned filename is the
+// suggested download name; a nil result means no record has the ID.
+func (dl *DetailedRequestLogger) ReadRawRecord(id string, bodies bool) (string, []byte, error) {
+	if dl.usesFileStore() {
+		if name := dl.detailFilenameForID(id, isMetaFile); name != "" {
+			if bodies {
+				name = bodiesFileFor(name)
+			}
+			data, err := dl.readDetailFile(name)
+			if err != nil {
+				return "", nil, err
+			}
+			return strings.TrimSuffix(name, detailedGzipSuffix), data, nil
+		}
+	} else if record, err := dl.store.ReadByID(id); err != nil {
+		return "", nil, err
+	} else if record != nil {
+		name := dl.generateDetailFilename(record)
+		if bodies {
+			_, recordBodies := stripBodies(record)
+			data, err := json.MarshalIndent(recordBodies, "", "  ")
+			return bodiesFileFor(name), append(data, '\n'), err
+		}
+		data, err := json.MarshalIndent(record, "", "  ")
+		return name, append(data, '\n'), err
+	}
+
+	if name := dl.detailFilenameForID(id, isPendingFile); name != "" {
+		data, err := dl.readDetailFile(name)
+		if err != nil {
+			return "", nil, err
+		}
+		return name, data, nil
+	}
+	return "", nil, nil
+}
+
+// detailFilenameForID returns the file in the logs directory accepted by match
+// that holds the record with id, or "" if there is none.
+func (dl *DetailedRequestLogger) detailFilenameForID(id string, match func(string) bool) string {
+	entries, err := os.ReadDir(dl.logsDir)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !match(name) || !strings.Contains(name, id) {
+			continue
+		}
+		if record, errRead := dl.readRecordFromFile(name); errRead == nil && record.ID == id {
+			return name
+		}
+	}
+	return ""
+}
+
+// readFileRecordByID is the file store implementation of ReadRecordByID.
+func (dl *DetailedRequestLogger) readFileRecordByID(id string) (*DetailedRequestRecord, error) {
 	entries, err := os.ReadDir(dl.logsDir)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -1003,7 +2257,7 @@ func (dl *DetailedRequestLogger) ReadRecordByID(id string) (*DetailedRequestReco
 			if errRead != nil || record.ID != id {
 				continue
 			}
-			bodiesName := strings.TrimSuffix(name, detailedFileSuffix) + detailedBodiesSuffix
+			bodiesName := bodiesFileFor(name)
 			if bodies, errBodies := dl.readBodiesFromFile(bodiesName); errBodies == nil {
 				mergeBodies(record, bodies)
 			}
@@ -1024,8 +2278,21 @@ func (dl *DetailedRequestLogger) ReadRecordByID(id string) (*DetailedRequestReco
 	return nil, nil
 }
 
-// DeleteAll removes all detail log files (meta + bodies) and the legacy JSONL file.
+// DeleteAll removes every stored record, along with any detail files
+// (pending placeholders included) and the legacy JSONL file.
 func (dl *DetailedRequestLogger) DeleteAll() error {
+	storeErr := dl.store.DeleteAll()
+	if dl.usesFileStore() {
+		return storeErr
+	}
+	if err := dl.deleteAllFiles(); err != nil {
+		return err
+	}
+	return storeErr
+}
+
+// deleteAllFiles removes all detail log files (meta + bodies) and the legacy JSONL file.
+func (dl *DetailedRequestLogger) deleteAllFiles() error {
 	os.Remove(filepath.Join(dl.logsDir, indexFileName))
 
 	entries, err := os.ReadDir(dl.logsDir)
@@ -1042,9 +2309,7 @@ func (dl *DetailedRequestLogger) DeleteAll() error {
 			continue
 		}
 		name := entry.Name()
-		isDetailFile := strings.HasPrefix(name, detailedFilePrefix) && strings.HasSuffix(name, detailedFileSuffix)
-		isLegacy := name == legacyDetailedLogFileName
-		if isDetailFile || isLegacy {
+		if isDetailFile(name) || name == legacyDetailedLogFileName {
 			if errRm := os.Remove(filepath.Join(dl.logsDir, name)); errRm != nil {
 				lastErr = errRm
 			}
@@ -1054,8 +2319,13 @@ func (dl *DetailedRequestLogger) DeleteAll() error {
 	return lastErr
 }
 
-// GetStats returns size information about all detail log files (meta + bodies).
+// GetStats returns the total stored size in bytes and the number of completed records.
 func (dl *DetailedRequestLogger) GetStats() (int64, int, error) {
+	return dl.store.Stats()
+}
+
+// fileStats returns size information about all detail log files (meta + bodies).
+func (dl *DetailedRequestLogger) fileStats() (int64, int, error) {
 	entries, err := os.ReadDir(dl.logsDir)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -1072,7 +2342,7 @@ func (dl *DetailedRequestLogger) GetStats() (int64, int, error) {
 			continue
 		}
 		name := entry.Name()
-		if !strings.HasPrefix(name, detailedFilePrefix) || !strings.HasSuffix(name, detailedFileSuffix) {
+		if !isDetailFile(name) {
 			continue
 		}
 		info, errInfo := entry.Info()
@@ -1088,53 +2358,327 @@ func (dl *DetailedRequestLogger) GetStats() (int64, int, error) {
 	return totalSize, count, nil
 }
 
+// StreamingStats aggregates streamed output across all indexed records.
+type StreamingStats struct {
+	StreamingRecords int   `json:"streaming_records"`
+	TotalBytes       int64 `json:"total_bytes"`
+	TotalChunks      int64 `json:"total_chunks"`
+	MaxBytes         int64 `json:"max_bytes"`
+}
+
+// GetStreamingStats sums the streamed byte and chunk counts recorded in the index
+// (or in the stored records, for stores other than the file store).
+func (dl *DetailedRequestLogger) GetStreamingStats() (*StreamingStats, error) {
+	var index []IndexEntry
+	if dl.usesFileStore() {
+		var err error
+		if index, err = dl.loadIndex(); err != nil {
+			return nil, err
+		}
+	} else {
+		errEach := dl.eachMatchingRecord(RecordFilter{IncludeSimulated: true}, false, func(r *DetailedRequestRecord) error {
+			index = append(index, IndexEntry{StreamedBytes: r.StreamedBytes, StreamChunks: r.StreamChunks})
+			return nil
+		})
+		if errEach != nil {
+			return nil, errEach
+		}
+	}
+	stats := &StreamingStats{}
+	for _, e := range index {
+		if e.StreamedBytes == 0 && e.StreamChunks == 0 {
+			continue
+		}
+		stats.StreamingRecords++
+		stats.TotalBytes += e.StreamedBytes
+		stats.TotalChunks += int64(e.StreamChunks)
+		if e.StreamedBytes > stats.MaxBytes {
+			stats.MaxBytes = e.StreamedBytes
+		}
+	}
+	return stats, nil
+}
+
+// ModelStats aggregates detailed records for a single model.
+type ModelStats struct {
+	Model         string  `json:"model"`
+	Count         int     `json:"count"`
+	SuccessCount  int     `json:"success_count"`
+	ErrorCount    int     `json:"error_count"`
+	AvgDurationMs float64 `json:"avg_duration_ms"`
+	P50DurationMs int64   `json:"p50_duration_ms"`
+	P95DurationMs int64   `json:"p95_duration_ms"`
+}
+
+// DetailedStats is the per-model aggregation returned by GetDetailedStats.
+type DetailedStats struct {
+	Total  int           `json:"total"`
+	Models []*ModelStats `json:"models"`
+}
+
+// unknownModelBucket groups records without a model name.
+const unknownModelBucket = "unknown"
+
+// GetDetailedStats aggregates call volume, error counts and duration percentiles
+// per model over all records matching filter. Pagination fields are ignored.
+// Models are sorted by descending count.
+func (dl *DetailedRequestLogger) GetDetailedStats(filter RecordFilter) (*DetailedStats, error) {
+	byModel := make(map[string]*ModelStats)
+	durations := make(map[string][]int64)
+	stats := &DetailedStats{Models: []*ModelStats{}}
+	err := dl.eachMatchingRecord(filter, false, func(record *DetailedRequestRecord) error {
+		model := record.Model
+		if model == "" {
+			model = unknownModelBucket
+		}
+		ms, ok := byModel[model]
+		if !ok {
+			ms = &ModelStats{Model: model}
+			byModel[model] = ms
+			stats.Models = append(stats.Models, ms)
+		}
+		ms.Count++
+		if record.HasError || record.Error != "" || record.StatusCode >= 400 {
+			ms.ErrorCount++
+		} else {
+			ms.SuccessCount++
+		}
+		durations[model] = append(durations[model], record.TotalDurationMs)
+		stats.Total++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ms := range stats.Models {
+		d := durations[ms.Model]
+		sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+		var sum int64
+		for _, v := range d {
+			sum += v
+		}
+		ms.AvgDurationMs = float64(sum) / float64(len(d))
+		ms.P50DurationMs = percentile(d, 50)
+		ms.P95DurationMs = percentile(d, 95)
+	}
+	sort.SliceStable(stats.Models, func(i, j int) bool {
+		return stats.Models[i].Count > stats.Models[j].Count
+	})
+	return stats, nil
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted values.
+func percentile(sorted []int64, p int) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100 // ceil(p/100 * n)
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
 
 // RecordFilter defines the criteria for filtering detailed request records.
 type RecordFilter struct {
 	APIKeyHash       string
-	StatusCode       string // e.g. "200", "4xx", "5xx"
+	StatusCode       string // e.g. "200", "4xx", "*", ">=400", or a comma-separated list "429,500,503"
 	After            time.Time
 	Before           time.Time
 	Offset           int
 	Limit            int
-	IncludeSimulated bool // when false (default), simulated records are excluded
+	IncludeSimulated bool   // when false (default), simulated records are excluded
+	Compact          bool   // when true, ReadRecordSummaries returns DetailedRequestCompact rows
+	HasToolCalls     bool   // when true, only records with tools declared or tool calls returned
+	HasError         bool   // when true, only failed records
+	ExcludeCanceled  bool   // when true, records the client abandoned are left out
+	ModelPrefix      string // case-insensitive model name prefix, e.g. "gpt-4", or a whole-name pattern with * wildcards, e.g. "gemini-*-pro"
+	MinDurationMs    int64  // only records that took at least this long
+	URLPattern       string // regular expression matched against the request URL, e.g. "^/v1/messages"
+	Tag              string // only records carrying this tag
+	RouteID          string // only records served through this unified routing route
+
+	// Cursor paging (see HasCursor); when set, Offset is ignored.
+	BeforeID string    // only records listed after this ID
+	BeforeTS time.Time // only records older than this; used when BeforeID is unset or gone
+
+	urlRe *regexp.Regexp // compiled URLPattern, set by Compile
 }
 
-// applyFilters filters records based on the given criteria.
-func (dl *DetailedRequestLogger) applyFilters(records []DetailedRequestRecord, filter RecordFilter) []DetailedRequestRecord {
-	filtered := make([]DetailedRequestRecord, 0, len(records))
-	for _, r := range records {
-		if r.IsSimulated && !filter.IncludeSimulated {
-			continue
-		}
-		if filter.APIKeyHash != "" && r.APIKeyHash != filter.APIKeyHash && r.APIKey != filter.APIKeyHash {
-			continue
+// HasCursor reports whether the filter pages by cursor (BeforeID/BeforeTS)
+// instead of Offset.
+func (f *RecordFilter) HasCursor() bool {
+	return f.BeforeID != "" || !f.BeforeTS.IsZero()
+}
+
+// cursorStart returns the position just past the filter's cursor in a list of n
+// records ordered newest first, where idAt and tsAt describe the i-th record.
+// BeforeID is located first; if it is absent (e.g. removed by retention) the
+// first record older than BeforeTS is used, and with neither there is nothing left.
+func (f *RecordFilter) cursorStart(n int, idAt func(int) string, tsAt func(int) time.Time) int {
+	if f.BeforeID != "" {
+		for i := 0; i < n; i++ {
+			if idAt(i) == f.BeforeID {
+				return i + 1
+			}
 		}
-		if !matchStatusCode(r.StatusCode, filter.StatusCode) {
-			continue
+	}
+	if !f.BeforeTS.IsZero() {
+		for i := 0; i < n; i++ {
+			if tsAt(i).Before(f.BeforeTS) {
+				return i
+			}
 		}
-		if !filter.After.IsZero() && r.Timestamp.Before(filter.After) {
-			continue
+	}
+	return n
+}
+
+// Compile validates and compiles URLPattern so matching does not recompile it
+// per record. Callers that accept user input should call it and report the error.
+func (f *RecordFilter) Compile() error {
+	f.urlRe = nil
+	if f.URLPattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile(f.URLPattern)
+	if err != nil {
+		return fmt.Errorf("invalid url pattern: %w", err)
+	}
+	f.urlRe = re
+	return nil
+}
+
+// matchURL reports whether url matches URLPattern. An empty pattern matches
+// everything; an uncompilable pattern matches nothing.
+func (f *RecordFilter) matchURL(url string) bool {
+	if f.URLPattern == "" {
+		return true
+	}
+	re := f.urlRe
+	if re == nil {
+		var err error
+		if re, err = regexp.Compile(f.URLPattern); err != nil {
+			return false
 		}
-		if !filter.Before.IsZero() && r.Timestamp.After(filter.Before) {
-			continue
+	}
+	return re.MatchString(url)
+}
+
+// matchRecordFilter reports whether a single record satisfies the filter criteria.
+// Pagination fields are ignored.
+func matchRecordFilter(r *DetailedRequestRecord, filter RecordFilter) bool {
+	if r.IsSimulated && !filter.IncludeSimulated {
+		return false
+	}
+	if filter.APIKeyHash != "" && r.APIKeyHash != filter.APIKeyHash && r.APIKey != filter.APIKeyHash {
+		return false
+	}
+	if !matchStatusCode(r.StatusCode, filter.StatusCode) {
+		return false
+	}
+	if !filter.After.IsZero() && r.Timestamp.Before(filter.After) {
+		return false
+	}
+	if !filter.Before.IsZero() && r.Timestamp.After(filter.Before) {
+		return false
+	}
+	if filter.HasToolCalls && !r.HasToolCalls {
+		return false
+	}
+	if filter.HasError && !r.HasError {
+		return false
+	}
+	if filter.ExcludeCanceled && r.ClientCanceled {
+		return false
+	}
+	if !matchModelPrefix(r.Model, filter.ModelPrefix) {
+		return false
+	}
+	if filter.MinDurationMs > 0 && r.TotalDurationMs < filter.MinDurationMs {
+		return false
+	}
+	if !filter.matchURL(r.URL) {
+		return false
+	}
+	if filter.RouteID != "" && r.RouteID != filter.RouteID {
+		return false
+	}
+	if filter.Tag != "" && !hasTag(r.Tags, filter.Tag) {
+		return false
+	}
+	return true
+}
+
+// matchModelPrefix checks whether model starts with prefix, ignoring case.
+// A prefix containing * is instead matched against the whole model name, each
+// * matching any run of characters. An empty prefix matches everything.
+func matchModelPrefix(model, prefix string) bool {
+	if prefix == "" {
+		return true
+	}
+	model, prefix = strings.ToLower(model), strings.ToLower(prefix)
+	if !strings.Contains(prefix, "*") {
+		return strings.HasPrefix(model, prefix)
+	}
+	parts := strings.Split(prefix, "*")
+	if !strings.HasPrefix(model, parts[0]) {
+		return false
+	}
+	model = model[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(model, part)
+		if i < 0 {
+			return false
 		}
-		filtered = append(filtered, r)
+		model = model[i+len(part):]
 	}
-	return filtered
+	return len(model) >= len(last) && strings.HasSuffix(model, last)
 }
 
 // matchStatusCode checks if a status code matches the filter pattern.
-// Supports exact match (e.g. "200") and class match (e.g. "2xx", "4xx", "5xx").
+// Supports:
+//   - wildcard: "*" or "xxx" matches any code
+//   - exact match: "200"
+//   - class match: "2xx", "4xx", "5xx"
+//   - comparators: ">=400", "<500", ">399", "<=499", "=200", "!=200"
+//   - comma-separated lists of the above: "429,500,503", "429,5xx"
 func matchStatusCode(code int, pattern string) bool {
 	if pattern == "" {
 		return true
 	}
+	if strings.Contains(pattern, ",") {
+		for _, part := range strings.Split(pattern, ",") {
+			if strings.TrimSpace(part) != "" && matchStatusCode(code, part) {
+				return true
+			}
+		}
+		return false
+	}
 	pattern = strings.ToLower(strings.TrimSpace(pattern))
-	if pattern == "" {
+	if pattern == "" || pattern == "*" || pattern == "xxx" {
 		return true
 	}
 
+	// Comparator match: ">=400", "<500", ...
+	if op, value, ok := parseStatusComparator(pattern); ok {
+		switch op {
+		case ">=":
+			return code >= value
+		case "<=":
+			return code <= value
+		case ">":
+			return code > value
+		case "<":
+			return code < value
+		case "!=":
+			return code != value
+		default:
+			return code == value
+		}
+	}
+
 	// Class match: "2xx", "4xx", "5xx"
 	if len(pattern) == 3 && pattern[1] == 'x' && pattern[2] == 'x' {
 		classDigit := pattern[0]
@@ -1146,6 +2690,22 @@ func matchStatusCode(code int, pattern string) bool {
 	return fmt.Sprintf("%d", code) == pattern
 }
 
+// parseStatusComparator splits a comparator pattern such as ">=400" into its
+// operator and numeric operand. ok is false when the pattern has no operator
+// or the operand is not a number.
+func parseStatusComparator(pattern string) (op string, value int, ok bool) {
+	for _, candidate := range []string{">=", "<=", "!=", ">", "<", "="} {
+		if strings.HasPrefix(pattern, candidate) {
+			n, err := strconv.Atoi(strings.TrimSpace(pattern[len(candidate):]))
+			if err != nil {
+				return "", 0, false
+			}
+			return candidate, n, true
+		}
+	}
+	return "", 0, false
+}
+
 // MaskAPIKey returns a masked version of the API key for display.
 // Shows first 4 and last 4 characters with dots in between.
 func MaskAPIKey(key string) string {
@@ -0,0 +1,831 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchStatusCode(t *testing.T) {
+	tests := []struct {
+		code    int
+		pattern string
+		want    bool
+	}{
+		{200, "", true},
+		{200, "200", true},
+		{201, "200", false},
+		{404, "4xx", true},
+		{500, "4xx", false},
+		{503, "5XX", true},
+		{200, "*", true},
+		{599, "xxx", true},
+		{400, ">=400", true},
+		{399, ">=400", false},
+		{499, "<500", true},
+		{500, "<500", false},
+		{401, ">400", true},
+		{400, ">400", false},
+		{499, "<=499", true},
+		{500, "<=499", false},
+		{200, "=200", true},
+		{201, "=200", false},
+		{201, "!=200", true},
+		{200, "!=200", false},
+		{400, " >= 400 ", true},
+		{400, ">=abc", false},
+		{500, "429,500,503", true},
+		{502, "429,500,503", false},
+		{503, "429, 5xx", true},
+		{200, "429,", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchStatusCode(tt.code, tt.pattern); got != tt.want {
+			t.Fatalf("matchStatusCode(%d, %q) = %t, want %t", tt.code, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestMatchModelPrefix(t *testing.T) {
+	tests := []struct {
+		model   string
+		pattern string
+		want    bool
+	}{
+		{"gemini-2.5-pro", "", true},
+		{"gemini-2.5-pro", "Gemini", true},
+		{"gemini-2.5-pro", "gemini-*", true},
+		{"gemini-2.5-pro", "gemini-*-pro", true},
+		{"gemini-2.5-pro-preview", "gemini-*-pro", false},
+		{"gemini-2.5-pro", "*pro", true},
+		{"gemini-2.5-flash", "*pro*", false},
+		{"claude-sonnet", "claude-*", true},
+		{"claude", "claude-*", false},
+		{"ab", "a*b*b", false},
+	}
+	for _, tt := range tests {
+		if got := matchModelPrefix(tt.model, tt.pattern); got != tt.want {
+			t.Fatalf("matchModelPrefix(%q, %q) = %v, want %v", tt.model, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyRequestError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{context.Canceled, ErrorClassClientCanceled},
+		{fmt.Errorf("read body: %w", context.Canceled), ErrorClassClientCanceled},
+		{context.DeadlineExceeded, ErrorClassTimeout},
+		{errors.New("connection reset by peer"), ErrorClassUpstream},
+	}
+	for _, tt := range tests {
+		if got := ClassifyRequestError(tt.err); got != tt.want {
+			t.Fatalf("ClassifyRequestError(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRecordFilterRouteID(t *testing.T) {
+	r := DetailedRequestRecord{RouteID: "route-a", RouteName: "alpha", CredentialID: "cred"}
+	if !matchRecordFilter(&r, RecordFilter{RouteID: "route-a"}) {
+		t.Fatalf("record on route-a filtered out")
+	}
+	if matchRecordFilter(&r, RecordFilter{RouteID: "route-b"}) {
+		t.Fatalf("record on route-a matched route-b")
+	}
+	entries := []IndexEntry{newIndexEntry(&r, "a.json"), newIndexEntry(&DetailedRequestRecord{ID: "direct"}, "b.json")}
+	if got := applyIndexFilters(entries, RecordFilter{RouteID: "route-a"}); len(got) != 1 || got[0].Filename != "a.json" {
+		t.Fatalf("index filter = %+v, want only a.json", got)
+	}
+}
+
+func TestRecordFilterURLPattern(t *testing.T) {
+	bad := RecordFilter{URLPattern: "(["}
+	if err := bad.Compile(); err == nil {
+		t.Fatalf("Compile accepted an invalid pattern")
+	}
+	if bad.matchURL("/v1/messages") {
+		t.Fatalf("invalid pattern must not match")
+	}
+
+	filter := RecordFilter{URLPattern: "^/v1/messages"}
+	if err := filter.Compile(); err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	r := DetailedRequestRecord{URL: "/v1/messages?beta=true", StatusCode: 200}
+	if !matchRecordFilter(&r, filter) {
+		t.Fatalf("expected %q to match", r.URL)
+	}
+	r.URL = "/v1/chat/completions"
+	if matchRecordFilter(&r, filter) {
+		t.Fatalf("expected %q not to match", r.URL)
+	}
+}
+
+func TestStreamRecordsWritesNDJSON(t *testing.T) {
+	dl := NewDetailedRequestLogger(true, t.TempDir(), 0, 0, nil)
+	defer dl.Close()
+
+	now := time.Now()
+	records := []*DetailedRequestRecord{
+		{ID: "ok-1", Timestamp: now, URL: "/v1/chat/completions", Method: "POST", StatusCode: 200, RequestBody: `{"model":"a"}`},
+		{ID: "err-1", Timestamp: now, URL: "/v1/chat/completions", Method: "POST", StatusCode: 500, RequestBody: `{"model":"b"}`},
+	}
+	for _, r := range records {
+		if err := dl.writeRecordFile(r); err != nil {
+			t.Fatalf("writeRecordFile: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := dl.StreamRecords(RecordFilter{StatusCode: "5xx"}, &buf); err != nil {
+		t.Fatalf("StreamRecords: %v", err)
+	}
+
+	var got []DetailedRequestRecord
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var rec DetailedRequestRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", scanner.Text(), err)
+		}
+		got = append(got, rec)
+	}
+	if len(got) != 1 || got[0].ID != "err-1" {
+		t.Fatalf("streamed records = %+v, want only err-1", got)
+	}
+	if got[0].RequestBody == "" {
+		t.Fatalf("expected bodies to be merged into exported record")
+	}
+}
+
+func TestCompressOldFilesKeepsRecordsReadable(t *testing.T) {
+	dir := t.TempDir()
+	dl := NewDetailedRequestLogger(true, dir, 0, 1, nil)
+	defer dl.Close()
+
+	base := time.Now().Add(-time.Hour)
+	for i, id := range []string{"old00001", "new00002"} {
+		rec := &DetailedRequestRecord{
+			ID:          id,
+			Timestamp:   base.Add(time.Duration(i) * time.Minute),
+			URL:         "/v1/chat/completions",
+			Method:      "POST",
+			StatusCode:  200,
+			RequestBody: `{"model":"m"}`,
+		}
+		if err := dl.writeRecordFile(rec); err != nil {
+			t.Fatalf("writeRecordFile: %v", err)
+		}
+		name := dl.generateDetailFilename(rec)
+		mtime := rec.Timestamp
+		_ = os.Chtimes(filepath.Join(dir, name), mtime, mtime)
+	}
+
+	dl.cleanupOldFiles()
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "*old00001*"+detailedGzipSuffix))
+	if len(matches) != 2 {
+		t.Fatalf("expected compressed meta and bodies for old record, got %v", matches)
+	}
+	if newer, _ := filepath.Glob(filepath.Join(dir, "*new00002*"+detailedGzipSuffix)); len(newer) != 0 {
+		t.Fatalf("newest record should stay uncompressed, got %v", newer)
+	}
+
+	rec, err := dl.ReadRecordByID("old00001")
+	if err != nil || rec == nil {
+		t.Fatalf("ReadRecordByID on compressed record: rec=%v err=%v", rec, err)
+	}
+	if rec.RequestBody == "" {
+		t.Fatalf("expected bodies to be read from compressed companion")
+	}
+
+	_, count, err := dl.GetStats()
+	if err != nil || count != 2 {
+		t.Fatalf("GetStats count = %d, err = %v; want 2", count, err)
+	}
+	summaries, total, skipped, err := dl.ReadRecordSummaries(RecordFilter{}, nil)
+	if err != nil || total != 2 || skipped != 0 || len(summaries) != 2 {
+		t.Fatalf("ReadRecordSummaries total=%d skipped=%d len=%d err=%v", total, skipped, len(summaries), err)
+	}
+}
+
+func TestWritesAreAtomicAndTempFilesIgnored(t *testing.T) {
+	dir := t.TempDir()
+	dl := NewDetailedRequestLogger(true, dir, 0, 0, nil)
+	defer dl.Close()
+
+	rec := &DetailedRequestRecord{ID: "atomic01", Timestamp: time.Now(), URL: "/v1/messages", Method: "POST", StatusCode: 200}
+	if err := dl.writeRecordFile(rec); err != nil {
+		t.Fatalf("writeRecordFile: %v", err)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(dir, "*"+detailedTempSuffix))
+	if len(leftovers) != 0 {
+		t.Fatalf("temp files left behind: %v", leftovers)
+	}
+
+	// Simulate a write in progress and one abandoned by a crash.
+	partial := filepath.Join(dir, "detail-partial.json"+detailedTempSuffix)
+	stale := filepath.Join(dir, "detail-stale.json"+detailedTempSuffix)
+	for _, path := range []string{partial, stale} {
+		if err := os.WriteFile(path, []byte(`{"id":`), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	old := time.Now().Add(-2 * staleTempFileAge)
+	_ = os.Chtimes(stale, old, old)
+
+	files, err := dl.listDetailFiles()
+	if err != nil || len(files) != 1 {
+		t.Fatalf("listDetailFiles len=%d err=%v, want only the completed record", len(files), err)
+	}
+
+	dl.cleanupOldFiles()
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("expected stale temp file to be removed, stat err=%v", err)
+	}
+	if _, err := os.Stat(partial); err != nil {
+		t.Fatalf("expected in-progress temp file to be kept: %v", err)
+	}
+}
+
+func TestCleanupOldFilesEnforcesMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	dl := NewDetailedRequestLogger(true, dir, 0, 0, nil)
+	defer dl.Close()
+	dl.SetMaxAge(24 * time.Hour)
+
+	now := time.Now()
+	for _, tc := range []struct {
+		id  string
+		age time.Duration
+	}{
+		{"stale001", 48 * time.Hour},
+		{"fresh002", time.Hour},
+	} {
+		rec := &DetailedRequestRecord{ID: tc.id, Timestamp: now.Add(-tc.age), URL: "/v1/chat/completions", Method: "POST", StatusCode: 200}
+		if err := dl.writeRecordFile(rec); err != nil {
+			t.Fatalf("writeRecordFile: %v", err)
+		}
+		name := dl.generateDetailFilename(rec)
+		_ = os.Chtimes(filepath.Join(dir, name), rec.Timestamp, rec.Timestamp)
+	}
+
+	dl.cleanupOldFiles()
+
+	if rec, _ := dl.ReadRecordByID("stale001"); rec != nil {
+		t.Fatalf("expected record older than max age to be removed")
+	}
+	if rec, _ := dl.ReadRecordByID("fresh002"); rec == nil {
+		t.Fatalf("expected record within max age to be kept")
+	}
+}
+
+func TestCleanupRunsOnTickerAndOnClose(t *testing.T) {
+	logRecords := func(dl *DetailedRequestLogger) {
+		base := time.Now().Add(-time.Hour)
+		for i, id := range []string{"tick0001", "tick0002", "tick0003"} {
+			dl.LogRecord(&DetailedRequestRecord{ID: id, Timestamp: base.Add(time.Duration(i) * time.Minute), URL: "/v1/chat/completions", Method: "POST", StatusCode: 500})
+		}
+	}
+
+	ticked := NewDetailedRequestLogger(true, t.TempDir(), 0, 0, nil)
+	defer ticked.Close()
+	ticked.maxFiles = 1
+	ticked.SetCleanupInterval(20 * time.Millisecond)
+	logRecords(ticked)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, count, _ := ticked.GetStats(); count == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			_, count, _ := ticked.GetStats()
+			t.Fatalf("ticker cleanup left %d records, want 1", count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	closed := NewDetailedRequestLogger(true, t.TempDir(), 0, 0, nil)
+	closed.maxFiles = 1
+	closed.SetCleanupInterval(time.Hour)
+	logRecords(closed)
+	closed.Close()
+	if _, count, _ := closed.GetStats(); count != 1 {
+		t.Fatalf("Close left %d records, want 1 after the final cleanup", count)
+	}
+	// The cleanup drops removed records from the index without a rebuild.
+	index, err := closed.loadIndex()
+	if err != nil || len(index) != 1 {
+		t.Fatalf("index after cleanup = %+v (err %v), want the one remaining record", index, err)
+	}
+	if _, err := os.Stat(filepath.Join(closed.logsDir, index[0].Filename)); err != nil {
+		t.Fatalf("index lists a removed file: %v", err)
+	}
+}
+
+func TestIdleAgeSweepRunsEveryRetentionSweepInterval(t *testing.T) {
+	dl := NewDetailedRequestLogger(true, t.TempDir(), 0, 0, nil)
+	defer dl.Close()
+	dl.SetMaxAge(time.Hour)
+
+	lastCleanup := func() time.Time {
+		dl.mu.Lock()
+		defer dl.mu.Unlock()
+		return dl.lastCleanup
+	}
+	dl.cleanupIfDue()
+	first := lastCleanup()
+	if first.IsZero() {
+		t.Fatalf("age sweep did not run with a max age set")
+	}
+	dl.cleanupIfDue()
+	if got := lastCleanup(); !got.Equal(first) {
+		t.Fatalf("idle age sweep ran again before retentionSweepInterval")
+	}
+}
+
+func TestGetDetailedStatsGroupsByModel(t *testing.T) {
+	dl := NewDetailedRequestLogger(true, t.TempDir(), 0, 0, nil)
+	defer dl.Close()
+
+	now := time.Now()
+	records := []*DetailedRequestRecord{
+		{ID: "a1", Model: "gpt", StatusCode: 200, TotalDurationMs: 100},
+		{ID: "a2", Model: "gpt", StatusCode: 200, TotalDurationMs: 300},
+		{ID: "a3", Model: "gpt", StatusCode: 500, TotalDurationMs: 200},
+		{ID: "b1", StatusCode: 200, TotalDurationMs: 50},
+		{ID: "old", Model: "gpt", StatusCode: 200, TotalDurationMs: 10, Timestamp: now.Add(-2 * time.Hour)},
+	}
+	for _, r := range records {
+		if r.Timestamp.IsZero() {
+			r.Timestamp = now
+		}
+		r.URL = "/v1/chat/completions"
+		r.Method = "POST"
+		if err := dl.writeRecordFile(r); err != nil {
+			t.Fatalf("writeRecordFile: %v", err)
+		}
+	}
+
+	stats, err := dl.GetDetailedStats(RecordFilter{After: now.Add(-time.Hour)})
+	if err != nil {
+		t.Fatalf("GetDetailedStats: %v", err)
+	}
+	if stats.Total != 4 || len(stats.Models) != 2 {
+		t.Fatalf("total=%d models=%d, want 4 and 2", stats.Total, len(stats.Models))
+	}
+	gpt := stats.Models[0]
+	if gpt.Model != "gpt" || gpt.Count != 3 || gpt.SuccessCount != 2 || gpt.ErrorCount != 1 {
+		t.Fatalf("unexpected gpt stats: %+v", gpt)
+	}
+	if gpt.AvgDurationMs != 200 || gpt.P50DurationMs != 200 || gpt.P95DurationMs != 300 {
+		t.Fatalf("unexpected gpt durations: %+v", gpt)
+	}
+	if stats.Models[1].Model != unknownModelBucket {
+		t.Fatalf("expected unknown bucket for empty model, got %q", stats.Models[1].Model)
+	}
+}
+
+func TestSampleKeepIsDeterministic(t *testing.T) {
+	if !sampleKeep("any", 1) {
+		t.Fatalf("rate 1 must keep every record")
+	}
+	if sampleKeep("any", 0) {
+		t.Fatalf("rate 0 must drop every record")
+	}
+
+	kept := 0
+	for i := 0; i < 10000; i++ {
+		id := fmt.Sprintf("req-%d", i)
+		first := sampleKeep(id, 0.25)
+		if sampleKeep(id, 0.25) != first {
+			t.Fatalf("sampling of %s is not deterministic", id)
+		}
+		if first {
+			kept++
+		}
+	}
+	if kept < 2000 || kept > 3000 {
+		t.Fatalf("kept %d of 10000 at rate 0.25, want roughly 2500", kept)
+	}
+}
+
+func TestSubscribeReceivesLoggedRecords(t *testing.T) {
+	dl := NewDetailedRequestLogger(true, t.TempDir(), 0, 0, nil)
+	fast := dl.Subscribe()
+	slow := dl.Subscribe()
+
+	for i := 0; i < detailedTailBufferSize+5; i++ {
+		dl.LogRecord(&DetailedRequestRecord{ID: fmt.Sprintf("r%d", i), Timestamp: time.Now(), Model: "m", StatusCode: 200})
+		if got := <-fast; got.ID != fmt.Sprintf("r%d", i) || got.Model != "m" {
+			t.Fatalf("record %d: got %+v", i, got)
+		}
+	}
+	// The slow subscriber never read, so it kept only what fits in its buffer.
+	if len(slow) != detailedTailBufferSize {
+		t.Fatalf("slow subscriber buffered %d records, want %d", len(slow), detailedTailBufferSize)
+	}
+
+	dl.Unsubscribe(fast)
+	if _, ok := <-fast; ok {
+		t.Fatalf("expected unsubscribed channel to be closed")
+	}
+	dl.Close()
+	for range slow {
+	}
+	if _, ok := <-dl.Subscribe(); ok {
+		t.Fatalf("expected subscription after Close to be closed")
+	}
+}
+
+func TestCursorPaginationWalksEveryRecordOnce(t *testing.T) {
+	dir := t.TempDir()
+	dl := NewDetailedRequestLogger(true, dir, 0, 0, nil)
+	defer dl.Close()
+
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		rec := &DetailedRequestRecord{
+			ID:         fmt.Sprintf("cur%05d", i),
+			Timestamp:  base.Add(time.Duration(i) * time.Minute),
+			URL:        "/v1/messages",
+			Method:     "POST",
+			StatusCode: 200,
+		}
+		if err := dl.writeRecordFile(rec); err != nil {
+			t.Fatalf("writeRecordFile: %v", err)
+		}
+		_ = os.Chtimes(filepath.Join(dir, dl.generateDetailFilename(rec)), rec.Timestamp, rec.Timestamp)
+	}
+	want := []string{"cur00004", "cur00003", "cur00002", "cur00001", "cur00000"}
+
+	var summaries, full []string
+	summaryFilter := RecordFilter{Limit: 2, Compact: true}
+	fullFilter := RecordFilter{Limit: 2}
+	for page := 0; page < 4; page++ {
+		rows, total, _, err := dl.ReadRecordSummaries(summaryFilter, nil)
+		if err != nil || total != 5 {
+			t.Fatalf("ReadRecordSummaries page %d: total=%d err=%v", page, total, err)
+		}
+		for _, row := range rows {
+			summaries = append(summaries, row.(DetailedRequestCompact).ID)
+		}
+		if len(rows) > 0 {
+			summaryFilter.BeforeID = rows[len(rows)-1].(DetailedRequestCompact).ID
+		}
+
+		records, _, _, _, err := dl.ReadRecords(fullFilter)
+		if err != nil {
+			t.Fatalf("ReadRecords page %d: %v", page, err)
+		}
+		for _, r := range records {
+			full = append(full, r.ID)
+		}
+		if len(records) > 0 {
+			fullFilter.BeforeID = records[len(records)-1].ID
+		}
+	}
+	for name, got := range map[string][]string{"summaries": summaries, "records": full} {
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("%s pages = %v, want %v", name, got, want)
+		}
+	}
+
+	// A cursor whose record is gone resumes from its timestamp.
+	records, _, _, _, err := dl.ReadRecords(RecordFilter{BeforeID: "deleted", BeforeTS: base.Add(90 * time.Second)})
+	if err != nil || len(records) != 2 || records[0].ID != "cur00001" {
+		t.Fatalf("timestamp fallback = %v, err %v", records, err)
+	}
+}
+
+func TestUpdateRecordTagsRewritesMetaAndIndex(t *testing.T) {
+	dir := t.TempDir()
+	dl := NewDetailedRequestLogger(true, dir, 0, 1, nil)
+	defer dl.Close()
+
+	base := time.Now().Add(-time.Hour)
+	for i, id := range []string{"old00001", "new00002"} {
+		rec := &DetailedRequestRecord{
+			ID:          id,
+			Timestamp:   base.Add(time.Duration(i) * time.Minute),
+			URL:         "/v1/chat/completions",
+			Method:      "POST",
+			StatusCode:  200,
+			RequestBody: `{"model":"m"}`,
+		}
+		if err := dl.writeRecordFile(rec); err != nil {
+			t.Fatalf("writeRecordFile: %v", err)
+		}
+		_ = os.Chtimes(filepath.Join(dir, dl.generateDetailFilename(rec)), rec.Timestamp, rec.Timestamp)
+	}
+	dl.cleanupOldFiles() // compresses old00001
+
+	tags, err := dl.UpdateRecordTags("old00001", []string{" incident ", "todo", "incident"}, nil)
+	if err != nil || len(tags) != 2 || tags[0] != "incident" || tags[1] != "todo" {
+		t.Fatalf("UpdateRecordTags = %v, %v", tags, err)
+	}
+	if tags, err = dl.UpdateRecordTags("old00001", nil, []string{"todo"}); err != nil || len(tags) != 1 {
+		t.Fatalf("removing a tag = %v, %v", tags, err)
+	}
+	if _, err := dl.UpdateRecordTags("missing", []string{"x"}, nil); err != ErrRecordNotFound {
+		t.Fatalf("tagging a missing record: err = %v", err)
+	}
+
+	rec, err := dl.ReadRecordByID("old00001")
+	if err != nil || rec == nil || rec.RequestBody == "" || len(rec.Tags) != 1 || rec.Tags[0] != "incident" {
+		t.Fatalf("ReadRecordByID = %+v, %v", rec, err)
+	}
+	if plain, _ := filepath.Glob(filepath.Join(dir, "*old00001.json")); len(plain) != 0 {
+		t.Fatalf("compressed meta left an uncompressed copy: %v", plain)
+	}
+
+	summaries, total, _, err := dl.ReadRecordSummaries(RecordFilter{Tag: "incident"}, nil)
+	if err != nil || total != 1 || len(summaries) != 1 {
+		t.Fatalf("tag filter total=%d len=%d err=%v", total, len(summaries), err)
+	}
+	if summary := summaries[0].(DetailedRequestSummary); summary.ID != "old00001" {
+		t.Fatalf("tag filter returned %s", summary.ID)
+	}
+	files, err := dl.listDetailFiles()
+	if err != nil || len(files) != 2 || !strings.Contains(files[0].Name(), "new00002") {
+		t.Fatalf("tagging changed listing order: %v, %v", files, err)
+	}
+}
+
+func TestUpdateRecordTagsDoesNotBlockOrPanic(t *testing.T) {
+	full := &DetailedRequestLogger{writeCh: make(chan *writeOp, 1)}
+	full.writeCh <- &writeOp{}
+	if _, err := full.UpdateRecordTags("r1", []string{"x"}, nil); !errors.Is(err, ErrWriteQueueFull) {
+		t.Fatalf("full queue: err = %v, want ErrWriteQueueFull", err)
+	}
+
+	dl := NewDetailedRequestLogger(true, t.TempDir(), 0, 0, nil)
+	dl.Close()
+	if _, err := dl.UpdateRecordTags("r1", []string{"x"}, nil); err == nil {
+		t.Fatalf("closed logger accepted a tag update")
+	}
+}
+
+func TestReadRawRecordReturnsStoredFiles(t *testing.T) {
+	dir := t.TempDir()
+	dl := NewDetailedRequestLogger(true, dir, 0, 0, nil)
+	defer dl.Close()
+
+	rec := &DetailedRequestRecord{ID: "raw00001", Timestamp: time.Now(), URL: "/v1/chat/completions", Method: "POST", StatusCode: 200, RequestBody: `{"model":"m"}`}
+	if err := dl.writeRecordFile(rec); err != nil {
+		t.Fatalf("writeRecordFile: %v", err)
+	}
+	metaName := dl.generateDetailFilename(rec)
+	for _, name := range []string{metaName, bodiesFileFor(metaName)} {
+		if err := gzipDetailFile(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("gzipDetailFile: %v", err)
+		}
+	}
+
+	name, data, err := dl.ReadRawRecord("raw00001", false)
+	if err != nil || name != metaName {
+		t.Fatalf("ReadRawRecord meta = %q, %v; want %q", name, err, metaName)
+	}
+	var meta DetailedRequestRecord
+	if err := json.Unmarshal(data, &meta); err != nil || meta.ID != "raw00001" || meta.RequestBody != "" {
+		t.Fatalf("meta file content = %s (err %v)", data, err)
+	}
+	name, data, err = dl.ReadRawRecord("raw00001", true)
+	if err != nil || name != bodiesFileFor(metaName) || !bytes.Contains(data, []byte(`\"model\"`)) {
+		t.Fatalf("ReadRawRecord bodies = %q, %s, %v", name, data, err)
+	}
+	if _, data, err := dl.ReadRawRecord("missing", false); err != nil || data != nil {
+		t.Fatalf("ReadRawRecord for a missing ID = %s, %v", data, err)
+	}
+}
+
+func TestWriteBatchIndexesRecordsTogether(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	dl := NewDetailedRequestLogger(true, dir, 0, 0, nil)
+	defer dl.Close()
+	// The directory is created up front, and recreated if removed later.
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("logs directory not created at construction: %v", err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("RemoveAll: %v", err)
+	}
+
+	base := time.Now().Add(-time.Hour)
+	var ops []*writeOp
+	for i := 0; i < 3; i++ {
+		rec := &DetailedRequestRecord{ID: fmt.Sprintf("batch%03d", i), Timestamp: base.Add(time.Duration(i) * time.Minute), URL: "/v1/messages", Method: "POST", StatusCode: 200}
+		ops = append(ops, &writeOp{opType: writeOpComplete, record: rec})
+	}
+	ops = append(ops, &writeOp{opType: writeOpTags, tags: &tagUpdate{id: "batch001", add: []string{"keep"}, done: make(chan struct{})}})
+	dl.writeBatch(ops)
+	<-ops[3].tags.done
+	if err := ops[3].tags.err; err != nil {
+		t.Fatalf("tag update in batch: %v", err)
+	}
+
+	entries, err := dl.loadIndex()
+	if err != nil {
+		t.Fatalf("loadIndex: %v", err)
+	}
+	var ids []string
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+	}
+	if want := "[batch002 batch001 batch000]"; fmt.Sprint(ids) != want {
+		t.Fatalf("index ids = %v, want %s", ids, want)
+	}
+	if len(entries[1].Tags) != 1 || entries[1].Tags[0] != "keep" {
+		t.Fatalf("batch001 tags = %v, want [keep]", entries[1].Tags)
+	}
+
+	var buf bytes.Buffer
+	got, err := encodeIndented(&buf, entries[0])
+	if err != nil {
+		t.Fatalf("encodeIndented: %v", err)
+	}
+	want, _ := json.MarshalIndent(entries[0], "", "  ")
+	if string(got) != string(want)+"\n" {
+		t.Fatalf("encodeIndented = %q, want MarshalIndent output plus newline", got)
+	}
+}
+
+// BenchmarkWriteRecords writes 10k records through the write loop's batch path
+// with batching off and at the default batch size.
+func BenchmarkWriteRecords(b *testing.B) {
+	const records = 10000
+	for _, size := range []int{1, DefaultDetailedWriteBatchSize} {
+		b.Run(fmt.Sprintf("batch=%d", size), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				b.StopTimer()
+				dl := NewDetailedRequestLogger(true, b.TempDir(), 0, 0, nil)
+				base := time.Now()
+				ops := make([]*writeOp, records)
+				for i := range ops {
+					ops[i] = &writeOp{opType: writeOpComplete, record: &DetailedRequestRecord{
+						ID:         fmt.Sprintf("bench%05d", i),
+						Timestamp:  base.Add(time.Duration(i) * time.Millisecond),
+						URL:        "/v1/chat/completions",
+						Method:     "POST",
+						StatusCode: 200,
+					}}
+				}
+				b.StartTimer()
+				for i := 0; i < records; i += size {
+					dl.writeBatch(ops[i:min(i+size, records)])
+				}
+				b.StopTimer()
+				dl.Close()
+			}
+		})
+	}
+}
+
+func TestFilenameTemplateNamesCompletedRecords(t *testing.T) {
+	dir := t.TempDir()
+	dl := NewDetailedRequestLogger(true, dir, 0, 0, nil)
+	defer dl.Close()
+	if err := dl.SetFilenameTemplate("{prefix}{ts}-{id}"); err == nil {
+		t.Fatalf("SetFilenameTemplate accepted a template without {suffix}")
+	}
+	if err := dl.SetFilenameTemplate("{prefix}{ts}-{status}-{model}-{id}{suffix}"); err != nil {
+		t.Fatalf("SetFilenameTemplate: %v", err)
+	}
+
+	ts := time.Date(2026, 2, 8, 13, 1, 45, 0, time.UTC)
+	rec := &DetailedRequestRecord{ID: "tmpl0001", Timestamp: ts, URL: "/v1/chat/completions", Method: "POST", Model: "openai/gpt-4o:latest", Pending: true}
+	if err := dl.writePendingFile(rec); err != nil {
+		t.Fatalf("writePendingFile: %v", err)
+	}
+	rec.Pending = false
+	rec.StatusCode = 502
+	if err := dl.writeRecordFile(rec); err != nil {
+		t.Fatalf("writeRecordFile: %v", err)
+	}
+
+	want := "detail-2026-02-08T130145-502-openai-gpt-4o-latest-tmpl0001.json"
+	if _, err := os.Stat(filepath.Join(dir, want)); err != nil {
+		t.Fatalf("expected %s: %v", want, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, dl.pendingFilename(rec))); !os.IsNotExist(err) {
+		t.Fatalf("pending placeholder left behind: %v", err)
+	}
+	got, err := dl.ReadRecordByID("tmpl0001")
+	if err != nil || got == nil || got.StatusCode != 502 {
+		t.Fatalf("ReadRecordByID = %+v, %v", got, err)
+	}
+}
+
+func TestReadRecordsListsFromJSONLIndex(t *testing.T) {
+	dir := t.TempDir()
+	dl := NewDetailedRequestLogger(true, dir, 0, 0, nil)
+	defer dl.Close()
+
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 4; i++ {
+		rec := &DetailedRequestRecord{
+			ID:         fmt.Sprintf("jsl%05d", i),
+			Timestamp:  base.Add(time.Duration(i) * time.Minute),
+			URL:        "/v1/messages",
+			Method:     "POST",
+			StatusCode: 200 + 300*(i%2),
+		}
+		if err := dl.writeRecordFile(rec); err != nil {
+			t.Fatalf("writeRecordFile: %v", err)
+		}
+		_ = os.Chtimes(filepath.Join(dir, dl.generateDetailFilename(rec)), rec.Timestamp, rec.Timestamp)
+	}
+
+	// Each record is one appended line.
+	data, err := os.ReadFile(filepath.Join(dir, indexFileName))
+	if err != nil {
+		t.Fatalf("read index: %v", err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 4 {
+		t.Fatalf("index has %d lines, want 4", lines)
+	}
+
+	// Listing pages through the index and reads only the page's files.
+	records, total, _, skipped, err := dl.ReadRecords(RecordFilter{StatusCode: "5xx", Limit: 1})
+	if err != nil || total != 2 || skipped != 0 || len(records) != 1 || records[0].ID != "jsl00003" {
+		t.Fatalf("ReadRecords = %v total=%d skipped=%d err=%v", records, total, skipped, err)
+	}
+	if records[0].URL != "/v1/messages" {
+		t.Fatalf("record not read from its file: %+v", records[0])
+	}
+
+	// A line torn by a crash mid-append marks the index stale; it is rebuilt.
+	if err := os.WriteFile(filepath.Join(dir, indexFileName), append(data, `{"id":"jsl0`...), 0644); err != nil {
+		t.Fatalf("tear index: %v", err)
+	}
+	_, total, _, _, err = dl.ReadRecords(RecordFilter{})
+	if err != nil || total != 4 {
+		t.Fatalf("ReadRecords after torn index: total=%d err=%v", total, err)
+	}
+	if entries, err := dl.loadIndex(); err != nil || len(entries) != 4 {
+		t.Fatalf("index not rebuilt: %d entries, err %v", len(entries), err)
+	}
+
+	// A missing index is rebuilt on demand too.
+	if err := os.Remove(filepath.Join(dir, indexFileName)); err != nil {
+		t.Fatalf("remove index: %v", err)
+	}
+	records, total, _, _, err = dl.ReadRecords(RecordFilter{Limit: 2})
+	if err != nil || total != 4 || len(records) != 2 || records[0].ID != "jsl00003" {
+		t.Fatalf("ReadRecords after missing index = %v total=%d err=%v", records, total, err)
+	}
+}
+
+func TestListDetailFilesSortsByFilenameTimestamp(t *testing.T) {
+	dir := t.TempDir()
+	dl := NewDetailedRequestLogger(true, dir, 0, 0, nil)
+	defer dl.Close()
+
+	// Filenames say skew0 < skew1 < skew2; mtimes, as after a clock jump, say the reverse.
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i := 0; i < 3; i++ {
+		rec := &DetailedRequestRecord{ID: fmt.Sprintf("skew%04d", i), Timestamp: base.Add(time.Duration(i) * time.Minute), URL: "/v1/messages", Method: "POST", StatusCode: 200}
+		if err := dl.writeRecordFile(rec); err != nil {
+			t.Fatalf("writeRecordFile: %v", err)
+		}
+		mtime := base.Add(-time.Duration(i) * time.Hour)
+		_ = os.Chtimes(filepath.Join(dir, dl.generateDetailFilename(rec)), mtime, mtime)
+	}
+	// A name without {ts} falls back to its mtime, here the newest of all.
+	if err := dl.SetFilenameTemplate("{prefix}{id}{suffix}"); err != nil {
+		t.Fatalf("SetFilenameTemplate: %v", err)
+	}
+	rec := &DetailedRequestRecord{ID: "nots0001", Timestamp: base, URL: "/v1/messages", Method: "POST", StatusCode: 200}
+	if err := dl.writeRecordFile(rec); err != nil {
+		t.Fatalf("writeRecordFile: %v", err)
+	}
+
+	files, err := dl.listDetailFiles()
+	if err != nil {
+		t.Fatalf("listDetailFiles: %v", err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	want := []string{"detail-nots0001.json"}
+	for i := 2; i >= 0; i-- {
+		r := &DetailedRequestRecord{ID: fmt.Sprintf("skew%04d", i), Timestamp: base.Add(time.Duration(i) * time.Minute), URL: "/v1/messages"}
+		want = append(want, renderDetailFilename("", r))
+	}
+	if fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Fatalf("order = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,72 @@
+package logging
+
+import "sync"
+
+// detailedTailBufferSize is the number of records buffered per tail subscriber.
+// A subscriber that falls further behind misses records rather than stalling
+// LogRecord.
+const detailedTailBufferSize = 64
+
+// detailedTail fans completed records out to live subscribers.
+type detailedTail struct {
+	mu     sync.Mutex
+	subs   map[<-chan DetailedRequestCompact]chan DetailedRequestCompact
+	closed bool
+}
+
+// Subscribe returns a channel that receives a compact summary of every record
+// passed to LogRecord from now on. The channel is closed by Unsubscribe or
+// when the logger is closed.
+func (dl *DetailedRequestLogger) Subscribe() <-chan DetailedRequestCompact {
+	ch := make(chan DetailedRequestCompact, detailedTailBufferSize)
+	t := &dl.tail
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		close(ch)
+		return ch
+	}
+	if t.subs == nil {
+		t.subs = make(map[<-chan DetailedRequestCompact]chan DetailedRequestCompact)
+	}
+	t.subs[ch] = ch
+	return ch
+}
+
+// Unsubscribe stops delivery to a channel returned by Subscribe and closes it.
+func (dl *DetailedRequestLogger) Unsubscribe(ch <-chan DetailedRequestCompact) {
+	t := &dl.tail
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if sub, ok := t.subs[ch]; ok {
+		delete(t.subs, ch)
+		close(sub)
+	}
+}
+
+// publish delivers record to every subscriber without blocking.
+func (t *detailedTail) publish(record *DetailedRequestRecord) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.subs) == 0 {
+		return
+	}
+	compact := record.ToCompact()
+	for _, sub := range t.subs {
+		select {
+		case sub <- compact:
+		default: // slow subscriber; drop rather than block logging
+		}
+	}
+}
+
+// close closes every subscriber channel and rejects new subscriptions.
+func (t *detailedTail) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	for key, sub := range t.subs {
+		delete(t.subs, key)
+		close(sub)
+	}
+}
@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// ReconstructedText assembles the assistant text of a streaming response from
+// the SSE data events captured in ResponseBody. Text deltas are recognised in
+// the OpenAI chat (choices.0.delta.content), OpenAI responses
+// (response.output_text.delta), Claude (content_block_delta) and Gemini
+// (candidates.0.content.parts) formats; reasoning, tool-call and usage events
+// are skipped. It returns "" for non-streaming records or when no text was found.
+func (r *DetailedRequestRecord) ReconstructedText() string {
+	if r == nil || !r.IsStreaming || r.ResponseBody == "" {
+		return ""
+	}
+	var text strings.Builder
+	for _, line := range strings.Split(r.ResponseBody, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(line[len("data:"):])
+		if !gjson.Valid(payload) {
+			continue // e.g. "[DONE]"
+		}
+		text.WriteString(streamEventText(gjson.Parse(payload)))
+	}
+	return text.String()
+}
+
+// streamEventText returns the assistant text carried by one SSE data event.
+func streamEventText(event gjson.Result) string {
+	switch event.Get("type").String() {
+	case "content_block_delta": // Claude
+		if event.Get("delta.type").String() == "text_delta" {
+			return event.Get("delta.text").String()
+		}
+		return ""
+	case "response.output_text.delta": // OpenAI responses
+		return event.Get("delta").String()
+	}
+	if content := event.Get("choices.0.delta.content"); content.Type == gjson.String { // OpenAI chat
+		return content.String()
+	}
+	var text strings.Builder
+	for _, part := range event.Get("candidates.0.content.parts").Array() { // Gemini
+		if !part.Get("thought").Bool() {
+			text.WriteString(part.Get("text").String())
+		}
+	}
+	return text.String()
+}
@@ -0,0 +1,51 @@
+package logging
+
+import "testing"
+
+func TestReconstructedText(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "openai chat",
+			body: "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\n\n" +
+				"data: [DONE]\n\n",
+			want: "Hello",
+		},
+		{
+			name: "claude",
+			body: "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"thinking_delta\",\"thinking\":\"hmm\"}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi \"}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"there\"}}\n\n" +
+				"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+			want: "Hi there",
+		},
+		{
+			name: "gemini",
+			body: "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"plan\",\"thought\":true},{\"text\":\"Bon\"}]}}]}\n\n" +
+				"data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"jour\"}]}}],\"usageMetadata\":{\"totalTokenCount\":3}}\n\n",
+			want: "Bonjour",
+		},
+		{
+			name: "openai responses",
+			body: "event: response.output_text.delta\ndata: {\"type\":\"response.output_text.delta\",\"delta\":\"Yes\"}\n\n" +
+				"event: response.completed\ndata: {\"type\":\"response.completed\",\"response\":{}}\n\n",
+			want: "Yes",
+		},
+	}
+	for _, tt := range tests {
+		record := &DetailedRequestRecord{IsStreaming: true, ResponseBody: tt.body}
+		if got := record.ReconstructedText(); got != tt.want {
+			t.Fatalf("%s: ReconstructedText() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	nonStreaming := &DetailedRequestRecord{ResponseBody: tests[0].body}
+	if got := nonStreaming.ReconstructedText(); got != "" {
+		t.Fatalf("non-streaming record reconstructed %q", got)
+	}
+}
@@ -0,0 +1,133 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/tidwall/gjson"
+)
+
+// newModelHTTPClient is newProxyAwareHTTPClient for an executor's model
+// request: it also applies the body transforms set on ctx by
+// util.WithBodyTransforms. Token refreshes, model listings and other auxiliary
+// calls use newProxyAwareHTTPClient, so the transforms never reach them.
+func newModelHTTPClient(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, timeout time.Duration) *http.Client {
+	return withBodyTransforms(ctx, newProxyAwareHTTPClient(ctx, cfg, auth, timeout))
+}
+
+// withBodyTransforms wraps the client's transport with the body transforms set
+// on ctx by util.WithBodyTransforms, if any.
+func withBodyTransforms(ctx context.Context, httpClient *http.Client) *http.Client {
+	transforms := util.BodyTransformsFrom(ctx)
+	if len(transforms.Request) == 0 && len(transforms.Response) == 0 {
+		return httpClient
+	}
+	base := httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	httpClient.Transport = &bodyTransformTransport{base: base, transforms: transforms}
+	return httpClient
+}
+
+// bodyTransformTransport edits JSON bodies on the wire, so the transforms see
+// the request after format translation and the response before it. SSE
+// responses are transformed one data event at a time. Responses with a
+// Content-Encoding the transport did not remove are passed through untouched.
+type bodyTransformTransport struct {
+	base       http.RoundTripper
+	transforms util.BodyTransforms
+}
+
+func (t *bodyTransformTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.transforms.Request) > 0 && req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = util.ApplyBodyTransforms(body, t.transforms.Request)
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+		req.ContentLength = int64(len(body))
+		if req.Header.Get("Content-Length") != "" {
+			req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || len(t.transforms.Response) == 0 || resp.Body == nil {
+		return resp, err
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return resp, nil
+	}
+	if strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+		resp.Body = &sseTransformReader{src: bufio.NewReader(resp.Body), body: resp.Body, transforms: t.transforms.Response}
+		resp.ContentLength = -1
+		resp.Header.Del("Content-Length")
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	body = util.ApplyBodyTransforms(body, t.transforms.Response)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return resp, nil
+}
+
+// sseTransformReader applies transforms to the JSON payload of each SSE data
+// line as the stream is read.
+type sseTransformReader struct {
+	src        *bufio.Reader
+	body       io.Closer
+	transforms []util.BodyTransform
+	pending    []byte
+	err        error
+}
+
+func (r *sseTransformReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		var line []byte
+		line, r.err = r.src.ReadBytes('\n')
+		r.pending = transformSSELine(line, r.transforms)
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *sseTransformReader) Close() error {
+	return r.body.Close()
+}
+
+// transformSSELine transforms the payload of a "data:" line, keeping its line ending.
+func transformSSELine(line []byte, transforms []util.BodyTransform) []byte {
+	content := bytes.TrimRight(line, "\r\n")
+	if !bytes.HasPrefix(content, []byte("data:")) {
+		return line
+	}
+	payload := bytes.TrimSpace(content[len("data:"):])
+	if !gjson.ValidBytes(payload) {
+		return line
+	}
+	out := append([]byte("data: "), util.ApplyBodyTransforms(payload, transforms)...)
+	return append(out, line[len(content):]...)
+}
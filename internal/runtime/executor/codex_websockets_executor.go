@@ -7,7 +7,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -27,7 +26,6 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
-	"golang.org/x/net/proxy"
 )
 
 const (
@@ -632,7 +630,7 @@ func (e *CodexWebsocketsExecutor) ExecuteStream(ctx context.Context, auth *clipr
 }
 
 func (e *CodexWebsocketsExecutor) dialCodexWebsocket(ctx context.Context, auth *cliproxyauth.Auth, wsURL string, headers http.Header) (*websocket.Conn, *http.Response, error) {
-	dialer := newProxyAwareWebsocketDialer(e.cfg, auth)
+	dialer := newProxyAwareWebsocketDialer(ctx, e.cfg, auth)
 	dialer.HandshakeTimeout = codexResponsesWebsocketHandshakeTO
 	dialer.EnableCompression = true
 	if ctx == nil {
@@ -740,23 +738,25 @@ func markCodexWebsocketCreateSent(sess *codexWebsocketSession, conn *websocket.C
 	sess.connMu.Unlock()
 }
 
-func newProxyAwareWebsocketDialer(cfg *config.Config, auth *cliproxyauth.Auth) *websocket.Dialer {
+func newProxyAwareWebsocketDialer(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth) *websocket.Dialer {
 	dialer := &websocket.Dialer{
 		Proxy:             http.ProxyFromEnvironment,
 		HandshakeTimeout:  codexResponsesWebsocketHandshakeTO,
 		EnableCompression: true,
-		NetDialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
+		NetDialContext:    util.DialContext,
 	}
 
-	proxyURL := ""
+	var proxyDNS string
+	proxyURL := strings.TrimSpace(util.UpstreamProxyFrom(ctx))
 	if auth != nil {
-		proxyURL = strings.TrimSpace(auth.ProxyURL)
+		if proxyURL == "" {
+			proxyURL = strings.TrimSpace(auth.ProxyURL)
+		}
+		proxyDNS = strings.TrimSpace(auth.ProxyDNS)
 	}
 	if proxyURL == "" && cfg != nil {
 		proxyURL = strings.TrimSpace(cfg.ProxyURL)
+		proxyDNS = strings.TrimSpace(cfg.ProxyDNS)
 	}
 	if proxyURL == "" {
 		return dialer
@@ -770,21 +770,20 @@ func newProxyAwareWebsocketDialer(cfg *config.Config, auth *cliproxyauth.Auth) *
 
 	switch parsedURL.Scheme {
 	case "socks5":
-		var proxyAuth *proxy.Auth
-		if parsedURL.User != nil {
-			username := parsedURL.User.Username()
-			password, _ := parsedURL.User.Password()
-			proxyAuth = &proxy.Auth{User: username, Password: password}
-		}
-		socksDialer, errSOCKS5 := proxy.SOCKS5("tcp", parsedURL.Host, proxyAuth, proxy.Direct)
+		socksDialContext, errSOCKS5 := util.SOCKS5DialContext(parsedURL)
 		if errSOCKS5 != nil {
 			log.Errorf("codex websockets executor: create SOCKS5 dialer failed: %v", errSOCKS5)
 			return dialer
 		}
 		dialer.Proxy = nil
-		dialer.NetDialContext = func(_ context.Context, network, addr string) (net.Conn, error) {
-			return socksDialer.Dial(network, addr)
+		dialer.NetDialContext = socksDialContext
+	case "ss":
+		transport := util.ProxyTransport(proxyURL, proxyDNS)
+		if transport == nil {
+			return dialer
 		}
+		dialer.Proxy = nil
+		dialer.NetDialContext = transport.DialContext
 	case "http", "https":
 		dialer.Proxy = http.ProxyURL(parsedURL)
 	default:
@@ -149,7 +149,7 @@ func (e *GeminiCLIExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth
 		models = append([]string{baseModel}, models...)
 	}
 
-	httpClient := newHTTPClient(ctx, e.cfg, auth, 0)
+	httpClient := newModelHTTPClient(ctx, e.cfg, auth, 0)
 	respCtx := context.WithValue(ctx, "alt", opts.Alt)
 
 	var authID, authLabel, authType, authValue string
@@ -297,7 +297,7 @@ func (e *GeminiCLIExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 		models = append([]string{baseModel}, models...)
 	}
 
-	httpClient := newHTTPClient(ctx, e.cfg, auth, 0)
+	httpClient := newModelHTTPClient(ctx, e.cfg, auth, 0)
 	respCtx := context.WithValue(ctx, "alt", opts.Alt)
 
 	var authID, authLabel, authType, authValue string
@@ -470,7 +470,7 @@ func (e *GeminiCLIExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.
 		models = append([]string{baseModel}, models...)
 	}
 
-	httpClient := newHTTPClient(ctx, e.cfg, auth, 0)
+	httpClient := newModelHTTPClient(ctx, e.cfg, auth, 0)
 	respCtx := context.WithValue(ctx, "alt", opts.Alt)
 
 	var authID, authLabel, authType, authValue string
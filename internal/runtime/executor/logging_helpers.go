@@ -18,23 +18,43 @@ import (
 	"github.com/tidwall/gjson"
 )
 
+// 请求日志（RequestLog）使用的 Gin 键；详细日志使用 logging.DetailedAttemptsKey 存放结构化记录
 const (
-	// 请求日志（RequestLog）使用的 Gin 键
 	apiAttemptsKey = "API_UPSTREAM_ATTEMPTS"
 	apiRequestKey  = "API_REQUEST"
 	apiResponseKey = "API_RESPONSE"
-
-	// 详细日志（DetailedRequestLog）专用 Gin 键，与请求日志完全解耦
-	detailedLogAttemptsKey = "DETAILED_LOG_API_ATTEMPTS"
-	detailedLogRequestKey  = "DETAILED_LOG_API_REQUEST"
-	detailedLogResponseKey = "DETAILED_LOG_API_RESPONSE"
 )
 
-// attemptKeys 表示一组 Gin 键，用于某一类消费者（请求日志 or 详细日志）
+// defaultMaxUpstreamLogBytes is used when RequestLogMaxUpstreamKB is not set.
+const defaultMaxUpstreamLogBytes = 16 * 1024 * 1024
+
+// upstreamLogTruncatedMarker ends request-log text cut off at the size cap.
+const upstreamLogTruncatedMarker = "\n<truncated: request log upstream size limit reached>\n"
+
+// attemptKeys 表示请求日志使用的一组 Gin 键
 type attemptKeys struct {
 	attempts string
 	request  string
 	response string
+	maxBytes int // cap on the text kept under request and under response
+}
+
+// requestLogKeys returns the request log's Gin keys with the size cap from cfg.
+func requestLogKeys(cfg *config.Config) *attemptKeys {
+	maxBytes := defaultMaxUpstreamLogBytes
+	if cfg != nil && cfg.RequestLogMaxUpstreamKB > 0 {
+		maxBytes = cfg.RequestLogMaxUpstreamKB * 1024
+	}
+	return &attemptKeys{apiAttemptsKey, apiRequestKey, apiResponseKey, maxBytes}
+}
+
+// detailedLogMaxBodyBytes returns the detailed log body cap; 0 lets the
+// logging package apply its default.
+func detailedLogMaxBodyBytes(cfg *config.Config) int {
+	if cfg == nil {
+		return 0
+	}
+	return cfg.DetailedRequestLogMaxBodyKB * 1024
 }
 
 // upstreamRequestLog captures the outbound upstream request details for logging.
@@ -50,10 +70,13 @@ type upstreamRequestLog struct {
 	AuthValue string
 }
 
+// upstreamAttempt holds the formatting state of one attempt's section in the
+// request log. The text itself is appended straight to the aggregated Gin
+// values, so long streams are neither stored twice nor re-concatenated.
 type upstreamAttempt struct {
 	index                int
-	request              string
-	response             *strings.Builder
+	responseStarted      bool // some response text has been appended
+	responseEndsNewline  bool
 	responseIntroWritten bool
 	statusWritten        bool
 	headersWritten       bool
@@ -81,10 +104,10 @@ func recordAPIRequest(ctx context.Context, cfg *config.Config, info upstreamRequ
 		return
 	}
 	if shouldRecordAttemptsForDetailedLog(cfg) {
-		recordAPIRequestForKeys(ginCtx, &attemptKeys{detailedLogAttemptsKey, detailedLogRequestKey, detailedLogResponseKey}, info)
+		recordDetailedAttemptRequest(ginCtx, info)
 	}
 	if shouldRecordAttemptsForRequestLog(cfg) {
-		recordAPIRequestForKeys(ginCtx, &attemptKeys{apiAttemptsKey, apiRequestKey, apiResponseKey}, info)
+		recordAPIRequestForKeys(ginCtx, requestLogKeys(cfg), info)
 	}
 }
 
@@ -116,14 +139,8 @@ func recordAPIRequestForKeys(ginCtx *gin.Context, keys *attemptKeys, info upstre
 	}
 	builder.WriteString("\n\n")
 
-	attempt := &upstreamAttempt{
-		index:    index,
-		request:  builder.String(),
-		response: &strings.Builder{},
-	}
-	attempts = append(attempts, attempt)
-	ginCtx.Set(keys.attempts, attempts)
-	updateAggregatedRequestForKey(ginCtx, attempts, keys.request)
+	ginCtx.Set(keys.attempts, append(attempts, &upstreamAttempt{index: index}))
+	appendCappedLogText(ginCtx, keys.request, builder.String(), keys.maxBytes)
 }
 
 // recordAPIResponseMetadata captures upstream response status/header information for the latest attempt.
@@ -133,29 +150,37 @@ func recordAPIResponseMetadata(ctx context.Context, cfg *config.Config, status i
 		return
 	}
 	if shouldRecordAttemptsForDetailedLog(cfg) {
-		recordAPIResponseMetadataForKeys(ginCtx, &attemptKeys{detailedLogAttemptsKey, detailedLogRequestKey, detailedLogResponseKey}, status, headers)
+		attempt := ensureDetailedAttempt(ginCtx)
+		if status > 0 && attempt.StatusCode == 0 {
+			attempt.StatusCode = status
+		}
+		if attempt.ResponseHeaders == nil {
+			attempt.ResponseHeaders = maskedHeaders(headers)
+		}
+		touchDetailedAttempt(attempt)
 	}
 	if shouldRecordAttemptsForRequestLog(cfg) {
-		recordAPIResponseMetadataForKeys(ginCtx, &attemptKeys{apiAttemptsKey, apiRequestKey, apiResponseKey}, status, headers)
+		recordAPIResponseMetadataForKeys(ginCtx, requestLogKeys(cfg), status, headers)
 	}
 }
 
 func recordAPIResponseMetadataForKeys(ginCtx *gin.Context, keys *attemptKeys, status int, headers http.Header) {
 	attempts, attempt := ensureAttemptForKey(ginCtx, keys)
-	ensureResponseIntro(attempt)
+	var text strings.Builder
+	ensureResponseIntro(&text, attempt)
 
 	if status > 0 && !attempt.statusWritten {
-		attempt.response.WriteString(fmt.Sprintf("Status: %d\n", status))
+		text.WriteString(fmt.Sprintf("Status: %d\n", status))
 		attempt.statusWritten = true
 	}
 	if !attempt.headersWritten {
-		attempt.response.WriteString("Headers:\n")
-		writeHeaders(attempt.response, headers)
+		text.WriteString("Headers:\n")
+		writeHeaders(&text, headers)
 		attempt.headersWritten = true
-		attempt.response.WriteString("\n")
+		text.WriteString("\n")
 	}
 
-	updateAggregatedResponseForKey(ginCtx, attempts, keys.response)
+	appendAttemptResponse(ginCtx, keys, attempts, attempt, text.String())
 }
 
 // recordAPIResponseError adds an error entry for the latest attempt when no HTTP response is available.
@@ -168,27 +193,34 @@ func recordAPIResponseError(ctx context.Context, cfg *config.Config, err error)
 		return
 	}
 	if shouldRecordAttemptsForDetailedLog(cfg) {
-		recordAPIResponseErrorForKeys(ginCtx, &attemptKeys{detailedLogAttemptsKey, detailedLogRequestKey, detailedLogResponseKey}, err)
+		attempt := ensureDetailedAttempt(ginCtx)
+		if attempt.Error != "" {
+			attempt.Error += "\n"
+		}
+		attempt.Error += err.Error()
+		touchDetailedAttempt(attempt)
+		ginCtx.Set(logging.ErrorClassKey, logging.ClassifyRequestError(err))
 	}
 	if shouldRecordAttemptsForRequestLog(cfg) {
-		recordAPIResponseErrorForKeys(ginCtx, &attemptKeys{apiAttemptsKey, apiRequestKey, apiResponseKey}, err)
+		recordAPIResponseErrorForKeys(ginCtx, requestLogKeys(cfg), err)
 	}
 }
 
 func recordAPIResponseErrorForKeys(ginCtx *gin.Context, keys *attemptKeys, err error) {
 	attempts, attempt := ensureAttemptForKey(ginCtx, keys)
-	ensureResponseIntro(attempt)
+	var text strings.Builder
+	ensureResponseIntro(&text, attempt)
 
 	if attempt.bodyStarted && !attempt.bodyHasContent {
 		attempt.bodyStarted = false
 	}
 	if attempt.errorWritten {
-		attempt.response.WriteString("\n")
+		text.WriteString("\n")
 	}
-	attempt.response.WriteString(fmt.Sprintf("Error: %s\n", err.Error()))
+	text.WriteString(fmt.Sprintf("Error: %s\n", err.Error()))
 	attempt.errorWritten = true
 
-	updateAggregatedResponseForKey(ginCtx, attempts, keys.response)
+	appendAttemptResponse(ginCtx, keys, attempts, attempt, text.String())
 }
 
 // appendAPIResponseChunk appends an upstream response chunk to Gin context.
@@ -202,34 +234,97 @@ func appendAPIResponseChunk(ctx context.Context, cfg *config.Config, chunk []byt
 		return
 	}
 	if shouldRecordAttemptsForDetailedLog(cfg) {
-		appendAPIResponseChunkForKeys(ginCtx, &attemptKeys{detailedLogAttemptsKey, detailedLogRequestKey, detailedLogResponseKey}, data)
+		attempt := ensureDetailedAttempt(ginCtx)
+		attempt.AppendResponseChunk(data, detailedLogMaxBodyBytes(cfg))
+		touchDetailedAttempt(attempt)
 	}
 	if shouldRecordAttemptsForRequestLog(cfg) {
-		appendAPIResponseChunkForKeys(ginCtx, &attemptKeys{apiAttemptsKey, apiRequestKey, apiResponseKey}, data)
+		appendAPIResponseChunkForKeys(ginCtx, requestLogKeys(cfg), data)
 	}
 }
 
 func appendAPIResponseChunkForKeys(ginCtx *gin.Context, keys *attemptKeys, data []byte) {
 	attempts, attempt := ensureAttemptForKey(ginCtx, keys)
-	ensureResponseIntro(attempt)
+	var text strings.Builder
+	ensureResponseIntro(&text, attempt)
 
 	if !attempt.headersWritten {
-		attempt.response.WriteString("Headers:\n")
-		writeHeaders(attempt.response, nil)
+		text.WriteString("Headers:\n")
+		writeHeaders(&text, nil)
 		attempt.headersWritten = true
-		attempt.response.WriteString("\n")
+		text.WriteString("\n")
 	}
 	if !attempt.bodyStarted {
-		attempt.response.WriteString("Body:\n")
+		text.WriteString("Body:\n")
 		attempt.bodyStarted = true
 	}
 	if attempt.bodyHasContent {
-		attempt.response.WriteString("\n\n")
+		text.WriteString("\n\n")
 	}
-	attempt.response.WriteString(string(data))
+	text.Write(data)
 	attempt.bodyHasContent = true
 
-	updateAggregatedResponseForKey(ginCtx, attempts, keys.response)
+	appendAttemptResponse(ginCtx, keys, attempts, attempt, text.String())
+}
+
+// recordDetailedAttemptRequest appends a structured attempt for the detailed
+// request log, so the middleware needs no text parsing.
+func recordDetailedAttemptRequest(ginCtx *gin.Context, info upstreamRequestLog) {
+	attempts := getDetailedAttempts(ginCtx)
+	attempt := &logging.DetailedAttempt{
+		Index:          len(attempts) + 1,
+		Timestamp:      time.Now(),
+		UpstreamURL:    info.URL,
+		Method:         info.Method,
+		Auth:           formatAuthInfo(info),
+		RequestHeaders: maskedHeaders(info.Headers),
+		RequestBody:    string(info.Body),
+	}
+	ginCtx.Set(logging.DetailedAttemptsKey, append(attempts, attempt))
+}
+
+func getDetailedAttempts(ginCtx *gin.Context) []*logging.DetailedAttempt {
+	if value, exists := ginCtx.Get(logging.DetailedAttemptsKey); exists {
+		if attempts, ok := value.([]*logging.DetailedAttempt); ok {
+			return attempts
+		}
+	}
+	return nil
+}
+
+// ensureDetailedAttempt returns the latest detailed attempt, creating one when
+// response data arrives without a recorded request.
+func ensureDetailedAttempt(ginCtx *gin.Context) *logging.DetailedAttempt {
+	attempts := getDetailedAttempts(ginCtx)
+	if len(attempts) == 0 {
+		attempt := &logging.DetailedAttempt{Index: 1, Timestamp: time.Now()}
+		ginCtx.Set(logging.DetailedAttemptsKey, []*logging.DetailedAttempt{attempt})
+		return attempt
+	}
+	return attempts[len(attempts)-1]
+}
+
+// touchDetailedAttempt extends the attempt's duration to now.
+func touchDetailedAttempt(attempt *logging.DetailedAttempt) {
+	if !attempt.Timestamp.IsZero() {
+		attempt.DurationMs = time.Since(attempt.Timestamp).Milliseconds()
+	}
+}
+
+// maskedHeaders copies headers with sensitive values masked, matching writeHeaders.
+func maskedHeaders(headers http.Header) map[string][]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	out := make(map[string][]string, len(headers))
+	for key, values := range headers {
+		masked := make([]string, len(values))
+		for i, value := range values {
+			masked[i] = util.MaskSensitiveHeaderValue(key, value)
+		}
+		out[key] = masked
+	}
+	return out
 }
 
 func ginContextFrom(ctx context.Context) *gin.Context {
@@ -252,61 +347,64 @@ func getAttemptsForKey(ginCtx *gin.Context, attemptsKey string) []*upstreamAttem
 func ensureAttemptForKey(ginCtx *gin.Context, keys *attemptKeys) ([]*upstreamAttempt, *upstreamAttempt) {
 	attempts := getAttemptsForKey(ginCtx, keys.attempts)
 	if len(attempts) == 0 {
-		attempt := &upstreamAttempt{
-			index:    1,
-			request:  "=== API REQUEST 1 ===\n<missing>\n\n",
-			response: &strings.Builder{},
-		}
-		attempts = []*upstreamAttempt{attempt}
+		attempts = []*upstreamAttempt{{index: 1}}
 		ginCtx.Set(keys.attempts, attempts)
-		updateAggregatedRequestForKey(ginCtx, attempts, keys.request)
+		appendCappedLogText(ginCtx, keys.request, "=== API REQUEST 1 ===\n<missing>\n\n", keys.maxBytes)
 	}
 	return attempts, attempts[len(attempts)-1]
 }
 
-func ensureResponseIntro(attempt *upstreamAttempt) {
-	if attempt == nil || attempt.response == nil || attempt.responseIntroWritten {
+func ensureResponseIntro(builder *strings.Builder, attempt *upstreamAttempt) {
+	if attempt == nil || attempt.responseIntroWritten {
 		return
 	}
-	attempt.response.WriteString(fmt.Sprintf("=== API RESPONSE %d ===\n", attempt.index))
-	attempt.response.WriteString(fmt.Sprintf("Timestamp: %s\n", time.Now().Format(time.RFC3339Nano)))
-	attempt.response.WriteString("\n")
+	builder.WriteString(fmt.Sprintf("=== API RESPONSE %d ===\n", attempt.index))
+	builder.WriteString(fmt.Sprintf("Timestamp: %s\n", time.Now().Format(time.RFC3339Nano)))
+	builder.WriteString("\n")
 	attempt.responseIntroWritten = true
 }
 
-func updateAggregatedRequestForKey(ginCtx *gin.Context, attempts []*upstreamAttempt, requestKey string) {
-	if ginCtx == nil {
+// appendAttemptResponse appends text to the aggregated response log for the
+// latest attempt. When an attempt writes its first text, the section of the
+// attempt that wrote before it is closed with a newline and a blank line.
+func appendAttemptResponse(ginCtx *gin.Context, keys *attemptKeys, attempts []*upstreamAttempt, attempt *upstreamAttempt, text string) {
+	if text == "" {
 		return
 	}
-	var builder strings.Builder
-	for _, attempt := range attempts {
-		builder.WriteString(attempt.request)
+	if !attempt.responseStarted {
+		for i := len(attempts) - 2; i >= 0; i-- {
+			if previous := attempts[i]; previous.responseStarted {
+				if !previous.responseEndsNewline {
+					text = "\n" + text
+				}
+				text = "\n" + text
+				break
+			}
+		}
+		attempt.responseStarted = true
 	}
-	ginCtx.Set(requestKey, []byte(builder.String()))
+	attempt.responseEndsNewline = strings.HasSuffix(text, "\n")
+	appendCappedLogText(ginCtx, keys.response, text, keys.maxBytes)
 }
 
-func updateAggregatedResponseForKey(ginCtx *gin.Context, attempts []*upstreamAttempt, responseKey string) {
+// appendCappedLogText appends text to the []byte stored under key, keeping at
+// most maxBytes of log text. The write that reaches the cap is cut off and
+// marked; later writes are dropped.
+func appendCappedLogText(ginCtx *gin.Context, key, text string, maxBytes int) {
 	if ginCtx == nil {
 		return
 	}
-	var builder strings.Builder
-	for idx, attempt := range attempts {
-		if attempt == nil || attempt.response == nil {
-			continue
-		}
-		responseText := attempt.response.String()
-		if responseText == "" {
-			continue
-		}
-		builder.WriteString(responseText)
-		if !strings.HasSuffix(responseText, "\n") {
-			builder.WriteString("\n")
-		}
-		if idx < len(attempts)-1 {
-			builder.WriteString("\n")
-		}
+	var current []byte
+	if value, exists := ginCtx.Get(key); exists {
+		current, _ = value.([]byte)
+	}
+	if len(current) >= maxBytes {
+		return
+	}
+	if room := maxBytes - len(current); len(text) > room {
+		text = text[:room] + upstreamLogTruncatedMarker
 	}
-	ginCtx.Set(responseKey, []byte(builder.String()))
+	ginCtx.Set(key, append(current, text...))
 }
 
 func writeHeaders(builder *strings.Builder, headers http.Header) {
@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+func TestParseRetryAfterHeader(t *testing.T) {
+	future := time.Now().Add(2 * time.Minute).UTC().Format(http.TimeFormat)
+	past := time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		value   string
+		wantMin time.Duration
+		wantMax time.Duration
+		wantNil bool
+	}{
+		{value: "", wantNil: true},
+		{value: "30", wantMin: 30 * time.Second, wantMax: 30 * time.Second},
+		{value: " 5 ", wantMin: 5 * time.Second, wantMax: 5 * time.Second},
+		{value: "0", wantNil: true},
+		{value: "-3", wantNil: true},
+		{value: "soon", wantNil: true},
+		{value: future, wantMin: 110 * time.Second, wantMax: 2 * time.Minute},
+		{value: past, wantNil: true},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.value != "" {
+			header.Set("Retry-After", tt.value)
+		}
+		got := parseRetryAfterHeader(header)
+		if tt.wantNil {
+			if got != nil {
+				t.Fatalf("parseRetryAfterHeader(%q) = %v, want nil", tt.value, *got)
+			}
+			continue
+		}
+		if got == nil || *got < tt.wantMin || *got > tt.wantMax {
+			t.Fatalf("parseRetryAfterHeader(%q) = %v, want between %v and %v", tt.value, got, tt.wantMin, tt.wantMax)
+		}
+	}
+}
+
+func TestOpenAICompatExecutorKeepsRetryAfterOutOfQuotaBackoff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "86400")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error":{"message":"slow down"}}`))
+	}))
+	defer server.Close()
+
+	executor := NewOpenAICompatExecutor("openai-compatibility", &config.Config{})
+	auth := &cliproxyauth.Auth{Attributes: map[string]string{
+		"base_url": server.URL + "/v1",
+		"api_key":  "test",
+	}}
+	_, err := executor.Execute(context.Background(), auth, cliproxyexecutor.Request{
+		Model:   "gpt-4o",
+		Payload: []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`),
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("openai")})
+
+	var sErr statusErr
+	if !errors.As(err, &sErr) {
+		t.Fatalf("Execute error = %v, want statusErr", err)
+	}
+	if sErr.RetryAfter() != nil {
+		t.Fatalf("RetryAfter() = %v, want nil so the auth manager keeps its own backoff", *sErr.RetryAfter())
+	}
+	if got := sErr.UpstreamRetryAfter(); got == nil || *got != 24*time.Hour {
+		t.Fatalf("UpstreamRetryAfter() = %v, want 24h", got)
+	}
+}
@@ -0,0 +1,55 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+func TestBodyTransformsEditRequestAndStream(t *testing.T) {
+	var gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: {\"text\":\"hi\",\"debug\":1}\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	ctx := util.WithBodyTransforms(context.Background(), util.BodyTransforms{
+		Request: []util.BodyTransform{
+			{Op: util.BodyTransformSet, Path: "safety_settings", Value: []any{map[string]any{"threshold": "BLOCK_NONE"}}},
+			{Op: util.BodyTransformDelete, Path: "user"},
+		},
+		Response: []util.BodyTransform{{Op: util.BodyTransformDelete, Path: "debug"}},
+	})
+	// Auxiliary calls such as token refreshes are sent as-is.
+	aux, err := newProxyAwareHTTPClient(ctx, nil, nil, 0).Post(server.URL, "application/json", strings.NewReader(`{"model":"m","user":"u"}`))
+	if err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+	aux.Body.Close()
+	if want := `{"model":"m","user":"u"}`; gotBody != want {
+		t.Fatalf("auxiliary upstream body = %s, want %s", gotBody, want)
+	}
+
+	client := newModelHTTPClient(ctx, nil, nil, 0)
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"model":"m","user":"u"}`))
+	if err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+	defer resp.Body.Close()
+	stream, _ := io.ReadAll(resp.Body)
+
+	if want := `{"model":"m","safety_settings":[{"threshold":"BLOCK_NONE"}]}`; gotBody != want {
+		t.Fatalf("upstream body = %s, want %s", gotBody, want)
+	}
+	if want := "data: {\"text\":\"hi\"}\n\ndata: [DONE]\n\n"; string(stream) != want {
+		t.Fatalf("stream = %q, want %q", stream, want)
+	}
+}
@@ -0,0 +1,107 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Body transform operations.
+const (
+	BodyTransformSet    = "set"
+	BodyTransformDelete = "delete"
+)
+
+// BodyTransform is one edit of a JSON body: "set" writes Value at Path and
+// "delete" removes Path. Paths use sjson syntax, e.g. "safety_settings" or
+// "generationConfig.thinkingConfig".
+type BodyTransform struct {
+	Op    string `json:"op" yaml:"op"`
+	Path  string `json:"path" yaml:"path"`
+	Value any    `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// Validate reports whether the transform can be applied. sjson silently
+// ignores paths with queries or modifiers on set, so those are rejected here.
+func (t BodyTransform) Validate() error {
+	path := strings.TrimSpace(t.Path)
+	if path == "" {
+		return fmt.Errorf("path is required")
+	}
+	if strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".") || strings.Contains(path, "..") {
+		return fmt.Errorf("path %q has an empty segment", path)
+	}
+	if _, err := sjson.Delete("{}", path); err != nil {
+		return fmt.Errorf("path %q: %w", path, err)
+	}
+	switch t.Op {
+	case BodyTransformSet:
+		if t.Value == nil {
+			return fmt.Errorf("set %q needs a value", path)
+		}
+		if _, err := json.Marshal(t.Value); err != nil {
+			return fmt.Errorf("set %q: value is not JSON: %w", path, err)
+		}
+	case BodyTransformDelete:
+	default:
+		return fmt.Errorf("unknown op %q (want %s or %s)", t.Op, BodyTransformSet, BodyTransformDelete)
+	}
+	return nil
+}
+
+// ApplyBodyTransforms applies transforms to body in order. Bodies that are not
+// JSON are returned unchanged, as is the body when a transform fails.
+func ApplyBodyTransforms(body []byte, transforms []BodyTransform) []byte {
+	if len(transforms) == 0 || !gjson.ValidBytes(body) {
+		return body
+	}
+	out := body
+	for _, t := range transforms {
+		var err error
+		switch t.Op {
+		case BodyTransformSet:
+			out, err = sjson.SetBytes(out, t.Path, t.Value)
+		case BodyTransformDelete:
+			out, err = sjson.DeleteBytes(out, t.Path)
+		default:
+			err = fmt.Errorf("unknown op %q", t.Op)
+		}
+		if err != nil {
+			log.Debugf("body transform %s %q failed: %v", t.Op, t.Path, err)
+			return body
+		}
+	}
+	return out
+}
+
+// BodyTransforms are the edits made to one upstream call's request body just
+// before it is sent and to its response body just after it is received.
+type BodyTransforms struct {
+	Request  []BodyTransform
+	Response []BodyTransform
+}
+
+type bodyTransformsKey struct{}
+
+// WithBodyTransforms returns a context whose upstream calls apply transforms.
+// Empty transforms leave ctx unchanged.
+func WithBodyTransforms(ctx context.Context, transforms BodyTransforms) context.Context {
+	if len(transforms.Request) == 0 && len(transforms.Response) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, bodyTransformsKey{}, transforms)
+}
+
+// BodyTransformsFrom returns the transforms set by WithBodyTransforms.
+func BodyTransformsFrom(ctx context.Context) BodyTransforms {
+	if ctx == nil {
+		return BodyTransforms{}
+	}
+	transforms, _ := ctx.Value(bodyTransformsKey{}).(BodyTransforms)
+	return transforms
+}
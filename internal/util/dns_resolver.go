@@ -0,0 +1,295 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// dnsQueryTimeout bounds one DoT or DoH exchange.
+	dnsQueryTimeout = 5 * time.Second
+	// dnsMinTTL and dnsMaxTTL clamp how long an answer is cached, so a zero
+	// TTL does not force a query per dial and a huge one cannot pin a stale IP.
+	dnsMinTTL = 5 * time.Second
+	dnsMaxTTL = time.Hour
+)
+
+// DNSResolver resolves hostnames over DNS-over-TLS or DNS-over-HTTPS and
+// caches A-record answers for their TTL.
+type DNSResolver struct {
+	server string // resolver URL as configured
+	dot    string // host:port for tls:// resolvers
+	doh    string // query URL for https:// resolvers
+	strict bool
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]dnsCacheEntry
+}
+
+type dnsCacheEntry struct {
+	ips     []string
+	expires time.Time
+}
+
+// NewDNSResolver returns a resolver for server (tls://host:port or
+// https://host/dns-query). Unless strict is set, a failed lookup falls back
+// to the system resolver.
+func NewDNSResolver(server string, strict bool) (*DNSResolver, error) {
+	server = strings.TrimSpace(server)
+	if err := config.ValidateDNSResolver(server); err != nil {
+		return nil, err
+	}
+	if server == "" {
+		return nil, fmt.Errorf("empty resolver URL")
+	}
+	u, _ := url.Parse(server)
+	r := &DNSResolver{
+		server: server,
+		strict: strict,
+		client: &http.Client{Timeout: dnsQueryTimeout},
+		cache:  make(map[string]dnsCacheEntry),
+	}
+	if u.Scheme == "tls" {
+		r.dot = u.Host
+	} else {
+		r.doh = u.String()
+	}
+	return r, nil
+}
+
+// LookupHost returns the IPv4 addresses of host. IP literals are returned
+// unchanged.
+func (r *DNSResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+	key := strings.ToLower(strings.TrimSuffix(host, "."))
+
+	r.mu.Lock()
+	entry, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.ips, nil
+	}
+
+	ips, ttl, err := r.query(ctx, key)
+	if err != nil {
+		if r.strict {
+			return nil, fmt.Errorf("resolve %s via %s: %w", host, r.server, err)
+		}
+		log.Debugf("dns resolver %s failed for %s, using system DNS: %v", r.server, host, err)
+		return net.DefaultResolver.LookupHost(ctx, host)
+	}
+
+	if ttl < dnsMinTTL {
+		ttl = dnsMinTTL
+	} else if ttl > dnsMaxTTL {
+		ttl = dnsMaxTTL
+	}
+	r.mu.Lock()
+	r.cache[key] = dnsCacheEntry{ips: ips, expires: time.Now().Add(ttl)}
+	r.mu.Unlock()
+	return ips, nil
+}
+
+// DialContext resolves addr's host with r and dials the answers in order
+// until one connects.
+func (r *DNSResolver) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := r.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, ip := range ips {
+		conn, errDial := directDialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if errDial == nil {
+			return conn, nil
+		}
+		lastErr = errDial
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no addresses for %s", host)
+	}
+	return nil, lastErr
+}
+
+// query asks the configured server for host's A records and returns them with
+// the smallest TTL among them.
+func (r *DNSResolver) query(ctx context.Context, host string) ([]string, time.Duration, error) {
+	name, err := dnsmessage.NewName(host + ".")
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid hostname: %w", err)
+	}
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: uint16(time.Now().UnixNano()), RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  name,
+			Type:  dnsmessage.TypeA,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, 0, fmt.Errorf("pack query: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dnsQueryTimeout)
+	defer cancel()
+	var raw []byte
+	if r.dot != "" {
+		raw, err = r.exchangeDoT(ctx, packed)
+	} else {
+		raw, err = r.exchangeDoH(ctx, packed)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var resp dnsmessage.Message
+	if err = resp.Unpack(raw); err != nil {
+		return nil, 0, fmt.Errorf("unpack response: %w", err)
+	}
+	if resp.Header.RCode != dnsmessage.RCodeSuccess {
+		return nil, 0, fmt.Errorf("server returned %s", resp.Header.RCode)
+	}
+	var ips []string
+	var ttl time.Duration
+	for _, ans := range resp.Answers {
+		a, ok := ans.Body.(*dnsmessage.AResource)
+		if !ok {
+			continue
+		}
+		ips = append(ips, net.IP(a.A[:]).String())
+		if answerTTL := time.Duration(ans.Header.TTL) * time.Second; ttl == 0 || answerTTL < ttl {
+			ttl = answerTTL
+		}
+	}
+	if len(ips) == 0 {
+		return nil, 0, fmt.Errorf("no A record for %s", host)
+	}
+	return ips, ttl, nil
+}
+
+func (r *DNSResolver) exchangeDoT(ctx context.Context, packed []byte) ([]byte, error) {
+	host, _, _ := net.SplitHostPort(r.dot)
+	dialer := &tls.Dialer{Config: &tls.Config{ServerName: host}}
+	conn, err := dialer.DialContext(ctx, "tcp", r.dot)
+	if err != nil {
+		return nil, fmt.Errorf("connect to DoT server: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	// DNS over TLS frames each message with a 2-byte big-endian length.
+	frame := make([]byte, 2+len(packed))
+	binary.BigEndian.PutUint16(frame, uint16(len(packed)))
+	copy(frame[2:], packed)
+	if _, err = conn.Write(frame); err != nil {
+		return nil, fmt.Errorf("write query: %w", err)
+	}
+	var length [2]byte
+	if _, err = io.ReadFull(conn, length[:]); err != nil {
+		return nil, fmt.Errorf("read response length: %w", err)
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err = io.ReadFull(conn, resp); err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	return resp, nil
+}
+
+func (r *DNSResolver) exchangeDoH(ctx context.Context, packed []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.doh, bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("query DoH server: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH server returned HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+}
+
+// directDialer matches the dial settings of http.DefaultTransport.
+var directDialer = &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+var (
+	activeDNSResolver atomic.Pointer[DNSResolver]
+	dnsTransportOnce  sync.Once
+	dnsTransport      *http.Transport
+)
+
+// ConfigureDNSResolver installs the resolver described by cfg.DNSResolver for
+// DialContext and DNSTransport, or removes it when the setting is empty. An
+// unchanged setting keeps the current resolver and its cache.
+func ConfigureDNSResolver(cfg *config.SDKConfig) {
+	server, strict := "", false
+	if cfg != nil {
+		server, strict = strings.TrimSpace(cfg.DNSResolver), cfg.DNSResolverStrict
+	}
+	if current := activeDNSResolver.Load(); current != nil && current.server == server && current.strict == strict {
+		return
+	}
+	if server == "" {
+		activeDNSResolver.Store(nil)
+		return
+	}
+	r, err := NewDNSResolver(server, strict)
+	if err != nil {
+		log.Errorf("dns-resolver disabled: %v", err)
+		activeDNSResolver.Store(nil)
+		return
+	}
+	activeDNSResolver.Store(r)
+	log.Infof("resolving upstream hostnames via %s (strict=%t)", server, strict)
+}
+
+// DialContext dials addr, resolving its host with the configured dns-resolver
+// when one is set and with the system resolver otherwise.
+func DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if r := activeDNSResolver.Load(); r != nil {
+		return r.DialContext(ctx, network, addr)
+	}
+	return directDialer.DialContext(ctx, network, addr)
+}
+
+// DNSTransport returns a shared transport that dials through DialContext, or
+// nil when no dns-resolver is configured so callers keep their default.
+func DNSTransport() *http.Transport {
+	if activeDNSResolver.Load() == nil {
+		return nil
+	}
+	dnsTransportOnce.Do(func() {
+		dnsTransport = http.DefaultTransport.(*http.Transport).Clone()
+		dnsTransport.DialContext = DialContext
+	})
+	return dnsTransport
+}
@@ -0,0 +1,88 @@
+package util
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// newDoHServer answers every A query with 10.0.0.1 and counts the queries.
+func newDoHServer(t *testing.T, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var queries atomic.Int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries.Add(1)
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var msg dnsmessage.Message
+		if err := msg.Unpack(body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		msg.Header.Response = true
+		msg.Answers = []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: msg.Questions[0].Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 300},
+			Body:   &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}},
+		}}
+		packed, _ := msg.Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		_, _ = w.Write(packed)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &queries
+}
+
+func TestDNSResolverDoHCachesAnswers(t *testing.T) {
+	srv, queries := newDoHServer(t, http.StatusOK)
+	r, err := NewDNSResolver(srv.URL+"/dns-query", true)
+	if err != nil {
+		t.Fatalf("NewDNSResolver: %v", err)
+	}
+	r.client = srv.Client()
+
+	for i := 0; i < 2; i++ {
+		ips, errLookup := r.LookupHost(context.Background(), "api.example.com")
+		if errLookup != nil {
+			t.Fatalf("LookupHost: %v", errLookup)
+		}
+		if len(ips) != 1 || ips[0] != "10.0.0.1" {
+			t.Fatalf("LookupHost = %v, want [10.0.0.1]", ips)
+		}
+	}
+	if got := queries.Load(); got != 1 {
+		t.Fatalf("DoH queries = %d, want 1 (second lookup cached)", got)
+	}
+	if ips, _ := r.LookupHost(context.Background(), "192.0.2.7"); len(ips) != 1 || ips[0] != "192.0.2.7" {
+		t.Fatalf("IP literal lookup = %v, want it unchanged", ips)
+	}
+}
+
+func TestDNSResolverStrictMode(t *testing.T) {
+	srv, _ := newDoHServer(t, http.StatusServiceUnavailable)
+
+	strict, err := NewDNSResolver(srv.URL+"/dns-query", true)
+	if err != nil {
+		t.Fatalf("NewDNSResolver: %v", err)
+	}
+	strict.client = srv.Client()
+	if _, err = strict.LookupHost(context.Background(), "localhost"); err == nil {
+		t.Fatalf("strict resolver fell back to system DNS")
+	}
+
+	lenient, err := NewDNSResolver(srv.URL+"/dns-query", false)
+	if err != nil {
+		t.Fatalf("NewDNSResolver: %v", err)
+	}
+	lenient.client = srv.Client()
+	if ips, errLookup := lenient.LookupHost(context.Background(), "localhost"); errLookup != nil || len(ips) == 0 {
+		t.Fatalf("LookupHost fallback = %v, %v, want system answer", ips, errLookup)
+	}
+}
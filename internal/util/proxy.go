@@ -5,9 +5,12 @@ package util
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	log "github.com/sirupsen/logrus"
@@ -25,31 +28,108 @@ func SetProxy(cfg *config.SDKConfig, httpClient *http.Client) *http.Client {
 		// Handle different proxy schemes.
 		if proxyURL.Scheme == "socks5" {
 			// Configure SOCKS5 proxy with optional authentication.
-			var proxyAuth *proxy.Auth
-			if proxyURL.User != nil {
-				username := proxyURL.User.Username()
-				password, _ := proxyURL.User.Password()
-				proxyAuth = &proxy.Auth{User: username, Password: password}
-			}
-			dialer, errSOCKS5 := proxy.SOCKS5("tcp", proxyURL.Host, proxyAuth, proxy.Direct)
+			dialContext, errSOCKS5 := SOCKS5DialContext(proxyURL)
 			if errSOCKS5 != nil {
 				log.Errorf("create SOCKS5 dialer failed: %v", errSOCKS5)
 				return httpClient
 			}
 			// Set up a custom transport using the SOCKS5 dialer.
-			transport = &http.Transport{
-				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-					return dialer.Dial(network, addr)
-				},
-			}
+			transport = &http.Transport{DialContext: dialContext}
 		} else if proxyURL.Scheme == "http" || proxyURL.Scheme == "https" {
 			// Configure HTTP or HTTPS proxy.
 			transport = &http.Transport{Proxy: http.ProxyURL(proxyURL)}
 		}
 	}
+	// Without a proxy, hostnames still go through the configured dns-resolver.
+	if transport == nil {
+		transport = DNSTransport()
+	}
 	// If a new transport was created, apply it to the HTTP client.
 	if transport != nil {
 		httpClient.Transport = transport
 	}
 	return httpClient
 }
+
+// proxyDialTimeout bounds the TCP connect to the proxy server itself.
+const proxyDialTimeout = 15 * time.Second
+
+// ProxyUnreachableError reports that the proxy server itself could not be
+// reached, as opposed to the proxy failing to reach the upstream.
+type ProxyUnreachableError struct {
+	Proxy string // proxy host:port
+	Err   error
+}
+
+func (e *ProxyUnreachableError) Error() string {
+	return fmt.Sprintf("upstream proxy %s unreachable: %v", e.Proxy, e.Err)
+}
+
+func (e *ProxyUnreachableError) Unwrap() error { return e.Err }
+
+// SOCKS5DialContext returns a DialContext that connects through the SOCKS5
+// proxy described by proxyURL, honouring its user:pass credentials. A failure
+// to connect to the proxy is returned as a *ProxyUnreachableError.
+func SOCKS5DialContext(proxyURL *url.URL) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	var proxyAuth *proxy.Auth
+	if proxyURL.User != nil {
+		username := proxyURL.User.Username()
+		password, _ := proxyURL.User.Password()
+		proxyAuth = &proxy.Auth{User: username, Password: password}
+	}
+	dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, proxyAuth, proxyServerDialer{proxy: proxyURL.Host})
+	if err != nil {
+		return nil, err
+	}
+	contextDialer, ok := dialer.(proxy.ContextDialer)
+	if !ok {
+		return nil, fmt.Errorf("SOCKS5 dialer does not support contexts")
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, errDial := contextDialer.DialContext(ctx, network, addr)
+		var unreachable *ProxyUnreachableError
+		if errors.As(errDial, &unreachable) {
+			return nil, unreachable
+		}
+		return conn, errDial
+	}, nil
+}
+
+// proxyServerDialer dials the proxy server and tags connect failures so they
+// can be told apart from errors the proxy reports about the upstream.
+type proxyServerDialer struct {
+	proxy string
+}
+
+func (d proxyServerDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d proxyServerDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := (&net.Dialer{Timeout: proxyDialTimeout}).DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, &ProxyUnreachableError{Proxy: d.proxy, Err: err}
+	}
+	return conn, nil
+}
+
+type upstreamProxyKey struct{}
+
+// WithUpstreamProxy returns a context whose upstream calls go through proxyURL,
+// overriding the credential's and the global proxy. An empty proxyURL leaves
+// ctx unchanged.
+func WithUpstreamProxy(ctx context.Context, proxyURL string) context.Context {
+	if proxyURL == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, upstreamProxyKey{}, proxyURL)
+}
+
+// UpstreamProxyFrom returns the proxy set by WithUpstreamProxy, or "".
+func UpstreamProxyFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	proxyURL, _ := ctx.Value(upstreamProxyKey{}).(string)
+	return proxyURL
+}
@@ -0,0 +1,45 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+)
+
+func TestSOCKS5DialContextReportsUnreachableProxy(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+
+	dial, err := SOCKS5DialContext(&url.URL{Scheme: "socks5", Host: addr})
+	if err != nil {
+		t.Fatalf("SOCKS5DialContext: %v", err)
+	}
+	_, err = dial(context.Background(), "tcp", "example.com:443")
+	var unreachable *ProxyUnreachableError
+	if !errors.As(err, &unreachable) {
+		t.Fatalf("err = %v, want ProxyUnreachableError", err)
+	}
+	if unreachable.Proxy != addr {
+		t.Fatalf("Proxy = %q, want %q", unreachable.Proxy, addr)
+	}
+}
+
+func TestUpstreamProxyContext(t *testing.T) {
+	ctx := context.Background()
+	if got := UpstreamProxyFrom(ctx); got != "" {
+		t.Fatalf("UpstreamProxyFrom(empty) = %q, want empty", got)
+	}
+	if WithUpstreamProxy(ctx, "") != ctx {
+		t.Fatalf("WithUpstreamProxy with empty URL should return ctx unchanged")
+	}
+	const proxyURL = "socks5://127.0.0.1:1080"
+	if got := UpstreamProxyFrom(WithUpstreamProxy(ctx, proxyURL)); got != proxyURL {
+		t.Fatalf("UpstreamProxyFrom = %q, want %q", got, proxyURL)
+	}
+}
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestProxyTransportIsReused(t *testing.T) {
+	const proxyURL = "ss://aes-256-gcm:secret@127.0.0.1:8388"
+	first := ProxyTransport(proxyURL, "")
+	if first == nil {
+		t.Fatalf("ProxyTransport returned nil for a valid ss URL")
+	}
+	if again := ProxyTransport(proxyURL, ""); again != first {
+		t.Fatalf("ProxyTransport built a second transport for the same proxy")
+	}
+	if other := ProxyTransport("ss://aes-256-gcm:other@127.0.0.1:8388", ""); other == first {
+		t.Fatalf("ProxyTransport shared a transport across different proxies")
+	}
+	if bad := ProxyTransport("ss://rot13:secret@127.0.0.1:8388", ""); bad != nil {
+		t.Fatalf("ProxyTransport accepted an unknown cipher")
+	}
+}
+
+func TestShadowsocksDialReportsUnreachableProxy(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+
+	transport := NewProxyTransport("ss://aes-256-gcm:secret@"+addr, "")
+	if transport == nil {
+		t.Fatalf("NewProxyTransport returned nil")
+	}
+	_, err = transport.DialContext(context.Background(), "tcp", "example.com:443")
+	var unreachable *ProxyUnreachableError
+	if !errors.As(err, &unreachable) {
+		t.Fatalf("err = %v, want ProxyUnreachableError", err)
+	}
+}
+
+func TestProxyTransportCacheIsBounded(t *testing.T) {
+	first := ProxyTransport("http://127.0.0.1:1", "")
+	for i := 2; i <= maxProxyTransports+8; i++ {
+		if ProxyTransport(fmt.Sprintf("http://127.0.0.1:%d", i), "") == nil {
+			t.Fatalf("ProxyTransport returned nil for an http proxy")
+		}
+	}
+	proxyTransports.Lock()
+	size := len(proxyTransports.m)
+	_, kept := proxyTransports.m["http://127.0.0.1:1|"]
+	proxyTransports.Unlock()
+	if size > maxProxyTransports {
+		t.Fatalf("cache holds %d transports, want at most %d", size, maxProxyTransports)
+	}
+	if kept {
+		t.Fatalf("least recently used transport was not evicted")
+	}
+	if again := ProxyTransport("http://127.0.0.1:1", ""); again == first {
+		t.Fatalf("evicted transport was returned from the cache")
+	}
+}